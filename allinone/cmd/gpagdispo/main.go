@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	env "github.com/caarlos0/env/v6"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sixstone-qq/gpagdispo/allinone/pkg/memory"
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/conf"
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/dns"
+	checkerdomain "github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/grpc"
+	chttp "github.com/sixstone-qq/gpagdispo/checker/pkg/http"
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/metrics"
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/tcp"
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/tracing"
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/websocket"
+	recordermetrics "github.com/sixstone-qq/gpagdispo/recorder/pkg/metrics"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/recorder"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/store"
+)
+
+type config struct {
+	ConfigFilePath   string        `env:"CONFIG_PATH" envDefault:"checker/websites.ion"`
+	DBPath           string        `env:"DB_PATH" envDefault:"recorder/db"`
+	DBDSN            string        `env:"DB_DSN" envDefault:"sqlite:gpagdispo.db"`
+	Tick             time.Duration `env:"TICK_TIME" envDefault:"2s"`
+	Location         string        `env:"CHECKER_LOCATION"`
+	CheckerID        string        `env:"CHECKER_ID"`
+	MaintenanceMode  string        `env:"MAINTENANCE_MODE" envDefault:"mark"`
+	QueueSize        int           `env:"QUEUE_SIZE" envDefault:"1024"`
+	HTTPAddr         string        `env:"HTTP_ADDR"`
+	MetricsAddr      string        `env:"METRICS_ADDR"`
+	OTLPEndpoint     string        `env:"OTLP_ENDPOINT"`
+	OTLPInsecure     bool          `env:"OTLP_INSECURE"`
+	TraceSampleRatio float64       `env:"TRACE_SAMPLE_RATIO" envDefault:"1"`
+	MaxBodySize      int64         `env:"MAX_BODY_SIZE" envDefault:"1048576"`
+	Recorder         recorder.Config
+}
+
+func main() {
+	// A single checker produces the results
+	cfg := &config{Recorder: recorder.Config{StatusQuorum: 1}}
+
+	if err := env.Parse(cfg); err != nil {
+		log.Fatal().Err(err).Msg("can't parse configuration")
+	}
+	if err := cfg.Recorder.Validate(); err != nil {
+		log.Fatal().Err(err).Msg("invalid configuration")
+	}
+
+	if cfg.MaintenanceMode != "mark" && cfg.MaintenanceMode != "skip" {
+		log.Fatal().Str("mode", cfg.MaintenanceMode).Msg("unknown maintenance mode. Valid ones: mark, skip")
+	}
+
+	websites, err := conf.LoadWebsiteParams(cfg.ConfigFilePath)
+	if err != nil {
+		log.Fatal().Err(err).Msg("can't load file")
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: "gpagdispo",
+		Endpoint:    cfg.OTLPEndpoint,
+		Insecure:    cfg.OTLPInsecure,
+		SampleRatio: cfg.TraceSampleRatio,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("can't set up tracing")
+	}
+
+	// Recorder
+	s, err := store.Open(cfg.DBDSN)
+	if err != nil {
+		log.Fatal().Err(err).Msg("can't connect to DB")
+	}
+
+	err = s.CreateSchema(store.MigrationsPath(cfg.DBPath, cfg.DBDSN))
+	if err != nil {
+		log.Fatal().Err(err).Msg("can't create DB schema")
+	}
+
+	var recorderMetrics *recordermetrics.Recorder
+	if cfg.MetricsAddr != "" {
+		recorderMetrics = recordermetrics.NewRecorder(prometheus.DefaultRegisterer)
+	}
+
+	rec, err := recorder.New(context.Background(), cfg.Recorder, s, recorderMetrics)
+	if err != nil {
+		log.Fatal().Err(err).Msg("can't create recorder")
+	}
+
+	// The recorder runs until the pending results are recorded
+	recorderCtx, stopRecorder := context.WithCancel(context.Background())
+	recorderDone := make(chan struct{})
+	go func() {
+		rec.Run(recorderCtx)
+		close(recorderDone)
+	}()
+
+	var servers []*http.Server
+	if cfg.HTTPAddr != "" {
+		servers = append(servers, &http.Server{Addr: cfg.HTTPAddr, Handler: rec.API})
+	}
+
+	// Checker
+	fetcher := checkerdomain.Fetchers{
+		checkerdomain.ProbeHTTP:      (&chttp.Fetcher{Client: http.DefaultClient, MaxBodySize: cfg.MaxBodySize}).FetchWebsiteResult,
+		checkerdomain.ProbeTCP:       (&tcp.Fetcher{}).FetchWebsiteResult,
+		checkerdomain.ProbeTLS:       (&tcp.TLSFetcher{}).FetchWebsiteResult,
+		checkerdomain.ProbeDNS:       (&dns.Fetcher{}).FetchWebsiteResult,
+		checkerdomain.ProbeGRPC:      (&grpc.Fetcher{}).FetchWebsiteResult,
+		checkerdomain.ProbeWebSocket: (&websocket.Fetcher{}).FetchWebsiteResult,
+	}
+	checker := &checkerdomain.Checker{
+		FetchWebsiteResult: fetcher.FetchWebsiteResult,
+		Location:           cfg.Location,
+		ID:                 cfg.CheckerID,
+		SkipMaintenance:    cfg.MaintenanceMode == "skip",
+	}
+
+	if cfg.MetricsAddr != "" {
+		checker.Observer = metrics.NewChecker(prometheus.DefaultRegisterer)
+
+		modules, err := conf.LoadProbeModules(cfg.ConfigFilePath)
+		if err != nil {
+			log.Fatal().Err(err).Msg("can't load probe modules")
+		}
+		mux := metrics.NewHandler(prometheus.DefaultGatherer, map[string]metrics.ReadinessCheck{
+			"db": s.Ping,
+		})
+		mux.Handle("/probe", &metrics.ProbeHandler{
+			Modules:            modules,
+			FetchWebsiteResult: fetcher.FetchWebsiteResult,
+		})
+		servers = append(servers, &http.Server{Addr: cfg.MetricsAddr, Handler: mux})
+	}
+
+	for _, svr := range servers {
+		go func(svr *http.Server) {
+			if err := svr.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal().Err(err).Str("addr", svr.Addr).Msg("can't serve HTTP")
+			}
+		}(svr)
+	}
+
+	// In-process transport between both
+	transport := memory.NewTransport(cfg.QueueSize, rec.HandleResult)
+	checker.ProduceResult = transport.Produce
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = transport.Consume(context.Background())
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Gracefully shutdown
+	termChan := make(chan os.Signal, 1)
+	signal.Notify(termChan, syscall.SIGTERM, syscall.SIGINT)
+
+	go func() {
+		<-termChan
+
+		log.Info().Msg("Shutting down...")
+
+		cancel()
+	}()
+
+	_ = checker.Monitor(ctx, websites, cfg.Tick)
+
+	// Record and export the pending results before closing
+	transport.Close()
+	wg.Wait()
+	stopRecorder()
+	<-recorderDone
+
+	for _, svr := range servers {
+		_ = svr.Shutdown(context.Background())
+	}
+	s.Close()
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Error().Err(err).Msg("can't flush traces")
+	}
+}
@@ -0,0 +1,19 @@
+module github.com/sixstone-qq/gpagdispo/allinone
+
+go 1.16
+
+require (
+	github.com/caarlos0/env/v6 v6.5.0
+	github.com/frankban/quicktest v1.12.1
+	github.com/prometheus/client_golang v1.10.0
+	github.com/rs/zerolog v1.21.0
+	github.com/sixstone-qq/gpagdispo/checker v0.0.0
+	github.com/sixstone-qq/gpagdispo/recorder v0.0.0
+	go.opentelemetry.io/otel v1.0.0
+	go.opentelemetry.io/otel/trace v1.0.0
+)
+
+replace (
+	github.com/sixstone-qq/gpagdispo/checker => ../checker
+	github.com/sixstone-qq/gpagdispo/recorder => ../recorder
+)
@@ -0,0 +1,136 @@
+// Package memory connects the checker and the recorder within the same process.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	checker "github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+	recorder "github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/kafka"
+)
+
+const tracerName = "github.com/sixstone-qq/gpagdispo/allinone/pkg/memory"
+
+// DefaultQueueSize is the number of results the transport holds before blocking the producers.
+const DefaultQueueSize = 1024
+
+// message is a produced website check with the trace context of the producer.
+type message struct {
+	value   []byte
+	carrier mapCarrier
+}
+
+// Compile-time check around interface implementation
+var _ propagation.TextMapCarrier = mapCarrier(nil)
+
+// mapCarrier carries the trace context of a message.
+type mapCarrier map[string]string
+
+// Get returns the value associated with the key.
+func (c mapCarrier) Get(key string) string { return c[key] }
+
+// Set stores the key-value pair.
+func (c mapCarrier) Set(key, value string) { c[key] = value }
+
+// Keys lists the stored keys.
+func (c mapCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Transport replaces the Kafka topic between the checker and the recorder with a
+// buffered channel. The results are encoded as the Kafka messages, so both ends
+// get the same data as they would through Kafka.
+type Transport struct {
+	messages chan message
+
+	// HandleMessage will be called upon every consumption
+	HandleMessage kafka.HandleFn
+}
+
+// NewTransport creates a transport holding up to queueSize results.
+func NewTransport(queueSize int, handleFn kafka.HandleFn) *Transport {
+	return &Transport{
+		messages:      make(chan message, queueSize),
+		HandleMessage: handleFn,
+	}
+}
+
+// checkerPayload and recorderPayload have the format of the Kafka messages
+type checkerPayload struct {
+	WebsiteParams checker.WebsiteParams `json:"website"`
+	WebsiteResult checker.WebsiteResult `json:"result"`
+}
+
+type recorderPayload struct {
+	WebsiteParams recorder.WebsiteParams `json:"website"`
+	WebsiteResult recorder.WebsiteResult `json:"result"`
+}
+
+// Produce sends a website check to the recorder. It has the signature of
+// domain.Checker.ProduceResult and blocks while the queue is full.
+func (t *Transport) Produce(ctx context.Context, wp checker.WebsiteParams, wr checker.WebsiteResult) error {
+	blob, err := json.Marshal(&checkerPayload{
+		WebsiteParams: wp,
+		WebsiteResult: wr,
+	})
+	if err != nil {
+		return err
+	}
+
+	msg := message{value: blob, carrier: mapCarrier{}}
+	otel.GetTextMapPropagator().Inject(ctx, msg.carrier)
+
+	select {
+	case t.messages <- msg:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("can't enqueue result: %w", ctx.Err())
+	}
+}
+
+// Consume handles the produced website checks until the transport is closed.
+// The results already produced are handled before returning.
+func (t *Transport) Consume(ctx context.Context) error {
+	for msg := range t.messages {
+		t.handle(ctx, msg)
+	}
+	return nil
+}
+
+func (t *Transport) handle(ctx context.Context, msg message) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, msg.carrier)
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "memory receive", trace.WithSpanKind(trace.SpanKindConsumer))
+	defer span.End()
+
+	var check recorderPayload
+	if err := json.Unmarshal(msg.value, &check); err != nil {
+		// Log and skip it, as the Kafka consumer
+		log.Error().Err(err).Msg("unable to unmarshal message")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "unable to unmarshal message")
+		return
+	}
+
+	if err := t.HandleMessage(ctx, check.WebsiteParams, check.WebsiteResult); err != nil {
+		log.Error().Err(err).Msg("error handling message")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error handling message")
+	}
+}
+
+// Close stops accepting results. It must be called once the producers are done.
+func (t *Transport) Close() {
+	close(t.messages)
+}
@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	checker "github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+	recorder "github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+func TestTransport(t *testing.T) {
+	c := qt.New(t)
+
+	var handled []recorder.WebsiteResult
+	var handledParams []recorder.WebsiteParams
+	tr := NewTransport(2, func(ctx context.Context, wp recorder.WebsiteParams, wr recorder.WebsiteResult) error {
+		handledParams = append(handledParams, wp)
+		handled = append(handled, wr)
+		return nil
+	})
+
+	wp, err := checker.NewWebsiteParams("http://foo.org", "HEAD", "fo+")
+	c.Assert(err, qt.IsNil)
+	wp.Tags = []string{"api"}
+
+	at := time.Date(2021, 5, 24, 10, 0, 0, 0, time.UTC)
+	status := http.StatusOK
+	matched := true
+	wr := checker.WebsiteResult{Elapsed: time.Second, Status: &status, Matched: &matched, At: at, Location: "eu-west"}
+
+	ctx := context.Background()
+	c.Assert(tr.Produce(ctx, *wp, wr), qt.IsNil)
+	c.Assert(tr.Produce(ctx, *wp, checker.WebsiteResult{Unreachable: true, At: at}), qt.IsNil)
+
+	c.Run("Full queue", func(c *qt.C) {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		err := tr.Produce(ctx, *wp, wr)
+		c.Assert(err, qt.ErrorMatches, "can't enqueue result: context deadline exceeded")
+	})
+
+	tr.Close()
+	c.Assert(tr.Consume(ctx), qt.IsNil)
+
+	regexp := "fo+"
+	c.Assert(handledParams, qt.DeepEquals, []recorder.WebsiteParams{
+		{ID: wp.ID, URL: "http://foo.org", Method: "HEAD", MatchRegexp: &regexp, Tags: []string{"api"}},
+		{ID: wp.ID, URL: "http://foo.org", Method: "HEAD", MatchRegexp: &regexp, Tags: []string{"api"}},
+	})
+	c.Assert(handled, qt.DeepEquals, []recorder.WebsiteResult{
+		{Elapsed: time.Second, Status: &status, Matched: &matched, At: at, Location: "eu-west"},
+		{Unreachable: true, At: at},
+	})
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
 	"os/signal"
@@ -9,21 +10,37 @@ import (
 	"time"
 
 	env "github.com/caarlos0/env/v6"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
 
 	"github.com/sixstone-qq/gpagdispo/checker/pkg/conf"
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/dns"
 	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/grpc"
 	chttp "github.com/sixstone-qq/gpagdispo/checker/pkg/http"
 	"github.com/sixstone-qq/gpagdispo/checker/pkg/kafka"
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/metrics"
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/tcp"
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/tracing"
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/websocket"
 )
 
 type config struct {
-	ConfigFilePath string        `env:"CONFIG_PATH" envDefault:"websites.ion"`
-	KafkaBrokers   []string      `env:"KAFKA_ADDRS" envDefault:"localhost:9092"`
-	KafkaCertFile  string        `env:"KAFKA_CERT_FILE"`
-	KafkaKeyFile   string        `env:"KAFKA_KEY_FILE"`
-	KafkaCAFile    string        `env:"KAFKA_CA_FILE"`
-	Tick           time.Duration `env:"TICK_TIME" envDefault:"2s"`
+	ConfigFilePath   string        `env:"CONFIG_PATH" envDefault:"websites.ion"`
+	KafkaBrokers     []string      `env:"KAFKA_ADDRS" envDefault:"localhost:9092"`
+	KafkaCertFile    string        `env:"KAFKA_CERT_FILE"`
+	KafkaKeyFile     string        `env:"KAFKA_KEY_FILE"`
+	KafkaCAFile      string        `env:"KAFKA_CA_FILE"`
+	Tick             time.Duration `env:"TICK_TIME" envDefault:"2s"`
+	Location         string        `env:"CHECKER_LOCATION"`
+	CheckerID        string        `env:"CHECKER_ID"`
+	MaintenanceMode  string        `env:"MAINTENANCE_MODE" envDefault:"mark"`
+	MetricsAddr      string        `env:"METRICS_ADDR"`
+	OTLPEndpoint     string        `env:"OTLP_ENDPOINT"`
+	OTLPInsecure     bool          `env:"OTLP_INSECURE"`
+	TraceSampleRatio float64       `env:"TRACE_SAMPLE_RATIO" envDefault:"1"`
+	InjectTrace      bool          `env:"INJECT_TRACEPARENT"`
+	MaxBodySize      int64         `env:"MAX_BODY_SIZE" envDefault:"1048576"`
 }
 
 func main() {
@@ -33,6 +50,10 @@ func main() {
 		log.Fatal().Err(err).Msg("can't parse configuration")
 	}
 
+	if cfg.MaintenanceMode != "mark" && cfg.MaintenanceMode != "skip" {
+		log.Fatal().Str("mode", cfg.MaintenanceMode).Msg("unknown maintenance mode. Valid ones: mark, skip")
+	}
+
 	websites, err := conf.LoadWebsiteParams(cfg.ConfigFilePath)
 	if err != nil {
 		log.Fatal().Err(err).Msg("can't load file")
@@ -48,8 +69,24 @@ func main() {
 		log.Fatal().Err(err).Msg("can't create topic")
 	}
 
-	// TODO: Set best client params
-	fetcher := &chttp.Fetcher{Client: http.DefaultClient}
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: "gpagdispo-checker",
+		Endpoint:    cfg.OTLPEndpoint,
+		Insecure:    cfg.OTLPInsecure,
+		SampleRatio: cfg.TraceSampleRatio,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("can't set up tracing")
+	}
+
+	fetcher := domain.Fetchers{
+		domain.ProbeHTTP:      (&chttp.Fetcher{Client: http.DefaultClient, InjectTraceContext: cfg.InjectTrace, MaxBodySize: cfg.MaxBodySize}).FetchWebsiteResult,
+		domain.ProbeTCP:       (&tcp.Fetcher{}).FetchWebsiteResult,
+		domain.ProbeTLS:       (&tcp.TLSFetcher{}).FetchWebsiteResult,
+		domain.ProbeDNS:       (&dns.Fetcher{}).FetchWebsiteResult,
+		domain.ProbeGRPC:      (&grpc.Fetcher{}).FetchWebsiteResult,
+		domain.ProbeWebSocket: (&websocket.Fetcher{}).FetchWebsiteResult,
+	}
 
 	producer, err := kafka.NewProducer(cfg.KafkaBrokers, kafkaCfg)
 	if err != nil {
@@ -59,10 +96,37 @@ func main() {
 	checker := &domain.Checker{
 		FetchWebsiteResult: fetcher.FetchWebsiteResult,
 		ProduceResult:      producer.Produce,
+		Location:           cfg.Location,
+		ID:                 cfg.CheckerID,
+		SkipMaintenance:    cfg.MaintenanceMode == "skip",
+	}
+
+	var svr *http.Server
+	if cfg.MetricsAddr != "" {
+		checker.Observer = metrics.NewChecker(prometheus.DefaultRegisterer)
+		metrics.RegisterProducer(prometheus.DefaultRegisterer, producer)
+		modules, err := conf.LoadProbeModules(cfg.ConfigFilePath)
+		if err != nil {
+			log.Fatal().Err(err).Msg("can't load probe modules")
+		}
+		mux := metrics.NewHandler(prometheus.DefaultGatherer, map[string]metrics.ReadinessCheck{
+			"kafka": func(ctx context.Context) error { return producer.Ready() },
+		})
+		mux.Handle("/probe", &metrics.ProbeHandler{
+			Modules:            modules,
+			FetchWebsiteResult: fetcher.FetchWebsiteResult,
+		})
+		svr = &http.Server{Addr: cfg.MetricsAddr, Handler: mux}
+
+		go func() {
+			if err := svr.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal().Err(err).Msg("can't serve metrics")
+			}
+		}()
 	}
 
 	// Gracefully shutdown
-	termChan := make(chan os.Signal)
+	termChan := make(chan os.Signal, 1)
 	signal.Notify(termChan, syscall.SIGTERM, syscall.SIGINT)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -76,4 +140,12 @@ func main() {
 	}()
 
 	_ = checker.Monitor(ctx, websites, cfg.Tick)
+
+	if svr != nil {
+		_ = svr.Shutdown(context.Background())
+	}
+	producer.Close()
+	if err := shutdownTracing(context.Background()); err != nil {
+		log.Error().Err(err).Msg("can't flush traces")
+	}
 }
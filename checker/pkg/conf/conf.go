@@ -1,5 +1,5 @@
 // Package conf reads from configuration file to return the list of
-// websites to monitor
+// websites to monitor and the modules to probe targets on demand
 package conf
 
 import (
@@ -16,17 +16,253 @@ import (
 // config defines the format of the configuration file.
 type config struct {
 	Websites []website `ion:"websites" json:"websites"`
+	// Maintenance defines the maintenance windows of the websites with any of their tags.
+	Maintenance []maintenance `ion:"maintenance" json:"maintenance"`
+	// Modules defines how to probe targets on demand.
+	Modules []module `ion:"modules" json:"modules"`
+	// Defaults holds the settings of every website unless they override them.
+	Defaults defaults `ion:"defaults" json:"defaults"`
 }
 
-// website defines the website params to check in the conf file.
-type website struct {
-	URL         string `ion:"url" json:"url"`
+// defaults defines the default website settings in the conf file.
+type defaults struct {
+	Client *client `ion:"client" json:"client"`
+}
+
+// client defines the HTTP client settings in the conf file. The booleans are
+// pointers so a website can override a default with false.
+type client struct {
+	Proxy              string   `ion:"proxy" json:"proxy"`
+	DisableKeepAlives  *bool    `ion:"disable_keep_alives" json:"disable_keep_alives"`
+	HTTPVersion        string   `ion:"http_version" json:"http_version"`
+	IPVersion          int      `ion:"ip_version" json:"ip_version"`
+	UserAgent          string   `ion:"user_agent" json:"user_agent"`
+	CAFile             string   `ion:"ca_file" json:"ca_file"`
+	CertFile           string   `ion:"cert_file" json:"cert_file"`
+	KeyFile            string   `ion:"key_file" json:"key_file"`
+	InsecureSkipVerify *bool    `ion:"insecure_skip_verify" json:"insecure_skip_verify"`
+	Resolve            []string `ion:"resolve" json:"resolve"`
+}
+
+// merge returns the client settings overriding the set ones of defaults.
+func (c *client) merge(defaults *client) *client {
+	if c == nil {
+		return defaults
+	}
+	if defaults == nil {
+		return c
+	}
+
+	merged := *defaults
+	if c.Proxy != "" {
+		merged.Proxy = c.Proxy
+	}
+	if c.DisableKeepAlives != nil {
+		merged.DisableKeepAlives = c.DisableKeepAlives
+	}
+	if c.HTTPVersion != "" {
+		merged.HTTPVersion = c.HTTPVersion
+	}
+	if c.IPVersion != 0 {
+		merged.IPVersion = c.IPVersion
+	}
+	if c.UserAgent != "" {
+		merged.UserAgent = c.UserAgent
+	}
+	if c.CAFile != "" {
+		merged.CAFile = c.CAFile
+	}
+	if c.CertFile != "" {
+		merged.CertFile, merged.KeyFile = c.CertFile, c.KeyFile
+	}
+	if c.InsecureSkipVerify != nil {
+		merged.InsecureSkipVerify = c.InsecureSkipVerify
+	}
+	if len(c.Resolve) > 0 {
+		merged.Resolve = c.Resolve
+	}
+	return &merged
+}
+
+func (c client) toDomain() domain.ClientParams {
+	return domain.ClientParams{
+		Proxy:              c.Proxy,
+		DisableKeepAlives:  c.DisableKeepAlives != nil && *c.DisableKeepAlives,
+		HTTPVersion:        c.HTTPVersion,
+		IPVersion:          c.IPVersion,
+		UserAgent:          c.UserAgent,
+		CAFile:             c.CAFile,
+		CertFile:           c.CertFile,
+		KeyFile:            c.KeyFile,
+		InsecureSkipVerify: c.InsecureSkipVerify != nil && *c.InsecureSkipVerify,
+		Resolve:            c.Resolve,
+	}
+}
+
+// module defines a probe module in the conf file.
+type module struct {
+	Name        string `ion:"name" json:"name"`
 	Method      string `ion:"method" json:"method"`
 	MatchRegexp string `ion:"match_regexp" json:"match_regexp"`
+	Timeout     string `ion:"timeout" json:"timeout"`
 }
 
-// LoadWebsiteParams loads websites to check from a configuration file formatted in ion or JSON.
-func LoadWebsiteParams(confPath string) ([]domain.WebsiteParams, error) {
+// website defines the website params to check in the conf file.
+type website struct {
+	URL         string        `ion:"url" json:"url"`
+	Method      string        `ion:"method" json:"method"`
+	MatchRegexp string        `ion:"match_regexp" json:"match_regexp"`
+	Tags        []string      `ion:"tags" json:"tags"`
+	Maintenance []maintenance `ion:"maintenance" json:"maintenance"`
+	// Expect lists the answers DNS probes must resolve.
+	Expect []string `ion:"expect" json:"expect"`
+	// MinCertValidity fails TLS probes whose certificate expires sooner.
+	MinCertValidity string `ion:"min_cert_validity" json:"min_cert_validity"`
+	// Metadata is sent with the health checks of gRPC probes.
+	Metadata map[string]string `ion:"metadata" json:"metadata"`
+	// Send is the message WebSocket probes send once connected.
+	Send string `ion:"send" json:"send"`
+	// Steps are the requests of a multi-step HTTP check.
+	Steps []step `ion:"steps" json:"steps"`
+	// Auth holds the credentials of HTTP probes.
+	Auth *auth `ion:"auth" json:"auth"`
+	// FollowRedirects is true, false or the maximum number of redirects HTTP probes follow.
+	FollowRedirects interface{} `ion:"follow_redirects" json:"follow_redirects"`
+	// ExpectFinalURL is the URL the redirects of HTTP probes must end at.
+	ExpectFinalURL string `ion:"expect_final_url" json:"expect_final_url"`
+	// Client tunes the HTTP client of HTTP probes, overriding the default one.
+	Client *client `ion:"client" json:"client"`
+	// PerIP checks every address the host of HTTP probes resolves to.
+	PerIP bool `ion:"per_ip" json:"per_ip"`
+}
+
+// redirects returns how the website follows redirects or nil if it is not set.
+func (w website) redirects() (*domain.RedirectParams, error) {
+	if w.FollowRedirects == nil && w.ExpectFinalURL == "" {
+		return nil, nil
+	}
+
+	rp := &domain.RedirectParams{Max: domain.DefaultMaxRedirects, ExpectedURL: w.ExpectFinalURL}
+	switch v := w.FollowRedirects.(type) {
+	case nil:
+	case bool:
+		if !v {
+			rp.Max = 0
+		}
+	case int:
+		rp.Max = v
+	case int64:
+		rp.Max = int(v)
+	case float64:
+		if v != float64(int(v)) {
+			return nil, fmt.Errorf("follow_redirects must be a boolean or an integer: %v provided", v)
+		}
+		rp.Max = int(v)
+	default:
+		return nil, fmt.Errorf("follow_redirects must be a boolean or an integer: %v provided", v)
+	}
+	return rp, nil
+}
+
+// auth defines the credentials of a website in the conf file.
+type auth struct {
+	Type         string   `ion:"type" json:"type"`
+	Username     string   `ion:"username" json:"username"`
+	Password     string   `ion:"password" json:"password"`
+	Token        string   `ion:"token" json:"token"`
+	TokenURL     string   `ion:"token_url" json:"token_url"`
+	ClientID     string   `ion:"client_id" json:"client_id"`
+	ClientSecret string   `ion:"client_secret" json:"client_secret"`
+	Scopes       []string `ion:"scopes" json:"scopes"`
+}
+
+func (a auth) toDomain() domain.AuthParams {
+	return domain.AuthParams{
+		Type:         domain.AuthType(a.Type),
+		Username:     a.Username,
+		Password:     a.Password,
+		Token:        a.Token,
+		TokenURL:     a.TokenURL,
+		ClientID:     a.ClientID,
+		ClientSecret: a.ClientSecret,
+		Scopes:       a.Scopes,
+	}
+}
+
+// step defines a request of a multi-step check in the conf file.
+type step struct {
+	Name        string            `ion:"name" json:"name"`
+	Method      string            `ion:"method" json:"method"`
+	URL         string            `ion:"url" json:"url"`
+	Headers     map[string]string `ion:"headers" json:"headers"`
+	Body        string            `ion:"body" json:"body"`
+	MatchRegexp string            `ion:"match_regexp" json:"match_regexp"`
+	Extract     []extraction      `ion:"extract" json:"extract"`
+}
+
+func (s step) toDomain() (*domain.Step, error) {
+	ds, err := domain.NewStep(s.Name, s.Method, s.URL, s.MatchRegexp)
+	if err != nil {
+		return nil, err
+	}
+	ds.Headers = s.Headers
+	ds.Body = s.Body
+
+	for _, e := range s.Extract {
+		de, err := e.toDomain()
+		if err != nil {
+			return nil, err
+		}
+		ds.Extract = append(ds.Extract, *de)
+	}
+	return ds, nil
+}
+
+// extraction defines a variable extracted from the response of a step in the conf file.
+// Only one of its sources must be set.
+type extraction struct {
+	Var      string `ion:"var" json:"var"`
+	Regexp   string `ion:"regexp" json:"regexp"`
+	JSONPath string `ion:"jsonpath" json:"jsonpath"`
+	Header   string `ion:"header" json:"header"`
+	Cookie   string `ion:"cookie" json:"cookie"`
+}
+
+func (e extraction) toDomain() (*domain.Extraction, error) {
+	var sources []domain.ExtractSource
+	var expr string
+	for source, v := range map[domain.ExtractSource]string{
+		domain.ExtractRegexp:   e.Regexp,
+		domain.ExtractJSONPath: e.JSONPath,
+		domain.ExtractHeader:   e.Header,
+		domain.ExtractCookie:   e.Cookie,
+	} {
+		if v != "" {
+			sources = append(sources, source)
+			expr = v
+		}
+	}
+	if len(sources) != 1 {
+		return nil, fmt.Errorf("extraction of %q requires one of regexp, jsonpath, header or cookie", e.Var)
+	}
+	return domain.NewExtraction(e.Var, sources[0], expr)
+}
+
+// maintenance defines a one-off or recurring maintenance window in the conf file.
+type maintenance struct {
+	Tags     []string `ion:"tags" json:"tags"`
+	Start    string   `ion:"start" json:"start"`
+	End      string   `ion:"end" json:"end"`
+	Cron     string   `ion:"cron" json:"cron"`
+	Duration string   `ion:"duration" json:"duration"`
+}
+
+func (m maintenance) toDomain() (*domain.MaintenanceWindow, error) {
+	return domain.NewMaintenanceWindow(m.Start, m.End, m.Cron, m.Duration)
+}
+
+// load decodes a configuration file formatted in ion or JSON.
+func load(confPath string) (*config, error) {
 	f, err := os.Open(confPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open config file: %w", err)
@@ -49,6 +285,41 @@ func LoadWebsiteParams(confPath string) ([]domain.WebsiteParams, error) {
 		return nil, fmt.Errorf("unable to decode configuration file: %w", err)
 	}
 
+	return &cfg, nil
+}
+
+// LoadProbeModules loads the probe modules by name from a configuration file formatted in ion or JSON.
+// domain.DefaultProbeModule is added unless a module with the same name is defined.
+func LoadProbeModules(confPath string) (map[string]domain.ProbeModule, error) {
+	cfg, err := load(confPath)
+	if err != nil {
+		return nil, err
+	}
+
+	modules := map[string]domain.ProbeModule{domain.DefaultProbeModule.Name: domain.DefaultProbeModule}
+	defined := make(map[string]bool, len(cfg.Modules))
+	for _, m := range cfg.Modules {
+		pm, err := domain.NewProbeModule(m.Name, m.Method, m.MatchRegexp, m.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("can't create probe module %q: %w", m.Name, err)
+		}
+		if defined[pm.Name] {
+			return nil, fmt.Errorf("duplicated probe module %q", pm.Name)
+		}
+		defined[pm.Name] = true
+		modules[pm.Name] = *pm
+	}
+
+	return modules, nil
+}
+
+// LoadWebsiteParams loads websites to check from a configuration file formatted in ion or JSON.
+func LoadWebsiteParams(confPath string) ([]domain.WebsiteParams, error) {
+	cfg, err := load(confPath)
+	if err != nil {
+		return nil, err
+	}
+
 	// Parse content to make sure is correct
 	wbParams := make([]domain.WebsiteParams, len(cfg.Websites))
 	for i, w := range cfg.Websites {
@@ -56,8 +327,82 @@ func LoadWebsiteParams(confPath string) ([]domain.WebsiteParams, error) {
 		if err != nil {
 			return nil, fmt.Errorf("can't create website param: %w", err)
 		}
+		if err := params.SetProbeOptions(domain.ProbeOptions{
+			Expected:        w.Expect,
+			MinCertValidity: w.MinCertValidity,
+			Metadata:        w.Metadata,
+			Send:            w.Send,
+		}); err != nil {
+			return nil, fmt.Errorf("can't set probe options of %s: %w", w.URL, err)
+		}
+
+		steps := make([]domain.Step, len(w.Steps))
+		for j, st := range w.Steps {
+			ds, err := st.toDomain()
+			if err != nil {
+				return nil, fmt.Errorf("can't create step %d of %s: %w", j+1, w.URL, err)
+			}
+			steps[j] = *ds
+		}
+		if err := params.SetSteps(steps); err != nil {
+			return nil, fmt.Errorf("can't set steps of %s: %w", w.URL, err)
+		}
+		if w.Auth != nil {
+			if err := params.SetAuth(w.Auth.toDomain()); err != nil {
+				return nil, fmt.Errorf("can't set auth of %s: %w", w.URL, err)
+			}
+		}
+		rp, err := w.redirects()
+		if err != nil {
+			return nil, fmt.Errorf("can't set redirects of %s: %w", w.URL, err)
+		}
+		if rp != nil {
+			if err := params.SetRedirects(*rp); err != nil {
+				return nil, fmt.Errorf("can't set redirects of %s: %w", w.URL, err)
+			}
+		}
+		// The default client settings only apply to HTTP probes
+		if c := w.Client.merge(cfg.Defaults.Client); c != nil && (w.Client != nil || params.Probe == domain.ProbeHTTP) {
+			if err := params.SetClient(c.toDomain()); err != nil {
+				return nil, fmt.Errorf("can't set client of %s: %w", w.URL, err)
+			}
+		}
+		if err := params.SetPerIP(w.PerIP); err != nil {
+			return nil, fmt.Errorf("can't set per IP checks of %s: %w", w.URL, err)
+		}
+		params.Tags = w.Tags
+
+		for _, m := range w.Maintenance {
+			mw, err := m.toDomain()
+			if err != nil {
+				return nil, fmt.Errorf("can't create maintenance window of %s: %w", w.URL, err)
+			}
+			params.Maintenance = append(params.Maintenance, *mw)
+		}
+		for _, m := range cfg.Maintenance {
+			mw, err := m.toDomain()
+			if err != nil {
+				return nil, fmt.Errorf("can't create maintenance window for tags %s: %w", m.Tags, err)
+			}
+			if hasAnyTag(params.Tags, m.Tags) {
+				params.Maintenance = append(params.Maintenance, *mw)
+			}
+		}
+
 		wbParams[i] = *params
 	}
 
 	return wbParams, nil
 }
+
+// hasAnyTag returns true if any of the tags is in websiteTags.
+func hasAnyTag(websiteTags, tags []string) bool {
+	for _, t := range tags {
+		for _, wt := range websiteTags {
+			if t == wt {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,267 @@
+// Package dns fetches the results of the DNS probes.
+package dns
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+// defaultPort is used by resolvers without port.
+const defaultPort = "53"
+
+// resolvConf holds the name servers used by the probes without resolver.
+var resolvConf = "/etc/resolv.conf"
+
+// maxUDPSize is the maximum size of the UDP answers without EDNS.
+const maxUDPSize = 512
+
+// Fetcher resolves the names of DNS probes.
+type Fetcher struct {
+	Dialer net.Dialer
+}
+
+// FetchWebsiteResult fetches the result of a DNS probe: the time to resolve the name and
+// its answers. The resolver is the host of the URL, or the first name server of the system
+// if not set. The query is sent to it, the hosts file is never read.
+// Matched says if the expected answers, if any, were all resolved.
+func (f *Fetcher) FetchWebsiteResult(ctx context.Context, wp domain.WebsiteParams) (*domain.WebsiteResult, error) {
+	server, err := resolver(wp)
+	if err != nil {
+		return nil, err
+	}
+	name := wp.DNSName()
+
+	recordType := domain.DNSRecordA
+	var expected []string
+	if wp.DNS != nil {
+		recordType = wp.DNS.RecordType
+		expected = wp.DNS.Expected
+	}
+
+	start := time.Now()
+	answers, err := f.lookup(ctx, server, recordType, name)
+	elapsed := time.Since(start)
+
+	wr := &domain.WebsiteResult{
+		Probe:   domain.ProbeDNS,
+		Elapsed: elapsed,
+		At:      time.Now().UTC(),
+	}
+	if err != nil {
+		var netErr net.Error
+		if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+			wr.Unreachable = true
+			return wr, nil
+		}
+		msg := err.Error()
+		wr.Error = &msg
+		return wr, nil
+	}
+
+	wr.DNS = &domain.DNSResult{Answers: answers}
+	if len(expected) > 0 {
+		matched := containsAll(answers, expected)
+		wr.Matched = &matched
+	}
+
+	return wr, nil
+}
+
+// resolver returns the address of the resolver of the probe.
+func resolver(wp domain.WebsiteParams) (string, error) {
+	if wp.URL.Host == "" {
+		return systemResolver()
+	}
+	if wp.URL.Port() == "" {
+		return net.JoinHostPort(wp.URL.Hostname(), defaultPort), nil
+	}
+	return wp.URL.Host, nil
+}
+
+// systemResolver returns the address of the first name server of resolvConf, or the local
+// one if there is none as the Go resolver does.
+func systemResolver() (string, error) {
+	blob, err := os.ReadFile(resolvConf)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("can't read name servers: %w", err)
+	}
+	for _, line := range strings.Split(string(blob), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+			return net.JoinHostPort(fields[1], defaultPort), nil
+		}
+	}
+	return net.JoinHostPort("127.0.0.1", defaultPort), nil
+}
+
+// lookup resolves the records of the type of the name with the server.
+func (f *Fetcher) lookup(ctx context.Context, server string, recordType domain.DNSRecordType, name string) ([]string, error) {
+	qtype, ok := map[domain.DNSRecordType]dnsmessage.Type{
+		domain.DNSRecordA:     dnsmessage.TypeA,
+		domain.DNSRecordAAAA:  dnsmessage.TypeAAAA,
+		domain.DNSRecordCNAME: dnsmessage.TypeCNAME,
+		domain.DNSRecordTXT:   dnsmessage.TypeTXT,
+	}[recordType]
+	if !ok {
+		return nil, fmt.Errorf("unknown DNS record type %q", recordType)
+	}
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, &net.DNSError{Err: "invalid name", Name: name}
+	}
+
+	resp, err := f.exchange(ctx, server, dnsmessage.Question{Name: qname, Type: qtype, Class: dnsmessage.ClassINET})
+	if err != nil {
+		var netErr net.Error
+		timeout := errors.As(err, &netErr) && netErr.Timeout()
+		return nil, &net.DNSError{Err: err.Error(), Name: name, Server: server, IsTimeout: timeout}
+	}
+	switch resp.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, &net.DNSError{Err: "no such host", Name: name, Server: server, IsNotFound: true}
+	default:
+		return nil, &net.DNSError{Err: "server misbehaving: " + resp.RCode.String(), Name: name, Server: server}
+	}
+
+	var answers []string
+	for _, r := range resp.Answers {
+		switch b := r.Body.(type) {
+		case *dnsmessage.AResource:
+			if qtype == dnsmessage.TypeA {
+				answers = append(answers, net.IP(b.A[:]).String())
+			}
+		case *dnsmessage.AAAAResource:
+			if qtype == dnsmessage.TypeAAAA {
+				answers = append(answers, net.IP(b.AAAA[:]).String())
+			}
+		case *dnsmessage.CNAMEResource:
+			if qtype == dnsmessage.TypeCNAME {
+				answers = append(answers, b.CNAME.String())
+			}
+		case *dnsmessage.TXTResource:
+			if qtype == dnsmessage.TypeTXT {
+				answers = append(answers, strings.Join(b.TXT, ""))
+			}
+		}
+	}
+	if len(answers) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: name, Server: server, IsNotFound: true}
+	}
+
+	return answers, nil
+}
+
+// exchange sends the question to the server over UDP, and again over TCP if the answer
+// is truncated, and returns the response.
+func (f *Fetcher) exchange(ctx context.Context, server string, q dnsmessage.Question) (*dnsmessage.Message, error) {
+	var idb [2]byte
+	if _, err := rand.Read(idb[:]); err != nil {
+		return nil, err
+	}
+	id := binary.BigEndian.Uint16(idb[:])
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{q},
+	}).Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, network := range []string{"udp", "tcp"} {
+		resp, err := f.roundTrip(ctx, network, server, query)
+		if err != nil {
+			return nil, err
+		}
+		if resp.ID != id || !resp.Response {
+			return nil, errors.New("unexpected response")
+		}
+		if !resp.Truncated {
+			return resp, nil
+		}
+	}
+	return nil, errors.New("truncated response")
+}
+
+// roundTrip sends the query to the server and reads its response. TCP messages are
+// prefixed with their length.
+func (f *Fetcher) roundTrip(ctx context.Context, network, server string, query []byte) (*dnsmessage.Message, error) {
+	conn, err := f.Dialer.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	var blob []byte
+	if network == "tcp" {
+		msg := make([]byte, 2+len(query))
+		binary.BigEndian.PutUint16(msg, uint16(len(query)))
+		copy(msg[2:], query)
+		if _, err := conn.Write(msg); err != nil {
+			return nil, err
+		}
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return nil, err
+		}
+		blob = make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, blob); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		blob = make([]byte, maxUDPSize)
+		n, err := conn.Read(blob)
+		if err != nil {
+			return nil, err
+		}
+		blob = blob[:n]
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(blob); err != nil {
+		return nil, fmt.Errorf("can't parse response: %w", err)
+	}
+	return &resp, nil
+}
+
+// containsAll returns true if every expected answer was resolved. Names are compared
+// case-insensitively without their trailing dot.
+func containsAll(answers, expected []string) bool {
+	resolved := make(map[string]bool, len(answers))
+	for _, a := range answers {
+		resolved[normalize(a)] = true
+	}
+	for _, e := range expected {
+		if !resolved[normalize(e)] {
+			return false
+		}
+	}
+	return true
+}
+
+func normalize(answer string) string {
+	return strings.ToLower(strings.TrimSuffix(answer, "."))
+}
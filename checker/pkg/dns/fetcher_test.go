@@ -0,0 +1,250 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+func TestFetchWebsiteResult(t *testing.T) {
+	c := qt.New(t)
+
+	addr := newFakeServer(c, map[dnsmessage.Type][]dnsmessage.Resource{
+		dnsmessage.TypeA: {
+			resource("foo.test.", &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}}),
+			resource("foo.test.", &dnsmessage.AResource{A: [4]byte{192, 0, 2, 2}}),
+		},
+		dnsmessage.TypeAAAA: {
+			resource("foo.test.", &dnsmessage.AAAAResource{AAAA: [16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}}),
+		},
+		dnsmessage.TypeCNAME: {
+			resource("www.foo.test.", &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("foo.test.")}),
+		},
+		dnsmessage.TypeTXT: {
+			resource("foo.test.", &dnsmessage.TXTResource{TXT: []string{"v=spf1 -all"}}),
+		},
+	})
+	fetcher := &Fetcher{}
+
+	for _, test := range []struct {
+		name     string
+		rawURL   string
+		expected []string
+		answers  []string
+		matched  *bool
+	}{
+		{"A", "dns://" + addr + "/foo.test.", nil, []string{"192.0.2.1", "192.0.2.2"}, nil},
+		{"AAAA", "dns://" + addr + "/foo.test.?type=aaaa", []string{"2001:db8::1"}, []string{"2001:db8::1"}, boolPtr(true)},
+		{"CNAME", "dns://" + addr + "/www.foo.test.?type=cname", []string{"FOO.test"}, []string{"foo.test."}, boolPtr(true)},
+		{"TXT", "dns://" + addr + "/foo.test.?type=txt", []string{"v=spf1 -all"}, []string{"v=spf1 -all"}, boolPtr(true)},
+		{"Mismatch", "dns://" + addr + "/foo.test.", []string{"192.0.2.1", "192.0.2.3"}, []string{"192.0.2.1", "192.0.2.2"}, boolPtr(false)},
+	} {
+		c.Run(test.name, func(c *qt.C) {
+			wp, err := domain.NewWebsiteParams(test.rawURL, "", "")
+			c.Assert(err, qt.IsNil)
+			c.Assert(wp.SetProbeOptions(domain.ProbeOptions{Expected: test.expected}), qt.IsNil)
+
+			wr, err := fetcher.FetchWebsiteResult(context.Background(), *wp)
+			c.Assert(err, qt.IsNil)
+			c.Assert(wr.Error, qt.IsNil)
+			c.Assert(wr.DNS, qt.DeepEquals, &domain.DNSResult{Answers: test.answers})
+			c.Assert(wr.Matched, qt.DeepEquals, test.matched)
+			c.Assert(wr.Failed(), qt.Equals, test.matched != nil && !*test.matched)
+		})
+	}
+
+	c.Run("Not found", func(c *qt.C) {
+		wp, err := domain.NewWebsiteParams("dns://"+addr+"/bar.test.", "", "")
+		c.Assert(err, qt.IsNil)
+
+		wr, err := fetcher.FetchWebsiteResult(context.Background(), *wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(wr.Error, qt.Not(qt.IsNil))
+		c.Assert(*wr.Error, qt.Contains, "no such host")
+		c.Assert(wr.Failed(), qt.IsTrue)
+	})
+
+	c.Run("Hosts file", func(c *qt.C) {
+		// localhost is only in the hosts file
+		wp, err := domain.NewWebsiteParams("dns://"+addr+"/localhost", "", "")
+		c.Assert(err, qt.IsNil)
+
+		wr, err := fetcher.FetchWebsiteResult(context.Background(), *wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(*wr.Error, qt.Equals, "lookup localhost. on "+addr+": no such host")
+	})
+
+	c.Run("System resolver", func(c *qt.C) {
+		host, port, err := net.SplitHostPort(addr)
+		c.Assert(err, qt.IsNil)
+		path := filepath.Join(c.TempDir(), "resolv.conf")
+		c.Assert(os.WriteFile(path, []byte("search test\nnameserver "+host+"\n"), 0o600), qt.IsNil)
+		c.Patch(&resolvConf, path)
+
+		wp, err := domain.NewWebsiteParams("dns:///foo.test.", "", "")
+		c.Assert(err, qt.IsNil)
+		server, err := resolver(*wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(server, qt.Equals, net.JoinHostPort(host, defaultPort))
+
+		// The fake server doesn't listen on the default port
+		answers, err := fetcher.lookup(context.Background(), net.JoinHostPort(host, port), domain.DNSRecordA, wp.DNSName())
+		c.Assert(err, qt.IsNil)
+		c.Assert(answers, qt.DeepEquals, []string{"192.0.2.1", "192.0.2.2"})
+	})
+
+	c.Run("Timeout", func(c *qt.C) {
+		// Nobody answers on this socket
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		c.Assert(err, qt.IsNil)
+		defer conn.Close()
+
+		wp, err := domain.NewWebsiteParams("dns://"+conn.LocalAddr().String()+"/foo.test.", "", "")
+		c.Assert(err, qt.IsNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		wr, err := fetcher.FetchWebsiteResult(ctx, *wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(wr.Unreachable, qt.IsTrue)
+	})
+}
+
+func TestFetchWebsiteResultTruncated(t *testing.T) {
+	c := qt.New(t)
+
+	// The UDP answer is truncated, the full one is served over TCP on the same port
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, qt.IsNil)
+	defer l.Close()
+	pc, err := net.ListenPacket("udp", l.Addr().String())
+	c.Assert(err, qt.IsNil)
+	defer pc.Close()
+
+	answer := func(req []byte, truncated bool) []byte {
+		var m dnsmessage.Message
+		c.Check(m.Unpack(req), qt.IsNil)
+		m.Response, m.Truncated = true, truncated
+		if !truncated {
+			m.Answers = []dnsmessage.Resource{resource("foo.test.", &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}})}
+			m.Answers[0].Header.Type = dnsmessage.TypeA
+		}
+		blob, err := m.Pack()
+		c.Check(err, qt.IsNil)
+		return blob
+	}
+	go func() {
+		buf := make([]byte, 512)
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		_, _ = pc.WriteTo(answer(buf[:n], true), addr)
+	}()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		blob := answer(req, false)
+		binary.BigEndian.PutUint16(size[:], uint16(len(blob)))
+		_, _ = conn.Write(append(size[:], blob...))
+	}()
+
+	wp, err := domain.NewWebsiteParams("dns://"+l.Addr().String()+"/foo.test.", "", "")
+	c.Assert(err, qt.IsNil)
+	wr, err := new(Fetcher).FetchWebsiteResult(context.Background(), *wp)
+	c.Assert(err, qt.IsNil)
+	c.Assert(wr.Error, qt.IsNil)
+	c.Assert(wr.DNS, qt.DeepEquals, &domain.DNSResult{Answers: []string{"192.0.2.1"}})
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func resource(name string, body dnsmessage.ResourceBody) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{
+			Name:  dnsmessage.MustNewName(name),
+			Class: dnsmessage.ClassINET,
+			TTL:   60,
+		},
+		Body: body,
+	}
+}
+
+// newFakeServer serves the records on UDP and returns its address. Unknown names are not found.
+func newFakeServer(c *qt.C, records map[dnsmessage.Type][]dnsmessage.Resource) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	c.Assert(err, qt.IsNil)
+	c.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var req dnsmessage.Message
+			if err := req.Unpack(buf[:n]); err != nil || len(req.Questions) != 1 {
+				continue
+			}
+			q := req.Questions[0]
+
+			resp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: req.ID, Response: true, Authoritative: true, RCode: dnsmessage.RCodeNameError},
+				Questions: req.Questions,
+			}
+			for _, name := range names(records) {
+				if name == q.Name.String() {
+					resp.RCode = dnsmessage.RCodeSuccess
+				}
+			}
+			for _, r := range records[q.Type] {
+				if r.Header.Name == q.Name {
+					r.Header.Type = q.Type
+					resp.Answers = append(resp.Answers, r)
+				}
+			}
+
+			blob, err := resp.Pack()
+			if err != nil {
+				continue
+			}
+			_, _ = conn.WriteTo(blob, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func names(records map[dnsmessage.Type][]dnsmessage.Resource) []string {
+	var names []string
+	for _, rs := range records {
+		for _, r := range rs {
+			names = append(names, r.Header.Name.String())
+		}
+	}
+	return names
+}
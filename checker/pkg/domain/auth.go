@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// AuthType defines how the checker authenticates to a website.
+type AuthType string
+
+const (
+	// AuthBasic sends the username and password as HTTP basic auth.
+	AuthBasic AuthType = "basic"
+	// AuthBearer sends a static bearer token.
+	AuthBearer AuthType = "bearer"
+	// AuthOAuth2 sends a bearer token got from the token URL with the OAuth2 client credentials grant.
+	AuthOAuth2 AuthType = "oauth2"
+)
+
+// AuthParams defines the credentials of an HTTP website. The secrets are neither
+// part of the payload nor of the ID.
+type AuthParams struct {
+	Type     AuthType `json:"type"`
+	Username string   `json:"-"`
+	Password string   `json:"-"`
+	// Token is the static bearer token.
+	Token string `json:"-"`
+	// TokenURL, ClientID, ClientSecret and Scopes define the OAuth2 client credentials grant.
+	TokenURL     string   `json:"token_url,omitempty"`
+	ClientID     string   `json:"-"`
+	ClientSecret string   `json:"-"`
+	Scopes       []string `json:"scopes,omitempty"`
+}
+
+// validate returns an error if a credential required by the auth type is missing.
+func (ap AuthParams) validate() error {
+	switch ap.Type {
+	case AuthBasic:
+		if ap.Username == "" {
+			return fmt.Errorf("basic auth requires a username")
+		}
+	case AuthBearer:
+		if ap.Token == "" {
+			return fmt.Errorf("bearer auth requires a token")
+		}
+	case AuthOAuth2:
+		u, err := url.Parse(ap.TokenURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("OAuth2 auth requires an HTTP token URL: %q provided", ap.TokenURL)
+		}
+		if ap.ClientID == "" || ap.ClientSecret == "" {
+			return fmt.Errorf("OAuth2 auth requires a client ID and secret")
+		}
+	default:
+		return fmt.Errorf(`unknown auth type "%s". Valid ones: %s`, ap.Type, []AuthType{AuthBasic, AuthBearer, AuthOAuth2})
+	}
+	return nil
+}
+
+// key returns the part of the ID of the website defined by the auth, without secrets.
+func (ap AuthParams) key() string {
+	return string(ap.Type) + ap.TokenURL + strings.Join(ap.Scopes, " ")
+}
+
+// SetAuth sets the credentials of an HTTP website. Only the auth type, the
+// token URL and the scopes are part of the ID.
+func (wp *WebsiteParams) SetAuth(ap AuthParams) error {
+	if wp.Probe != ProbeHTTP {
+		return fmt.Errorf("auth is only supported by HTTP probes")
+	}
+	if err := ap.validate(); err != nil {
+		return err
+	}
+
+	wp.Auth = &ap
+	wp.ID = wp.hash()
+
+	return nil
+}
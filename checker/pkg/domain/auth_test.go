@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestSetAuth(t *testing.T) {
+	c := qt.New(t)
+
+	oauth2 := AuthParams{
+		Type:         AuthOAuth2,
+		TokenURL:     "https://auth.foo.org/token",
+		ClientID:     "probe",
+		ClientSecret: "s3cr3t",
+		Scopes:       []string{"health:read"},
+	}
+
+	wp, err := NewWebsiteParams("https://api.foo.org/health", "", "")
+	c.Assert(err, qt.IsNil)
+	id := wp.ID
+	c.Assert(wp.SetAuth(oauth2), qt.IsNil)
+	c.Assert(wp.ID, qt.Not(qt.Equals), id)
+
+	c.Run("Secrets are not part of the ID nor the payload", func(c *qt.C) {
+		id := wp.ID
+		other := oauth2
+		other.ClientID, other.ClientSecret = "other", "0th3r"
+		c.Assert(wp.SetAuth(other), qt.IsNil)
+		c.Assert(wp.ID, qt.Equals, id)
+
+		blob, err := json.Marshal(wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(strings.Contains(string(blob), "0th3r"), qt.IsFalse)
+		c.Assert(strings.Contains(string(blob), `"token_url":"https://auth.foo.org/token"`), qt.IsTrue)
+	})
+
+	c.Run("NOK", func(c *qt.C) {
+		for _, test := range []struct {
+			auth AuthParams
+			err  string
+		}{
+			{AuthParams{Type: AuthBasic}, "basic auth requires a username"},
+			{AuthParams{Type: AuthBearer}, "bearer auth requires a token"},
+			{AuthParams{Type: AuthOAuth2, TokenURL: "/token", ClientID: "probe", ClientSecret: "s3cr3t"}, `OAuth2 auth requires an HTTP token URL: "/token" provided`},
+			{AuthParams{Type: AuthOAuth2, TokenURL: "https://auth.foo.org/token"}, "OAuth2 auth requires a client ID and secret"},
+			{AuthParams{Type: "digest"}, `unknown auth type "digest". Valid ones: \[basic bearer oauth2\]`},
+		} {
+			c.Check(wp.SetAuth(test.auth), qt.ErrorMatches, test.err)
+		}
+
+		wp, err := NewWebsiteParams("tcp://db.internal:5432", "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(wp.SetAuth(oauth2), qt.ErrorMatches, "auth is only supported by HTTP probes")
+	})
+}
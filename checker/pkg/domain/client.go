@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ClientParams tunes the HTTP client of HTTP probes. They are part of the ID
+// as they change what is checked, e.g. the IPv4 or IPv6 address of a website.
+type ClientParams struct {
+	// Proxy is the URL of an HTTP, HTTPS or SOCKS5 proxy. It may hold credentials
+	// so it is not part of the payload and its password is not part of the ID.
+	Proxy string `json:"-"`
+	// DisableKeepAlives opens a new connection per check to measure cold connections.
+	DisableKeepAlives bool `json:"disable_keep_alives,omitempty"`
+	// HTTPVersion forces HTTP/1.1 with "1.1" or HTTP/2 with "2".
+	HTTPVersion string `json:"http_version,omitempty"`
+	// IPVersion only connects to IPv4 addresses with 4 or IPv6 ones with 6.
+	IPVersion int    `json:"ip_version,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+	// CAFile is a PEM bundle of the trusted certificate authorities instead of the system ones.
+	CAFile string `json:"ca_file,omitempty"`
+	// CertFile and KeyFile are the PEM client certificate and key for mTLS.
+	CertFile           string `json:"cert_file,omitempty"`
+	KeyFile            string `json:"key_file,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+	// Resolve pins the address of host:port as curl --resolve host:port:ip.
+	Resolve []string `json:"resolve,omitempty"`
+}
+
+// HTTP versions of ClientParams.
+const (
+	HTTPVersion1 = "1.1"
+	HTTPVersion2 = "2"
+)
+
+// validate returns an error if a setting is invalid for the website URL.
+func (cp ClientParams) validate(u url.URL) error {
+	if cp.Proxy != "" {
+		p, err := url.Parse(cp.Proxy)
+		if err != nil {
+			return fmt.Errorf("can't parse proxy URL: %w", err)
+		}
+		switch p.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf(`unsupported proxy protocol "%s". Valid ones: [http https socks5]`, p.Scheme)
+		}
+	}
+
+	switch cp.HTTPVersion {
+	case "", HTTPVersion1:
+	case HTTPVersion2:
+		if u.Scheme != "https" {
+			return fmt.Errorf("HTTP/2 is only supported by https URLs")
+		}
+	default:
+		return fmt.Errorf(`unknown HTTP version "%s". Valid ones: [%s %s]`, cp.HTTPVersion, HTTPVersion1, HTTPVersion2)
+	}
+
+	switch cp.IPVersion {
+	case 0, 4, 6:
+	default:
+		return fmt.Errorf("unknown IP version %d. Valid ones: [4 6]", cp.IPVersion)
+	}
+
+	if (cp.CertFile == "") != (cp.KeyFile == "") {
+		return fmt.Errorf("client certificate requires both a cert and a key file")
+	}
+
+	_, err := cp.ResolvedAddrs()
+	return err
+}
+
+// ResolvedAddrs returns the pinned addresses by host:port.
+func (cp ClientParams) ResolvedAddrs() (map[string]string, error) {
+	if len(cp.Resolve) == 0 {
+		return nil, nil
+	}
+
+	addrs := make(map[string]string, len(cp.Resolve))
+	for _, r := range cp.Resolve {
+		parts := strings.SplitN(r, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("resolve %q must be host:port:ip", r)
+		}
+		host, port, rawIP := parts[0], parts[1], strings.Trim(parts[2], "[]")
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return nil, fmt.Errorf("resolve %q has an invalid port", r)
+		}
+		if net.ParseIP(rawIP) == nil {
+			return nil, fmt.Errorf("resolve %q has an invalid IP", r)
+		}
+		addrs[net.JoinHostPort(host, port)] = net.JoinHostPort(rawIP, port)
+	}
+	return addrs, nil
+}
+
+// key returns the part of the ID of the website defined by the client params, without secrets.
+func (cp ClientParams) key() string {
+	if p, err := url.Parse(cp.Proxy); err == nil {
+		cp.Proxy = p.Redacted()
+	}
+	return fmt.Sprintf("%+v", cp)
+}
+
+// SetClient tunes the HTTP client of an HTTP probe. It is part of the ID.
+func (wp *WebsiteParams) SetClient(cp ClientParams) error {
+	if wp.Probe != ProbeHTTP {
+		return fmt.Errorf("client settings are only supported by HTTP probes")
+	}
+	if err := cp.validate(wp.URL); err != nil {
+		return err
+	}
+
+	wp.Client = &cp
+	wp.ID = wp.hash()
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONPath selects a value of a JSON document. Only the child operators of the
+// JSONPath syntax are supported: $.a.b[0]['c d'].
+type JSONPath struct {
+	expr string
+	// keys are the object keys (string) or array indexes (int) from the root.
+	keys []interface{}
+}
+
+// NewJSONPath parses a JSONPath expression.
+func NewJSONPath(expr string) (*JSONPath, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("JSONPath %q must start with $", expr)
+	}
+
+	p := &JSONPath{expr: expr}
+	rest := expr[1:]
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("JSONPath %q has an empty key", expr)
+			}
+			p.keys = append(p.keys, name)
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, "['"):
+			end := strings.Index(rest, "']")
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q has an unterminated key", expr)
+			}
+			p.keys = append(p.keys, rest[2:end])
+			rest = rest[end+2:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q has an unterminated index", expr)
+			}
+			i, err := strconv.Atoi(rest[1:end])
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("JSONPath %q has an invalid index %q", expr, rest[1:end])
+			}
+			p.keys = append(p.keys, i)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("JSONPath %q is invalid at %q", expr, rest)
+		}
+	}
+
+	return p, nil
+}
+
+// String returns the expression of the path.
+func (p JSONPath) String() string {
+	return p.expr
+}
+
+// Eval returns the value selected in the JSON document. Strings are returned as is,
+// other values JSON encoded.
+func (p JSONPath) Eval(blob []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(blob))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", fmt.Errorf("can't decode JSON: %w", err)
+	}
+
+	for _, key := range p.keys {
+		switch k := key.(type) {
+		case string:
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return "", fmt.Errorf("%s: %q is not a key of a JSON object", p.expr, k)
+			}
+			if v, ok = obj[k]; !ok {
+				return "", fmt.Errorf("%s: key %q not found", p.expr, k)
+			}
+		case int:
+			arr, ok := v.([]interface{})
+			if !ok || k >= len(arr) {
+				return "", fmt.Errorf("%s: index %d not found", p.expr, k)
+			}
+			v = arr[k]
+		}
+	}
+
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case nil:
+		return "", fmt.Errorf("%s: value is null", p.expr)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("can't encode JSON: %w", err)
+	}
+	return string(out), nil
+}
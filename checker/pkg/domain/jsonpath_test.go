@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestJSONPath(t *testing.T) {
+	c := qt.New(t)
+
+	blob := []byte(`{"token": "abc", "user": {"id": 42, "roles": ["admin", "dev"], "full name": "Foo Bar", "meta": null}}`)
+	for _, test := range []struct {
+		expr  string
+		value string
+		err   string
+	}{
+		{"$.token", "abc", ""},
+		{"$.user.id", "42", ""},
+		{"$.user.roles[1]", "dev", ""},
+		{"$.user.roles", `["admin","dev"]`, ""},
+		{"$['user']['full name']", "Foo Bar", ""},
+		{"$.user.roles[2]", "", `\$.user.roles\[2\]: index 2 not found`},
+		{"$.user.email", "", `\$.user.email: key "email" not found`},
+		{"$.token.id", "", `\$.token.id: "id" is not a key of a JSON object`},
+		{"$.user.meta", "", `\$.user.meta: value is null`},
+	} {
+		p, err := NewJSONPath(test.expr)
+		c.Assert(err, qt.IsNil)
+		c.Check(p.String(), qt.Equals, test.expr)
+
+		v, err := p.Eval(blob)
+		if test.err != "" {
+			c.Check(err, qt.ErrorMatches, test.err)
+			continue
+		}
+		c.Check(err, qt.IsNil, qt.Commentf(test.expr))
+		c.Check(v, qt.Equals, test.value, qt.Commentf(test.expr))
+	}
+
+	for expr, err := range map[string]string{
+		"token":       `JSONPath "token" must start with \$`,
+		"$..token":    `JSONPath "\$..token" has an empty key`,
+		"$['token'":   `JSONPath "\$\['token'" has an unterminated key`,
+		"$.roles[-1]": `JSONPath "\$.roles\[-1\]" has an invalid index "-1"`,
+		"$token":      `JSONPath "\$token" is invalid at "token"`,
+	} {
+		_, e := NewJSONPath(expr)
+		c.Check(e, qt.ErrorMatches, err)
+	}
+
+	p, err := NewJSONPath("$.token")
+	c.Assert(err, qt.IsNil)
+	_, err = p.Eval([]byte("<html>"))
+	c.Assert(err, qt.ErrorMatches, "can't decode JSON: .*")
+}
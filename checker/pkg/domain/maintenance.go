@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// MaintenanceWindow defines a period of time where a website is under maintenance.
+// It is either one-off, from Start to End, or recurring, lasting Duration every time
+// Schedule is activated.
+type MaintenanceWindow struct {
+	Start    time.Time
+	End      time.Time
+	Schedule cron.Schedule
+	Duration time.Duration
+}
+
+// NewMaintenanceWindow creates a new MaintenanceWindow parsing input strings.
+// rawStart and rawEnd are RFC 3339 formatted times for one-off windows.
+// rawCron is a standard cron expression and rawDuration a Go duration for recurring windows.
+func NewMaintenanceWindow(rawStart, rawEnd, rawCron, rawDuration string) (*MaintenanceWindow, error) {
+	switch {
+	case rawCron != "" && (rawStart != "" || rawEnd != ""):
+		return nil, fmt.Errorf("maintenance window must be either one-off or recurring")
+	case rawCron != "":
+		schedule, err := cron.ParseStandard(rawCron)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse cron: %w", err)
+		}
+		duration, err := time.ParseDuration(rawDuration)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse duration: %w", err)
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("duration must be positive: %s provided", duration)
+		}
+		return &MaintenanceWindow{Schedule: schedule, Duration: duration}, nil
+	}
+
+	start, err := time.Parse(time.RFC3339, rawStart)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse start: %w", err)
+	}
+	end, err := time.Parse(time.RFC3339, rawEnd)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse end: %w", err)
+	}
+	if !end.After(start) {
+		return nil, fmt.Errorf("end must be after start")
+	}
+
+	return &MaintenanceWindow{Start: start, End: end}, nil
+}
+
+// Active returns true if the maintenance window is active at the given time.
+func (mw MaintenanceWindow) Active(at time.Time) bool {
+	if mw.Schedule != nil {
+		// The last activation before at within the duration
+		return !mw.Schedule.Next(at.Add(-mw.Duration)).After(at)
+	}
+	return !at.Before(mw.Start) && at.Before(mw.End)
+}
+
+// InMaintenance returns true if any of the website maintenance windows is active at the given time.
+func (wp WebsiteParams) InMaintenance(at time.Time) bool {
+	for _, mw := range wp.Maintenance {
+		if mw.Active(at) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestMaintenanceWindow(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("One-off", func(c *qt.C) {
+		mw, err := NewMaintenanceWindow("2021-05-21T22:00:00Z", "2021-05-22T02:00:00Z", "", "")
+		c.Assert(err, qt.IsNil)
+
+		c.Assert(mw.Active(time.Date(2021, 5, 21, 21, 59, 59, 0, time.UTC)), qt.IsFalse)
+		c.Assert(mw.Active(time.Date(2021, 5, 21, 22, 0, 0, 0, time.UTC)), qt.IsTrue)
+		c.Assert(mw.Active(time.Date(2021, 5, 22, 1, 0, 0, 0, time.UTC)), qt.IsTrue)
+		c.Assert(mw.Active(time.Date(2021, 5, 22, 2, 0, 0, 0, time.UTC)), qt.IsFalse)
+	})
+
+	c.Run("Recurring", func(c *qt.C) {
+		// Every Sunday at 03:00 for 2 hours
+		mw, err := NewMaintenanceWindow("", "", "CRON_TZ=UTC 0 3 * * SUN", "2h")
+		c.Assert(err, qt.IsNil)
+
+		c.Assert(mw.Active(time.Date(2021, 5, 23, 2, 59, 0, 0, time.UTC)), qt.IsFalse)
+		c.Assert(mw.Active(time.Date(2021, 5, 23, 3, 0, 0, 0, time.UTC)), qt.IsTrue)
+		c.Assert(mw.Active(time.Date(2021, 5, 23, 4, 59, 59, 0, time.UTC)), qt.IsTrue)
+		c.Assert(mw.Active(time.Date(2021, 5, 23, 5, 0, 0, 0, time.UTC)), qt.IsFalse)
+		c.Assert(mw.Active(time.Date(2021, 5, 24, 3, 30, 0, 0, time.UTC)), qt.IsFalse, qt.Commentf("Monday"))
+		c.Assert(mw.Active(time.Date(2021, 5, 30, 3, 30, 0, 0, time.UTC)), qt.IsTrue, qt.Commentf("next Sunday"))
+	})
+
+	c.Run("NOK", func(c *qt.C) {
+		tests := []struct {
+			Name                       string
+			Start, End, Cron, Duration string
+			Error                      string
+		}{
+			{Name: "empty", Error: `can't parse start: .*`},
+			{Name: "both", Start: "2021-05-21T22:00:00Z", Cron: "@daily", Duration: "1h", Error: `maintenance window must be either one-off or recurring`},
+			{Name: "end before start", Start: "2021-05-21T22:00:00Z", End: "2021-05-21T21:00:00Z", Error: `end must be after start`},
+			{Name: "wrong cron", Cron: "foo", Duration: "1h", Error: `can't parse cron: .*`},
+			{Name: "missing duration", Cron: "@daily", Error: `can't parse duration: .*`},
+			{Name: "negative duration", Cron: "@daily", Duration: "-1h", Error: `duration must be positive: -1h0m0s provided`},
+		}
+		for _, st := range tests {
+			c.Run(st.Name, func(c *qt.C) {
+				mw, err := NewMaintenanceWindow(st.Start, st.End, st.Cron, st.Duration)
+				c.Assert(err, qt.ErrorMatches, st.Error)
+				c.Assert(mw, qt.IsNil)
+			})
+		}
+	})
+}
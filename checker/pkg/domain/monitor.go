@@ -2,19 +2,50 @@ package domain
 
 import (
 	"context"
+	"fmt"
+	"net"
 	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// delayTicks definms the stacked work a worker can have due to delays
-const delayTicks = 2
+const (
+	// delayTicks definms the stacked work a worker can have due to delays
+	delayTicks = 2
+	tracerName = "github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
 
 // Checker is in charge of checks website availability
 type Checker struct {
 	FetchWebsiteResult func(ctx context.Context, wp WebsiteParams) (*WebsiteResult, error)
-	ProduceResult      func(wp WebsiteParams, wr WebsiteResult) error
+	ProduceResult      func(ctx context.Context, wp WebsiteParams, wr WebsiteResult) error
+	// Location identifies where this checker runs from. It is set in every result.
+	Location string
+	// ID identifies this checker among the ones running from the same location. It is set in every result.
+	ID string
+	// SkipMaintenance skips the checks of websites under maintenance instead of marking their results.
+	SkipMaintenance bool
+	// Observer is optionally notified about every check.
+	Observer CheckObserver
+	// LookupIPAddr resolves the hosts of the websites checked per IP. Nil uses net.DefaultResolver.
+	LookupIPAddr func(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// CheckObserver is notified about the checks performed by a Checker.
+type CheckObserver interface {
+	// CheckExecuted is called after every fetch. failed is true if the fetch
+	// returned an error or the result is failed.
+	CheckExecuted(wp WebsiteParams, elapsed time.Duration, failed bool)
+	// CheckSkipped is called when a check is skipped due to maintenance.
+	CheckSkipped(wp WebsiteParams)
+	// CheckLate is called when a check can't start on time because the
+	// previous one of the same website is still running.
+	CheckLate(wp WebsiteParams)
 }
 
 // Monitor periodically checks websites indefinitely
@@ -34,7 +65,14 @@ loop:
 		select {
 		case <-time.After(tick):
 			for i, wp := range websites {
-				work[i] <- wp
+				select {
+				case work[i] <- wp:
+				default:
+					if c.Observer != nil {
+						c.Observer.CheckLate(wp)
+					}
+					work[i] <- wp
+				}
 			}
 		case <-ctx.Done():
 			err := ctx.Err()
@@ -60,19 +98,102 @@ func (c *Checker) worker(id int, work <-chan WebsiteParams, wg *sync.WaitGroup,
 	defer wg.Done()
 
 	for wp := range work {
-		log.Log().Int("id", id).Msgf("%+v", wp)
-		ctx, cancel := context.WithTimeout(context.Background(), maxProcessingTime)
-		wr, err := c.FetchWebsiteResult(ctx, wp)
-		if err != nil {
-			log.Error().Err(err).Str("url", wp.URL.String()).Msg("can't fetch result")
-			cancel()
+		// The params may hold credentials, only the URL without password is logged
+		log.Log().Int("worker", id).Str("website_id", wp.ID).Str("url", wp.URL.Redacted()).Msg("checking website")
+		inMaintenance := wp.InMaintenance(time.Now())
+		if inMaintenance && c.SkipMaintenance {
+			log.Log().Str("url", wp.URL.Redacted()).Msg("skipping check under maintenance")
+			if c.Observer != nil {
+				c.Observer.CheckSkipped(wp)
+			}
 			continue
 		}
-		err = c.ProduceResult(wp, *wr)
-		cancel()
-		if err != nil {
-			log.Error().Err(err).Msg("can't produce result")
-		}
-		log.Log().Msgf("%+v check: %+v", wp, wr)
+		c.check(wp, inMaintenance, maxProcessingTime)
+	}
+}
+
+// check fetches the website result and produces it within a trace.
+func (c *Checker) check(wp WebsiteParams, inMaintenance bool, maxProcessingTime time.Duration) {
+	ctx, span := otel.Tracer(tracerName).Start(context.Background(), "check",
+		trace.WithAttributes(
+			attribute.String("website.id", wp.ID),
+			attribute.String("http.url", wp.URL.Redacted()),
+			attribute.String("http.method", string(wp.Method)),
+			attribute.Bool("website.in_maintenance", inMaintenance),
+		))
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, maxProcessingTime)
+	defer cancel()
+
+	if !wp.PerIP {
+		c.fetchAndProduce(ctx, span, wp, inMaintenance)
+		return
+	}
+
+	addrs, err := c.lookupIPAddr(ctx, wp.URL.Hostname())
+	if err != nil {
+		// The website is down for every IP
+		log.Error().Err(err).Str("url", wp.URL.Redacted()).Msg("can't resolve host")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "can't resolve host")
+		msg := fmt.Sprintf("can't resolve host: %s", err)
+		c.produce(ctx, span, wp, WebsiteResult{Probe: wp.Probe, Error: &msg, At: time.Now().UTC()}, inMaintenance)
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(addrs))
+	for _, addr := range addrs {
+		go func(addr net.IPAddr) {
+			defer wg.Done()
+			wpAddr := wp
+			wpAddr.Addr = addr.String()
+			c.fetchAndProduce(ctx, span, wpAddr, inMaintenance)
+		}(addr)
+	}
+	wg.Wait()
+}
+
+// lookupIPAddr resolves the host with LookupIPAddr or net.DefaultResolver.
+func (c *Checker) lookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if c.LookupIPAddr != nil {
+		return c.LookupIPAddr(ctx, host)
+	}
+	return net.DefaultResolver.LookupIPAddr(ctx, host)
+}
+
+// fetchAndProduce fetches the website result and produces it.
+func (c *Checker) fetchAndProduce(ctx context.Context, span trace.Span, wp WebsiteParams, inMaintenance bool) {
+	start := time.Now()
+	wr, err := c.FetchWebsiteResult(ctx, wp)
+	if c.Observer != nil {
+		c.Observer.CheckExecuted(wp, time.Since(start), err != nil || wr.Failed())
+	}
+	if err != nil {
+		log.Error().Err(err).Str("url", wp.URL.Redacted()).Msg("can't fetch result")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "can't fetch result")
+		return
+	}
+	wr.IP = wp.Addr
+	c.produce(ctx, span, wp, *wr, inMaintenance)
+}
+
+// produce sets the location and the maintenance of the result and produces it.
+func (c *Checker) produce(ctx context.Context, span trace.Span, wp WebsiteParams, wr WebsiteResult, inMaintenance bool) {
+	if wr.Failed() {
+		span.SetStatus(codes.Error, "check failed")
+	}
+
+	wr.Location = c.Location
+	wr.CheckerID = c.ID
+	wr.InMaintenance = inMaintenance
+	err := c.ProduceResult(ctx, wp, wr)
+	if err != nil {
+		log.Error().Err(err).Msg("can't produce result")
+		span.RecordError(err)
 	}
+	log.Log().Str("website_id", wp.ID).Str("url", wp.URL.Redacted()).Str("ip", wr.IP).
+		Bool("failed", wr.Failed()).Dur("elapsed", wr.Elapsed).Msg("website checked")
 }
@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultProbeTimeout is the timeout of the probe modules not setting it.
+const DefaultProbeTimeout = 10 * time.Second
+
+// DefaultProbeModule is the module used when none is requested, named after the
+// blackbox_exporter default one.
+var DefaultProbeModule = ProbeModule{Name: "http_2xx", Method: HTTPMethodGet, Timeout: DefaultProbeTimeout}
+
+// ProbeModule defines how to check a target on demand.
+type ProbeModule struct {
+	Name        string
+	Method      HTTPMethod
+	MatchRegexp *regexp.Regexp
+	Timeout     time.Duration
+}
+
+// NewProbeModule creates a new ProbeModule parsing input strings.
+// An empty rawMethod will set Get HTTP method and an empty rawTimeout DefaultProbeTimeout.
+func NewProbeModule(name, rawMethod, rawRegexp, rawTimeout string) (*ProbeModule, error) {
+	if name == "" {
+		return nil, fmt.Errorf("module name is required")
+	}
+
+	m := &ProbeModule{Name: name, Method: HTTPMethodGet, Timeout: DefaultProbeTimeout}
+
+	var err error
+	if rawMethod != "" {
+		m.Method, err = NewHTTPMethod(rawMethod)
+		if err != nil {
+			return nil, fmt.Errorf("can't create HTTP method: %w", err)
+		}
+	}
+
+	if rawRegexp != "" {
+		m.MatchRegexp, err = regexp.Compile(rawRegexp)
+		if err != nil {
+			return nil, fmt.Errorf("can't compile regexp: %w", err)
+		}
+	}
+
+	if rawTimeout != "" {
+		m.Timeout, err = time.ParseDuration(rawTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse timeout: %w", err)
+		}
+		if m.Timeout <= 0 {
+			return nil, fmt.Errorf("timeout must be positive")
+		}
+	}
+
+	return m, nil
+}
+
+// WebsiteParams returns the params to check the target with this module.
+// The method and the regexp only apply to HTTP targets. As with blackbox_exporter,
+// a target without scheme such as example.com:8080 is checked over http://.
+func (m ProbeModule) WebsiteParams(target string) (*WebsiteParams, error) {
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse URL: %w", err)
+	}
+	if probeSchemes[u.Scheme] != ProbeHTTP {
+		return NewWebsiteParams(target, "", "")
+	}
+
+	var rawRegexp string
+	if m.MatchRegexp != nil {
+		rawRegexp = m.MatchRegexp.String()
+	}
+	return NewWebsiteParams(target, string(m.Method), rawRegexp)
+}
@@ -0,0 +1,166 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ProbeType defines the kind of check performed on a website. It is given by the URL scheme.
+type ProbeType string
+
+const (
+	// ProbeHTTP requests http:// and https:// URLs.
+	ProbeHTTP ProbeType = "http"
+	// ProbeTCP connects to tcp://host:port URLs.
+	ProbeTCP ProbeType = "tcp"
+	// ProbeTLS performs a TLS handshake with tls://host[:port] URLs and validates the certificate.
+	ProbeTLS ProbeType = "tls"
+	// ProbeDNS resolves dns://[resolver[:port]]/name?type=A URLs.
+	ProbeDNS ProbeType = "dns"
+	// ProbeGRPC calls the gRPC health service of grpc://host:port[/service] URLs,
+	// over TLS with grpcs:// ones.
+	ProbeGRPC ProbeType = "grpc"
+	// ProbeWebSocket opens a WebSocket with ws:// and wss:// URLs and optionally exchanges a message.
+	ProbeWebSocket ProbeType = "websocket"
+)
+
+// probeSchemes maps the URL schemes to their probe type.
+var probeSchemes = map[string]ProbeType{
+	"http":  ProbeHTTP,
+	"https": ProbeHTTP,
+	"tcp":   ProbeTCP,
+	"tls":   ProbeTLS,
+	"dns":   ProbeDNS,
+	"grpc":  ProbeGRPC,
+	"grpcs": ProbeGRPC,
+	"ws":    ProbeWebSocket,
+	"wss":   ProbeWebSocket,
+}
+
+// DNSRecordType defines the DNS records a DNS probe resolves.
+type DNSRecordType string
+
+const (
+	DNSRecordA     DNSRecordType = "A"
+	DNSRecordAAAA  DNSRecordType = "AAAA"
+	DNSRecordCNAME DNSRecordType = "CNAME"
+	DNSRecordTXT   DNSRecordType = "TXT"
+)
+
+// NewDNSRecordType creates a new DNSRecordType based on a case-insensitive string.
+// An empty string returns A records.
+func NewDNSRecordType(in string) (DNSRecordType, error) {
+	if in == "" {
+		return DNSRecordA, nil
+	}
+	t := DNSRecordType(strings.ToUpper(in))
+	switch t {
+	case DNSRecordA, DNSRecordAAAA, DNSRecordCNAME, DNSRecordTXT:
+		return t, nil
+	}
+	return "", fmt.Errorf(`unknown DNS record type "%s". Valid ones: %s`, in,
+		[]DNSRecordType{DNSRecordA, DNSRecordAAAA, DNSRecordCNAME, DNSRecordTXT})
+}
+
+// DNSParams defines the settings of the DNS probes.
+type DNSParams struct {
+	RecordType DNSRecordType `json:"record_type"`
+	// Expected are the answers which must be resolved. Other answers are allowed.
+	Expected []string `json:"expected,omitempty"`
+}
+
+// TLSParams defines the settings of the TLS probes.
+type TLSParams struct {
+	// MinCertValidity fails the check if the certificate expires within this duration.
+	MinCertValidity time.Duration `json:"min_cert_validity,omitempty"`
+}
+
+// GRPCParams defines the settings of the gRPC probes.
+type GRPCParams struct {
+	// Service is the name of the checked service. Empty checks the server health.
+	Service string `json:"service,omitempty"`
+	TLS     bool   `json:"tls"`
+	// Metadata is sent with the health check. It may hold credentials so it is neither
+	// part of the payload nor its values part of the ID.
+	Metadata map[string]string `json:"-"`
+}
+
+// ProbeOptions defines the settings specific to the non HTTP probe types.
+// Zero values are ignored.
+type ProbeOptions struct {
+	// Expected answers of DNS probes.
+	Expected []string
+	// MinCertValidity of TLS probes as a duration string.
+	MinCertValidity string
+	// Metadata of gRPC probes.
+	Metadata map[string]string
+	// Send is the message sent by WebSocket probes.
+	Send string
+}
+
+// WebSocketParams defines the settings of the WebSocket probes.
+type WebSocketParams struct {
+	// Send is the text message sent once connected. The first message received
+	// is matched against the regular expression of the website, if any.
+	Send string `json:"send,omitempty"`
+}
+
+// TLSResult defines the handshake details of a TLS probe.
+type TLSResult struct {
+	Version     string    `json:"version"`
+	CipherSuite string    `json:"cipher_suite"`
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	NotAfter    time.Time `json:"not_after"`
+	// Verified says if the certificate chain is trusted and valid for the host.
+	Verified bool `json:"verified"`
+}
+
+// DNSResult defines the answers of a DNS probe.
+type DNSResult struct {
+	Answers []string `json:"answers"`
+}
+
+// GRPCResult defines the answer of a gRPC probe.
+type GRPCResult struct {
+	// Code is the gRPC status code of the health check call.
+	Code int `json:"code"`
+	// ServingStatus is the health of the service, e.g. SERVING or NOT_SERVING, if the call succeeded.
+	ServingStatus string `json:"serving_status,omitempty"`
+}
+
+// WebSocketResult defines the timings of a WebSocket probe.
+type WebSocketResult struct {
+	// Handshake is the time to open the WebSocket.
+	Handshake time.Duration `json:"handshake"`
+	// ReplyLatency is the time between the message sent, or the handshake if none,
+	// and the first message received. It is only set if a reply was awaited and received.
+	ReplyLatency *time.Duration `json:"reply_latency,omitempty"`
+	// CloseCode is the status code of the close frame sent by the server, if any.
+	CloseCode *int `json:"close_code,omitempty"`
+}
+
+// FetchFn fetches the result of a website check.
+type FetchFn func(ctx context.Context, wp WebsiteParams) (*WebsiteResult, error)
+
+// Fetchers dispatches the checks to the fetcher of their probe type.
+type Fetchers map[ProbeType]FetchFn
+
+// FetchWebsiteResult fetches the result with the fetcher of the website probe type.
+// It has the signature of Checker.FetchWebsiteResult.
+func (f Fetchers) FetchWebsiteResult(ctx context.Context, wp WebsiteParams) (*WebsiteResult, error) {
+	fetch, ok := f[wp.Probe]
+	if !ok {
+		return nil, fmt.Errorf("no fetcher for %s probes", wp.Probe)
+	}
+
+	wr, err := fetch(ctx, wp)
+	if err != nil {
+		return nil, err
+	}
+	wr.Probe = wp.Probe
+
+	return wr, nil
+}
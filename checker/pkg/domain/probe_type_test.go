@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestNewWebsiteParamsProbes(t *testing.T) {
+	c := qt.New(t)
+
+	for _, test := range []struct {
+		rawURL string
+		probe  ProbeType
+		err    string
+	}{
+		{"https://foo.org", ProbeHTTP, ""},
+		{"tcp://db.internal:5432", ProbeTCP, ""},
+		{"tcp://db.internal", "", "TCP probes require host and port: tcp://db.internal provided"},
+		{"tls://foo.org", ProbeTLS, ""},
+		{"tls:///path", "", "TLS probes require a host: tls:///path provided"},
+		{"dns:///foo.org", ProbeDNS, ""},
+		{"dns://1.1.1.1", "", "DNS probes require a name to resolve: dns://1.1.1.1 provided"},
+		{"dns://1.1.1.1/foo.org?type=mx", "", `can't create DNS record type: unknown DNS record type "mx". Valid ones: \[A AAAA CNAME TXT\]`},
+		{"icmp://foo.org", "", `unsupported protocol "icmp". Valid ones: \[dns grpc grpcs http https tcp tls ws wss\]`},
+	} {
+		wp, err := NewWebsiteParams(test.rawURL, "", "")
+		if test.err != "" {
+			c.Check(err, qt.ErrorMatches, test.err)
+			continue
+		}
+		c.Assert(err, qt.IsNil)
+		c.Check(wp.Probe, qt.Equals, test.probe, qt.Commentf(test.rawURL))
+	}
+
+	_, err := NewWebsiteParams("tcp://db.internal:5432", "HEAD", "")
+	c.Assert(err, qt.ErrorMatches, "method is only supported by HTTP probes")
+
+	wp, err := NewWebsiteParams("tcp://db.internal:5432", "", "")
+	c.Assert(err, qt.IsNil)
+	c.Assert(wp.SetProbeOptions(ProbeOptions{Expected: []string{"1.2.3.4"}}), qt.ErrorMatches, "expected answers are only supported by DNS probes")
+	c.Assert(wp.SetProbeOptions(ProbeOptions{MinCertValidity: "24h"}), qt.ErrorMatches, "minimum certificate validity is only supported by TLS probes")
+	c.Assert(wp.SetProbeOptions(ProbeOptions{Send: "ping"}), qt.ErrorMatches, "messages to send are only supported by WebSocket probes")
+
+	wp, err = NewWebsiteParams("wss://rt.foo.org/socket", "", "pong")
+	c.Assert(err, qt.IsNil)
+	c.Assert(wp.Probe, qt.Equals, ProbeWebSocket)
+	id := wp.ID
+	c.Assert(wp.SetProbeOptions(ProbeOptions{Send: "ping"}), qt.IsNil)
+	c.Assert(wp.WebSocket, qt.DeepEquals, &WebSocketParams{Send: "ping"})
+	c.Assert(wp.ID, qt.Not(qt.Equals), id)
+}
+
+func TestFetchers(t *testing.T) {
+	c := qt.New(t)
+
+	errMsg := "connection refused"
+	fetchers := Fetchers{
+		ProbeTCP: func(ctx context.Context, wp WebsiteParams) (*WebsiteResult, error) {
+			return &WebsiteResult{Error: &errMsg}, nil
+		},
+	}
+
+	wp, err := NewWebsiteParams("tcp://db.internal:5432", "", "")
+	c.Assert(err, qt.IsNil)
+	wr, err := fetchers.FetchWebsiteResult(context.Background(), *wp)
+	c.Assert(err, qt.IsNil)
+	c.Assert(wr.Probe, qt.Equals, ProbeTCP)
+	c.Assert(wr.Failed(), qt.IsTrue)
+
+	wp, err = NewWebsiteParams("dns:///foo.org", "", "")
+	c.Assert(err, qt.IsNil)
+	_, err = fetchers.FetchWebsiteResult(context.Background(), *wp)
+	c.Assert(err, qt.ErrorMatches, "no fetcher for dns probes")
+}
+
+func TestWebsiteResultFailed(t *testing.T) {
+	c := qt.New(t)
+
+	ok, notFound, no := 200, 404, false
+	errMsg := "boom"
+	for _, test := range []struct {
+		wr     WebsiteResult
+		failed bool
+	}{
+		{WebsiteResult{Status: &ok}, false},
+		{WebsiteResult{Status: &notFound}, true},
+		{WebsiteResult{}, true},
+		{WebsiteResult{Probe: ProbeHTTP, Status: &ok, Matched: &no}, true},
+		{WebsiteResult{Probe: ProbeTCP}, false},
+		{WebsiteResult{Probe: ProbeTCP, Unreachable: true}, true},
+		{WebsiteResult{Probe: ProbeTLS, Error: &errMsg}, true},
+		{WebsiteResult{Probe: ProbeDNS, Matched: &no}, true},
+	} {
+		c.Check(test.wr.Failed(), qt.Equals, test.failed, qt.Commentf("%+v", test.wr))
+	}
+}
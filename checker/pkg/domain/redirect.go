@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultMaxRedirects is the number of redirects HTTP probes follow by default, as http.Client.
+const DefaultMaxRedirects = 10
+
+// RedirectParams defines how HTTP probes follow redirects.
+type RedirectParams struct {
+	// Max is the maximum number of redirects to follow, the check fails if more are
+	// required. Zero does not follow them: the redirect response is the result of the check.
+	Max int `json:"max"`
+	// ExpectedURL is the URL the redirects must end at, if any.
+	ExpectedURL string `json:"expected_url,omitempty"`
+}
+
+// SetRedirects sets how an HTTP probe follows redirects and the URL they
+// must end at. It is part of the ID.
+func (wp *WebsiteParams) SetRedirects(rp RedirectParams) error {
+	if wp.Probe != ProbeHTTP {
+		return fmt.Errorf("redirects are only supported by HTTP probes")
+	}
+	if rp.Max < 0 {
+		return fmt.Errorf("maximum number of redirects must be positive: %d provided", rp.Max)
+	}
+	if rp.ExpectedURL != "" {
+		u, err := wp.URL.Parse(rp.ExpectedURL)
+		if err != nil {
+			return fmt.Errorf("can't parse expected URL: %w", err)
+		}
+		rp.ExpectedURL = u.String()
+	}
+
+	wp.Redirects = &rp
+	wp.ID = wp.hash()
+
+	return nil
+}
+
+// MaxRedirects returns the maximum number of redirects the probe follows.
+func (wp WebsiteParams) MaxRedirects() int {
+	if wp.Redirects == nil {
+		return DefaultMaxRedirects
+	}
+	return wp.Redirects.Max
+}
+
+// key returns the part of the ID of the website defined by the redirect params.
+// The default ones are empty so setting them explicitly keeps the ID.
+func (rp RedirectParams) key() string {
+	if rp == (RedirectParams{Max: DefaultMaxRedirects}) {
+		return ""
+	}
+	return fmt.Sprintf("%d%s", rp.Max, rp.ExpectedURL)
+}
+
+// Redirect defines a hop of the redirect chain of an HTTP probe.
+type Redirect struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	// Elapsed is the time to get the redirect response.
+	Elapsed time.Duration `json:"elapsed"`
+}
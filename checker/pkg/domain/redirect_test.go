@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestSetRedirects(t *testing.T) {
+	c := qt.New(t)
+
+	wp, err := NewWebsiteParams("http://foo.org/account", "", "")
+	c.Assert(err, qt.IsNil)
+	c.Assert(wp.MaxRedirects(), qt.Equals, DefaultMaxRedirects)
+
+	id := wp.ID
+	c.Assert(wp.SetRedirects(RedirectParams{Max: DefaultMaxRedirects}), qt.IsNil)
+	c.Assert(wp.ID, qt.Equals, id, qt.Commentf("the default redirects keep the ID"))
+
+	c.Assert(wp.SetRedirects(RedirectParams{Max: 2, ExpectedURL: "/account/home"}), qt.IsNil)
+	c.Assert(wp.Redirects, qt.DeepEquals, &RedirectParams{Max: 2, ExpectedURL: "http://foo.org/account/home"})
+	c.Assert(wp.MaxRedirects(), qt.Equals, 2)
+	c.Assert(wp.ID, qt.Not(qt.Equals), id)
+
+	c.Assert(wp.SetRedirects(RedirectParams{Max: -1}), qt.ErrorMatches, "maximum number of redirects must be positive: -1 provided")
+	c.Assert(wp.SetRedirects(RedirectParams{ExpectedURL: ":"}), qt.ErrorMatches, "can't parse expected URL: .*")
+
+	wp, err = NewWebsiteParams("tls://foo.org", "", "")
+	c.Assert(err, qt.IsNil)
+	c.Assert(wp.SetRedirects(RedirectParams{}), qt.ErrorMatches, "redirects are only supported by HTTP probes")
+}
@@ -0,0 +1,203 @@
+package domain
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stepMethods are the HTTP methods allowed in the steps of a multi-step check.
+var stepMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// varRegexp matches the {{name}} references to the variables extracted by the previous steps.
+var varRegexp = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// varNameRegexp matches the valid variable names.
+var varNameRegexp = regexp.MustCompile(`^\w+$`)
+
+// Step defines a request of a multi-step check. Its URL, header values and body may
+// reference the variables extracted by the previous steps as {{name}}.
+type Step struct {
+	Name   string `json:"name,omitempty"`
+	Method string `json:"method"`
+	// URL is resolved against the URL of the website.
+	URL string `json:"url"`
+	// Headers and Body may hold credentials so they are not part of the payload and
+	// only the header names are part of the ID.
+	Headers     map[string]string `json:"-"`
+	Body        string            `json:"-"`
+	MatchRegexp *regexp.Regexp    `json:"-"`
+	Extract     []Extraction      `json:"-"`
+}
+
+// NewStep creates a new Step parsing input strings.
+// An empty rawMethod will set Get HTTP method.
+// An empty rawRegexp will not generate any regular expression.
+func NewStep(name, rawMethod, rawURL, rawRegexp string) (*Step, error) {
+	if rawURL == "" {
+		return nil, fmt.Errorf("step URL is required")
+	}
+
+	s := &Step{Name: name, Method: http.MethodGet, URL: rawURL}
+	if rawMethod != "" {
+		s.Method = strings.ToUpper(rawMethod)
+		if !containsString(stepMethods, s.Method) {
+			return nil, fmt.Errorf(`unknown HTTP method "%s". Valid ones: %s`, rawMethod, stepMethods)
+		}
+	}
+
+	if rawRegexp != "" {
+		var err error
+		s.MatchRegexp, err = regexp.Compile(rawRegexp)
+		if err != nil {
+			return nil, fmt.Errorf("can't compile regexp: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// vars returns the names of the variables referenced by the step.
+func (s Step) vars() []string {
+	texts := []string{s.URL, s.Body}
+	for _, v := range s.Headers {
+		texts = append(texts, v)
+	}
+
+	var names []string
+	for _, t := range texts {
+		for _, m := range varRegexp.FindAllStringSubmatch(t, -1) {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// key returns the part of the ID of the website defined by the step.
+func (s Step) key() string {
+	parts := []string{s.Method, s.URL}
+
+	headers := make([]string, 0, len(s.Headers))
+	for h := range s.Headers {
+		headers = append(headers, http.CanonicalHeaderKey(h))
+	}
+	sort.Strings(headers)
+	parts = append(parts, headers...)
+
+	if s.MatchRegexp != nil {
+		parts = append(parts, s.MatchRegexp.String())
+	}
+	for _, e := range s.Extract {
+		parts = append(parts, e.Var, string(e.Source), e.Expr)
+	}
+	return strings.Join(parts, "|")
+}
+
+// ExpandVars replaces the {{name}} references in s by the value of the variables.
+func ExpandVars(s string, vars map[string]string) (string, error) {
+	var err error
+	expanded := varRegexp.ReplaceAllStringFunc(s, func(ref string) string {
+		name := varRegexp.FindStringSubmatch(ref)[1]
+		v, ok := vars[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("undefined variable %q", name)
+		}
+		return v
+	})
+	return expanded, err
+}
+
+// ExtractSource defines where a step extracts a variable from.
+type ExtractSource string
+
+const (
+	// ExtractRegexp extracts the first capture group of a regular expression matching the body.
+	ExtractRegexp ExtractSource = "regexp"
+	// ExtractJSONPath extracts a value of the JSON body.
+	ExtractJSONPath ExtractSource = "jsonpath"
+	// ExtractHeader extracts a response header.
+	ExtractHeader ExtractSource = "header"
+	// ExtractCookie extracts a cookie of the cookie jar.
+	ExtractCookie ExtractSource = "cookie"
+)
+
+// Extraction defines a variable extracted from the response of a step.
+type Extraction struct {
+	Var    string
+	Source ExtractSource
+	// Expr is the regular expression, the JSONPath, the header or the cookie name.
+	Expr string
+	// Regexp and JSONPath are the parsed expressions of their sources.
+	Regexp   *regexp.Regexp
+	JSONPath *JSONPath
+}
+
+// NewExtraction creates a new Extraction of a variable from the source parsing its expression.
+func NewExtraction(name string, source ExtractSource, expr string) (*Extraction, error) {
+	if !varNameRegexp.MatchString(name) {
+		return nil, fmt.Errorf("invalid variable name %q", name)
+	}
+	if expr == "" {
+		return nil, fmt.Errorf("missing %s to extract %s", source, name)
+	}
+
+	e := &Extraction{Var: name, Source: source, Expr: expr}
+	var err error
+	switch source {
+	case ExtractRegexp:
+		e.Regexp, err = regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("can't compile regexp: %w", err)
+		}
+		if e.Regexp.NumSubexp() < 1 {
+			return nil, fmt.Errorf("regexp %q of %s has no capture group", expr, name)
+		}
+	case ExtractJSONPath:
+		e.JSONPath, err = NewJSONPath(expr)
+		if err != nil {
+			return nil, err
+		}
+	case ExtractHeader, ExtractCookie:
+	default:
+		return nil, fmt.Errorf(`unknown extraction source "%s". Valid ones: %s`, source,
+			[]ExtractSource{ExtractRegexp, ExtractJSONPath, ExtractHeader, ExtractCookie})
+	}
+
+	return e, nil
+}
+
+// StepResult defines the result of a step of a multi-step check.
+type StepResult struct {
+	Name    string        `json:"name,omitempty"`
+	Elapsed time.Duration `json:"elapsed"`
+	Status  *int          `json:"status"`
+	Matched *bool         `json:"matched"`
+	// Error describes why the step failed besides its status or regexp.
+	Error *string `json:"error,omitempty"`
+	// BodySize is the number of bytes of the response body read, up to the maximum body size.
+	BodySize int64 `json:"body_size,omitempty"`
+	// BodyTruncated means the response body is larger than the maximum body size,
+	// only its beginning was matched and extracted from.
+	BodyTruncated bool `json:"body_truncated,omitempty"`
+	// ContentType is the Content-Type header of the response.
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// Failed returns true if the step did not succeed.
+func (sr StepResult) Failed() bool {
+	return sr.Error != nil || sr.Status == nil || *sr.Status >= 400 || (sr.Matched != nil && !*sr.Matched)
+}
@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestSetSteps(t *testing.T) {
+	c := qt.New(t)
+
+	login, err := NewStep("login", "post", "/login", "")
+	c.Assert(err, qt.IsNil)
+	c.Assert(login.Method, qt.Equals, "POST")
+	login.Headers = map[string]string{"Content-Type": "application/json"}
+	login.Body = `{"password": "s3cr3t"}`
+	token, err := NewExtraction("token", ExtractJSONPath, "$.token")
+	c.Assert(err, qt.IsNil)
+	login.Extract = []Extraction{*token}
+
+	cart, err := NewStep("cart", "", "/cart", "items")
+	c.Assert(err, qt.IsNil)
+	c.Assert(cart.Method, qt.Equals, "GET")
+	cart.Headers = map[string]string{"Authorization": "Bearer {{ token }}"}
+
+	wp, err := NewWebsiteParams("https://shop.foo.org", "", "")
+	c.Assert(err, qt.IsNil)
+	id := wp.ID
+
+	c.Assert(wp.SetSteps([]Step{*cart, *login}), qt.ErrorMatches, `step 1 references undefined variable "token"`)
+	c.Assert(wp.Steps, qt.IsNil)
+
+	c.Assert(wp.SetSteps([]Step{*login, *cart}), qt.IsNil)
+	c.Assert(wp.Steps, qt.HasLen, 2)
+	c.Assert(wp.ID, qt.Not(qt.Equals), id)
+
+	c.Run("Secrets are not part of the ID", func(c *qt.C) {
+		id := wp.ID
+		login.Body = `{"password": "0th3r"}`
+		cart.Headers = map[string]string{"Authorization": "Basic {{token}}"}
+		c.Assert(wp.SetSteps([]Step{*login, *cart}), qt.IsNil)
+		c.Assert(wp.ID, qt.Equals, id)
+	})
+
+	c.Run("Only HTTP", func(c *qt.C) {
+		wp, err := NewWebsiteParams("tcp://db.internal:5432", "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(wp.SetSteps([]Step{*login}), qt.ErrorMatches, "steps are only supported by HTTP probes")
+	})
+
+	c.Run("NOK", func(c *qt.C) {
+		_, err := NewStep("", "GET", "", "")
+		c.Check(err, qt.ErrorMatches, "step URL is required")
+		_, err = NewStep("", "TRACE", "/", "")
+		c.Check(err, qt.ErrorMatches, `unknown HTTP method "TRACE". Valid ones: \[GET HEAD POST PUT PATCH DELETE\]`)
+		_, err = NewExtraction("token", ExtractRegexp, "token=\\w+")
+		c.Check(err, qt.ErrorMatches, `regexp "token=\\\\w\+" of token has no capture group`)
+		_, err = NewExtraction("the token", ExtractHeader, "X-Token")
+		c.Check(err, qt.ErrorMatches, `invalid variable name "the token"`)
+		_, err = NewExtraction("token", "body", "token")
+		c.Check(err, qt.ErrorMatches, `unknown extraction source "body". Valid ones: \[regexp jsonpath header cookie\]`)
+	})
+}
+
+func TestExpandVars(t *testing.T) {
+	c := qt.New(t)
+
+	s, err := ExpandVars("/users/{{id}}?token={{ token }}", map[string]string{"id": "42", "token": "abc"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(s, qt.Equals, "/users/42?token=abc")
+
+	_, err = ExpandVars("/users/{{id}}", nil)
+	c.Assert(err, qt.ErrorMatches, `undefined variable "id"`)
+}
@@ -7,6 +7,9 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
+	"strings"
+	"time"
 )
 
 // HTTPMethod defines the valid HTTP methods to use in the checker
@@ -29,36 +32,84 @@ func NewHTTPMethod(in string) (HTTPMethod, error) {
 
 // WebsiteParams defines the website parameters to check against
 type WebsiteParams struct {
-	ID          string         `json:"id"`
-	URL         url.URL        `json:"-"`
+	ID  string  `json:"id"`
+	URL url.URL `json:"-"`
+	// Probe is the type of check given by the URL scheme.
+	Probe ProbeType `json:"probe"`
+	// Method is only set for HTTP probes and MatchRegexp for HTTP and WebSocket ones.
 	Method      HTTPMethod     `json:"method"`
 	MatchRegexp *regexp.Regexp `json:"-"`
+	// DNS is only set for DNS probes.
+	DNS *DNSParams `json:"dns,omitempty"`
+	// TLS is only set for TLS probes.
+	TLS *TLSParams `json:"tls,omitempty"`
+	// GRPC is only set for gRPC probes.
+	GRPC *GRPCParams `json:"grpc,omitempty"`
+	// WebSocket is only set for WebSocket probes.
+	WebSocket *WebSocketParams `json:"websocket,omitempty"`
+	// Steps are the requests of a multi-step check, performed instead of requesting URL.
+	Steps []Step `json:"steps,omitempty"`
+	// Auth optionally holds the credentials of HTTP probes.
+	Auth *AuthParams `json:"auth,omitempty"`
+	// Redirects optionally defines how HTTP probes follow redirects. Nil follows DefaultMaxRedirects.
+	Redirects *RedirectParams `json:"redirects,omitempty"`
+	// Client optionally tunes the HTTP client of HTTP probes.
+	Client *ClientParams `json:"client,omitempty"`
+	// PerIP checks every address the host of HTTP probes resolves to. It is not part of the ID.
+	PerIP bool `json:"per_ip,omitempty"`
+	// Addr is the IP address to check, set by the Checker in per IP mode.
+	Addr string `json:"-"`
+	// Tags groups websites. They are not part of the ID.
+	Tags []string `json:"tags,omitempty"`
+	// Maintenance holds the maintenance windows of the website. They are not part of the ID.
+	Maintenance []MaintenanceWindow `json:"-"`
 }
 
 // NewWebsiteParams creates a new WebsiteParmams parsing input strings.
-// An empty rawMethod will set Get HTTP method.
-// An empty rawRegexp will not generate any regular expression.
+// The URL scheme sets the probe type, the record type of DNS probes is given
+// by the type query parameter.
+// An empty rawMethod will set Get HTTP method to HTTP probes.
+// An empty rawRegexp will not generate any regular expression. It matches the
+// body of HTTP probes and the reply of WebSocket ones.
 func NewWebsiteParams(rawURL, rawMethod, rawRegexp string) (*WebsiteParams, error) {
 	u, err := url.Parse(rawURL)
 	if err != nil {
 		return nil, fmt.Errorf("can't parse URL: %w", err)
 	}
 
-	if u.Scheme != "http" && u.Scheme != "https" {
-		return nil, fmt.Errorf(`only HTTP protocol is supported: "%s" provided`, u.Scheme)
-	}
-
-	m := HTTPMethodGet
-	if rawMethod != "" {
-		m, err = NewHTTPMethod(rawMethod)
-		if err != nil {
-			return nil, fmt.Errorf("can't create HTTP method: %w", err)
+	probe, ok := probeSchemes[u.Scheme]
+	if !ok {
+		schemes := make([]string, 0, len(probeSchemes))
+		for s := range probeSchemes {
+			schemes = append(schemes, s)
 		}
+		sort.Strings(schemes)
+		return nil, fmt.Errorf(`unsupported protocol "%s". Valid ones: %s`, u.Scheme, schemes)
 	}
 
 	wp := &WebsiteParams{
-		URL:    *u,
-		Method: m,
+		URL:   *u,
+		Probe: probe,
+	}
+
+	if probe == ProbeHTTP {
+		wp.Method = HTTPMethodGet
+		if rawMethod != "" {
+			wp.Method, err = NewHTTPMethod(rawMethod)
+			if err != nil {
+				return nil, fmt.Errorf("can't create HTTP method: %w", err)
+			}
+		}
+	} else {
+		if rawMethod != "" {
+			return nil, fmt.Errorf("method is only supported by HTTP probes")
+		}
+		if rawRegexp != "" && probe != ProbeWebSocket {
+			return nil, fmt.Errorf("regexp is only supported by HTTP and WebSocket probes")
+		}
+		if err := wp.parseProbeURL(); err != nil {
+			return nil, err
+		}
 	}
 
 	if rawRegexp != "" {
@@ -68,12 +119,174 @@ func NewWebsiteParams(rawURL, rawMethod, rawRegexp string) (*WebsiteParams, erro
 		}
 	}
 
-	// Generate the ID based on struct fields
-	wp.ID = fmt.Sprintf("%x", sha1.Sum([]byte(wp.URL.String()+string(wp.Method)+rawRegexp)))
+	wp.ID = wp.hash()
 
 	return wp, nil
 }
 
+// parseProbeURL validates the URL of the non HTTP probes and sets their defaults.
+func (wp *WebsiteParams) parseProbeURL() error {
+	switch wp.Probe {
+	case ProbeTCP:
+		if wp.URL.Hostname() == "" || wp.URL.Port() == "" {
+			return fmt.Errorf("TCP probes require host and port: %s provided", wp.URL.String())
+		}
+	case ProbeTLS:
+		if wp.URL.Hostname() == "" {
+			return fmt.Errorf("TLS probes require a host: %s provided", wp.URL.String())
+		}
+		wp.TLS = new(TLSParams)
+	case ProbeDNS:
+		if wp.DNSName() == "" {
+			return fmt.Errorf("DNS probes require a name to resolve: %s provided", wp.URL.String())
+		}
+		rt, err := NewDNSRecordType(wp.URL.Query().Get("type"))
+		if err != nil {
+			return fmt.Errorf("can't create DNS record type: %w", err)
+		}
+		wp.DNS = &DNSParams{RecordType: rt}
+	case ProbeGRPC:
+		if wp.URL.Hostname() == "" || wp.URL.Port() == "" {
+			return fmt.Errorf("gRPC probes require host and port: %s provided", wp.URL.String())
+		}
+		wp.GRPC = &GRPCParams{
+			Service: strings.TrimPrefix(wp.URL.Path, "/"),
+			TLS:     wp.URL.Scheme == "grpcs",
+		}
+	case ProbeWebSocket:
+		if wp.URL.Hostname() == "" {
+			return fmt.Errorf("WebSocket probes require a host: %s provided", wp.URL.String())
+		}
+		wp.WebSocket = new(WebSocketParams)
+	}
+	return nil
+}
+
+// DNSName returns the name a DNS probe resolves.
+func (wp WebsiteParams) DNSName() string {
+	return strings.TrimPrefix(wp.URL.Path, "/")
+}
+
+// SetProbeOptions sets the settings specific to the probe type: the expected answers
+// of DNS probes, the minimum certificate validity of TLS probes, the metadata of
+// gRPC probes and the message sent by WebSocket probes. They are part of the ID.
+func (wp *WebsiteParams) SetProbeOptions(opts ProbeOptions) error {
+	if len(opts.Expected) > 0 {
+		if wp.DNS == nil {
+			return fmt.Errorf("expected answers are only supported by DNS probes")
+		}
+		wp.DNS.Expected = opts.Expected
+	}
+
+	if opts.MinCertValidity != "" {
+		if wp.TLS == nil {
+			return fmt.Errorf("minimum certificate validity is only supported by TLS probes")
+		}
+		d, err := time.ParseDuration(opts.MinCertValidity)
+		if err != nil {
+			return fmt.Errorf("can't parse minimum certificate validity: %w", err)
+		}
+		wp.TLS.MinCertValidity = d
+	}
+
+	if len(opts.Metadata) > 0 {
+		if wp.GRPC == nil {
+			return fmt.Errorf("metadata is only supported by gRPC probes")
+		}
+		wp.GRPC.Metadata = opts.Metadata
+	}
+
+	if opts.Send != "" {
+		if wp.WebSocket == nil {
+			return fmt.Errorf("messages to send are only supported by WebSocket probes")
+		}
+		wp.WebSocket.Send = opts.Send
+	}
+
+	wp.ID = wp.hash()
+
+	return nil
+}
+
+// SetPerIP checks every address the host of an HTTP probe resolves to
+// instead of the one picked by the dialer.
+func (wp *WebsiteParams) SetPerIP(perIP bool) error {
+	if perIP && wp.Probe != ProbeHTTP {
+		return fmt.Errorf("per IP checks are only supported by HTTP probes")
+	}
+	wp.PerIP = perIP
+	return nil
+}
+
+// SetSteps sets the steps of a multi-step HTTP check. The variables referenced
+// by a step must be extracted by a previous one. They are part of the ID.
+func (wp *WebsiteParams) SetSteps(steps []Step) error {
+	if len(steps) == 0 {
+		return nil
+	}
+	if wp.Probe != ProbeHTTP {
+		return fmt.Errorf("steps are only supported by HTTP probes")
+	}
+
+	defined := make(map[string]bool)
+	for i, s := range steps {
+		for _, name := range s.vars() {
+			if !defined[name] {
+				return fmt.Errorf("step %d references undefined variable %q", i+1, name)
+			}
+		}
+		for _, e := range s.Extract {
+			defined[e.Var] = true
+		}
+	}
+
+	wp.Steps = steps
+	wp.ID = wp.hash()
+
+	return nil
+}
+
+// hash generates the ID based on the struct fields which change the check: the URL,
+// the method, the regexp, the probe options, the steps, the credentials, the redirects
+// and the client settings. Following the default redirects doesn't change it and
+// only the keys of the gRPC metadata are used.
+func (wp *WebsiteParams) hash() string {
+	key := wp.URL.String() + string(wp.Method)
+	if wp.MatchRegexp != nil {
+		key += wp.MatchRegexp.String()
+	}
+	if wp.DNS != nil && len(wp.DNS.Expected) > 0 {
+		key += strings.Join(wp.DNS.Expected, ",")
+	}
+	if wp.TLS != nil && wp.TLS.MinCertValidity > 0 {
+		key += wp.TLS.MinCertValidity.String()
+	}
+	for _, s := range wp.Steps {
+		key += s.key()
+	}
+	if wp.Auth != nil {
+		key += wp.Auth.key()
+	}
+	if wp.Redirects != nil {
+		key += wp.Redirects.key()
+	}
+	if wp.Client != nil {
+		key += wp.Client.key()
+	}
+	if wp.WebSocket != nil && wp.WebSocket.Send != "" {
+		key += wp.WebSocket.Send
+	}
+	if wp.GRPC != nil && len(wp.GRPC.Metadata) > 0 {
+		keys := make([]string, 0, len(wp.GRPC.Metadata))
+		for k := range wp.GRPC.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		key += strings.Join(keys, ",")
+	}
+	return fmt.Sprintf("%x", sha1.Sum([]byte(key)))
+}
+
 // MarshalJSON provides custom JSON marshalling.
 func (wp *WebsiteParams) MarshalJSON() ([]byte, error) {
 	var matchRegexp *string
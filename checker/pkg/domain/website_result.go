@@ -6,10 +6,63 @@ import "time"
 type WebsiteResult struct {
 	Elapsed time.Duration `json:"elapsed"`
 	Status  *int          `json:"status"`
-	// Matched optionally says if the body response matched the regular expression if provided.
+	// Matched optionally says if the body response, or the WebSocket reply, matched the
+	// regular expression if provided, or if the expected answers were resolved by DNS probes.
 	Matched *bool `json:"matched"`
 	// Unreachable means the website check timed out.
 	Unreachable bool `json:"unreachable"`
 	// At determines when the result was recorded
 	At time.Time `json:"at"`
+	// Location identifies where the check was performed from.
+	Location string `json:"location"`
+	// CheckerID identifies the checker among the ones of the same location.
+	CheckerID string `json:"checker_id,omitempty"`
+	// IP is the address checked in per IP mode.
+	IP string `json:"ip,omitempty"`
+	// InMaintenance means the check was performed during a maintenance window.
+	InMaintenance bool `json:"in_maintenance"`
+	// Probe is the type of check. Empty means HTTP.
+	Probe ProbeType `json:"probe,omitempty"`
+	// Error describes why a check of a non HTTP probe, or a step of a multi-step check, failed.
+	Error *string `json:"error,omitempty"`
+	// TLS is set by TLS probes once the handshake is done.
+	TLS *TLSResult `json:"tls,omitempty"`
+	// DNS is set by DNS probes.
+	DNS *DNSResult `json:"dns,omitempty"`
+	// GRPC is set by gRPC probes once the health service is called.
+	GRPC *GRPCResult `json:"grpc,omitempty"`
+	// WebSocket is set by WebSocket probes once the handshake is done.
+	WebSocket *WebSocketResult `json:"websocket,omitempty"`
+	// Steps holds the results of the steps of a multi-step check, up to the failed one.
+	// Status and Matched are then the ones of the last performed step.
+	Steps []StepResult `json:"steps,omitempty"`
+	// FailedStep is the index in Steps of the step the multi-step check failed at.
+	FailedStep *int `json:"failed_step,omitempty"`
+	// BodySize is the number of bytes of the response body read by HTTP probes,
+	// up to the maximum body size.
+	BodySize int64 `json:"body_size,omitempty"`
+	// BodyTruncated means the response body is larger than the maximum body size,
+	// only its beginning was matched against the regular expression.
+	BodyTruncated bool `json:"body_truncated,omitempty"`
+	// ContentType is the Content-Type header of the response of HTTP probes.
+	ContentType string `json:"content_type,omitempty"`
+	// Redirects is the chain of redirects followed by HTTP probes.
+	Redirects []Redirect `json:"redirects,omitempty"`
+	// FinalURL is the URL of the last response of HTTP probes which were redirected
+	// or expect a final URL.
+	FinalURL string `json:"final_url,omitempty"`
+}
+
+// Failed returns true if the check did not succeed: the website was
+// unreachable, answered with an error status, did not match the
+// regular expression or the probe reported an error.
+func (wr WebsiteResult) Failed() bool {
+	if wr.Unreachable || wr.Error != nil || (wr.Matched != nil && !*wr.Matched) {
+		return true
+	}
+	// Only HTTP probes have a status
+	if wr.Probe != "" && wr.Probe != ProbeHTTP {
+		return false
+	}
+	return wr.Status == nil || *wr.Status >= 400
 }
@@ -14,6 +14,7 @@ func TestMarshalJSON(t *testing.T) {
 		c.Assert(err, qt.IsNil)
 		c.Assert(`{"id": "55065fa3a951948bbb31caf615859b0dbedbb8c5",
                            "url": "http://foo.org",
+                           "probe": "http",
                            "method": "GET",
                            "match_regexp": "foo*"}`,
 			qt.JSONEquals,
@@ -25,10 +26,26 @@ func TestMarshalJSON(t *testing.T) {
 		c.Assert(err, qt.IsNil)
 		c.Assert(`{"id": "4afd9ae157f6fda38a00b0e778e5e98e599ce381",
                            "url": "http://foo.baz",
+                           "probe": "http",
                            "method": "HEAD",
                            "match_regexp": null}`,
 			qt.JSONEquals,
 			wp)
 	})
 
+	c.Run("DNS", func(c *qt.C) {
+		wp, err := NewWebsiteParams("dns://1.1.1.1:53/foo.org?type=txt", "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(wp.DNSName(), qt.Equals, "foo.org")
+		err = wp.SetProbeOptions(ProbeOptions{Expected: []string{"v=spf1 -all"}})
+		c.Assert(err, qt.IsNil)
+		c.Assert(`{"id": "3e0508351a9db16d11607d1e2a3df58d4ca26da8",
+                           "url": "dns://1.1.1.1:53/foo.org?type=txt",
+                           "probe": "dns",
+                           "method": "",
+                           "match_regexp": null,
+                           "dns": {"record_type": "TXT", "expected": ["v=spf1 -all"]}}`,
+			qt.JSONEquals,
+			wp)
+	})
 }
@@ -0,0 +1,83 @@
+// Package grpc fetches the results of the gRPC probes calling the standard health service.
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+// Fetcher calls grpc.health.v1.Health/Check on the address of gRPC probes.
+type Fetcher struct {
+	// RootCAs are the trusted certificate authorities of grpcs:// probes. Nil uses the system ones.
+	RootCAs *x509.CertPool
+}
+
+// FetchWebsiteResult fetches the result of a gRPC probe: the time to connect and get the
+// health of the service. The check fails if the call fails or the service is not serving.
+func (f *Fetcher) FetchWebsiteResult(ctx context.Context, wp domain.WebsiteParams) (*domain.WebsiteResult, error) {
+	var params domain.GRPCParams
+	if wp.GRPC != nil {
+		params = *wp.GRPC
+	}
+
+	creds := grpc.WithTransportCredentials(insecure.NewCredentials())
+	if params.TLS {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			ServerName: wp.URL.Hostname(),
+			RootCAs:    f.RootCAs,
+		}))
+	}
+
+	wr := &domain.WebsiteResult{Probe: domain.ProbeGRPC}
+
+	start := time.Now()
+	conn, err := grpc.DialContext(ctx, wp.URL.Host, creds)
+	if err != nil {
+		if ctx.Err() == nil {
+			return nil, fmt.Errorf("can't dial %s: %w", wp.URL.Host, err)
+		}
+		wr.Elapsed, wr.At, wr.Unreachable = time.Since(start), time.Now().UTC(), true
+		return wr, nil
+	}
+	defer func() { _ = conn.Close() }()
+
+	if len(params.Metadata) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(params.Metadata))
+	}
+	resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: params.Service})
+	wr.Elapsed, wr.At = time.Since(start), time.Now().UTC()
+
+	st := status.Convert(err)
+	if st.Code() == codes.DeadlineExceeded || ctx.Err() != nil {
+		wr.Unreachable = true
+		return wr, nil
+	}
+
+	wr.GRPC = &domain.GRPCResult{Code: int(st.Code())}
+	if err != nil {
+		msg := fmt.Sprintf("health check failed: %s", st.Message())
+		wr.Error = &msg
+		return wr, nil
+	}
+
+	wr.GRPC.ServingStatus = resp.GetStatus().String()
+	if resp.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+		msg := fmt.Sprintf("service is %s", wr.GRPC.ServingStatus)
+		wr.Error = &msg
+	}
+
+	return wr, nil
+}
@@ -0,0 +1,160 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+func TestFetchWebsiteResult(t *testing.T) {
+	c := qt.New(t)
+
+	addr, hs := newHealthServer(c, nil)
+	hs.SetServingStatus("foo.v1.Users", grpc_health_v1.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("foo.v1.Orders", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	fetcher := &Fetcher{}
+	fetch := func(c *qt.C, rawURL string, opts domain.ProbeOptions) *domain.WebsiteResult {
+		wp, err := domain.NewWebsiteParams(rawURL, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(wp.SetProbeOptions(opts), qt.IsNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		wr, err := fetcher.FetchWebsiteResult(ctx, *wp)
+		c.Assert(err, qt.IsNil)
+		return wr
+	}
+
+	c.Run("Serving", func(c *qt.C) {
+		for _, rawURL := range []string{"grpc://" + addr, "grpc://" + addr + "/foo.v1.Users"} {
+			wr := fetch(c, rawURL, domain.ProbeOptions{})
+			c.Assert(wr.Error, qt.IsNil)
+			c.Assert(wr.GRPC, qt.DeepEquals, &domain.GRPCResult{Code: int(codes.OK), ServingStatus: "SERVING"})
+			c.Assert(wr.Failed(), qt.IsFalse)
+		}
+	})
+
+	c.Run("Not serving", func(c *qt.C) {
+		wr := fetch(c, "grpc://"+addr+"/foo.v1.Orders", domain.ProbeOptions{})
+		c.Assert(wr.GRPC, qt.DeepEquals, &domain.GRPCResult{Code: int(codes.OK), ServingStatus: "NOT_SERVING"})
+		c.Assert(*wr.Error, qt.Equals, "service is NOT_SERVING")
+		c.Assert(wr.Failed(), qt.IsTrue)
+	})
+
+	c.Run("Unknown service", func(c *qt.C) {
+		wr := fetch(c, "grpc://"+addr+"/foo.v1.Unknown", domain.ProbeOptions{})
+		c.Assert(wr.GRPC, qt.DeepEquals, &domain.GRPCResult{Code: int(codes.NotFound)})
+		c.Assert(*wr.Error, qt.Equals, "health check failed: unknown service")
+		c.Assert(wr.Failed(), qt.IsTrue)
+	})
+
+	c.Run("Connection refused", func(c *qt.C) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		c.Assert(err, qt.IsNil)
+		closed := l.Addr().String()
+		c.Assert(l.Close(), qt.IsNil)
+
+		wr := fetch(c, "grpc://"+closed, domain.ProbeOptions{})
+		c.Assert(wr.GRPC, qt.DeepEquals, &domain.GRPCResult{Code: int(codes.Unavailable)})
+		c.Assert(wr.Failed(), qt.IsTrue)
+	})
+
+	c.Run("Timeout", func(c *qt.C) {
+		wp, err := domain.NewWebsiteParams("grpc://"+addr, "", "")
+		c.Assert(err, qt.IsNil)
+
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+		defer cancel()
+		wr, err := fetcher.FetchWebsiteResult(ctx, *wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(wr.Unreachable, qt.IsTrue)
+	})
+}
+
+func TestFetchWebsiteResultMetadata(t *testing.T) {
+	c := qt.New(t)
+
+	// Only authorized calls are answered
+	addr, _ := newHealthServer(c, nil, grpc.UnaryInterceptor(
+		func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			md, _ := metadata.FromIncomingContext(ctx)
+			if v := md.Get("x-api-key"); len(v) != 1 || v[0] != "s3cr3t" {
+				return nil, status.Error(codes.Unauthenticated, "invalid API key")
+			}
+			return handler(ctx, req)
+		}))
+
+	wp, err := domain.NewWebsiteParams("grpc://"+addr, "", "")
+	c.Assert(err, qt.IsNil)
+
+	wr, err := (&Fetcher{}).FetchWebsiteResult(context.Background(), *wp)
+	c.Assert(err, qt.IsNil)
+	c.Assert(wr.GRPC.Code, qt.Equals, int(codes.Unauthenticated))
+
+	c.Assert(wp.SetProbeOptions(domain.ProbeOptions{Metadata: map[string]string{"x-api-key": "s3cr3t"}}), qt.IsNil)
+	wr, err = (&Fetcher{}).FetchWebsiteResult(context.Background(), *wp)
+	c.Assert(err, qt.IsNil)
+	c.Assert(wr.Error, qt.IsNil)
+	c.Assert(wr.GRPC.ServingStatus, qt.Equals, "SERVING")
+}
+
+func TestFetchWebsiteResultTLS(t *testing.T) {
+	c := qt.New(t)
+
+	// Borrow the certificate of a TLS test server, valid for 127.0.0.1
+	svr := httptest.NewTLSServer(http.NotFoundHandler())
+	svr.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(svr.Certificate())
+
+	addr, _ := newHealthServer(c, &tls.Config{Certificates: svr.TLS.Certificates})
+
+	wp, err := domain.NewWebsiteParams("grpcs://"+addr, "", "")
+	c.Assert(err, qt.IsNil)
+
+	wr, err := (&Fetcher{RootCAs: roots}).FetchWebsiteResult(context.Background(), *wp)
+	c.Assert(err, qt.IsNil)
+	c.Assert(wr.Error, qt.IsNil)
+	c.Assert(wr.GRPC.ServingStatus, qt.Equals, "SERVING")
+
+	wr, err = (&Fetcher{RootCAs: x509.NewCertPool()}).FetchWebsiteResult(context.Background(), *wp)
+	c.Assert(err, qt.IsNil)
+	c.Assert(wr.GRPC.Code, qt.Equals, int(codes.Unavailable))
+	c.Assert(*wr.Error, qt.Contains, "certificate")
+}
+
+// newHealthServer serves the health service on a local address, over TLS if
+// tlsCfg is set, and returns the address.
+func newHealthServer(c *qt.C, tlsCfg *tls.Config, opts ...grpc.ServerOption) (string, *health.Server) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, qt.IsNil)
+
+	if tlsCfg != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+	svr := grpc.NewServer(opts...)
+	hs := health.NewServer()
+	grpc_health_v1.RegisterHealthServer(svr, hs)
+
+	go func() { _ = svr.Serve(l) }()
+	c.Cleanup(svr.Stop)
+
+	return l.Addr().String(), hs
+}
@@ -0,0 +1,197 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+// tokenExpiryDelta is subtracted from the expiry of the OAuth2 tokens to
+// refresh them before the websites reject them.
+const tokenExpiryDelta = 10 * time.Second
+
+// tokenRequestTimeout bounds the token requests, as they don't end with the check which started them.
+const tokenRequestTimeout = 10 * time.Second
+
+// authorize sets the credentials of the website in the request unless it
+// already has an Authorization header.
+func (f *Fetcher) authorize(ctx context.Context, req *http.Request, ap *domain.AuthParams) error {
+	if ap == nil || req.Header.Get("Authorization") != "" {
+		return nil
+	}
+
+	switch ap.Type {
+	case domain.AuthBasic:
+		req.SetBasicAuth(ap.Username, ap.Password)
+	case domain.AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+ap.Token)
+	case domain.AuthOAuth2:
+		token, err := f.tokens.token(ctx, f.Client, *ap)
+		if err != nil {
+			return fmt.Errorf("can't get OAuth2 token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+// tokenCache caches the OAuth2 tokens until they expire. The zero value is ready to use.
+type tokenCache struct {
+	mu     sync.Mutex
+	tokens map[tokenKey]cachedToken
+	// pending holds the token requests in flight, shared by the checks of the same client.
+	pending map[tokenKey]*tokenRequest
+}
+
+// tokenRequest is a token request in flight. done is closed once token or err is set.
+type tokenRequest struct {
+	done  chan struct{}
+	token *cachedToken
+	err   error
+}
+
+// tokenKey identifies the tokens of a client. It is never sent anywhere.
+type tokenKey struct {
+	tokenURL, clientID, clientSecret, scopes string
+}
+
+type cachedToken struct {
+	value string
+	// expiry is zero if the token endpoint did not tell when it expires.
+	expiry time.Time
+}
+
+func newTokenKey(ap domain.AuthParams) tokenKey {
+	return tokenKey{ap.TokenURL, ap.ClientID, ap.ClientSecret, strings.Join(ap.Scopes, " ")}
+}
+
+// token returns the cached token of the client or requests a new one if it
+// is missing or about to expire. A single request is sent at once per client,
+// detached from the check which started it, and every check waits for it until
+// its own context is done.
+func (tc *tokenCache) token(ctx context.Context, client *http.Client, ap domain.AuthParams) (string, error) {
+	key := newTokenKey(ap)
+
+	tc.mu.Lock()
+	if t, ok := tc.tokens[key]; ok && (t.expiry.IsZero() || time.Now().Add(tokenExpiryDelta).Before(t.expiry)) {
+		tc.mu.Unlock()
+		return t.value, nil
+	}
+	r, waiting := tc.pending[key]
+	if !waiting {
+		r = &tokenRequest{done: make(chan struct{})}
+		if tc.pending == nil {
+			tc.pending = make(map[tokenKey]*tokenRequest)
+		}
+		tc.pending[key] = r
+		go tc.request(detachedContext{ctx}, key, r, client, ap)
+	}
+	tc.mu.Unlock()
+
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if r.err != nil {
+		return "", r.err
+	}
+	return r.token.value, nil
+}
+
+// request performs the token request r shared by the checks and caches its token.
+func (tc *tokenCache) request(ctx context.Context, key tokenKey, r *tokenRequest, client *http.Client, ap domain.AuthParams) {
+	ctx, cancel := context.WithTimeout(ctx, tokenRequestTimeout)
+	defer cancel()
+	token, err := requestToken(ctx, client, ap)
+
+	tc.mu.Lock()
+	r.token, r.err = token, err
+	delete(tc.pending, key)
+	if err == nil {
+		if tc.tokens == nil {
+			tc.tokens = make(map[tokenKey]cachedToken)
+		}
+		tc.tokens[key] = *token
+	}
+	tc.mu.Unlock()
+	close(r.done)
+}
+
+// detachedContext keeps the values of its parent, like the trace, but not its
+// cancellation. It is context.WithoutCancel of later Go versions.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (d detachedContext) Value(key interface{}) interface{} { return d.parent.Value(key) }
+
+// invalidate removes the cached token of the client so the next check gets a new one.
+func (tc *tokenCache) invalidate(ap domain.AuthParams) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	delete(tc.tokens, newTokenKey(ap))
+}
+
+// requestToken gets a token from the token URL with the client credentials grant.
+func requestToken(ctx context.Context, client *http.Client, ap domain.AuthParams) (*cachedToken, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(ap.Scopes) > 0 {
+		form.Set("scope", strings.Join(ap.Scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ap.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(ap.ClientID), url.QueryEscape(ap.ClientSecret))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	blob, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("can't read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint answered %d", resp.StatusCode)
+	}
+
+	var tr struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(blob, &tr); err != nil {
+		return nil, fmt.Errorf("can't decode token response: %w", err)
+	}
+	if tr.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access token")
+	}
+	if tr.TokenType != "" && !strings.EqualFold(tr.TokenType, "bearer") {
+		return nil, fmt.Errorf("unsupported token type %q", tr.TokenType)
+	}
+
+	t := &cachedToken{value: tr.AccessToken}
+	if tr.ExpiresIn > 0 {
+		t.expiry = start.Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return t, nil
+}
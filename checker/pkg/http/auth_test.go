@@ -0,0 +1,210 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+func TestFetchWebsiteResultAuth(t *testing.T) {
+	c := qt.New(t)
+
+	// The token server issues numbered tokens which expire in expiresIn seconds.
+	var issued int32
+	expiresIn := int32(3600)
+	tokenSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if r.Method != http.MethodPost || r.FormValue("grant_type") != "client_credentials" ||
+			id != "probe" || secret != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n := atomic.AddInt32(&issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "t%d-%s", "token_type": "Bearer", "expires_in": %d}`,
+			n, r.FormValue("scope"), atomic.LoadInt32(&expiresIn))
+	}))
+	defer tokenSvr.Close()
+
+	// The website accepts the credentials set in valid.
+	var valid atomic.Value
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != valid.Load().(string) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer svr.Close()
+
+	fetch := func(c *qt.C, fetcher *Fetcher, ap domain.AuthParams) *domain.WebsiteResult {
+		wp, err := domain.NewWebsiteParams(svr.URL, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(wp.SetAuth(ap), qt.IsNil)
+
+		wr, err := fetcher.FetchWebsiteResult(context.Background(), *wp)
+		c.Assert(err, qt.IsNil)
+		return wr
+	}
+
+	c.Run("Basic", func(c *qt.C) {
+		valid.Store("Basic cHJvYmU6czNjcjN0")
+		wr := fetch(c, &Fetcher{Client: http.DefaultClient}, domain.AuthParams{Type: domain.AuthBasic, Username: "probe", Password: "s3cr3t"})
+		c.Assert(*wr.Status, qt.Equals, http.StatusOK)
+	})
+
+	c.Run("Bearer", func(c *qt.C) {
+		valid.Store("Bearer t0k3n")
+		wr := fetch(c, &Fetcher{Client: http.DefaultClient}, domain.AuthParams{Type: domain.AuthBearer, Token: "t0k3n"})
+		c.Assert(*wr.Status, qt.Equals, http.StatusOK)
+	})
+
+	oauth2 := domain.AuthParams{
+		Type:         domain.AuthOAuth2,
+		TokenURL:     tokenSvr.URL,
+		ClientID:     "probe",
+		ClientSecret: "s3cr3t",
+		Scopes:       []string{"health:read"},
+	}
+
+	c.Run("OAuth2", func(c *qt.C) {
+		atomic.StoreInt32(&issued, 0)
+		fetcher := &Fetcher{Client: http.DefaultClient}
+
+		valid.Store("Bearer t1-health:read")
+		for i := 0; i < 2; i++ {
+			wr := fetch(c, fetcher, oauth2)
+			c.Assert(*wr.Status, qt.Equals, http.StatusOK)
+		}
+		c.Assert(atomic.LoadInt32(&issued), qt.Equals, int32(1), qt.Commentf("token is cached"))
+
+		// The rejected token is forgotten
+		valid.Store("Bearer t2-health:read")
+		wr := fetch(c, fetcher, oauth2)
+		c.Assert(*wr.Status, qt.Equals, http.StatusUnauthorized)
+		wr = fetch(c, fetcher, oauth2)
+		c.Assert(*wr.Status, qt.Equals, http.StatusOK)
+		c.Assert(atomic.LoadInt32(&issued), qt.Equals, int32(2))
+	})
+
+	c.Run("OAuth2 expired", func(c *qt.C) {
+		atomic.StoreInt32(&issued, 0)
+		atomic.StoreInt32(&expiresIn, 5)
+		defer atomic.StoreInt32(&expiresIn, 3600)
+		fetcher := &Fetcher{Client: http.DefaultClient}
+
+		valid.Store("Bearer t1-health:read")
+		wr := fetch(c, fetcher, oauth2)
+		c.Assert(*wr.Status, qt.Equals, http.StatusOK)
+
+		// The token expires within tokenExpiryDelta so it is refreshed
+		valid.Store("Bearer t2-health:read")
+		wr = fetch(c, fetcher, oauth2)
+		c.Assert(*wr.Status, qt.Equals, http.StatusOK)
+		c.Assert(atomic.LoadInt32(&issued), qt.Equals, int32(2))
+	})
+
+	c.Run("OAuth2 rejected client", func(c *qt.C) {
+		wrong := oauth2
+		wrong.ClientSecret = "wrong"
+		wr := fetch(c, &Fetcher{Client: http.DefaultClient}, wrong)
+		c.Assert(wr.Failed(), qt.IsTrue)
+		c.Assert(wr.Status, qt.IsNil)
+		c.Assert(*wr.Error, qt.Equals, "can't get OAuth2 token: token endpoint answered 401")
+	})
+}
+
+func TestTokenCacheConcurrent(t *testing.T) {
+	c := qt.New(t)
+
+	// The token server answers once released
+	release := make(chan struct{})
+	var requests int32
+	tokenSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		<-release
+		fmt.Fprintf(w, `{"access_token": "t%d-%s", "token_type": "Bearer"}`, n, r.FormValue("scope"))
+	}))
+	defer tokenSvr.Close()
+
+	ap := domain.AuthParams{Type: domain.AuthOAuth2, TokenURL: tokenSvr.URL, ClientID: "probe", ClientSecret: "s3cr3t"}
+	tc := new(tokenCache)
+
+	type result struct {
+		token string
+		err   error
+	}
+	results := make(chan result, 5)
+	get := func(ap domain.AuthParams) {
+		token, err := tc.token(context.Background(), http.DefaultClient, ap)
+		results <- result{token, err}
+	}
+
+	go get(ap)
+	for atomic.LoadInt32(&requests) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		go get(ap)
+	}
+
+	// A check waiting for the token gives up with its context
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := tc.token(ctx, http.DefaultClient, ap)
+	c.Assert(err, qt.Equals, context.DeadlineExceeded)
+
+	// The tokens of other clients are still requested
+	other := ap
+	other.Scopes = []string{"health:read"}
+	go get(other)
+	for atomic.LoadInt32(&requests) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	tokens := make(map[string]int)
+	for i := 0; i < 5; i++ {
+		r := <-results
+		c.Assert(r.err, qt.IsNil)
+		tokens[r.token]++
+	}
+	c.Assert(atomic.LoadInt32(&requests), qt.Equals, int32(2), qt.Commentf("a single request per client"))
+	c.Assert(tokens, qt.HasLen, 2)
+	c.Assert(tokens["t2-health:read"], qt.Equals, 1)
+
+	c.Run("Cancelled check", func(c *qt.C) {
+		requested, release := make(chan struct{}), make(chan struct{})
+		tokenSvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(requested)
+			<-release
+			fmt.Fprint(w, `{"access_token": "t0k3n", "token_type": "Bearer"}`)
+		}))
+		defer tokenSvr.Close()
+		ap := ap
+		ap.TokenURL = tokenSvr.URL
+
+		// The check which requested the token is cancelled while another one waits for it
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			_, err := tc.token(ctx, http.DefaultClient, ap)
+			results <- result{err: err}
+		}()
+		<-requested
+		go get(ap)
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+		c.Assert((<-results).err, qt.Equals, context.Canceled)
+
+		close(release)
+		r := <-results
+		c.Assert(r.err, qt.IsNil)
+		c.Assert(r.token, qt.Equals, "t0k3n")
+	})
+}
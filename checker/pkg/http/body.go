@@ -0,0 +1,44 @@
+package http
+
+import "io"
+
+// DefaultMaxBodySize is the maximum size of the response bodies read when the Fetcher does not set one.
+const DefaultMaxBodySize = 1 << 20
+
+// maxBodySize returns the maximum size of the response bodies to read.
+func (f *Fetcher) maxBodySize() int64 {
+	if f.MaxBodySize > 0 {
+		return f.MaxBodySize
+	}
+	return DefaultMaxBodySize
+}
+
+// bodyReader reads a response body up to a maximum size and counts the bytes read.
+type bodyReader struct {
+	body io.ReadCloser
+	r    io.Reader
+	n    int64
+}
+
+func newBodyReader(body io.ReadCloser, max int64) *bodyReader {
+	return &bodyReader{body: body, r: io.LimitReader(body, max)}
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+// drain reads the rest of the body up to the maximum size so the connection can be
+// reused, and closes it. It returns true if the body is larger than the maximum size.
+func (b *bodyReader) drain() (truncated bool, err error) {
+	defer func() { _ = b.body.Close() }()
+
+	if _, err := io.Copy(io.Discard, b); err != nil {
+		return false, err
+	}
+	var next [1]byte
+	_, err = io.ReadFull(b.body, next[:])
+	return err == nil, nil
+}
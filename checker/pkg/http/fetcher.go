@@ -1,55 +1,162 @@
 package http
 
 import (
+	"bufio"
 	"context"
 	"errors"
-	"io"
+	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
 )
 
+const tracerName = "github.com/sixstone-qq/gpagdispo/checker/pkg/http"
+
 // Fetcher is in charge of fetch website results from provided websites.
 type Fetcher struct {
 	Client *http.Client
+	// InjectTraceContext sends the trace context in traceparent header to the monitored websites.
+	InjectTraceContext bool
+	// MaxBodySize is the maximum number of bytes of the response bodies read. Zero means DefaultMaxBodySize.
+	MaxBodySize int64
+
+	tokens     tokenCache
+	transports transportCache
 }
 
-// FetchWebsiteResult fetches the result to monitor from incoming WebsiteParams
+// FetchWebsiteResult fetches the result to monitor from incoming WebsiteParams.
+// The steps of multi-step checks are performed instead of requesting the URL.
 func (f *Fetcher) FetchWebsiteResult(ctx context.Context, wp domain.WebsiteParams) (*domain.WebsiteResult, error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "fetch",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.method", string(wp.Method)),
+			attribute.String("http.url", wp.URL.Redacted()),
+		))
+	defer span.End()
+
+	if len(wp.Steps) > 0 {
+		return f.fetchSteps(ctx, wp)
+	}
+
 	req, err := http.NewRequestWithContext(ctx, string(wp.Method), wp.URL.String(), nil)
 	if err != nil {
 		return nil, err
 	}
+	setUserAgent(req, wp)
+	if f.InjectTraceContext {
+		propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	}
+	if err := f.authorize(ctx, req, wp.Auth); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "can't authorize")
+		return authFailedResult(err), nil
+	}
+	client, err := f.client(wp)
+	if err != nil {
+		return nil, err
+	}
+	rr := newRedirectRecorder(wp)
+	client.CheckRedirect = rr.checkRedirect
+
 	start := time.Now()
-	resp, err := f.Client.Do(req)
+	resp, err := client.Do(req)
 	elapsed := time.Since(start)
 	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) {
-			return &domain.WebsiteResult{
-				Elapsed:     elapsed,
-				Unreachable: true,
-				At:          time.Now().UTC(),
-			}, nil
-		}
-		return nil, err
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "can't fetch website")
+		return failedResult(elapsed, err), nil
 	}
 
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	f.checkAuth(resp, wp.Auth)
+
+	// The regular expression is matched while the body is read, up to the maximum size.
 	var matched *bool
+	body := newBodyReader(resp.Body, f.maxBodySize())
 	if wp.MatchRegexp != nil {
-		blob, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, err
-		}
-		res := wp.MatchRegexp.Match(blob)
+		res := wp.MatchRegexp.MatchReader(bufio.NewReader(body))
 		matched = &res
 	}
+	truncated, err := body.drain()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "can't read body")
+		if errors.Is(err, context.DeadlineExceeded) {
+			return unreachableResult(elapsed), nil
+		}
+		return nil, fmt.Errorf("can't read body: %w", err)
+	}
+
+	wr := &domain.WebsiteResult{
+		Status:        &resp.StatusCode,
+		Elapsed:       elapsed,
+		Matched:       matched,
+		At:            time.Now().UTC(),
+		BodySize:      body.n,
+		BodyTruncated: truncated,
+		ContentType:   resp.Header.Get("Content-Type"),
+	}
+	rr.setResult(wr, resp, wp)
+	if err := checkProto(resp, wp); err != nil && wr.Error == nil {
+		msg := err.Error()
+		wr.Error = &msg
+	}
+
+	return wr, nil
+}
 
+// unreachableResult returns the result of a check which timed out.
+func unreachableResult(elapsed time.Duration) *domain.WebsiteResult {
+	return &domain.WebsiteResult{
+		Elapsed:     elapsed,
+		Unreachable: true,
+		At:          time.Now().UTC(),
+	}
+}
+
+// failedResult returns the result of a request which got no response: unreachable
+// if it timed out, failed with the error if the connection was refused or reset.
+func failedResult(elapsed time.Duration, err error) *domain.WebsiteResult {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return unreachableResult(elapsed)
+	}
+
+	// The URL is already in the result, only the cause is kept
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	msg := fmt.Sprintf("can't fetch website: %s", err)
 	return &domain.WebsiteResult{
-		Status:  &resp.StatusCode,
 		Elapsed: elapsed,
-		Matched: matched,
+		Error:   &msg,
 		At:      time.Now().UTC(),
-	}, nil
+	}
+}
 
+// authFailedResult returns the result of a check whose credentials could not be got.
+func authFailedResult(err error) *domain.WebsiteResult {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &domain.WebsiteResult{Unreachable: true, At: time.Now().UTC()}
+	}
+	msg := err.Error()
+	return &domain.WebsiteResult{Error: &msg, At: time.Now().UTC()}
+}
+
+// checkAuth forgets the OAuth2 token rejected by a website so the next check gets a new one.
+func (f *Fetcher) checkAuth(resp *http.Response, ap *domain.AuthParams) {
+	if resp.StatusCode == http.StatusUnauthorized && ap != nil && ap.Type == domain.AuthOAuth2 {
+		f.tokens.invalidate(*ap)
+	}
 }
@@ -4,14 +4,22 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	qt "github.com/frankban/quicktest"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
 )
@@ -35,8 +43,10 @@ func TestFetchWebsiteResult(t *testing.T) {
 			c.Assert(wr,
 				websiteResultEquals,
 				&domain.WebsiteResult{At: time.Now().UTC(),
-					Status:  &ok,
-					Elapsed: time.Second})
+					Status:      &ok,
+					Elapsed:     time.Second,
+					BodySize:    7,
+					ContentType: "text/plain; charset=utf-8"})
 		})
 
 		c.Run("Regexp", func(c *qt.C) {
@@ -49,9 +59,11 @@ func TestFetchWebsiteResult(t *testing.T) {
 			c.Assert(wr,
 				websiteResultEquals,
 				&domain.WebsiteResult{At: time.Now().UTC(),
-					Status:  &ok,
-					Matched: &yeah,
-					Elapsed: time.Second})
+					Status:      &ok,
+					Matched:     &yeah,
+					Elapsed:     time.Second,
+					BodySize:    7,
+					ContentType: "text/plain; charset=utf-8"})
 
 			wp, err = domain.NewWebsiteParams(svr.URL, "", "not match")
 			c.Assert(err, qt.IsNil)
@@ -62,12 +74,72 @@ func TestFetchWebsiteResult(t *testing.T) {
 			c.Assert(wr,
 				websiteResultEquals,
 				&domain.WebsiteResult{At: time.Now().UTC(),
-					Status:  &ok,
-					Matched: &yeah,
-					Elapsed: time.Second})
+					Status:      &ok,
+					Matched:     &yeah,
+					Elapsed:     time.Second,
+					BodySize:    7,
+					ContentType: "text/plain; charset=utf-8"})
 		})
 	})
 
+	c.Run("Large body", func(c *qt.C) {
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html>" + strings.Repeat(" ", 2048) + "</html>"))
+		}))
+		defer svr.Close()
+		fetcher := &Fetcher{Client: http.DefaultClient, MaxBodySize: 1024}
+
+		for _, test := range []struct {
+			regexp  string
+			matched bool
+		}{
+			{regexp: "<html>", matched: true},
+			{regexp: "</html>", matched: false},
+		} {
+			wp, err := domain.NewWebsiteParams(svr.URL, "", test.regexp)
+			c.Assert(err, qt.IsNil)
+
+			wr, err := fetcher.FetchWebsiteResult(context.TODO(), *wp)
+			c.Assert(err, qt.IsNil)
+			c.Assert(wr,
+				websiteResultEquals,
+				&domain.WebsiteResult{At: time.Now().UTC(),
+					Status:        &ok,
+					Matched:       &test.matched,
+					Elapsed:       time.Second,
+					BodySize:      1024,
+					BodyTruncated: true,
+					ContentType:   "text/html"},
+				qt.Commentf("regexp %s", test.regexp))
+		}
+	})
+
+	c.Run("Connections reused", func(c *qt.C) {
+		var conns int32
+		svr := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(strings.Repeat("a", 4096)))
+		}))
+		svr.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				atomic.AddInt32(&conns, 1)
+			}
+		}
+		svr.Start()
+		defer svr.Close()
+		fetcher := &Fetcher{Client: svr.Client()}
+
+		wp, err := domain.NewWebsiteParams(svr.URL, "", "")
+		c.Assert(err, qt.IsNil)
+		for i := 0; i < 3; i++ {
+			wr, err := fetcher.FetchWebsiteResult(context.TODO(), *wp)
+			c.Assert(err, qt.IsNil)
+			c.Assert(wr.BodySize, qt.Equals, int64(4096))
+			c.Assert(wr.BodyTruncated, qt.IsFalse)
+		}
+		c.Assert(atomic.LoadInt32(&conns), qt.Equals, int32(1))
+	})
+
 	c.Run("Slow", func(c *qt.C) {
 		svr, fs := newFakeServer(c)
 
@@ -89,6 +161,50 @@ func TestFetchWebsiteResult(t *testing.T) {
 	})
 }
 
+func TestFetchWebsiteResultTracing(t *testing.T) {
+	c := qt.New(t)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(tp)
+	c.Cleanup(func() { otel.SetTracerProvider(trace.NewNoopTracerProvider()) })
+
+	var traceparent string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer svr.Close()
+
+	wp, err := domain.NewWebsiteParams(svr.URL, "", "")
+	c.Assert(err, qt.IsNil)
+
+	for _, inject := range []bool{false, true} {
+		c.Run(fmt.Sprintf("inject=%t", inject), func(c *qt.C) {
+			exporter.Reset()
+			fetcher := &Fetcher{Client: http.DefaultClient, InjectTraceContext: inject}
+
+			ctx, parent := tp.Tracer("test").Start(context.Background(), "check")
+			_, err := fetcher.FetchWebsiteResult(ctx, *wp)
+			parent.End()
+			c.Assert(err, qt.IsNil)
+
+			spans := exporter.GetSpans()
+			c.Assert(spans, qt.HasLen, 2)
+			fetch := spans[0]
+			c.Assert(fetch.Name, qt.Equals, "fetch")
+			c.Assert(fetch.Parent.SpanID(), qt.Equals, parent.SpanContext().SpanID())
+			c.Assert(fetch.Attributes, qt.Contains, attribute.Int("http.status_code", http.StatusOK))
+
+			if inject {
+				c.Assert(traceparent, qt.Equals,
+					fmt.Sprintf("00-%s-%s-01", fetch.SpanContext.TraceID(), fetch.SpanContext.SpanID()))
+			} else {
+				c.Assert(traceparent, qt.Equals, "")
+			}
+		})
+	}
+}
+
 var websiteResultEquals = qt.CmpEquals(
 	cmp.Comparer(func(x, y time.Duration) bool {
 		return math.Abs(float64(x-y)) < float64(time.Second) && x != 0 && y != 0
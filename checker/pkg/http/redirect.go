@@ -0,0 +1,73 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+// redirectRecorder follows the redirects of a check up to a maximum and records the chain.
+type redirectRecorder struct {
+	max      int
+	hopStart time.Time
+	chain    []domain.Redirect
+	// err is set if the redirects were not followed to the end.
+	err error
+}
+
+func newRedirectRecorder(wp domain.WebsiteParams) *redirectRecorder {
+	return &redirectRecorder{max: wp.MaxRedirects(), hopStart: time.Now()}
+}
+
+// checkRedirect implements http.Client.CheckRedirect. The last redirect response is
+// returned instead of following a loop or more redirects than the maximum, which
+// fails the check unless the maximum is zero.
+func (rr *redirectRecorder) checkRedirect(req *http.Request, via []*http.Request) error {
+	for _, r := range via {
+		if r.URL.String() == req.URL.String() {
+			rr.err = fmt.Errorf("redirect loop to %s", req.URL)
+			return http.ErrUseLastResponse
+		}
+	}
+	if len(via) > rr.max {
+		if rr.max > 0 {
+			rr.err = fmt.Errorf("stopped after %d redirects", rr.max)
+		}
+		return http.ErrUseLastResponse
+	}
+
+	now := time.Now()
+	rr.chain = append(rr.chain, domain.Redirect{
+		URL:     via[len(via)-1].URL.String(),
+		Status:  req.Response.StatusCode,
+		Elapsed: now.Sub(rr.hopStart),
+	})
+	rr.hopStart = now
+
+	return nil
+}
+
+// setResult sets the redirect chain and the final URL of the check in the result
+// and an error if the final URL is not the expected one.
+func (rr *redirectRecorder) setResult(wr *domain.WebsiteResult, resp *http.Response, wp domain.WebsiteParams) {
+	wr.Redirects = rr.chain
+
+	var expected string
+	if wp.Redirects != nil {
+		expected = wp.Redirects.ExpectedURL
+	}
+	if len(rr.chain) > 0 || expected != "" {
+		wr.FinalURL = resp.Request.URL.String()
+	}
+
+	err := rr.err
+	if err == nil && expected != "" && wr.FinalURL != expected {
+		err = fmt.Errorf("final URL %s is not %s", wr.FinalURL, expected)
+	}
+	if err != nil {
+		msg := err.Error()
+		wr.Error = &msg
+	}
+}
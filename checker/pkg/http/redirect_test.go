@@ -0,0 +1,129 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+func TestFetchWebsiteResultRedirects(t *testing.T) {
+	c := qt.New(t)
+	fetcher := &Fetcher{Client: http.DefaultClient}
+
+	mux := http.NewServeMux()
+	mux.Handle("/old", http.RedirectHandler("/login", http.StatusMovedPermanently))
+	mux.Handle("/login", http.RedirectHandler("/home", http.StatusFound))
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {})
+	mux.Handle("/loop", http.RedirectHandler("/loop2", http.StatusFound))
+	mux.Handle("/loop2", http.RedirectHandler("/loop", http.StatusFound))
+	svr := httptest.NewServer(mux)
+	defer svr.Close()
+
+	fetch := func(c *qt.C, path string, rp *domain.RedirectParams) *domain.WebsiteResult {
+		wp, err := domain.NewWebsiteParams(svr.URL+path, "", "")
+		c.Assert(err, qt.IsNil)
+		if rp != nil {
+			c.Assert(wp.SetRedirects(*rp), qt.IsNil)
+		}
+
+		wr, err := fetcher.FetchWebsiteResult(context.Background(), *wp)
+		c.Assert(err, qt.IsNil)
+		return wr
+	}
+
+	c.Run("Chain", func(c *qt.C) {
+		wr := fetch(c, "/old", nil)
+		c.Assert(wr.Failed(), qt.IsFalse)
+		c.Assert(*wr.Status, qt.Equals, http.StatusOK)
+		c.Assert(wr.FinalURL, qt.Equals, svr.URL+"/home")
+		c.Assert(wr.Redirects, qt.HasLen, 2)
+		c.Assert(wr.Redirects[0].URL, qt.Equals, svr.URL+"/old")
+		c.Assert(wr.Redirects[0].Status, qt.Equals, http.StatusMovedPermanently)
+		c.Assert(wr.Redirects[1].URL, qt.Equals, svr.URL+"/login")
+		c.Assert(wr.Redirects[1].Status, qt.Equals, http.StatusFound)
+		c.Assert(wr.Redirects[0].Elapsed+wr.Redirects[1].Elapsed <= wr.Elapsed, qt.IsTrue)
+	})
+
+	c.Run("No redirect", func(c *qt.C) {
+		wr := fetch(c, "/home", nil)
+		c.Assert(wr.Redirects, qt.IsNil)
+		c.Assert(wr.FinalURL, qt.Equals, "")
+	})
+
+	c.Run("Not followed", func(c *qt.C) {
+		// follow_redirects: false checks the redirect response itself
+		wr := fetch(c, "/old", &domain.RedirectParams{Max: 0})
+		c.Assert(wr.Failed(), qt.IsFalse)
+		c.Assert(wr.Error, qt.IsNil)
+		c.Assert(*wr.Status, qt.Equals, http.StatusMovedPermanently)
+		c.Assert(wr.Redirects, qt.IsNil)
+		c.Assert(wr.FinalURL, qt.Equals, "")
+
+		wr = fetch(c, "/old", &domain.RedirectParams{Max: 0, ExpectedURL: "/home"})
+		c.Assert(wr.Failed(), qt.IsTrue)
+		c.Assert(*wr.Error, qt.Equals, "final URL "+svr.URL+"/old is not "+svr.URL+"/home")
+	})
+
+	c.Run("Too many", func(c *qt.C) {
+		wr := fetch(c, "/old", &domain.RedirectParams{Max: 1})
+		c.Assert(wr.Failed(), qt.IsTrue)
+		c.Assert(*wr.Status, qt.Equals, http.StatusFound)
+		c.Assert(*wr.Error, qt.Equals, "stopped after 1 redirects")
+		c.Assert(wr.Redirects, qt.HasLen, 1)
+		c.Assert(wr.FinalURL, qt.Equals, svr.URL+"/login")
+	})
+
+	c.Run("Loop", func(c *qt.C) {
+		wr := fetch(c, "/loop", nil)
+		c.Assert(wr.Failed(), qt.IsTrue)
+		c.Assert(*wr.Error, qt.Equals, "redirect loop to "+svr.URL+"/loop")
+		c.Assert(wr.Redirects, qt.HasLen, 1)
+	})
+
+	c.Run("Expected final URL", func(c *qt.C) {
+		wr := fetch(c, "/old", &domain.RedirectParams{Max: domain.DefaultMaxRedirects, ExpectedURL: "/home"})
+		c.Assert(wr.Failed(), qt.IsFalse)
+
+		wr = fetch(c, "/old", &domain.RedirectParams{Max: domain.DefaultMaxRedirects, ExpectedURL: "/dashboard"})
+		c.Assert(wr.Failed(), qt.IsTrue)
+		c.Assert(*wr.Error, qt.Equals, "final URL "+svr.URL+"/home is not "+svr.URL+"/dashboard")
+	})
+
+	c.Run("Steps", func(c *qt.C) {
+		fetchStep := func(c *qt.C, path string, rp *domain.RedirectParams) *domain.WebsiteResult {
+			wp, err := domain.NewWebsiteParams(svr.URL, "", "")
+			c.Assert(err, qt.IsNil)
+			if rp != nil {
+				c.Assert(wp.SetRedirects(*rp), qt.IsNil)
+			}
+			s, err := domain.NewStep("", "", path, "")
+			c.Assert(err, qt.IsNil)
+			c.Assert(wp.SetSteps([]domain.Step{*s}), qt.IsNil)
+
+			wr, err := fetcher.FetchWebsiteResult(context.Background(), *wp)
+			c.Assert(err, qt.IsNil)
+			return wr
+		}
+
+		wr := fetchStep(c, "/old", nil)
+		c.Assert(wr.Failed(), qt.IsFalse)
+		c.Assert(*wr.Steps[0].Status, qt.Equals, http.StatusOK)
+
+		wr = fetchStep(c, "/old", &domain.RedirectParams{Max: 0})
+		c.Assert(wr.Failed(), qt.IsFalse)
+		c.Assert(*wr.Steps[0].Status, qt.Equals, http.StatusMovedPermanently)
+
+		wr = fetchStep(c, "/old", &domain.RedirectParams{Max: 1})
+		c.Assert(wr.Failed(), qt.IsTrue)
+		c.Assert(*wr.Steps[0].Error, qt.Equals, "stopped after 1 redirects")
+
+		wr = fetchStep(c, "/loop", nil)
+		c.Assert(wr.Failed(), qt.IsTrue)
+		c.Assert(*wr.Steps[0].Error, qt.Equals, "redirect loop to "+svr.URL+"/loop")
+	})
+}
@@ -0,0 +1,223 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+// fetchSteps performs the steps of a multi-step check in order, sharing a cookie jar
+// and the extracted variables, until one fails.
+func (f *Fetcher) fetchSteps(ctx context.Context, wp domain.WebsiteParams) (*domain.WebsiteResult, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't create cookie jar: %w", err)
+	}
+	client, err := f.client(wp)
+	if err != nil {
+		return nil, err
+	}
+	client.Jar = jar
+
+	wr := &domain.WebsiteResult{Probe: domain.ProbeHTTP}
+	vars := make(map[string]string)
+	for i, s := range wp.Steps {
+		sr, err := f.fetchStep(ctx, client, wp, s, vars)
+		wr.Elapsed += sr.Elapsed
+		wr.Status, wr.Matched = sr.Status, sr.Matched
+		wr.Steps = append(wr.Steps, sr)
+		if err != nil || sr.Failed() {
+			failed := i
+			wr.FailedStep = &failed
+			wr.Unreachable = err != nil
+
+			msg := fmt.Sprintf("step %d failed", i+1)
+			if s.Name != "" {
+				msg = fmt.Sprintf("step %d (%s) failed", i+1, s.Name)
+			}
+			if sr.Error != nil {
+				msg += ": " + *sr.Error
+			}
+			wr.Error = &msg
+			break
+		}
+	}
+	wr.At = time.Now().UTC()
+
+	return wr, nil
+}
+
+// fetchStep performs a step and extracts its variables into vars. It only returns an
+// error when the check timed out, the other failures are set in the step result.
+func (f *Fetcher) fetchStep(ctx context.Context, client *http.Client, wp domain.WebsiteParams, s domain.Step, vars map[string]string) (domain.StepResult, error) {
+	sr := domain.StepResult{Name: s.Name}
+	fail := func(err error) (domain.StepResult, error) {
+		msg := err.Error()
+		sr.Error = &msg
+		return sr, nil
+	}
+
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "step",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("step.name", s.Name),
+			attribute.String("http.method", s.Method),
+		))
+	defer span.End()
+
+	req, err := newStepRequest(ctx, wp.URL, s, vars)
+	if err != nil {
+		return fail(err)
+	}
+	if err := f.authorize(ctx, req, wp.Auth); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return sr, err
+		}
+		return fail(err)
+	}
+	span.SetAttributes(attribute.String("http.url", req.URL.Redacted()))
+	setUserAgent(req, wp)
+	if f.InjectTraceContext {
+		propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	}
+
+	// The redirects of every step are followed as the ones of the website
+	rr := newRedirectRecorder(wp)
+	client.CheckRedirect = rr.checkRedirect
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		sr.Elapsed = time.Since(start)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "can't fetch step")
+		if errors.Is(err, context.DeadlineExceeded) {
+			return sr, err
+		}
+		return fail(err)
+	}
+	body := newBodyReader(resp.Body, f.maxBodySize())
+	defer func() { _ = resp.Body.Close() }()
+
+	blob, err := io.ReadAll(body)
+	if err == nil {
+		sr.BodyTruncated, err = body.drain()
+	}
+	sr.Elapsed = time.Since(start)
+	sr.BodySize = body.n
+	sr.ContentType = resp.Header.Get("Content-Type")
+	if err != nil {
+		if ctx.Err() != nil {
+			return sr, err
+		}
+		return fail(fmt.Errorf("can't read body: %w", err))
+	}
+
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	f.checkAuth(resp, wp.Auth)
+	sr.Status = &resp.StatusCode
+	if s.MatchRegexp != nil {
+		matched := s.MatchRegexp.Match(blob)
+		sr.Matched = &matched
+	}
+	if rr.err != nil {
+		return fail(rr.err)
+	}
+	if sr.Failed() {
+		return sr, nil
+	}
+	if err := checkProto(resp, wp); err != nil {
+		return fail(err)
+	}
+
+	for _, e := range s.Extract {
+		v, err := extract(e, resp, blob, client.Jar)
+		if err != nil {
+			return fail(fmt.Errorf("can't extract %s: %w", e.Var, err))
+		}
+		vars[e.Var] = v
+	}
+
+	return sr, nil
+}
+
+// newStepRequest creates the request of a step replacing the variables
+// references. Its URL is resolved against the URL of the website.
+func newStepRequest(ctx context.Context, base url.URL, s domain.Step, vars map[string]string) (*http.Request, error) {
+	rawURL, err := domain.ExpandVars(s.URL, vars)
+	if err != nil {
+		return nil, err
+	}
+	u, err := base.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse URL: %w", err)
+	}
+
+	body, err := domain.ExpandVars(s.Body, vars)
+	if err != nil {
+		return nil, err
+	}
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, s.Method, u.String(), r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range s.Headers {
+		v, err := domain.ExpandVars(v, vars)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(k, v)
+	}
+
+	return req, nil
+}
+
+// extract returns the value of the variable from the response of a step.
+func extract(e domain.Extraction, resp *http.Response, blob []byte, jar http.CookieJar) (string, error) {
+	switch e.Source {
+	case domain.ExtractRegexp:
+		m := e.Regexp.FindSubmatch(blob)
+		if m == nil {
+			return "", fmt.Errorf("regexp %q does not match", e.Expr)
+		}
+		return string(m[1]), nil
+	case domain.ExtractJSONPath:
+		return e.JSONPath.Eval(blob)
+	case domain.ExtractHeader:
+		if vs := resp.Header.Values(e.Expr); len(vs) > 0 {
+			return vs[0], nil
+		}
+		return "", fmt.Errorf("header %q not found", e.Expr)
+	case domain.ExtractCookie:
+		cookies := resp.Cookies()
+		if jar != nil {
+			cookies = append(cookies, jar.Cookies(resp.Request.URL)...)
+		}
+		for _, c := range cookies {
+			if c.Name == e.Expr {
+				return c.Value, nil
+			}
+		}
+		return "", fmt.Errorf("cookie %q not found", e.Expr)
+	}
+	return "", fmt.Errorf("unknown extraction source %q", e.Source)
+}
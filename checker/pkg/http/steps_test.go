@@ -0,0 +1,159 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+func TestFetchWebsiteResultSteps(t *testing.T) {
+	c := qt.New(t)
+	fetcher := &Fetcher{Client: http.DefaultClient}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || string(body) != `{"password": "s3cr3t"}` {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: "s1", Path: "/"})
+		w.Header().Set("X-Request-Id", "r1")
+		fmt.Fprintln(w, `{"token": "t0k3n", "csrf": "<input name=csrf value=c5rf>"}`)
+	})
+	mux.HandleFunc("/cart/", func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("sid")
+		if err != nil || cookie.Value != "s1" || r.Header.Get("Authorization") != "Bearer t0k3n" ||
+			r.URL.Query().Get("csrf") != "c5rf" || r.URL.Path != "/cart/r1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprintln(w, "2 items")
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, strings.Repeat("a", 2048))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	svr := httptest.NewServer(mux)
+	defer svr.Close()
+
+	newSteps := func(c *qt.C, password, regexp string) *domain.WebsiteParams {
+		wp, err := domain.NewWebsiteParams(svr.URL, "", "")
+		c.Assert(err, qt.IsNil)
+
+		login, err := domain.NewStep("login", http.MethodPost, "/login", "")
+		c.Assert(err, qt.IsNil)
+		login.Body = fmt.Sprintf(`{"password": "%s"}`, password)
+		for _, e := range []struct {
+			name   string
+			source domain.ExtractSource
+			expr   string
+		}{
+			{"token", domain.ExtractJSONPath, "$.token"},
+			{"csrf", domain.ExtractRegexp, "value=(\\w+)"},
+			{"request", domain.ExtractHeader, "X-Request-Id"},
+			{"session", domain.ExtractCookie, "sid"},
+		} {
+			de, err := domain.NewExtraction(e.name, e.source, e.expr)
+			c.Assert(err, qt.IsNil)
+			login.Extract = append(login.Extract, *de)
+		}
+
+		cart, err := domain.NewStep("", "", "/cart/{{request}}?csrf={{csrf}}", regexp)
+		c.Assert(err, qt.IsNil)
+		cart.Headers = map[string]string{"Authorization": "Bearer {{token}}"}
+
+		c.Assert(wp.SetSteps([]domain.Step{*login, *cart}), qt.IsNil)
+		return wp
+	}
+
+	c.Run("OK", func(c *qt.C) {
+		wr, err := fetcher.FetchWebsiteResult(context.Background(), *newSteps(c, "s3cr3t", "items"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(wr.Failed(), qt.IsFalse, qt.Commentf("%v", wr.Error))
+		c.Assert(wr.Steps, qt.HasLen, 2)
+		c.Assert(wr.Steps[0].Name, qt.Equals, "login")
+		c.Assert(*wr.Steps[1].Status, qt.Equals, http.StatusOK)
+		c.Assert(*wr.Matched, qt.IsTrue)
+		c.Assert(wr.Steps[1].BodySize, qt.Equals, int64(len("2 items\n")))
+		c.Assert(wr.Steps[1].BodyTruncated, qt.IsFalse)
+		c.Assert(wr.Steps[1].ContentType, qt.Equals, "text/plain; charset=utf-8")
+		c.Assert(wr.Elapsed, qt.Equals, wr.Steps[0].Elapsed+wr.Steps[1].Elapsed)
+		c.Assert(wr.FailedStep, qt.IsNil)
+	})
+
+	c.Run("Failed status", func(c *qt.C) {
+		wr, err := fetcher.FetchWebsiteResult(context.Background(), *newSteps(c, "wrong", "items"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(wr.Failed(), qt.IsTrue)
+		c.Assert(wr.Steps, qt.HasLen, 1)
+		c.Assert(*wr.Status, qt.Equals, http.StatusUnauthorized)
+		c.Assert(*wr.FailedStep, qt.Equals, 0)
+		c.Assert(*wr.Error, qt.Equals, "step 1 (login) failed")
+	})
+
+	c.Run("Not matched", func(c *qt.C) {
+		wr, err := fetcher.FetchWebsiteResult(context.Background(), *newSteps(c, "s3cr3t", "empty"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(wr.Failed(), qt.IsTrue)
+		c.Assert(wr.Steps, qt.HasLen, 2)
+		c.Assert(*wr.Matched, qt.IsFalse)
+		c.Assert(*wr.FailedStep, qt.Equals, 1)
+	})
+
+	c.Run("Failed extraction", func(c *qt.C) {
+		wp := newSteps(c, "s3cr3t", "")
+		e, err := domain.NewExtraction("user", domain.ExtractJSONPath, "$.user")
+		c.Assert(err, qt.IsNil)
+		wp.Steps[0].Extract = append(wp.Steps[0].Extract, *e)
+
+		wr, err := fetcher.FetchWebsiteResult(context.Background(), *wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(wr.Failed(), qt.IsTrue)
+		c.Assert(*wr.Steps[0].Error, qt.Equals, `can't extract user: $.user: key "user" not found`)
+		c.Assert(*wr.Error, qt.Equals, `step 1 (login) failed: can't extract user: $.user: key "user" not found`)
+	})
+
+	c.Run("Large body", func(c *qt.C) {
+		wp, err := domain.NewWebsiteParams(svr.URL, "", "")
+		c.Assert(err, qt.IsNil)
+		large, err := domain.NewStep("", "", "/large", "a$")
+		c.Assert(err, qt.IsNil)
+		c.Assert(wp.SetSteps([]domain.Step{*large}), qt.IsNil)
+
+		fetcher := &Fetcher{Client: http.DefaultClient, MaxBodySize: 1024}
+		wr, err := fetcher.FetchWebsiteResult(context.Background(), *wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(wr.Failed(), qt.IsFalse, qt.Commentf("%v", wr.Error))
+		c.Assert(wr.Steps[0].BodySize, qt.Equals, int64(1024))
+		c.Assert(wr.Steps[0].BodyTruncated, qt.IsTrue)
+		c.Assert(wr.Steps[0].ContentType, qt.Equals, "text/html")
+	})
+
+	c.Run("Slow", func(c *qt.C) {
+		wp, err := domain.NewWebsiteParams(svr.URL, "", "")
+		c.Assert(err, qt.IsNil)
+		slow, err := domain.NewStep("", "", "/slow", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(wp.SetSteps([]domain.Step{*slow}), qt.IsNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		wr, err := fetcher.FetchWebsiteResult(ctx, *wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(wr.Unreachable, qt.IsTrue)
+		c.Assert(*wr.FailedStep, qt.Equals, 0)
+	})
+}
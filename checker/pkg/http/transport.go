@@ -0,0 +1,153 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+// client returns the client to check the website: a copy of the fetcher one
+// with the transport of the website client settings, if any. The host of the
+// website is pinned to its address in per IP mode so the Host header and the
+// TLS server name are preserved.
+func (f *Fetcher) client(wp domain.WebsiteParams) (*http.Client, error) {
+	client := *f.Client
+	if wp.Client == nil && wp.Addr == "" {
+		return &client, nil
+	}
+
+	var cp domain.ClientParams
+	if wp.Client != nil {
+		cp = *wp.Client
+	}
+	if wp.Addr != "" {
+		port := wp.URL.Port()
+		if port == "" {
+			port = map[string]string{"http": "80", "https": "443"}[wp.URL.Scheme]
+		}
+		cp.Resolve = append(append([]string(nil), cp.Resolve...), fmt.Sprintf("%s:%s:%s", wp.URL.Hostname(), port, wp.Addr))
+	}
+
+	t, err := f.transports.transport(cp)
+	if err != nil {
+		return nil, err
+	}
+	client.Transport = t
+	return &client, nil
+}
+
+// setUserAgent sets the User-Agent header of the website client settings, if any.
+func setUserAgent(req *http.Request, wp domain.WebsiteParams) {
+	if wp.Client != nil && wp.Client.UserAgent != "" {
+		req.Header.Set("User-Agent", wp.Client.UserAgent)
+	}
+}
+
+// checkProto returns an error if the website requires HTTP/2 and the response is not.
+func checkProto(resp *http.Response, wp domain.WebsiteParams) error {
+	if wp.Client != nil && wp.Client.HTTPVersion == domain.HTTPVersion2 && resp.ProtoMajor != 2 {
+		return fmt.Errorf("HTTP/2 was not negotiated: %s", resp.Proto)
+	}
+	return nil
+}
+
+// transportCache holds a transport per distinct client settings so the
+// connections are reused across checks. The zero value is ready to use.
+type transportCache struct {
+	mu         sync.Mutex
+	transports map[string]*http.Transport
+}
+
+// transport returns the transport of the client settings, creating it if needed.
+func (tc *transportCache) transport(cp domain.ClientParams) (*http.Transport, error) {
+	// The User-Agent is set per request
+	cp.UserAgent = ""
+	key := fmt.Sprintf("%+v", cp)
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	if t, ok := tc.transports[key]; ok {
+		return t, nil
+	}
+
+	t, err := newTransport(cp)
+	if err != nil {
+		return nil, err
+	}
+	if tc.transports == nil {
+		tc.transports = make(map[string]*http.Transport)
+	}
+	tc.transports[key] = t
+
+	return t, nil
+}
+
+// newTransport creates a transport from http.DefaultTransport tuned with the client settings.
+func newTransport(cp domain.ClientParams) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cp.Proxy != "" {
+		proxy, err := url.Parse(cp.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse proxy URL: %w", err)
+		}
+		t.Proxy = http.ProxyURL(proxy)
+	}
+	t.DisableKeepAlives = cp.DisableKeepAlives
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cp.InsecureSkipVerify}
+	if cp.CAFile != "" {
+		blob, err := os.ReadFile(cp.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't read CA file: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(blob) {
+			return nil, fmt.Errorf("no certificate found in %s", cp.CAFile)
+		}
+	}
+	if cp.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cp.CertFile, cp.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("can't load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	t.TLSClientConfig = tlsConfig
+
+	switch cp.HTTPVersion {
+	case domain.HTTPVersion1:
+		// A non nil map disables HTTP/2
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	case domain.HTTPVersion2:
+		t.ForceAttemptHTTP2 = true
+	}
+
+	addrs, err := cp.ResolvedAddrs()
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if cp.IPVersion != 0 {
+			network = fmt.Sprintf("tcp%d", cp.IPVersion)
+		}
+		if pinned, ok := addrs[addr]; ok {
+			addr = pinned
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	return t, nil
+}
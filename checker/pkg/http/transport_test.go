@@ -0,0 +1,206 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+func TestFetchWebsiteResultClient(t *testing.T) {
+	c := qt.New(t)
+	fetcher := &Fetcher{Client: http.DefaultClient}
+
+	var userAgent, proto string
+	var clientCerts int
+	svr := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent, proto = r.UserAgent(), r.Proto
+		clientCerts = len(r.TLS.PeerCertificates)
+	}))
+	svr.EnableHTTP2 = true
+	svr.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	svr.StartTLS()
+	defer svr.Close()
+	_, port, err := net.SplitHostPort(svr.Listener.Addr().String())
+	c.Assert(err, qt.IsNil)
+
+	dir := c.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	err = os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: svr.Certificate().Raw}), 0o600)
+	c.Assert(err, qt.IsNil)
+
+	fetch := func(c *qt.C, rawURL string, cp domain.ClientParams) *domain.WebsiteResult {
+		wp, err := domain.NewWebsiteParams(rawURL, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(wp.SetClient(cp), qt.IsNil)
+
+		wr, err := fetcher.FetchWebsiteResult(context.Background(), *wp)
+		c.Assert(err, qt.IsNil)
+		return wr
+	}
+
+	c.Run("CA file and HTTP version", func(c *qt.C) {
+		for _, version := range []string{domain.HTTPVersion1, domain.HTTPVersion2} {
+			wr := fetch(c, svr.URL, domain.ClientParams{CAFile: caFile, HTTPVersion: version, UserAgent: "gpagdispo/1.0"})
+			c.Assert(wr.Failed(), qt.IsFalse, qt.Commentf("%v", wr.Error))
+			c.Assert(userAgent, qt.Equals, "gpagdispo/1.0")
+			c.Assert(proto, qt.Equals, "HTTP/"+map[string]string{"1.1": "1.1", "2": "2.0"}[version])
+		}
+	})
+
+	c.Run("Untrusted", func(c *qt.C) {
+		wp, err := domain.NewWebsiteParams(svr.URL, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(wp.SetClient(domain.ClientParams{DisableKeepAlives: true}), qt.IsNil)
+		wr, err := fetcher.FetchWebsiteResult(context.Background(), *wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(*wr.Error, qt.Matches, "can't fetch website: .*certificate.*")
+
+		wr = fetch(c, svr.URL, domain.ClientParams{InsecureSkipVerify: true})
+		c.Assert(wr.Failed(), qt.IsFalse)
+	})
+
+	c.Run("Resolve", func(c *qt.C) {
+		// example.com is in the SANs of the httptest certificate
+		wr := fetch(c, "https://example.com:"+port, domain.ClientParams{
+			CAFile:  caFile,
+			Resolve: []string{"example.com:" + port + ":127.0.0.1"},
+		})
+		c.Assert(wr.Failed(), qt.IsFalse, qt.Commentf("%v", wr.Error))
+	})
+
+	c.Run("IPv6 only", func(c *qt.C) {
+		wp, err := domain.NewWebsiteParams(svr.URL, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(wp.SetClient(domain.ClientParams{InsecureSkipVerify: true, IPVersion: 6}), qt.IsNil)
+		wr, err := fetcher.FetchWebsiteResult(context.Background(), *wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(wr.Failed(), qt.IsTrue)
+		c.Assert(wr.Error, qt.Not(qt.IsNil))
+	})
+
+	c.Run("Client certificate", func(c *qt.C) {
+		certFile, keyFile := writeClientCert(c, dir)
+		wr := fetch(c, svr.URL, domain.ClientParams{CAFile: caFile, CertFile: certFile, KeyFile: keyFile})
+		c.Assert(wr.Failed(), qt.IsFalse)
+		c.Assert(clientCerts, qt.Equals, 1)
+
+		wr = fetch(c, svr.URL, domain.ClientParams{CAFile: caFile})
+		c.Assert(wr.Failed(), qt.IsFalse)
+		c.Assert(clientCerts, qt.Equals, 0)
+
+		wp, err := domain.NewWebsiteParams(svr.URL, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(wp.SetClient(domain.ClientParams{CertFile: keyFile, KeyFile: certFile}), qt.IsNil)
+		_, err = fetcher.FetchWebsiteResult(context.Background(), *wp)
+		c.Assert(err, qt.ErrorMatches, "can't load client certificate: .*")
+	})
+
+	c.Run("Cached transports", func(c *qt.C) {
+		var tc transportCache
+		t1, err := tc.transport(domain.ClientParams{IPVersion: 4, UserAgent: "foo"})
+		c.Assert(err, qt.IsNil)
+		t2, err := tc.transport(domain.ClientParams{IPVersion: 4, UserAgent: "bar"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(t1 == t2, qt.IsTrue)
+		t3, err := tc.transport(domain.ClientParams{IPVersion: 6})
+		c.Assert(err, qt.IsNil)
+		c.Assert(t1 == t3, qt.IsFalse)
+	})
+}
+
+// writeClientCert writes a self-signed client certificate and its key in dir.
+func writeClientCert(c *qt.C, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, qt.IsNil)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "probe"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	c.Assert(err, qt.IsNil)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, qt.IsNil)
+
+	certFile, keyFile = filepath.Join(dir, "probe.pem"), filepath.Join(dir, "probe.key")
+	c.Assert(os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600), qt.IsNil)
+	c.Assert(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600), qt.IsNil)
+	return certFile, keyFile
+}
+
+func TestFetchWebsiteResultPerIP(t *testing.T) {
+	c := qt.New(t)
+	fetcher := &Fetcher{Client: http.DefaultClient}
+
+	var host string
+	svr := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+	}))
+	defer svr.Close()
+	_, port, err := net.SplitHostPort(svr.Listener.Addr().String())
+	c.Assert(err, qt.IsNil)
+
+	// example.com is in the SANs of the httptest certificate
+	wp, err := domain.NewWebsiteParams("https://example.com:"+port, "", "")
+	c.Assert(err, qt.IsNil)
+	c.Assert(wp.SetClient(domain.ClientParams{InsecureSkipVerify: true}), qt.IsNil)
+	wp.Addr = "127.0.0.1"
+
+	wr, err := fetcher.FetchWebsiteResult(context.Background(), *wp)
+	c.Assert(err, qt.IsNil)
+	c.Assert(wr.Failed(), qt.IsFalse)
+	c.Assert(host, qt.Equals, "example.com:"+port)
+
+	c.Run("Closed port", func(c *qt.C) {
+		wp.PerIP = true
+		var mu sync.Mutex
+		produced := make(map[string]domain.WebsiteResult)
+		checker := &domain.Checker{
+			FetchWebsiteResult: fetcher.FetchWebsiteResult,
+			ProduceResult: func(ctx context.Context, wp domain.WebsiteParams, wr domain.WebsiteResult) error {
+				mu.Lock()
+				defer mu.Unlock()
+				produced[wr.IP] = wr
+				return nil
+			},
+			// The server only listens on 127.0.0.1, the connections to 127.0.0.2 are refused
+			LookupIPAddr: func(ctx context.Context, host string) ([]net.IPAddr, error) {
+				return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}, {IP: net.ParseIP("127.0.0.2")}}, nil
+			},
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+		defer cancel()
+		err := checker.Monitor(ctx, []domain.WebsiteParams{*wp}, 100*time.Millisecond)
+		c.Assert(err, qt.IsNil)
+
+		mu.Lock()
+		defer mu.Unlock()
+		c.Assert(produced, qt.HasLen, 2)
+		c.Assert(produced["127.0.0.1"].Failed(), qt.IsFalse)
+		refused := produced["127.0.0.2"]
+		c.Assert(refused.Failed(), qt.IsTrue)
+		c.Assert(refused.Unreachable, qt.IsFalse)
+		c.Assert(*refused.Error, qt.Matches, "can't fetch website: .*connection refused")
+	})
+}
@@ -1,12 +1,17 @@
 package kafka
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/Shopify/sarama"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
 )
@@ -15,8 +20,14 @@ const websiteTopic = "website.monitor"
 
 // Producer generates the website checks into a Kafka topic
 type Producer struct {
+	client      sarama.Client
 	kfkProducer sarama.AsyncProducer
 	wg          sync.WaitGroup
+
+	// queued is the number of messages waiting to be acknowledged
+	queued int64
+	// deliveryErrors is the number of messages that couldn't be delivered
+	deliveryErrors uint64
 }
 
 type payload struct {
@@ -31,28 +42,55 @@ func NewProducer(addrs []string, cfg Config) (*Producer, error) {
 		return nil, fmt.Errorf("can't create config: %w", err)
 	}
 
-	producer, err := sarama.NewAsyncProducer(addrs, saramaCfg)
+	// Successes are tracked to know the number of messages in flight
+	saramaCfg.Producer.Return.Successes = true
+
+	client, err := sarama.NewClient(addrs, saramaCfg)
+	if err != nil {
+		return nil, fmt.Errorf("can't create client: %w", err)
+	}
+
+	producer, err := sarama.NewAsyncProducerFromClient(client)
 	if err != nil {
+		_ = client.Close()
 		return nil, fmt.Errorf("can't create async producer: %w", err)
 	}
 
 	p := &Producer{
+		client:      client,
 		kfkProducer: producer,
 	}
 
-	p.wg.Add(1)
+	p.wg.Add(2)
 	go func() {
 		defer p.wg.Done()
 		for err := range p.kfkProducer.Errors() {
+			atomic.AddInt64(&p.queued, -1)
+			atomic.AddUint64(&p.deliveryErrors, 1)
 			log.Error().Err(err).Msg("Error producing result")
 		}
 	}()
+	go func() {
+		defer p.wg.Done()
+		for range p.kfkProducer.Successes() {
+			atomic.AddInt64(&p.queued, -1)
+		}
+	}()
 
 	return p, nil
 }
 
-// Produce produces a website check
-func (p *Producer) Produce(wp domain.WebsiteParams, wr domain.WebsiteResult) error {
+// Produce produces a website check. The trace context is sent in the message headers.
+func (p *Producer) Produce(ctx context.Context, wp domain.WebsiteParams, wr domain.WebsiteResult) error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, websiteTopic+" send",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination", websiteTopic),
+			attribute.String("messaging.kafka.message_key", wp.ID),
+		))
+	defer span.End()
+
 	blob, err := json.Marshal(&payload{
 		WebsiteParams: wp,
 		WebsiteResult: wr,
@@ -66,14 +104,35 @@ func (p *Producer) Produce(wp domain.WebsiteParams, wr domain.WebsiteResult) err
 		Key:   sarama.StringEncoder(wp.ID),
 		Value: sarama.ByteEncoder(blob),
 	}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{msg: message})
 
+	atomic.AddInt64(&p.queued, 1)
 	p.kfkProducer.Input() <- message
 
 	return nil
 }
 
+// QueueDepth returns the number of produced messages not yet acknowledged by the broker.
+func (p *Producer) QueueDepth() int64 {
+	return atomic.LoadInt64(&p.queued)
+}
+
+// DeliveryErrors returns the number of messages that couldn't be delivered.
+func (p *Producer) DeliveryErrors() uint64 {
+	return atomic.LoadUint64(&p.deliveryErrors)
+}
+
+// Ready returns an error if the brokers holding the topic can't be reached.
+func (p *Producer) Ready() error {
+	if err := p.client.RefreshMetadata(websiteTopic); err != nil {
+		return fmt.Errorf("can't reach Kafka brokers: %w", err)
+	}
+	return nil
+}
+
 // Close closes the resources of the producer
 func (p *Producer) Close() {
 	p.kfkProducer.AsyncClose()
 	p.wg.Wait()
+	_ = p.client.Close()
 }
@@ -0,0 +1,46 @@
+package kafka
+
+import (
+	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const tracerName = "github.com/sixstone-qq/gpagdispo/checker/pkg/kafka"
+
+// Compile-time check around interface implementation
+var _ propagation.TextMapCarrier = (*headerCarrier)(nil)
+
+// headerCarrier carries the trace context in the headers of a Kafka message.
+type headerCarrier struct {
+	msg *sarama.ProducerMessage
+}
+
+// Get returns the value of the header with the given key.
+func (c headerCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set sets a header replacing any header with the same key.
+func (c headerCarrier) Set(key, value string) {
+	for i, h := range c.msg.Headers {
+		if string(h.Key) == key {
+			c.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+// Keys returns the keys of the headers.
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(c.msg.Headers))
+	for i, h := range c.msg.Headers {
+		keys[i] = string(h.Key)
+	}
+	return keys
+}
@@ -0,0 +1,33 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	qt "github.com/frankban/quicktest"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestHeaderCarrier(t *testing.T) {
+	c := qt.New(t)
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01},
+		SpanID:     trace.SpanID{0x02},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	msg := &sarama.ProducerMessage{Headers: []sarama.RecordHeader{{Key: []byte("traceparent"), Value: []byte("stale")}}}
+	carrier := headerCarrier{msg: msg}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+
+	c.Assert(carrier.Keys(), qt.DeepEquals, []string{"traceparent"}, qt.Commentf("headers are replaced"))
+	c.Assert(carrier.Get("traceparent"), qt.Equals, "00-01000000000000000000000000000000-0200000000000000-01")
+
+	extracted := trace.SpanContextFromContext(propagation.TraceContext{}.Extract(context.Background(), carrier))
+	c.Assert(extracted.TraceID(), qt.Equals, sc.TraceID())
+	c.Assert(extracted.SpanID(), qt.Equals, sc.SpanID())
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+const namespace = "gpagdispo_checker"
+
+// Compile-time check around interface implementation
+var _ domain.CheckObserver = (*Checker)(nil)
+
+// Checker holds the Prometheus metrics of the checks. It implements domain.CheckObserver.
+type Checker struct {
+	checksExecuted *prometheus.CounterVec
+	checksFailed   *prometheus.CounterVec
+	checksSkipped  *prometheus.CounterVec
+	checksLate     *prometheus.CounterVec
+	fetchDuration  *prometheus.HistogramVec
+}
+
+// NewChecker creates and registers the checks metrics.
+func NewChecker(reg prometheus.Registerer) *Checker {
+	newCounter := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      help,
+		}, []string{"url"})
+	}
+
+	m := &Checker{
+		checksExecuted: newCounter("checks_executed_total", "Number of website checks executed."),
+		checksFailed:   newCounter("checks_failed_total", "Number of website checks that failed or couldn't be fetched."),
+		checksSkipped:  newCounter("checks_skipped_total", "Number of website checks skipped due to maintenance."),
+		checksLate:     newCounter("checks_late_total", "Number of website checks started late because the previous one was still running."),
+		fetchDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "fetch_duration_seconds",
+			Help:      "Time spent fetching a website.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"url"}),
+	}
+	reg.MustRegister(m.checksExecuted, m.checksFailed, m.checksSkipped, m.checksLate, m.fetchDuration)
+
+	return m
+}
+
+// CheckExecuted implements domain.CheckObserver.
+func (m *Checker) CheckExecuted(wp domain.WebsiteParams, elapsed time.Duration, failed bool) {
+	url := wp.URL.String()
+	m.checksExecuted.WithLabelValues(url).Inc()
+	m.fetchDuration.WithLabelValues(url).Observe(elapsed.Seconds())
+	if failed {
+		m.checksFailed.WithLabelValues(url).Inc()
+	}
+}
+
+// CheckSkipped implements domain.CheckObserver.
+func (m *Checker) CheckSkipped(wp domain.WebsiteParams) {
+	m.checksSkipped.WithLabelValues(wp.URL.String()).Inc()
+}
+
+// CheckLate implements domain.CheckObserver.
+func (m *Checker) CheckLate(wp domain.WebsiteParams) {
+	m.checksLate.WithLabelValues(wp.URL.String()).Inc()
+}
+
+// ProducerStats is the producer state exposed as metrics.
+type ProducerStats interface {
+	QueueDepth() int64
+	DeliveryErrors() uint64
+}
+
+// RegisterProducer registers the metrics of the Kafka producer.
+func RegisterProducer(reg prometheus.Registerer, p ProducerStats) {
+	reg.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "producer_queue_depth",
+			Help:      "Number of produced results not yet acknowledged by Kafka.",
+		}, func() float64 { return float64(p.QueueDepth()) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "producer_delivery_errors_total",
+			Help:      "Number of results that couldn't be delivered to Kafka.",
+		}, func() float64 { return float64(p.DeliveryErrors()) }),
+	)
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+type fakeProducer struct{}
+
+func (fakeProducer) QueueDepth() int64      { return 3 }
+func (fakeProducer) DeliveryErrors() uint64 { return 2 }
+
+func TestChecker(t *testing.T) {
+	c := qt.New(t)
+
+	reg := prometheus.NewPedanticRegistry()
+	m := NewChecker(reg)
+	RegisterProducer(reg, fakeProducer{})
+
+	wp, err := domain.NewWebsiteParams("http://foo.org", "", "")
+	c.Assert(err, qt.IsNil)
+
+	m.CheckExecuted(*wp, 100*time.Millisecond, false)
+	m.CheckExecuted(*wp, 200*time.Millisecond, true)
+	m.CheckSkipped(*wp)
+	m.CheckLate(*wp)
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP gpagdispo_checker_checks_executed_total Number of website checks executed.
+# TYPE gpagdispo_checker_checks_executed_total counter
+gpagdispo_checker_checks_executed_total{url="http://foo.org"} 2
+# HELP gpagdispo_checker_checks_failed_total Number of website checks that failed or couldn't be fetched.
+# TYPE gpagdispo_checker_checks_failed_total counter
+gpagdispo_checker_checks_failed_total{url="http://foo.org"} 1
+# HELP gpagdispo_checker_checks_late_total Number of website checks started late because the previous one was still running.
+# TYPE gpagdispo_checker_checks_late_total counter
+gpagdispo_checker_checks_late_total{url="http://foo.org"} 1
+# HELP gpagdispo_checker_checks_skipped_total Number of website checks skipped due to maintenance.
+# TYPE gpagdispo_checker_checks_skipped_total counter
+gpagdispo_checker_checks_skipped_total{url="http://foo.org"} 1
+# HELP gpagdispo_checker_producer_delivery_errors_total Number of results that couldn't be delivered to Kafka.
+# TYPE gpagdispo_checker_producer_delivery_errors_total counter
+gpagdispo_checker_producer_delivery_errors_total 2
+# HELP gpagdispo_checker_producer_queue_depth Number of produced results not yet acknowledged by Kafka.
+# TYPE gpagdispo_checker_producer_queue_depth gauge
+gpagdispo_checker_producer_queue_depth 3
+`),
+		"gpagdispo_checker_checks_executed_total",
+		"gpagdispo_checker_checks_failed_total",
+		"gpagdispo_checker_checks_late_total",
+		"gpagdispo_checker_checks_skipped_total",
+		"gpagdispo_checker_producer_delivery_errors_total",
+		"gpagdispo_checker_producer_queue_depth")
+	c.Assert(err, qt.IsNil)
+	c.Assert(testutil.CollectAndCount(m.fetchDuration), qt.Equals, 1)
+}
+
+func TestHandler(t *testing.T) {
+	c := qt.New(t)
+
+	reg := prometheus.NewRegistry()
+	wp, err := domain.NewWebsiteParams("http://foo.org", "", "")
+	c.Assert(err, qt.IsNil)
+	NewChecker(reg).CheckExecuted(*wp, time.Second, false)
+
+	kafkaErr := error(nil)
+	h := NewHandler(reg, map[string]ReadinessCheck{
+		"kafka": func(ctx context.Context) error { return kafkaErr },
+	})
+
+	get := func(path string) (int, string) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		body, err := io.ReadAll(rec.Body)
+		c.Assert(err, qt.IsNil)
+		return rec.Code, string(body)
+	}
+
+	code, body := get("/metrics")
+	c.Assert(code, qt.Equals, http.StatusOK)
+	c.Assert(body, qt.Contains, "gpagdispo_checker_fetch_duration_seconds")
+
+	code, _ = get("/healthz")
+	c.Assert(code, qt.Equals, http.StatusOK)
+
+	code, body = get("/readyz")
+	c.Assert(code, qt.Equals, http.StatusOK)
+	var statuses map[string]string
+	c.Assert(json.Unmarshal([]byte(body), &statuses), qt.IsNil)
+	c.Assert(statuses, qt.DeepEquals, map[string]string{"kafka": "ok"})
+
+	kafkaErr = errors.New("broker down")
+	code, body = get("/readyz")
+	c.Assert(code, qt.Equals, http.StatusServiceUnavailable)
+	c.Assert(json.Unmarshal([]byte(body), &statuses), qt.IsNil)
+	c.Assert(statuses, qt.DeepEquals, map[string]string{"kafka": "broker down"})
+}
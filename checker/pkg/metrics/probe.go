@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+// scrapeTimeoutOffset is subtracted from the Prometheus scrape timeout to have
+// time to answer before the scraper gives up.
+const scrapeTimeoutOffset = 500 * time.Millisecond
+
+// ProbeHandler checks a target on demand and renders the result as Prometheus
+// metrics, compatible with blackbox_exporter /probe?target=...&module=...
+type ProbeHandler struct {
+	// Modules holds the probe modules by name.
+	Modules            map[string]domain.ProbeModule
+	FetchWebsiteResult func(ctx context.Context, wp domain.WebsiteParams) (*domain.WebsiteResult, error)
+}
+
+// ServeHTTP implements http.Handler.
+func (h *ProbeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	target := params.Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	moduleName := params.Get("module")
+	if moduleName == "" {
+		moduleName = domain.DefaultProbeModule.Name
+	}
+	module, ok := h.Modules[moduleName]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown module %q", moduleName), http.StatusBadRequest)
+		return
+	}
+
+	wp, err := module.WebsiteParams(target)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid target: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), probeTimeout(r, module))
+	defer cancel()
+
+	reg := prometheus.NewRegistry()
+	h.probe(ctx, reg, *wp)
+
+	promhttp.HandlerFor(reg, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// probe fetches the website and registers the result metrics in reg.
+func (h *ProbeHandler) probe(ctx context.Context, reg prometheus.Registerer, wp domain.WebsiteParams) {
+	newGauge := func(name, help string) prometheus.Gauge {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help})
+		reg.MustRegister(g)
+		return g
+	}
+	success := newGauge("probe_success", "Displays whether or not the probe was a success.")
+	duration := newGauge("probe_duration_seconds", "Returns how long the probe took to complete in seconds.")
+
+	start := time.Now()
+	wr, err := h.FetchWebsiteResult(ctx, wp)
+	duration.Set(time.Since(start).Seconds())
+	if err != nil {
+		log.Error().Err(err).Str("url", wp.URL.Redacted()).Msg("can't probe target")
+		return
+	}
+
+	switch wp.Probe {
+	case domain.ProbeHTTP:
+		statusCode := newGauge("probe_http_status_code", "Response HTTP status code.")
+		if wr.Status != nil {
+			statusCode.Set(float64(*wr.Status))
+		}
+		if wr.Matched != nil {
+			regexFailed := newGauge("probe_failed_due_to_regex", "Indicates if probe failed due to regex.")
+			if !*wr.Matched {
+				regexFailed.Set(1)
+			}
+		}
+	case domain.ProbeTLS:
+		if wr.TLS != nil {
+			newGauge("probe_ssl_earliest_cert_expiry", "Returns earliest SSL cert expiry date.").
+				Set(float64(wr.TLS.NotAfter.Unix()))
+		}
+	case domain.ProbeDNS:
+		if wr.DNS != nil {
+			newGauge("probe_dns_answer_rrs", "Returns number of entries in the answer resource record list.").
+				Set(float64(len(wr.DNS.Answers)))
+		}
+	case domain.ProbeGRPC:
+		if wr.GRPC != nil {
+			newGauge("probe_grpc_status_code", "Response gRPC status code.").Set(float64(wr.GRPC.Code))
+		}
+	}
+	if !wr.Failed() {
+		success.Set(1)
+	}
+}
+
+// probeTimeout returns the module timeout bounded by the scrape timeout sent by Prometheus.
+func probeTimeout(r *http.Request, module domain.ProbeModule) time.Duration {
+	timeout := module.Timeout
+	seconds, err := strconv.ParseFloat(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"), 64)
+	if err != nil {
+		return timeout
+	}
+
+	scrapeTimeout := time.Duration(seconds*float64(time.Second)) - scrapeTimeoutOffset
+	if scrapeTimeout > 0 && scrapeTimeout < timeout {
+		timeout = scrapeTimeout
+	}
+	return timeout
+}
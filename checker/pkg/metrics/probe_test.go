@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+	chttp "github.com/sixstone-qq/gpagdispo/checker/pkg/http"
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/tcp"
+)
+
+func TestProbeHandler(t *testing.T) {
+	c := qt.New(t)
+
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		fmt.Fprint(w, `{"status": "ok"}`)
+	}))
+	defer svr.Close()
+
+	h := &ProbeHandler{
+		Modules: map[string]domain.ProbeModule{
+			domain.DefaultProbeModule.Name: domain.DefaultProbeModule,
+			"health": {
+				Name:        "health",
+				Method:      domain.HTTPMethodGet,
+				MatchRegexp: regexp.MustCompile(`"status": "(ok|degraded)"`),
+				Timeout:     time.Second,
+			},
+			"missing": {
+				Name:        "missing",
+				Method:      domain.HTTPMethodGet,
+				MatchRegexp: regexp.MustCompile(`not there`),
+				Timeout:     time.Second,
+			},
+		},
+		FetchWebsiteResult: domain.Fetchers{
+			domain.ProbeHTTP: (&chttp.Fetcher{Client: http.DefaultClient}).FetchWebsiteResult,
+			domain.ProbeTCP:  (&tcp.Fetcher{}).FetchWebsiteResult,
+		}.FetchWebsiteResult,
+	}
+
+	probe := func(target, module string) (int, string) {
+		rec := httptest.NewRecorder()
+		q := url.Values{"target": {target}}
+		if module != "" {
+			q.Set("module", module)
+		}
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/probe?"+q.Encode(), nil))
+		body, err := io.ReadAll(rec.Body)
+		c.Assert(err, qt.IsNil)
+		return rec.Code, string(body)
+	}
+
+	c.Run("Default module", func(c *qt.C) {
+		code, body := probe(svr.URL, "")
+		c.Assert(code, qt.Equals, http.StatusOK)
+		c.Assert(body, qt.Contains, "probe_success 1\n")
+		c.Assert(body, qt.Contains, "probe_http_status_code 200\n")
+		c.Assert(body, qt.Contains, "probe_duration_seconds ")
+		c.Assert(body, qt.Not(qt.Contains), "probe_failed_due_to_regex")
+	})
+
+	c.Run("Without scheme", func(c *qt.C) {
+		_, body := probe(strings.TrimPrefix(svr.URL, "http://"), "health")
+		c.Assert(body, qt.Contains, "probe_success 1\n")
+		c.Assert(body, qt.Contains, "probe_http_status_code 200\n")
+	})
+
+	c.Run("Error status", func(c *qt.C) {
+		code, body := probe(svr.URL+"/down", "http_2xx")
+		c.Assert(code, qt.Equals, http.StatusOK)
+		c.Assert(body, qt.Contains, "probe_success 0\n")
+		c.Assert(body, qt.Contains, "probe_http_status_code 503\n")
+	})
+
+	c.Run("Regexp", func(c *qt.C) {
+		_, body := probe(svr.URL, "health")
+		c.Assert(body, qt.Contains, "probe_success 1\n")
+		c.Assert(body, qt.Contains, "probe_failed_due_to_regex 0\n")
+
+		_, body = probe(svr.URL, "missing")
+		c.Assert(body, qt.Contains, "probe_success 0\n")
+		c.Assert(body, qt.Contains, "probe_failed_due_to_regex 1\n")
+	})
+
+	c.Run("Connection error", func(c *qt.C) {
+		_, body := probe("http://127.0.0.1:1", "")
+		c.Assert(body, qt.Contains, "probe_success 0\n")
+		c.Assert(body, qt.Contains, "probe_http_status_code 0\n")
+	})
+
+	c.Run("TCP", func(c *qt.C) {
+		_, body := probe("tcp://"+svr.Listener.Addr().String(), "")
+		c.Assert(body, qt.Contains, "probe_success 1\n")
+		c.Assert(body, qt.Not(qt.Contains), "probe_http_status_code")
+	})
+
+	c.Run("Bad request", func(c *qt.C) {
+		code, body := probe("", "")
+		c.Assert(code, qt.Equals, http.StatusBadRequest)
+		c.Assert(body, qt.Equals, "target parameter is missing\n")
+
+		code, body = probe(svr.URL, "icmp")
+		c.Assert(code, qt.Equals, http.StatusBadRequest)
+		c.Assert(body, qt.Equals, "unknown module \"icmp\"\n")
+
+		code, _ = probe("ftp://foo.org", "")
+		c.Assert(code, qt.Equals, http.StatusBadRequest)
+	})
+}
+
+func TestProbeTimeout(t *testing.T) {
+	c := qt.New(t)
+
+	module := domain.ProbeModule{Timeout: 10 * time.Second}
+	for header, expected := range map[string]time.Duration{
+		"":    10 * time.Second,
+		"5":   4500 * time.Millisecond,
+		"20":  10 * time.Second,
+		"0.1": 10 * time.Second,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/probe", nil)
+		if header != "" {
+			r.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", header)
+		}
+		c.Assert(probeTimeout(r, module), qt.Equals, expected, qt.Commentf(header))
+	}
+}
+
+// Make sure the modules timeout is honoured.
+func TestProbeHandlerTimeout(t *testing.T) {
+	c := qt.New(t)
+
+	h := &ProbeHandler{
+		Modules: map[string]domain.ProbeModule{"fast": {Name: "fast", Method: domain.HTTPMethodGet, Timeout: 10 * time.Millisecond}},
+		FetchWebsiteResult: func(ctx context.Context, wp domain.WebsiteParams) (*domain.WebsiteResult, error) {
+			<-ctx.Done()
+			return &domain.WebsiteResult{Unreachable: true}, nil
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/probe?module=fast&target=http://foo.org", nil))
+	c.Assert(rec.Code, qt.Equals, http.StatusOK)
+	c.Assert(rec.Body.String(), qt.Contains, "probe_success 0\n")
+}
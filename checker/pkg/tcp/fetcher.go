@@ -0,0 +1,155 @@
+// Package tcp fetches the results of the TCP and TLS probes.
+package tcp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+// defaultTLSPort is used by TLS probes without port.
+const defaultTLSPort = "443"
+
+// Fetcher connects to the address of TCP probes.
+type Fetcher struct {
+	Dialer net.Dialer
+}
+
+// FetchWebsiteResult fetches the result of a TCP probe: the time to establish the connection.
+func (f *Fetcher) FetchWebsiteResult(ctx context.Context, wp domain.WebsiteParams) (*domain.WebsiteResult, error) {
+	start := time.Now()
+	conn, err := f.Dialer.DialContext(ctx, "tcp", wp.URL.Host)
+	elapsed := time.Since(start)
+	if err != nil {
+		return failedResult(domain.ProbeTCP, elapsed, err), nil
+	}
+	_ = conn.Close()
+
+	return &domain.WebsiteResult{
+		Probe:   domain.ProbeTCP,
+		Elapsed: elapsed,
+		At:      time.Now().UTC(),
+	}, nil
+}
+
+// TLSFetcher performs a TLS handshake with the address of TLS probes and validates
+// the certificate of the server.
+type TLSFetcher struct {
+	Dialer net.Dialer
+	// RootCAs are the trusted certificate authorities. Nil uses the system ones.
+	RootCAs *x509.CertPool
+}
+
+// FetchWebsiteResult fetches the result of a TLS probe: the time to establish the connection
+// and complete the handshake. The check fails if the certificate is not trusted, expired or
+// expires within the minimum validity of the probe.
+func (f *TLSFetcher) FetchWebsiteResult(ctx context.Context, wp domain.WebsiteParams) (*domain.WebsiteResult, error) {
+	host, port := wp.URL.Hostname(), wp.URL.Port()
+	if port == "" {
+		port = defaultTLSPort
+	}
+
+	// The certificate is verified below to report the handshake details even if it is not trusted.
+	dialer := &tls.Dialer{
+		NetDialer: &f.Dialer,
+		Config:    &tls.Config{ServerName: host, InsecureSkipVerify: true},
+	}
+
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	elapsed := time.Since(start)
+	if err != nil {
+		return failedResult(domain.ProbeTLS, elapsed, err), nil
+	}
+	defer func() { _ = conn.Close() }()
+
+	state := conn.(*tls.Conn).ConnectionState()
+	wr := &domain.WebsiteResult{
+		Probe:   domain.ProbeTLS,
+		Elapsed: elapsed,
+		At:      time.Now().UTC(),
+	}
+	if len(state.PeerCertificates) == 0 {
+		msg := "no peer certificate"
+		wr.Error = &msg
+		return wr, nil
+	}
+
+	cert := state.PeerCertificates[0]
+	wr.TLS = &domain.TLSResult{
+		Version:     tlsVersion(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		Subject:     cert.Subject.String(),
+		Issuer:      cert.Issuer.String(),
+		NotAfter:    cert.NotAfter.UTC(),
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range state.PeerCertificates[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         f.RootCAs,
+		Intermediates: intermediates,
+		CurrentTime:   wr.At,
+	})
+	wr.TLS.Verified = err == nil
+
+	var minValidity time.Duration
+	if wp.TLS != nil {
+		minValidity = wp.TLS.MinCertValidity
+	}
+
+	switch {
+	case err != nil:
+		msg := fmt.Sprintf("can't verify certificate: %s", err)
+		wr.Error = &msg
+	case cert.NotAfter.Sub(wr.At) < minValidity:
+		msg := fmt.Sprintf("certificate expires at %s, within %s", wr.TLS.NotAfter.Format(time.RFC3339), minValidity)
+		wr.Error = &msg
+	}
+
+	return wr, nil
+}
+
+// failedResult returns the result of a failed connection: unreachable if it timed out.
+func failedResult(probe domain.ProbeType, elapsed time.Duration, err error) *domain.WebsiteResult {
+	wr := &domain.WebsiteResult{
+		Probe:   probe,
+		Elapsed: elapsed,
+		At:      time.Now().UTC(),
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		wr.Unreachable = true
+		return wr
+	}
+
+	msg := err.Error()
+	wr.Error = &msg
+
+	return wr
+}
+
+// tlsVersion returns the name of a TLS version.
+func tlsVersion(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
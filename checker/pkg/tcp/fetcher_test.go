@@ -0,0 +1,116 @@
+package tcp
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+func TestFetchWebsiteResult(t *testing.T) {
+	c := qt.New(t)
+	fetcher := &Fetcher{}
+
+	c.Run("OK", func(c *qt.C) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		c.Assert(err, qt.IsNil)
+		defer l.Close()
+
+		wp, err := domain.NewWebsiteParams("tcp://"+l.Addr().String(), "", "")
+		c.Assert(err, qt.IsNil)
+
+		wr, err := fetcher.FetchWebsiteResult(context.Background(), *wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(wr.Error, qt.IsNil)
+		c.Assert(wr.Unreachable, qt.IsFalse)
+		c.Assert(wr.Failed(), qt.IsFalse)
+	})
+
+	c.Run("Refused", func(c *qt.C) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		c.Assert(err, qt.IsNil)
+		addr := l.Addr().String()
+		c.Assert(l.Close(), qt.IsNil)
+
+		wp, err := domain.NewWebsiteParams("tcp://"+addr, "", "")
+		c.Assert(err, qt.IsNil)
+
+		wr, err := fetcher.FetchWebsiteResult(context.Background(), *wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(wr.Error, qt.Not(qt.IsNil))
+		c.Assert(*wr.Error, qt.Contains, "connection refused")
+		c.Assert(wr.Failed(), qt.IsTrue)
+	})
+
+	c.Run("Timeout", func(c *qt.C) {
+		wp, err := domain.NewWebsiteParams("tcp://127.0.0.1:1", "", "")
+		c.Assert(err, qt.IsNil)
+
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+		defer cancel()
+
+		wr, err := fetcher.FetchWebsiteResult(ctx, *wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(wr.Unreachable, qt.IsTrue)
+	})
+}
+
+func TestTLSFetchWebsiteResult(t *testing.T) {
+	c := qt.New(t)
+
+	svr := httptest.NewTLSServer(http.NotFoundHandler())
+	defer svr.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(svr.Certificate())
+
+	// The certificate of the test server is valid for 127.0.0.1
+	rawURL := strings.Replace(svr.URL, "https://", "tls://", 1)
+	fetcher := &TLSFetcher{RootCAs: roots}
+
+	c.Run("Verified", func(c *qt.C) {
+		wp, err := domain.NewWebsiteParams(rawURL, "", "")
+		c.Assert(err, qt.IsNil)
+
+		wr, err := fetcher.FetchWebsiteResult(context.Background(), *wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(wr.Error, qt.IsNil)
+		c.Assert(wr.TLS, qt.Not(qt.IsNil))
+		c.Assert(wr.TLS.Verified, qt.IsTrue)
+		c.Assert(wr.TLS.Version, qt.Equals, "TLS 1.3")
+		c.Assert(wr.TLS.Subject, qt.Contains, "Acme Co")
+		c.Assert(wr.Failed(), qt.IsFalse)
+	})
+
+	c.Run("Expiring", func(c *qt.C) {
+		wp, err := domain.NewWebsiteParams(rawURL, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(wp.SetProbeOptions(domain.ProbeOptions{MinCertValidity: "876000h"}), qt.IsNil)
+
+		wr, err := fetcher.FetchWebsiteResult(context.Background(), *wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(wr.TLS.Verified, qt.IsTrue)
+		c.Assert(wr.Error, qt.Not(qt.IsNil))
+		c.Assert(*wr.Error, qt.Matches, "certificate expires at .*, within 876000h0m0s")
+		c.Assert(wr.Failed(), qt.IsTrue)
+	})
+
+	c.Run("Untrusted", func(c *qt.C) {
+		wp, err := domain.NewWebsiteParams(rawURL, "", "")
+		c.Assert(err, qt.IsNil)
+
+		untrusted := &TLSFetcher{RootCAs: x509.NewCertPool()}
+		wr, err := untrusted.FetchWebsiteResult(context.Background(), *wp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(wr.TLS.Verified, qt.IsFalse)
+		c.Assert(strings.HasPrefix(*wr.Error, "can't verify certificate: "), qt.IsTrue)
+	})
+}
@@ -0,0 +1,58 @@
+// Package tracing sets up the OpenTelemetry tracing of the checks.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+)
+
+// Config defines the OTLP exporter configuration.
+type Config struct {
+	// ServiceName identifies the service in the traces.
+	ServiceName string
+	// Endpoint is the host:port of the OTLP HTTP collector. Tracing is disabled if empty.
+	Endpoint string
+	// Insecure disables TLS to connect to the collector.
+	Insecure bool
+	// SampleRatio is the ratio of traces to sample.
+	SampleRatio float64
+}
+
+// Setup sets the global propagator and, if an endpoint is configured, the global
+// tracer provider exporting spans to it. The returned function flushes and stops
+// the exporter.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("can't create OTLP exporter: %w", err)
+	}
+
+	tp := NewTracerProvider(cfg.ServiceName, sdktrace.WithBatcher(exporter), sdktrace.WithSampler(
+		sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))))
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+// NewTracerProvider returns a tracer provider identifying the given service.
+func NewTracerProvider(serviceName string, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))
+	return sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{sdktrace.WithResource(res)}, opts...)...)
+}
@@ -0,0 +1,144 @@
+// Package websocket fetches the results of the WebSocket probes.
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+// maxMessageSize bounds the size of the messages read from the servers.
+const maxMessageSize = 1 << 20
+
+// Fetcher opens a WebSocket with the URL of WebSocket probes and optionally
+// exchanges a message.
+type Fetcher struct {
+	// Dialer opens the WebSockets. Nil uses websocket.DefaultDialer.
+	Dialer *ws.Dialer
+}
+
+// FetchWebsiteResult fetches the result of a WebSocket probe. Once connected, the configured
+// message is sent and, if there is a message or a regular expression, the first message
+// received is matched against the regular expression. The WebSocket is then closed.
+// The check fails if the handshake fails or the server closes the connection before replying.
+func (f *Fetcher) FetchWebsiteResult(ctx context.Context, wp domain.WebsiteParams) (*domain.WebsiteResult, error) {
+	wr := &domain.WebsiteResult{Probe: domain.ProbeWebSocket}
+
+	start := time.Now()
+	c, err := f.dial(ctx, wp)
+	if err != nil {
+		return failed(ctx, wr, start, err), nil
+	}
+	wr.WebSocket = &domain.WebSocketResult{Handshake: time.Since(start)}
+	c.SetReadLimit(maxMessageSize)
+
+	// Reads and writes are not bound to the context once upgraded
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		_ = c.Close()
+	}()
+
+	var send string
+	if wp.WebSocket != nil {
+		send = wp.WebSocket.Send
+	}
+	if send != "" || wp.MatchRegexp != nil {
+		sent := time.Now()
+		if send != "" {
+			err = c.WriteMessage(ws.TextMessage, []byte(send))
+		}
+		var reply []byte
+		if err == nil {
+			// The pings received meanwhile are answered
+			_, reply, err = c.ReadMessage()
+		}
+		if err != nil {
+			return failed(ctx, wr, start, err), nil
+		}
+
+		latency := time.Since(sent)
+		wr.WebSocket.ReplyLatency = &latency
+		if wp.MatchRegexp != nil {
+			matched := wp.MatchRegexp.Match(reply)
+			wr.Matched = &matched
+		}
+	}
+	wr.Elapsed, wr.At = time.Since(start), time.Now().UTC()
+
+	// A server not completing the closing handshake does not fail the check
+	if code, err := closeConn(ctx, c); err == nil {
+		wr.WebSocket.CloseCode = &code
+	}
+
+	return wr, nil
+}
+
+// dial performs the opening handshake.
+func (f *Fetcher) dial(ctx context.Context, wp domain.WebsiteParams) (*ws.Conn, error) {
+	dialer := f.Dialer
+	if dialer == nil {
+		dialer = ws.DefaultDialer
+	}
+
+	c, resp, err := dialer.DialContext(ctx, wp.URL.String(), nil)
+	if errors.Is(err, ws.ErrBadHandshake) && resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("unexpected handshake status %d", resp.StatusCode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// closeConn sends a close frame and waits for the one of the server. It returns
+// the close code of the server.
+func closeConn(ctx context.Context, c *ws.Conn) (int, error) {
+	deadline, _ := ctx.Deadline()
+	err := c.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseNormalClosure, ""), deadline)
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		_, _, err := c.ReadMessage()
+		var closeErr *ws.CloseError
+		if errors.As(err, &closeErr) {
+			return closeErr.Code, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// failed fills the result of a failed check: unreachable if it timed out.
+func failed(ctx context.Context, wr *domain.WebsiteResult, start time.Time, err error) *domain.WebsiteResult {
+	wr.Elapsed, wr.At = time.Since(start), time.Now().UTC()
+
+	if ctx.Err() != nil {
+		wr.Unreachable = true
+		return wr
+	}
+
+	msg := err.Error()
+	var closeErr *ws.CloseError
+	if errors.As(err, &closeErr) {
+		wr.WebSocket.CloseCode = &closeErr.Code
+		msg = fmt.Sprintf("connection closed with code %d", closeErr.Code)
+	}
+	wr.Error = &msg
+
+	return wr
+}
@@ -0,0 +1,147 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	ws "github.com/gorilla/websocket"
+	"golang.org/x/net/websocket"
+
+	"github.com/sixstone-qq/gpagdispo/checker/pkg/domain"
+)
+
+func TestFetchWebsiteResult(t *testing.T) {
+	c := qt.New(t)
+
+	mux := http.NewServeMux()
+	// Replies "pong <message>" to every message
+	mux.Handle("/echo", websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		for {
+			var msg string
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				return
+			}
+			if err := websocket.Message.Send(ws, "pong "+msg); err != nil {
+				return
+			}
+		}
+	}})
+	// Greets once connected
+	mux.Handle("/greet", websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		_ = websocket.Message.Send(ws, "hello")
+		var msg string
+		_ = websocket.Message.Receive(ws, &msg)
+	}})
+	// Never replies
+	mux.Handle("/mute", websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		var msg string
+		for websocket.Message.Receive(ws, &msg) == nil {
+		}
+	}})
+	// Closes with policy violation on connection
+	mux.HandleFunc("/reject", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&ws.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.WriteMessage(ws.CloseMessage, ws.FormatCloseMessage(ws.ClosePolicyViolation, ""))
+	})
+	svr := httptest.NewServer(mux)
+	defer svr.Close()
+	baseURL := strings.Replace(svr.URL, "http://", "ws://", 1)
+
+	fetcher := &Fetcher{}
+	fetch := func(c *qt.C, path, rawRegexp, send string) *domain.WebsiteResult {
+		wp, err := domain.NewWebsiteParams(baseURL+path, "", rawRegexp)
+		c.Assert(err, qt.IsNil)
+		c.Assert(wp.SetProbeOptions(domain.ProbeOptions{Send: send}), qt.IsNil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		wr, err := fetcher.FetchWebsiteResult(ctx, *wp)
+		c.Assert(err, qt.IsNil)
+		return wr
+	}
+	normal := ws.CloseNormalClosure
+
+	c.Run("Handshake only", func(c *qt.C) {
+		wr := fetch(c, "/echo", "", "")
+		c.Assert(wr.Error, qt.IsNil)
+		c.Assert(wr.Matched, qt.IsNil)
+		c.Assert(wr.WebSocket.ReplyLatency, qt.IsNil)
+		c.Assert(wr.WebSocket.CloseCode, qt.DeepEquals, &normal)
+		c.Assert(wr.Failed(), qt.IsFalse)
+	})
+
+	c.Run("Exchange", func(c *qt.C) {
+		wr := fetch(c, "/echo", "^pong ping$", "ping")
+		c.Assert(wr.Error, qt.IsNil)
+		c.Assert(*wr.Matched, qt.IsTrue)
+		c.Assert(wr.WebSocket.ReplyLatency, qt.Not(qt.IsNil))
+		c.Assert(wr.WebSocket.CloseCode, qt.DeepEquals, &normal)
+		c.Assert(wr.Failed(), qt.IsFalse)
+
+		wr = fetch(c, "/echo", "^pang", "ping")
+		c.Assert(*wr.Matched, qt.IsFalse)
+		c.Assert(wr.Failed(), qt.IsTrue)
+	})
+
+	c.Run("Greeting", func(c *qt.C) {
+		wr := fetch(c, "/greet", "hello", "")
+		c.Assert(*wr.Matched, qt.IsTrue)
+		c.Assert(wr.Failed(), qt.IsFalse)
+	})
+
+	c.Run("No reply", func(c *qt.C) {
+		wr := fetch(c, "/mute", "", "ping")
+		c.Assert(wr.Unreachable, qt.IsTrue)
+		c.Assert(wr.WebSocket.ReplyLatency, qt.IsNil)
+	})
+
+	c.Run("Closed before reply", func(c *qt.C) {
+		wr := fetch(c, "/reject", "", "ping")
+		c.Assert(*wr.Error, qt.Equals, "connection closed with code 1008")
+		code := 1008
+		c.Assert(wr.WebSocket.CloseCode, qt.DeepEquals, &code)
+		c.Assert(wr.Failed(), qt.IsTrue)
+	})
+
+	c.Run("Not a WebSocket", func(c *qt.C) {
+		wr := fetch(c, "/unknown", "", "")
+		c.Assert(*wr.Error, qt.Equals, "unexpected handshake status 404")
+		c.Assert(wr.WebSocket, qt.IsNil)
+		c.Assert(wr.Failed(), qt.IsTrue)
+	})
+}
+
+func TestFetchWebsiteResultTLS(t *testing.T) {
+	c := qt.New(t)
+
+	svr := httptest.NewTLSServer(websocket.Server{Handler: func(ws *websocket.Conn) {
+		defer ws.Close()
+		var msg string
+		if websocket.Message.Receive(ws, &msg) == nil {
+			_ = websocket.Message.Send(ws, msg)
+		}
+	}})
+	defer svr.Close()
+
+	wp, err := domain.NewWebsiteParams(strings.Replace(svr.URL, "https://", "wss://", 1), "", "ping")
+	c.Assert(err, qt.IsNil)
+	c.Assert(wp.SetProbeOptions(domain.ProbeOptions{Send: "ping"}), qt.IsNil)
+
+	dialer := &ws.Dialer{TLSClientConfig: svr.Client().Transport.(*http.Transport).TLSClientConfig}
+	wr, err := (&Fetcher{Dialer: dialer}).FetchWebsiteResult(context.Background(), *wp)
+	c.Assert(err, qt.IsNil)
+	c.Assert(wr.Error, qt.IsNil)
+	c.Assert(*wr.Matched, qt.IsTrue)
+}
@@ -0,0 +1,42 @@
+#!/bin/bash
+# Runs the e2e assertions against the all-in-one binary on a temporary
+# SQLite database, the results are queried through the HTTP API.
+
+set -e
+
+source "e2e-test/assert.sh"
+
+addr="localhost:${HTTP_PORT:-8089}"
+
+pushd allinone
+go build ./cmd/gpagdispo/...
+popd
+
+dbdir=$(mktemp -d)
+
+CONFIG_PATH=checker/websites.ion DB_DSN="sqlite:$dbdir/gpagdispo.db" HTTP_ADDR="$addr" ./allinone/gpagdispo &
+pid=$!
+
+stopSvc() {
+    kill $pid 2> /dev/null || true
+    rm -rf "$dbdir"
+}
+
+trap stopSvc EXIT
+
+# Wait for 10s to produce data
+echo "Sleep for 10s..."
+sleep 10
+
+ids=$(curl -sf "http://$addr/websites" | jq -r '.[].id')
+websites=$(echo "$ids" | wc -w)
+results=0
+for id in $ids; do
+    n=$(curl -sf "http://$addr/websites/$id/results?limit=1000" | jq '.results | length')
+    results=$((results + n))
+done
+
+stopSvc
+
+assert "$websites" -eq 3
+assert "$results" -gt 6
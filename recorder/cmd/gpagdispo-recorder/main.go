@@ -2,38 +2,70 @@ package main
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 
 	env "github.com/caarlos0/env/v6"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
 
 	"github.com/sixstone-qq/gpagdispo/recorder/pkg/kafka"
-	"github.com/sixstone-qq/gpagdispo/recorder/pkg/pg"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/metrics"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/recorder"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/store"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/tracing"
 )
 
 type config struct {
-	KafkaBrokers  []string `env:"KAFKA_ADDRS" envDefault:"localhost:9092"`
-	KafkaCertFile string   `env:"KAFKA_CERT_FILE"`
-	KafkaKeyFile  string   `env:"KAFKA_KEY_FILE"`
-	KafkaCAFile   string   `env:"KAFKA_CA_FILE"`
-	PostgreSQLDSN string   `env:"POSTGRESQL_DSN" envDefault:"postgres://postgres@localhost/website_monitor?sslmode=disable"`
+	KafkaBrokers     []string `env:"KAFKA_ADDRS" envDefault:"localhost:9092"`
+	KafkaCertFile    string   `env:"KAFKA_CERT_FILE"`
+	KafkaKeyFile     string   `env:"KAFKA_KEY_FILE"`
+	KafkaCAFile      string   `env:"KAFKA_CA_FILE"`
+	PostgreSQLDSN    string   `env:"POSTGRESQL_DSN" envDefault:"postgres://postgres@localhost/website_monitor?sslmode=disable"`
+	DBDSN            string   `env:"DB_DSN"`
+	HTTPAddr         string   `env:"HTTP_ADDR"`
+	MetricsAddr      string   `env:"METRICS_ADDR"`
+	OTLPEndpoint     string   `env:"OTLP_ENDPOINT"`
+	OTLPInsecure     bool     `env:"OTLP_INSECURE"`
+	TraceSampleRatio float64  `env:"TRACE_SAMPLE_RATIO" envDefault:"1"`
+	Recorder         recorder.Config
 }
 
 func main() {
-	cfg := new(config)
+	// Results of several locations are expected
+	cfg := &config{Recorder: recorder.Config{StatusQuorum: 2}}
 
 	if err := env.Parse(cfg); err != nil {
 		log.Fatal().Err(err).Msg("can't parse configuration")
 	}
+	if err := cfg.Recorder.Validate(); err != nil {
+		log.Fatal().Err(err).Msg("invalid configuration")
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: "gpagdispo-recorder",
+		Endpoint:    cfg.OTLPEndpoint,
+		Insecure:    cfg.OTLPInsecure,
+		SampleRatio: cfg.TraceSampleRatio,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("can't set up tracing")
+	}
 
-	s, err := pg.NewStore(cfg.PostgreSQLDSN)
+	// DB_DSN takes precedence to use SQLite
+	dsn := cfg.DBDSN
+	if dsn == "" {
+		dsn = cfg.PostgreSQLDSN
+	}
+	s, err := store.Open(dsn)
 	if err != nil {
 		log.Fatal().Err(err).Msg("can't connect to DB")
 	}
 
-	err = s.CreateSchema("db/migrations")
+	err = s.CreateSchema(store.MigrationsPath("db", dsn))
 	if err != nil {
 		log.Fatal().Err(err).Msg("can't create DB schema")
 	}
@@ -43,17 +75,62 @@ func main() {
 	kafkaCfg.TLS.KeyFile = cfg.KafkaKeyFile
 	kafkaCfg.TLS.CertFile = cfg.KafkaCertFile
 
-	consumer, err := kafka.NewConsumer(cfg.KafkaBrokers, kafkaCfg, s.InsertWebsiteResult)
+	var recorderMetrics *metrics.Recorder
+	if cfg.MetricsAddr != "" {
+		recorderMetrics = metrics.NewRecorder(prometheus.DefaultRegisterer)
+	}
+
+	rec, err := recorder.New(context.Background(), cfg.Recorder, s, recorderMetrics)
+	if err != nil {
+		log.Fatal().Err(err).Msg("can't create recorder")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	recorderDone := make(chan struct{})
+	go func() {
+		rec.Run(ctx)
+		close(recorderDone)
+	}()
+
+	var svr *http.Server
+	if cfg.HTTPAddr != "" {
+		svr = &http.Server{Addr: cfg.HTTPAddr, Handler: rec.API}
+
+		go func() {
+			if err := svr.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal().Err(err).Msg("can't serve HTTP API")
+			}
+		}()
+	}
+
+	consumer, err := kafka.NewConsumer(cfg.KafkaBrokers, kafkaCfg, rec.HandleResult)
 	if err != nil {
 		log.Fatal().Err(err).Msg("can't create Kafka consumer")
 	}
 
+	var metricsSvr *http.Server
+	if recorderMetrics != nil {
+		consumer.Observer = recorderMetrics
+		metricsSvr = &http.Server{
+			Addr: cfg.MetricsAddr,
+			Handler: metrics.NewHandler(prometheus.DefaultGatherer, map[string]metrics.ReadinessCheck{
+				"db":    s.Ping,
+				"kafka": func(ctx context.Context) error { return consumer.Ready() },
+			}),
+		}
+
+		go func() {
+			if err := metricsSvr.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal().Err(err).Msg("can't serve metrics")
+			}
+		}()
+	}
+
 	// Gracefully shutdown
-	termChan := make(chan os.Signal)
+	termChan := make(chan os.Signal, 1)
 	signal.Notify(termChan, syscall.SIGTERM, syscall.SIGINT)
 
-	ctx, cancel := context.WithCancel(context.Background())
-
 	go func() {
 		<-termChan
 
@@ -61,9 +138,20 @@ func main() {
 
 		cancel()
 
+		if svr != nil {
+			_ = svr.Shutdown(context.Background())
+		}
+		if metricsSvr != nil {
+			_ = metricsSvr.Shutdown(context.Background())
+		}
 		consumer.Close()
 		s.Close()
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Error().Err(err).Msg("can't flush traces")
+		}
 	}()
 
 	_ = consumer.Consume(ctx)
+	// Wait for the queued results to be exported
+	<-recorderDone
 }
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS index_websites_results_on_location_at;
+ALTER TABLE websites_results DROP CONSTRAINT IF EXISTS websites_results_pkey;
+ALTER TABLE websites_results DROP COLUMN IF EXISTS location;
+ALTER TABLE websites_results ADD PRIMARY KEY (website_id, at);
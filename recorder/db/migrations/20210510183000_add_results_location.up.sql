@@ -0,0 +1,8 @@
+ALTER TABLE websites_results ADD COLUMN IF NOT EXISTS location TEXT NOT NULL DEFAULT '';
+
+-- Results from different locations can be recorded at the same time.
+ALTER TABLE websites_results DROP CONSTRAINT IF EXISTS websites_results_pkey;
+ALTER TABLE websites_results ADD PRIMARY KEY (website_id, location, at);
+
+-- Filter results per location.
+CREATE INDEX IF NOT EXISTS index_websites_results_on_location_at ON websites_results(location, at DESC);
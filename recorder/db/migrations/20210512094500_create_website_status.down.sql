@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS index_website_status_on_window_start_desc;
+DROP TABLE IF EXISTS website_status;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS website_status (
+       website_id TEXT REFERENCES websites(id),
+       window_start TIMESTAMP WITHOUT TIME ZONE,
+       window_end TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+       verdict TEXT NOT NULL,
+       locations INT NOT NULL,
+       failed_locations INT NOT NULL,
+
+       PRIMARY KEY (website_id, window_start)
+);
+
+-- Get latest verdicts.
+CREATE INDEX IF NOT EXISTS index_website_status_on_window_start_desc ON website_status(window_start DESC);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS index_incidents_on_website_id_open;
+DROP TABLE IF EXISTS incidents;
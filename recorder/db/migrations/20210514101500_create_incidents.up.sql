@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS incidents (
+       id BIGSERIAL PRIMARY KEY,
+       website_id TEXT NOT NULL REFERENCES websites(id),
+       started_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+       ended_at TIMESTAMP WITHOUT TIME ZONE,
+       cause TEXT NOT NULL,
+       failed_checks INT NOT NULL DEFAULT 0
+);
+
+-- Get open incidents.
+CREATE INDEX IF NOT EXISTS index_incidents_on_website_id_open ON incidents(website_id) WHERE ended_at IS NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS index_notifications_on_next_attempt_at;
+DROP TABLE IF EXISTS notifications;
+ALTER TABLE websites DROP COLUMN IF EXISTS tags;
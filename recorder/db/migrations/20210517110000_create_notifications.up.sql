@@ -0,0 +1,16 @@
+ALTER TABLE websites ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+-- Outbox of the notifications to deliver to receivers.
+CREATE TABLE IF NOT EXISTS notifications (
+       id BIGSERIAL PRIMARY KEY,
+       receiver TEXT NOT NULL,
+       payload JSONB NOT NULL,
+       attempts INT NOT NULL DEFAULT 0,
+       next_attempt_at TIMESTAMP WITHOUT TIME ZONE,
+       last_error TEXT,
+       created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (NOW() AT TIME ZONE 'utc'),
+       delivered_at TIMESTAMP WITHOUT TIME ZONE
+);
+
+-- Get pending notifications.
+CREATE INDEX IF NOT EXISTS index_notifications_on_next_attempt_at ON notifications(next_attempt_at) WHERE delivered_at IS NULL;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS websites_flapping (
+       website_id TEXT PRIMARY KEY REFERENCES websites(id),
+       flapping BOOLEAN NOT NULL,
+       score DOUBLE PRECISION NOT NULL,
+       since TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS maintenance_windows;
+ALTER TABLE websites_results DROP COLUMN IF EXISTS in_maintenance;
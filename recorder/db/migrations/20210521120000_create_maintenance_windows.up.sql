@@ -0,0 +1,16 @@
+ALTER TABLE websites_results ADD COLUMN IF NOT EXISTS in_maintenance BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+       id BIGSERIAL PRIMARY KEY,
+       website_id TEXT,
+       tag TEXT,
+       starts_at TIMESTAMP WITHOUT TIME ZONE,
+       ends_at TIMESTAMP WITHOUT TIME ZONE,
+       cron TEXT,
+       duration TEXT,
+       reason TEXT NOT NULL DEFAULT '',
+
+       CHECK ((website_id IS NULL) <> (tag IS NULL)),
+       CHECK ((cron IS NULL AND starts_at IS NOT NULL AND ends_at IS NOT NULL) OR
+              (cron IS NOT NULL AND duration IS NOT NULL AND starts_at IS NULL AND ends_at IS NULL))
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS websites_results_daily;
+DROP TABLE IF EXISTS websites_results_hourly;
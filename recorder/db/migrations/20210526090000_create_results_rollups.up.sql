@@ -0,0 +1,20 @@
+-- Results aggregated per website and hour or day. Results in maintenance are excluded.
+CREATE TABLE IF NOT EXISTS websites_results_hourly (
+       website_id TEXT REFERENCES websites(id),
+       bucket_start TIMESTAMP WITHOUT TIME ZONE,
+       checks INT NOT NULL,
+       failures INT NOT NULL,
+       min_elapsed DOUBLE PRECISION NOT NULL,
+       avg_elapsed DOUBLE PRECISION NOT NULL,
+       max_elapsed DOUBLE PRECISION NOT NULL,
+       p50_elapsed DOUBLE PRECISION NOT NULL,
+       p95_elapsed DOUBLE PRECISION NOT NULL,
+       p99_elapsed DOUBLE PRECISION NOT NULL,
+       -- Number of checks per HTTP status code
+       status_codes JSONB NOT NULL DEFAULT '{}',
+
+       PRIMARY KEY (website_id, bucket_start)
+);
+
+CREATE TABLE IF NOT EXISTS websites_results_daily (LIKE websites_results_hourly INCLUDING ALL);
+ALTER TABLE websites_results_daily ADD FOREIGN KEY (website_id) REFERENCES websites(id);
@@ -0,0 +1,26 @@
+CREATE TABLE websites_results_unpartitioned (
+       website_id TEXT REFERENCES websites(id),
+       elapsed_time DOUBLE PRECISION,
+       status INT,
+       matched BOOLEAN,
+       unreachable BOOLEAN DEFAULT FALSE,
+       at TIMESTAMP WITHOUT TIME ZONE,
+       location TEXT NOT NULL DEFAULT '',
+       in_maintenance BOOLEAN NOT NULL DEFAULT FALSE,
+
+       PRIMARY KEY (website_id, location, at)
+);
+
+INSERT INTO websites_results_unpartitioned(website_id, elapsed_time, status, matched, unreachable, at, location, in_maintenance)
+SELECT website_id, elapsed_time, status, matched, unreachable, at, location, in_maintenance
+FROM websites_results;
+
+-- Drops the attached partitions as well
+DROP TABLE websites_results;
+
+ALTER TABLE websites_results_unpartitioned RENAME TO websites_results;
+ALTER INDEX websites_results_unpartitioned_pkey RENAME TO websites_results_pkey;
+
+CREATE INDEX IF NOT EXISTS index_websites_results_on_at_desc ON websites_results(at DESC);
+CREATE INDEX IF NOT EXISTS index_websites_results_on_at_asc ON websites_results(at ASC);
+CREATE INDEX IF NOT EXISTS index_websites_results_on_location_at ON websites_results(location, at DESC);
@@ -0,0 +1,44 @@
+-- websites_results is partitioned by month on at. The partitions are named
+-- websites_results_YYYY_MM and managed by the recorder.
+ALTER TABLE websites_results RENAME TO websites_results_unpartitioned;
+ALTER INDEX websites_results_pkey RENAME TO websites_results_unpartitioned_pkey;
+
+-- Redundant with the primary key and the partition pruning.
+DROP INDEX IF EXISTS index_websites_results_on_at_asc;
+DROP INDEX IF EXISTS index_websites_results_on_at_desc;
+DROP INDEX IF EXISTS index_websites_results_on_location_at;
+
+CREATE TABLE websites_results (
+       website_id TEXT REFERENCES websites(id),
+       elapsed_time DOUBLE PRECISION,
+       status INT,
+       matched BOOLEAN,
+       unreachable BOOLEAN DEFAULT FALSE,
+       at TIMESTAMP WITHOUT TIME ZONE,
+       location TEXT NOT NULL DEFAULT '',
+       in_maintenance BOOLEAN NOT NULL DEFAULT FALSE,
+
+       PRIMARY KEY (website_id, location, at)
+) PARTITION BY RANGE (at);
+
+-- Filter results per location.
+CREATE INDEX IF NOT EXISTS index_websites_results_on_location_at ON websites_results(location, at DESC);
+
+-- Partitions from the oldest result until the next month.
+DO $$
+DECLARE
+       month TIMESTAMP := date_trunc('month', COALESCE((SELECT MIN(at) FROM websites_results_unpartitioned), now() AT TIME ZONE 'UTC'));
+       last TIMESTAMP := date_trunc('month', GREATEST((SELECT MAX(at) FROM websites_results_unpartitioned), now() AT TIME ZONE 'UTC')) + INTERVAL '1 month';
+BEGIN
+       WHILE month <= last LOOP
+             EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF websites_results FOR VALUES FROM (%L) TO (%L)',
+                            'websites_results_' || to_char(month, 'YYYY_MM'), month, month + INTERVAL '1 month');
+             month := month + INTERVAL '1 month';
+       END LOOP;
+END $$;
+
+INSERT INTO websites_results(website_id, elapsed_time, status, matched, unreachable, at, location, in_maintenance)
+SELECT website_id, elapsed_time, status, matched, unreachable, at, location, in_maintenance
+FROM websites_results_unpartitioned;
+
+DROP TABLE websites_results_unpartitioned;
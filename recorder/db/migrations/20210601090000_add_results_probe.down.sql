@@ -0,0 +1,3 @@
+ALTER TABLE websites_results DROP COLUMN IF EXISTS details;
+ALTER TABLE websites_results DROP COLUMN IF EXISTS error;
+ALTER TABLE websites_results DROP COLUMN IF EXISTS probe;
@@ -0,0 +1,4 @@
+-- Results of the TCP, TLS and DNS probes. details holds the TLS handshake and the DNS answers.
+ALTER TABLE websites_results ADD COLUMN IF NOT EXISTS probe TEXT NOT NULL DEFAULT 'http';
+ALTER TABLE websites_results ADD COLUMN IF NOT EXISTS error TEXT;
+ALTER TABLE websites_results ADD COLUMN IF NOT EXISTS details JSONB;
@@ -0,0 +1,17 @@
+-- Results of the steps of multi-step checks, child rows of websites_results.
+-- They are not partitioned so they are deleted along with the expired results.
+CREATE TABLE IF NOT EXISTS websites_step_results (
+       website_id TEXT NOT NULL REFERENCES websites(id),
+       location TEXT NOT NULL DEFAULT '',
+       at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+       position INT NOT NULL,
+       name TEXT NOT NULL DEFAULT '',
+       elapsed_time DOUBLE PRECISION,
+       status INT,
+       matched BOOLEAN,
+       error TEXT,
+
+       PRIMARY KEY (website_id, location, at, position)
+);
+
+CREATE INDEX IF NOT EXISTS index_websites_step_results_on_at ON websites_step_results(at);
@@ -0,0 +1,8 @@
+ALTER TABLE websites_results ADD COLUMN IF NOT EXISTS ip TEXT NOT NULL DEFAULT '';
+ALTER TABLE websites_step_results ADD COLUMN IF NOT EXISTS ip TEXT NOT NULL DEFAULT '';
+
+-- Per IP checks record one result per address of a website at the same time.
+ALTER TABLE websites_results DROP CONSTRAINT IF EXISTS websites_results_pkey;
+ALTER TABLE websites_results ADD PRIMARY KEY (website_id, location, ip, at);
+ALTER TABLE websites_step_results DROP CONSTRAINT IF EXISTS websites_step_results_pkey;
+ALTER TABLE websites_step_results ADD PRIMARY KEY (website_id, location, ip, at, position);
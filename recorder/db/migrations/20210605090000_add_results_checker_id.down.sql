@@ -0,0 +1,6 @@
+ALTER TABLE websites_step_results DROP CONSTRAINT IF EXISTS websites_step_results_pkey;
+ALTER TABLE websites_step_results DROP COLUMN IF EXISTS checker_id;
+ALTER TABLE websites_step_results ADD PRIMARY KEY (website_id, location, ip, at, position);
+ALTER TABLE websites_results DROP CONSTRAINT IF EXISTS websites_results_pkey;
+ALTER TABLE websites_results DROP COLUMN IF EXISTS checker_id;
+ALTER TABLE websites_results ADD PRIMARY KEY (website_id, location, ip, at);
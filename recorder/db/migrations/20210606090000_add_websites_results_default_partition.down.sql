@@ -0,0 +1,2 @@
+-- The results out of the range of the monthly partitions are dropped.
+DROP TABLE IF EXISTS websites_results_default;
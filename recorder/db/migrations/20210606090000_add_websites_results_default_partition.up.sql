@@ -0,0 +1,4 @@
+-- Results out of the range of the monthly partitions, e.g. from a checker with a wrong
+-- clock, are stored there instead of failing. They are moved to the partition of their
+-- month once it is created.
+CREATE TABLE IF NOT EXISTS websites_results_default PARTITION OF websites_results DEFAULT;
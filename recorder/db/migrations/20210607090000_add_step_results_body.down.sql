@@ -0,0 +1,3 @@
+ALTER TABLE websites_step_results DROP COLUMN IF EXISTS content_type;
+ALTER TABLE websites_step_results DROP COLUMN IF EXISTS body_truncated;
+ALTER TABLE websites_step_results DROP COLUMN IF EXISTS body_size;
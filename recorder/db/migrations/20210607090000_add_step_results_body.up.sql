@@ -0,0 +1,4 @@
+-- Size, truncation and content type of the response body of the steps.
+ALTER TABLE websites_step_results ADD COLUMN IF NOT EXISTS body_size BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE websites_step_results ADD COLUMN IF NOT EXISTS body_truncated BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE websites_step_results ADD COLUMN IF NOT EXISTS content_type TEXT NOT NULL DEFAULT '';
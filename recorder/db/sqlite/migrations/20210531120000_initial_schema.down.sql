@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS websites_results_daily;
+DROP TABLE IF EXISTS websites_results_hourly;
+DROP TABLE IF EXISTS maintenance_windows;
+DROP TABLE IF EXISTS websites_flapping;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS incidents;
+DROP TABLE IF EXISTS website_status;
+DROP TABLE IF EXISTS websites_results;
+DROP TABLE IF EXISTS websites;
@@ -0,0 +1,122 @@
+-- SQLite schema equivalent to the PostgreSQL one. Timestamps are stored as UTC text,
+-- tags and status codes as JSON and results are not partitioned.
+CREATE TABLE IF NOT EXISTS websites (
+       id TEXT PRIMARY KEY,
+       url TEXT NOT NULL,
+       method TEXT NOT NULL,
+       match_regexp TEXT,
+       tags TEXT NOT NULL DEFAULT '[]'
+);
+
+CREATE TABLE IF NOT EXISTS websites_results (
+       website_id TEXT REFERENCES websites(id),
+       elapsed_time DOUBLE PRECISION,
+       status INT,
+       matched BOOLEAN,
+       unreachable BOOLEAN DEFAULT FALSE,
+       at TIMESTAMP,
+       location TEXT NOT NULL DEFAULT '',
+       in_maintenance BOOLEAN NOT NULL DEFAULT FALSE,
+
+       PRIMARY KEY (website_id, location, at)
+);
+
+-- Get latest results.
+CREATE INDEX IF NOT EXISTS index_websites_results_on_website_id_at ON websites_results(website_id, at DESC);
+
+-- Roll up and expire results.
+CREATE INDEX IF NOT EXISTS index_websites_results_on_at ON websites_results(at);
+
+CREATE TABLE IF NOT EXISTS website_status (
+       website_id TEXT REFERENCES websites(id),
+       window_start TIMESTAMP,
+       window_end TIMESTAMP NOT NULL,
+       verdict TEXT NOT NULL,
+       locations INT NOT NULL,
+       failed_locations INT NOT NULL,
+
+       PRIMARY KEY (website_id, window_start)
+);
+
+CREATE TABLE IF NOT EXISTS incidents (
+       id INTEGER PRIMARY KEY AUTOINCREMENT,
+       website_id TEXT NOT NULL REFERENCES websites(id),
+       started_at TIMESTAMP NOT NULL,
+       ended_at TIMESTAMP,
+       cause TEXT NOT NULL,
+       failed_checks INT NOT NULL DEFAULT 0
+);
+
+-- Get open incidents.
+CREATE INDEX IF NOT EXISTS index_incidents_on_website_id_open ON incidents(website_id) WHERE ended_at IS NULL;
+
+-- Outbox of the notifications to deliver to receivers.
+CREATE TABLE IF NOT EXISTS notifications (
+       id INTEGER PRIMARY KEY AUTOINCREMENT,
+       receiver TEXT NOT NULL,
+       payload TEXT NOT NULL,
+       attempts INT NOT NULL DEFAULT 0,
+       next_attempt_at TIMESTAMP,
+       last_error TEXT,
+       created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+       delivered_at TIMESTAMP
+);
+
+-- Get pending notifications.
+CREATE INDEX IF NOT EXISTS index_notifications_on_next_attempt_at ON notifications(next_attempt_at) WHERE delivered_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS websites_flapping (
+       website_id TEXT PRIMARY KEY REFERENCES websites(id),
+       flapping BOOLEAN NOT NULL,
+       score DOUBLE PRECISION NOT NULL,
+       since TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+       id INTEGER PRIMARY KEY AUTOINCREMENT,
+       website_id TEXT,
+       tag TEXT,
+       starts_at TIMESTAMP,
+       ends_at TIMESTAMP,
+       cron TEXT,
+       duration TEXT,
+       reason TEXT NOT NULL DEFAULT '',
+
+       CHECK ((website_id IS NULL) <> (tag IS NULL)),
+       CHECK ((cron IS NULL AND starts_at IS NOT NULL AND ends_at IS NOT NULL) OR
+              (cron IS NOT NULL AND duration IS NOT NULL AND starts_at IS NULL AND ends_at IS NULL))
+);
+
+-- Results aggregated per website and hour or day. Results in maintenance are excluded.
+CREATE TABLE IF NOT EXISTS websites_results_hourly (
+       website_id TEXT REFERENCES websites(id),
+       bucket_start TIMESTAMP,
+       checks INT NOT NULL,
+       failures INT NOT NULL,
+       min_elapsed DOUBLE PRECISION NOT NULL,
+       avg_elapsed DOUBLE PRECISION NOT NULL,
+       max_elapsed DOUBLE PRECISION NOT NULL,
+       p50_elapsed DOUBLE PRECISION NOT NULL,
+       p95_elapsed DOUBLE PRECISION NOT NULL,
+       p99_elapsed DOUBLE PRECISION NOT NULL,
+       -- Number of checks per HTTP status code
+       status_codes TEXT NOT NULL DEFAULT '{}',
+
+       PRIMARY KEY (website_id, bucket_start)
+);
+
+CREATE TABLE IF NOT EXISTS websites_results_daily (
+       website_id TEXT REFERENCES websites(id),
+       bucket_start TIMESTAMP,
+       checks INT NOT NULL,
+       failures INT NOT NULL,
+       min_elapsed DOUBLE PRECISION NOT NULL,
+       avg_elapsed DOUBLE PRECISION NOT NULL,
+       max_elapsed DOUBLE PRECISION NOT NULL,
+       p50_elapsed DOUBLE PRECISION NOT NULL,
+       p95_elapsed DOUBLE PRECISION NOT NULL,
+       p99_elapsed DOUBLE PRECISION NOT NULL,
+       status_codes TEXT NOT NULL DEFAULT '{}',
+
+       PRIMARY KEY (website_id, bucket_start)
+);
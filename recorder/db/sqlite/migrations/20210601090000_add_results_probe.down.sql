@@ -0,0 +1,3 @@
+ALTER TABLE websites_results DROP COLUMN details;
+ALTER TABLE websites_results DROP COLUMN error;
+ALTER TABLE websites_results DROP COLUMN probe;
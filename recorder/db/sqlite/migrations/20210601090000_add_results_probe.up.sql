@@ -0,0 +1,4 @@
+-- Results of the TCP, TLS and DNS probes. details holds the TLS handshake and the DNS answers as JSON.
+ALTER TABLE websites_results ADD COLUMN probe TEXT NOT NULL DEFAULT 'http';
+ALTER TABLE websites_results ADD COLUMN error TEXT;
+ALTER TABLE websites_results ADD COLUMN details TEXT;
@@ -0,0 +1,50 @@
+-- Several checkers can run from the same location and check a website at the same time.
+-- SQLite can't alter a primary key so both tables are rebuilt.
+CREATE TABLE websites_results_checker_id (
+       website_id TEXT REFERENCES websites(id),
+       elapsed_time DOUBLE PRECISION,
+       status INT,
+       matched BOOLEAN,
+       unreachable BOOLEAN DEFAULT FALSE,
+       at TIMESTAMP,
+       location TEXT NOT NULL DEFAULT '',
+       in_maintenance BOOLEAN NOT NULL DEFAULT FALSE,
+       probe TEXT NOT NULL DEFAULT 'http',
+       error TEXT,
+       details TEXT,
+       ip TEXT NOT NULL DEFAULT '',
+       checker_id TEXT NOT NULL DEFAULT '',
+
+       PRIMARY KEY (website_id, location, checker_id, ip, at)
+);
+INSERT INTO websites_results_checker_id(website_id, elapsed_time, status, matched, unreachable, at, location, in_maintenance, probe, error, details, ip)
+SELECT website_id, elapsed_time, status, matched, unreachable, at, location, in_maintenance, probe, error, details, ip
+FROM websites_results;
+DROP TABLE websites_results;
+ALTER TABLE websites_results_checker_id RENAME TO websites_results;
+
+CREATE INDEX IF NOT EXISTS index_websites_results_on_website_id_at ON websites_results(website_id, at DESC);
+CREATE INDEX IF NOT EXISTS index_websites_results_on_at ON websites_results(at);
+
+CREATE TABLE websites_step_results_checker_id (
+       website_id TEXT NOT NULL REFERENCES websites(id),
+       location TEXT NOT NULL DEFAULT '',
+       checker_id TEXT NOT NULL DEFAULT '',
+       ip TEXT NOT NULL DEFAULT '',
+       at TIMESTAMP NOT NULL,
+       position INT NOT NULL,
+       name TEXT NOT NULL DEFAULT '',
+       elapsed_time DOUBLE PRECISION,
+       status INT,
+       matched BOOLEAN,
+       error TEXT,
+
+       PRIMARY KEY (website_id, location, checker_id, ip, at, position)
+);
+INSERT INTO websites_step_results_checker_id(website_id, location, ip, at, position, name, elapsed_time, status, matched, error)
+SELECT website_id, location, ip, at, position, name, elapsed_time, status, matched, error
+FROM websites_step_results;
+DROP TABLE websites_step_results;
+ALTER TABLE websites_step_results_checker_id RENAME TO websites_step_results;
+
+CREATE INDEX IF NOT EXISTS index_websites_step_results_on_at ON websites_step_results(at);
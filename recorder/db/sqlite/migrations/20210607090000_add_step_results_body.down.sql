@@ -0,0 +1,3 @@
+ALTER TABLE websites_step_results DROP COLUMN content_type;
+ALTER TABLE websites_step_results DROP COLUMN body_truncated;
+ALTER TABLE websites_step_results DROP COLUMN body_size;
@@ -7,8 +7,17 @@ require (
 	github.com/caarlos0/env/v6 v6.5.0
 	github.com/frankban/quicktest v1.12.1
 	github.com/golang-migrate/migrate/v4 v4.14.1
-	github.com/google/go-cmp v0.5.5
+	github.com/golang/snappy v0.0.2
+	github.com/google/go-cmp v0.5.6
 	github.com/jmoiron/sqlx v1.3.3
 	github.com/lib/pq v1.10.1
+	github.com/prometheus/client_golang v1.10.0
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/rs/zerolog v1.21.0
+	go.opentelemetry.io/otel v1.0.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.0.0
+	go.opentelemetry.io/otel/sdk v1.0.0
+	go.opentelemetry.io/otel/trace v1.0.0
+	google.golang.org/protobuf v1.27.1
+	modernc.org/sqlite v1.11.2
 )
@@ -0,0 +1,103 @@
+// Package api serves the recorder HTTP API to query the website history
+// and manage the maintenance windows, as well as the status page and badges.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+// Store defines the storage the API reads from and writes to.
+type Store interface {
+	Websites(ctx context.Context) ([]domain.WebsiteParams, error)
+	QueryWebsiteResults(ctx context.Context, q domain.ResultsQuery) ([]domain.WebsiteResult, error)
+	Uptime(ctx context.Context, websiteID string, from, to time.Time, bucket time.Duration) ([]domain.UptimeStats, error)
+	UptimeByWebsite(ctx context.Context, from, to time.Time, bucket time.Duration) (map[string][]domain.UptimeStats, error)
+	Rollups(ctx context.Context, websiteID string, period domain.RollupPeriod, from, to time.Time) ([]domain.Rollup, error)
+	LatestStatuses(ctx context.Context) ([]domain.WebsiteLatestStatus, error)
+	Incidents(ctx context.Context, since time.Time) ([]domain.Incident, error)
+
+	CreateMaintenanceWindow(ctx context.Context, mw *domain.MaintenanceWindow) error
+	MaintenanceWindows(ctx context.Context) ([]domain.MaintenanceWindow, error)
+	DeleteMaintenanceWindow(ctx context.Context, id int64) (bool, error)
+}
+
+// Handler serves the API endpoints.
+type Handler struct {
+	Store Store
+	// OnMaintenanceChange is optionally called after a maintenance window is created or deleted.
+	OnMaintenanceChange func(ctx context.Context) error
+	// StatusGroups are the tags to group the websites in the status page.
+	// A website belongs to the group of its first matching tag.
+	StatusGroups []string
+	// AdminToken is the bearer token required to create or delete maintenance windows.
+	// The API is read-only if it is empty.
+	AdminToken string
+
+	mux *http.ServeMux
+}
+
+// NewHandler creates the API handler on top of the store.
+func NewHandler(store Store) *Handler {
+	h := &Handler{Store: store, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/", h.handleStatusPage)
+	h.mux.HandleFunc("/badge/", h.handleBadge)
+	h.mux.HandleFunc("/websites", h.handleWebsites)
+	h.mux.HandleFunc("/websites/", h.handleWebsite)
+	h.mux.HandleFunc("/status", h.handleStatus)
+	h.mux.HandleFunc("/maintenances", h.handleMaintenances)
+	h.mux.HandleFunc("/maintenances/", h.handleMaintenance)
+
+	return h
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.mux.ServeHTTP(w, req)
+}
+
+// writeJSON writes v JSON encoded with the given status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("can't write response")
+	}
+}
+
+// writeError writes the error as JSON with the given status.
+func writeError(w http.ResponseWriter, status int, err error) {
+	if status >= http.StatusInternalServerError {
+		log.Error().Err(err).Msg("API error")
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
+
+// authorized checks the request bears the admin token, writing the error otherwise.
+func (h *Handler) authorized(w http.ResponseWriter, req *http.Request) bool {
+	if h.AdminToken == "" {
+		writeError(w, http.StatusForbidden, errors.New("read-only API"))
+		return false
+	}
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.AdminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+		return false
+	}
+	return true
+}
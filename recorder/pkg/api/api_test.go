@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// fakeStore stores everything in memory.
+type fakeStore struct {
+	websites  []domain.WebsiteParams
+	results   map[string][]domain.WebsiteResult
+	statuses  []domain.WebsiteLatestStatus
+	incidents []domain.Incident
+	// uptime overrides the stats returned by Uptime if set.
+	uptime          map[string][]domain.UptimeStats
+	rollupsByPeriod map[domain.RollupPeriod][]domain.Rollup
+	windows         []domain.MaintenanceWindow
+	nextID          int64
+
+	// Last queries received
+	resultsQuery domain.ResultsQuery
+	uptimeQuery  []interface{}
+}
+
+func (s *fakeStore) Websites(ctx context.Context) ([]domain.WebsiteParams, error) {
+	return s.websites, nil
+}
+
+func (s *fakeStore) QueryWebsiteResults(ctx context.Context, q domain.ResultsQuery) ([]domain.WebsiteResult, error) {
+	s.resultsQuery = q
+	var results []domain.WebsiteResult
+	for _, wr := range s.results[q.WebsiteID] {
+		if !wr.At.Before(q.From) && wr.At.Before(q.To) && (q.Location == "" || q.Location == wr.Location) {
+			results = append(results, wr)
+		}
+	}
+	if q.Offset >= len(results) {
+		return nil, nil
+	}
+	results = results[q.Offset:]
+	if len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results, nil
+}
+
+func (s *fakeStore) Uptime(ctx context.Context, websiteID string, from, to time.Time, bucket time.Duration) ([]domain.UptimeStats, error) {
+	s.uptimeQuery = []interface{}{websiteID, from, to, bucket}
+	if s.uptime != nil {
+		return s.uptime[websiteID], nil
+	}
+	if _, ok := s.results[websiteID]; !ok {
+		return nil, nil
+	}
+	return []domain.UptimeStats{{
+		From: from, To: to, Checks: 4, Failures: 1, Uptime: domain.Uptime(4, 1), P50: 0.1, P95: 0.3, P99: 0.5,
+	}}, nil
+}
+
+func (s *fakeStore) UptimeByWebsite(ctx context.Context, from, to time.Time, bucket time.Duration) (map[string][]domain.UptimeStats, error) {
+	s.uptimeQuery = []interface{}{"", from, to, bucket}
+	return s.uptime, nil
+}
+
+func (s *fakeStore) Rollups(ctx context.Context, websiteID string, period domain.RollupPeriod, from, to time.Time) ([]domain.Rollup, error) {
+	var rollups []domain.Rollup
+	for _, r := range s.rollupsByPeriod[period] {
+		if r.WebsiteID == websiteID && !r.From.Before(from) && r.From.Before(to) {
+			rollups = append(rollups, r)
+		}
+	}
+	return rollups, nil
+}
+
+func (s *fakeStore) LatestStatuses(ctx context.Context) ([]domain.WebsiteLatestStatus, error) {
+	return s.statuses, nil
+}
+
+func (s *fakeStore) Incidents(ctx context.Context, since time.Time) ([]domain.Incident, error) {
+	var incidents []domain.Incident
+	for _, in := range s.incidents {
+		if in.Open() || !in.EndedAt.Before(since) {
+			incidents = append(incidents, in)
+		}
+	}
+	return incidents, nil
+}
+
+func (s *fakeStore) CreateMaintenanceWindow(ctx context.Context, mw *domain.MaintenanceWindow) error {
+	s.nextID++
+	mw.ID = s.nextID
+	s.windows = append(s.windows, *mw)
+	return nil
+}
+
+func (s *fakeStore) MaintenanceWindows(ctx context.Context) ([]domain.MaintenanceWindow, error) {
+	return s.windows, nil
+}
+
+func (s *fakeStore) DeleteMaintenanceWindow(ctx context.Context, id int64) (bool, error) {
+	for i, mw := range s.windows {
+		if mw.ID == id {
+			s.windows = append(s.windows[:i], s.windows[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+const (
+	// DefaultBadgeDays is the period of the badges when days is not provided.
+	DefaultBadgeDays = 30
+
+	badgeCharWidth = 7
+	badgePadding   = 10
+)
+
+const (
+	colorGreen      = "#4c1"
+	colorYellow     = "#dfb317"
+	colorOrange     = "#fe7d37"
+	colorRed        = "#e05d44"
+	colorGrey       = "#9f9f9f"
+	colorBrightBlue = "#007ec6"
+)
+
+// badge is the data rendered by the badge template.
+type badge struct {
+	Label, Value string
+	Color        string
+}
+
+func (b badge) LabelWidth() int { return len(b.Label)*badgeCharWidth + badgePadding }
+func (b badge) ValueWidth() int { return len(b.Value)*badgeCharWidth + badgePadding }
+func (b badge) Width() int      { return b.LabelWidth() + b.ValueWidth() }
+func (b badge) LabelX() int     { return b.LabelWidth() / 2 }
+func (b badge) ValueX() int     { return b.LabelWidth() + b.ValueWidth()/2 }
+
+// handleBadge serves /badge/{website_id}.svg with the website uptime, or its p95 latency
+// with metric=latency, over the last days.
+func (h *Handler) handleBadge(w http.ResponseWriter, req *http.Request) {
+	websiteID := strings.TrimPrefix(req.URL.Path, "/badge/")
+	if !strings.HasSuffix(websiteID, ".svg") || strings.Contains(websiteID, "/") || websiteID == ".svg" {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	websiteID = strings.TrimSuffix(websiteID, ".svg")
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodHead)
+		return
+	}
+
+	params := req.URL.Query()
+	days, err := parseInt(params, "days", DefaultBadgeDays)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if days <= 0 || days > StatusPageDays {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("days must be between 1 and %d", StatusPageDays))
+		return
+	}
+	metric := params.Get("metric")
+	if metric == "" {
+		metric = "uptime"
+	}
+	if metric != "uptime" && metric != "latency" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown metric %q", metric))
+		return
+	}
+
+	to := time.Now().UTC()
+	stats, err := h.Store.Uptime(req.Context(), websiteID, to.AddDate(0, 0, -days), to, 0)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	var s domain.UptimeStats
+	if len(stats) > 0 {
+		s = stats[0]
+	}
+
+	var b badge
+	if metric == "latency" {
+		b = latencyBadge(s)
+	} else {
+		b = uptimeBadge(s)
+	}
+
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, "badge.svg", b); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("can't render badge: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "max-age=300")
+	_, _ = buf.WriteTo(w)
+}
+
+func uptimeBadge(s domain.UptimeStats) badge {
+	b := badge{Label: "uptime", Value: "no data", Color: colorGrey}
+	if s.Checks == 0 {
+		return b
+	}
+	b.Value = fmt.Sprintf("%.2f%%", s.Uptime)
+	switch {
+	case s.Uptime >= 99.9:
+		b.Color = colorGreen
+	case s.Uptime >= 99:
+		b.Color = colorYellow
+	case s.Uptime >= 95:
+		b.Color = colorOrange
+	default:
+		b.Color = colorRed
+	}
+	return b
+}
+
+func latencyBadge(s domain.UptimeStats) badge {
+	b := badge{Label: "latency p95", Value: "no data", Color: colorGrey}
+	if s.Checks == 0 {
+		return b
+	}
+	p95 := time.Duration(s.P95 * float64(time.Second))
+	b.Value = p95.Round(time.Millisecond).String()
+	switch {
+	case p95 < 300*time.Millisecond:
+		b.Color = colorGreen
+	case p95 < time.Second:
+		b.Color = colorBrightBlue
+	case p95 < 3*time.Second:
+		b.Color = colorYellow
+	default:
+		b.Color = colorRed
+	}
+	return b
+}
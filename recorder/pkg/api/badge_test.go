@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+func TestBadge(t *testing.T) {
+	c := qt.New(t)
+
+	store := &fakeStore{
+		uptime: map[string][]domain.UptimeStats{
+			"id1": {{Checks: 1000, Failures: 1, Uptime: 99.9, P50: 0.05, P95: 0.1234, P99: 0.5}},
+		},
+	}
+	h := NewHandler(store)
+
+	c.Run("Uptime", func(c *qt.C) {
+		rec := serve(h, http.MethodGet, "/badge/id1.svg", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		c.Assert(rec.Header().Get("Content-Type"), qt.Equals, "image/svg+xml")
+		c.Assert(rec.Body.String(), qt.Contains, `aria-label="uptime: 99.90%"`)
+		c.Assert(rec.Body.String(), qt.Contains, `fill="#4c1"`)
+
+		c.Assert(store.uptimeQuery[0], qt.Equals, "id1")
+		from, to := store.uptimeQuery[1].(time.Time), store.uptimeQuery[2].(time.Time)
+		c.Assert(to.Sub(from), qt.Equals, DefaultBadgeDays*24*time.Hour)
+		c.Assert(store.uptimeQuery[3], qt.Equals, time.Duration(0))
+	})
+
+	c.Run("Latency", func(c *qt.C) {
+		rec := serve(h, http.MethodGet, "/badge/id1.svg?metric=latency&days=7", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		c.Assert(rec.Body.String(), qt.Contains, `<text x="109" y="14">123ms</text>`)
+		c.Assert(rec.Body.String(), qt.Contains, `fill="#4c1"`)
+
+		from, to := store.uptimeQuery[1].(time.Time), store.uptimeQuery[2].(time.Time)
+		c.Assert(to.Sub(from), qt.Equals, 7*24*time.Hour)
+	})
+
+	c.Run("No data", func(c *qt.C) {
+		rec := serve(h, http.MethodGet, "/badge/unknown.svg", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		c.Assert(rec.Body.String(), qt.Contains, `aria-label="uptime: no data"`)
+		c.Assert(rec.Body.String(), qt.Contains, `fill="#9f9f9f"`)
+	})
+
+	c.Run("Colors", func(c *qt.C) {
+		c.Assert(uptimeBadge(domain.UptimeStats{Checks: 1, Uptime: 99.5}).Color, qt.Equals, colorYellow)
+		c.Assert(uptimeBadge(domain.UptimeStats{Checks: 1, Uptime: 96}).Color, qt.Equals, colorOrange)
+		c.Assert(uptimeBadge(domain.UptimeStats{Checks: 1, Uptime: 50}).Color, qt.Equals, colorRed)
+		c.Assert(latencyBadge(domain.UptimeStats{Checks: 1, P95: 0.5}).Color, qt.Equals, colorBrightBlue)
+		c.Assert(latencyBadge(domain.UptimeStats{Checks: 1, P95: 2}).Color, qt.Equals, colorYellow)
+		c.Assert(latencyBadge(domain.UptimeStats{Checks: 1, P95: 5}).Value, qt.Equals, "5s")
+	})
+
+	c.Run("Bad request", func(c *qt.C) {
+		for _, target := range []string{"/badge/id1.svg?metric=foo", "/badge/id1.svg?days=0", "/badge/id1.svg?days=91"} {
+			rec := serve(h, http.MethodGet, target, "")
+			c.Check(rec.Code, qt.Equals, http.StatusBadRequest, qt.Commentf(target))
+		}
+	})
+
+	c.Run("Not found", func(c *qt.C) {
+		for _, target := range []string{"/badge/id1", "/badge/.svg", "/badge/id1/foo.svg"} {
+			rec := serve(h, http.MethodGet, target, "")
+			c.Check(rec.Code, qt.Equals, http.StatusNotFound, qt.Commentf(target))
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+// handleMaintenances lists or creates maintenance windows. Creating requires the admin token.
+func (h *Handler) handleMaintenances(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		windows, err := h.Store.MaintenanceWindows(req.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if windows == nil {
+			windows = []domain.MaintenanceWindow{}
+		}
+		writeJSON(w, http.StatusOK, windows)
+
+	case http.MethodPost:
+		if !h.authorized(w, req) {
+			return
+		}
+		var mw domain.MaintenanceWindow
+		if err := json.NewDecoder(req.Body).Decode(&mw); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := mw.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := h.Store.CreateMaintenanceWindow(req.Context(), &mw); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		h.maintenanceChanged(req.Context())
+		writeJSON(w, http.StatusCreated, mw)
+
+	default:
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// handleMaintenance deletes a maintenance window. It requires the admin token.
+func (h *Handler) handleMaintenance(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodDelete {
+		writeMethodNotAllowed(w, http.MethodDelete)
+		return
+	}
+	if !h.authorized(w, req) {
+		return
+	}
+
+	id, err := strconv.ParseInt(strings.TrimPrefix(req.URL.Path, "/maintenances/"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, errors.New("maintenance window not found"))
+		return
+	}
+
+	found, err := h.Store.DeleteMaintenanceWindow(req.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, errors.New("maintenance window not found"))
+		return
+	}
+	h.maintenanceChanged(req.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) maintenanceChanged(ctx context.Context) {
+	if h.OnMaintenanceChange == nil {
+		return
+	}
+	if err := h.OnMaintenanceChange(ctx); err != nil {
+		log.Error().Err(err).Msg("can't handle maintenance change")
+	}
+}
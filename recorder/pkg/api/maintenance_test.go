@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+func TestMaintenances(t *testing.T) {
+	c := qt.New(t)
+
+	store := new(fakeStore)
+	changes := 0
+	h := NewHandler(store)
+	h.AdminToken = "s3cr3t"
+	h.OnMaintenanceChange = func(ctx context.Context) error {
+		changes++
+		return nil
+	}
+
+	c.Run("Empty list", func(c *qt.C) {
+		rec := serve(h, http.MethodGet, "/maintenances", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		c.Assert(rec.Body.String(), qt.JSONEquals, []interface{}{})
+	})
+
+	c.Run("Create", func(c *qt.C) {
+		rec := serveAdmin(h, http.MethodPost, "/maintenances",
+			`{"tag": "api", "cron": "0 3 * * SUN", "duration": "2h", "reason": "weekly deploy"}`)
+		c.Assert(rec.Code, qt.Equals, http.StatusCreated)
+		c.Assert(rec.Body.String(), qt.JSONEquals, map[string]interface{}{
+			"id":       1,
+			"tag":      "api",
+			"cron":     "0 3 * * SUN",
+			"duration": "2h",
+			"reason":   "weekly deploy",
+		})
+		c.Assert(changes, qt.Equals, 1)
+
+		rec = serveAdmin(h, http.MethodPost, "/maintenances",
+			`{"website_id": "id1", "starts_at": "2021-05-21T22:00:00Z", "ends_at": "2021-05-22T02:00:00Z"}`)
+		c.Assert(rec.Code, qt.Equals, http.StatusCreated)
+
+		rec = serve(h, http.MethodGet, "/maintenances", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		var windows []domain.MaintenanceWindow
+		err := json.Unmarshal(rec.Body.Bytes(), &windows)
+		c.Assert(err, qt.IsNil)
+		c.Assert(windows, qt.HasLen, 2)
+		c.Assert(*windows[1].WebsiteID, qt.Equals, "id1")
+	})
+
+	c.Run("Create invalid", func(c *qt.C) {
+		rec := serveAdmin(h, http.MethodPost, "/maintenances", `{"tag": "api"}`)
+		c.Assert(rec.Code, qt.Equals, http.StatusBadRequest)
+		c.Assert(rec.Body.String(), qt.JSONEquals, map[string]string{"error": "missing starts_at or ends_at"})
+
+		rec = serveAdmin(h, http.MethodPost, "/maintenances", `{`)
+		c.Assert(rec.Code, qt.Equals, http.StatusBadRequest)
+	})
+
+	c.Run("Delete", func(c *qt.C) {
+		rec := serveAdmin(h, http.MethodDelete, "/maintenances/1", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusNoContent)
+		c.Assert(changes, qt.Equals, 3)
+
+		rec = serveAdmin(h, http.MethodDelete, "/maintenances/1", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusNotFound)
+
+		rec = serveAdmin(h, http.MethodDelete, "/maintenances/foo", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusNotFound)
+	})
+
+	c.Run("Unauthorized", func(c *qt.C) {
+		rec := serve(h, http.MethodPost, "/maintenances", `{"tag": "api", "cron": "0 3 * * SUN", "duration": "2h"}`)
+		c.Assert(rec.Code, qt.Equals, http.StatusUnauthorized)
+		c.Assert(rec.Header().Get("WWW-Authenticate"), qt.Equals, "Bearer")
+
+		req := httptest.NewRequest(http.MethodDelete, "/maintenances/2", nil)
+		req.Header.Set("Authorization", "Bearer wrong")
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		c.Assert(rec.Code, qt.Equals, http.StatusUnauthorized)
+
+		readOnly := NewHandler(store)
+		rec = serveAdmin(readOnly, http.MethodDelete, "/maintenances/2", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusForbidden)
+		rec = serve(readOnly, http.MethodGet, "/maintenances", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		c.Assert(changes, qt.Equals, 3)
+	})
+
+	c.Run("Method not allowed", func(c *qt.C) {
+		rec := serve(h, http.MethodPut, "/maintenances", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusMethodNotAllowed)
+		c.Assert(rec.Header().Get("Allow"), qt.Equals, "GET, POST")
+	})
+}
+
+// serveAdmin serves the request with the admin token of the tests.
+func serveAdmin(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer s3cr3t")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+const (
+	// StatusPageDays is the number of days shown in the status page uptime bars.
+	StatusPageDays = 90
+	// RecentIncidentsPeriod is how long resolved incidents are shown in the status page.
+	RecentIncidentsPeriod = 7 * 24 * time.Hour
+)
+
+//go:embed templates
+var templatesFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"percent": func(v float64) string { return fmt.Sprintf("%.2f%%", v) },
+	"date":    func(t time.Time) string { return t.UTC().Format("2006-01-02") },
+	"time":    func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04 UTC") },
+}).ParseFS(templatesFS, "templates/*"))
+
+// statusPage is the data rendered by the status page template.
+type statusPage struct {
+	GeneratedAt time.Time
+	Groups      []*statusGroup
+}
+
+// statusGroup is a set of websites sharing a status group tag.
+type statusGroup struct {
+	Name            string
+	Websites        []websiteView
+	OpenIncidents   []incidentView
+	RecentIncidents []incidentView
+}
+
+type websiteView struct {
+	domain.WebsiteParams
+	// State is the verdict, or maintenance or unknown when there are no results.
+	State string
+	// Uptime is nil when there are no results within the days.
+	Uptime *float64
+	Days   []dayView
+}
+
+type dayView struct {
+	Day    time.Time
+	Checks int
+	Uptime float64
+	// Class is the bar CSS class: up, degraded, down or nodata.
+	Class string
+}
+
+type incidentView struct {
+	domain.Incident
+	URL string
+}
+
+// handleStatusPage renders the HTML status page.
+func (h *Handler) handleStatusPage(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		writeMethodNotAllowed(w, http.MethodGet, http.MethodHead)
+		return
+	}
+
+	page, err := h.statusPage(req.Context(), time.Now().UTC())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, "status.html", page); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("can't render status page: %w", err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = buf.WriteTo(w)
+}
+
+// statusPage builds the status page as of now.
+func (h *Handler) statusPage(ctx context.Context, now time.Time) (*statusPage, error) {
+	statuses, err := h.Store.LatestStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	incidents, err := h.Store.Incidents(ctx, now.Add(-RecentIncidentsPeriod))
+	if err != nil {
+		return nil, err
+	}
+
+	to := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+	from := to.AddDate(0, 0, -StatusPageDays)
+	uptime, err := h.Store.UptimeByWebsite(ctx, from, to, 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &statusPage{GeneratedAt: now}
+	groups := make(map[string]*statusGroup)
+	groupOf := make(map[string]*statusGroup)
+	for _, st := range statuses {
+		name := h.statusGroup(st.Website)
+		g, ok := groups[name]
+		if !ok {
+			g = &statusGroup{Name: name}
+			groups[name] = g
+		}
+		groupOf[st.Website.ID] = g
+		g.Websites = append(g.Websites, newWebsiteView(st, from, uptime[st.Website.ID]))
+	}
+
+	for _, in := range incidents {
+		g, ok := groupOf[in.WebsiteID]
+		if !ok {
+			continue
+		}
+		view := incidentView{Incident: in}
+		for _, wv := range g.Websites {
+			if wv.ID == in.WebsiteID {
+				view.URL = wv.URL
+			}
+		}
+		if in.Open() {
+			g.OpenIncidents = append(g.OpenIncidents, view)
+		} else {
+			g.RecentIncidents = append(g.RecentIncidents, view)
+		}
+	}
+
+	// Groups keep the configured order, ungrouped websites go last
+	for _, name := range append(append([]string{}, h.StatusGroups...), h.ungroupedName()) {
+		if g, ok := groups[name]; ok {
+			page.Groups = append(page.Groups, g)
+			delete(groups, name)
+		}
+	}
+
+	return page, nil
+}
+
+// statusGroup returns the name of the group the website belongs to.
+func (h *Handler) statusGroup(wp domain.WebsiteParams) string {
+	for _, tag := range h.StatusGroups {
+		if wp.HasAnyTag([]string{tag}) {
+			return tag
+		}
+	}
+	return h.ungroupedName()
+}
+
+func (h *Handler) ungroupedName() string {
+	if len(h.StatusGroups) == 0 {
+		return "Websites"
+	}
+	return "Other"
+}
+
+// newWebsiteView fills the daily bars starting at from with the daily stats.
+func newWebsiteView(st domain.WebsiteLatestStatus, from time.Time, stats []domain.UptimeStats) websiteView {
+	wv := websiteView{WebsiteParams: st.Website, State: websiteState(st)}
+
+	byDay := make(map[time.Time]domain.UptimeStats, len(stats))
+	checks, failures := 0, 0
+	for _, s := range stats {
+		byDay[s.From.UTC()] = s
+		checks += s.Checks
+		failures += s.Failures
+	}
+	if checks > 0 {
+		uptime := domain.Uptime(checks, failures)
+		wv.Uptime = &uptime
+	}
+
+	for i := 0; i < StatusPageDays; i++ {
+		day := from.AddDate(0, 0, i)
+		s := byDay[day]
+		wv.Days = append(wv.Days, dayView{Day: day, Checks: s.Checks, Uptime: s.Uptime, Class: uptimeClass(s)})
+	}
+
+	return wv
+}
+
+// websiteState returns the current state of the website.
+func websiteState(st domain.WebsiteLatestStatus) string {
+	switch {
+	case st.Result == nil:
+		return "unknown"
+	case st.Result.InMaintenance:
+		return "maintenance"
+	case st.Verdict != nil:
+		return string(*st.Verdict)
+	case st.Result.Failed():
+		return string(domain.VerdictDown)
+	default:
+		return string(domain.VerdictUp)
+	}
+}
+
+func uptimeClass(s domain.UptimeStats) string {
+	switch {
+	case s.Checks == 0:
+		return "nodata"
+	case s.Uptime >= 99.9:
+		return string(domain.VerdictUp)
+	case s.Uptime >= 95:
+		return string(domain.VerdictDegraded)
+	default:
+		return string(domain.VerdictDown)
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+func TestStatusPage(t *testing.T) {
+	c := qt.New(t)
+
+	ok := http.StatusOK
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+	down := domain.VerdictDown
+	resolvedAt := now.Add(-time.Hour)
+	oldResolvedAt := now.Add(-RecentIncidentsPeriod - time.Hour)
+	store := &fakeStore{
+		statuses: []domain.WebsiteLatestStatus{
+			{
+				Website: domain.WebsiteParams{ID: "id1", URL: "http://api.example.com", Tags: []string{"prod", "api"}},
+				Result:  &domain.WebsiteResult{Unreachable: true, At: now},
+				Verdict: &down,
+			},
+			{
+				Website: domain.WebsiteParams{ID: "id2", URL: "http://example.com", Tags: []string{"web"}},
+				Result:  &domain.WebsiteResult{Status: &ok, At: now},
+			},
+			{
+				Website: domain.WebsiteParams{ID: "id3", URL: "http://blog.example.com"},
+				Result:  &domain.WebsiteResult{Status: &ok, At: now, InMaintenance: true},
+			},
+			{
+				Website: domain.WebsiteParams{ID: "id4", URL: "http://new.example.com", Tags: []string{"api"}},
+			},
+		},
+		incidents: []domain.Incident{
+			{ID: 3, WebsiteID: "id1", StartedAt: now.Add(-time.Minute), Cause: domain.CauseTimeout, FailedChecks: 3},
+			{ID: 2, WebsiteID: "id2", StartedAt: now.Add(-2 * time.Hour), EndedAt: &resolvedAt, Cause: domain.CauseStatus, FailedChecks: 5},
+			{ID: 1, WebsiteID: "id2", StartedAt: oldResolvedAt.Add(-time.Hour), EndedAt: &oldResolvedAt, Cause: domain.CauseStatus, FailedChecks: 5},
+		},
+		uptime: map[string][]domain.UptimeStats{
+			"id1": {
+				{From: today.AddDate(0, 0, -89), Checks: 10, Failures: 0, Uptime: 100},
+				{From: today, Checks: 10, Failures: 5, Uptime: 50},
+			},
+			"id2": {
+				{From: today.AddDate(0, 0, -1), Checks: 1000, Failures: 2, Uptime: 99.8},
+			},
+		},
+	}
+	h := NewHandler(store)
+	h.StatusGroups = []string{"api", "web"}
+
+	c.Run("Build", func(c *qt.C) {
+		page, err := h.statusPage(context.Background(), now)
+		c.Assert(err, qt.IsNil)
+		c.Assert(page.Groups, qt.HasLen, 3)
+		c.Assert(store.uptimeQuery[0], qt.Equals, "", qt.Commentf("the uptime of every website is queried at once"))
+		c.Assert(store.uptimeQuery[3], qt.Equals, 24*time.Hour)
+
+		api := page.Groups[0]
+		c.Assert(api.Name, qt.Equals, "api")
+		c.Assert(api.Websites, qt.HasLen, 2)
+		c.Assert(api.Websites[0].State, qt.Equals, "down")
+		c.Assert(*api.Websites[0].Uptime, qt.Equals, 75.0)
+		c.Assert(api.Websites[0].Days, qt.HasLen, StatusPageDays)
+		c.Assert(api.Websites[0].Days[0], qt.DeepEquals, dayView{Day: today.AddDate(0, 0, -89), Checks: 10, Uptime: 100, Class: "up"})
+		c.Assert(api.Websites[0].Days[1].Class, qt.Equals, "nodata")
+		c.Assert(api.Websites[0].Days[89].Class, qt.Equals, "down")
+		c.Assert(api.Websites[1].State, qt.Equals, "unknown")
+		c.Assert(api.Websites[1].Uptime, qt.IsNil)
+		c.Assert(api.OpenIncidents, qt.HasLen, 1)
+		c.Assert(api.OpenIncidents[0].URL, qt.Equals, "http://api.example.com")
+		c.Assert(api.RecentIncidents, qt.HasLen, 0)
+
+		web := page.Groups[1]
+		c.Assert(web.Name, qt.Equals, "web")
+		c.Assert(web.Websites[0].State, qt.Equals, "up")
+		c.Assert(web.Websites[0].Days[88].Class, qt.Equals, "degraded")
+		c.Assert(web.OpenIncidents, qt.HasLen, 0)
+		c.Assert(web.RecentIncidents, qt.HasLen, 1)
+		c.Assert(web.RecentIncidents[0].ID, qt.Equals, int64(2))
+
+		other := page.Groups[2]
+		c.Assert(other.Name, qt.Equals, "Other")
+		c.Assert(other.Websites[0].State, qt.Equals, "maintenance")
+	})
+
+	c.Run("Render", func(c *qt.C) {
+		rec := serve(h, http.MethodGet, "/", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		c.Assert(rec.Header().Get("Content-Type"), qt.Equals, "text/html; charset=utf-8")
+		body := rec.Body.String()
+		c.Assert(body, qt.Contains, `<h2>api</h2>`)
+		c.Assert(body, qt.Contains, `<a href="http://api.example.com">http://api.example.com</a> <span class="state down">down</span>`)
+		c.Assert(body, qt.Contains, `<strong>http://api.example.com</strong>: timeout since`)
+		c.Assert(body, qt.Contains, `<span>75.00% uptime</span>`)
+		c.Assert(body, qt.Contains, `title="`+today.Format("2006-01-02")+`: 50.00% of 10 checks"`)
+		c.Assert(body, qt.Not(qt.Contains), `<script`)
+	})
+
+	c.Run("Empty", func(c *qt.C) {
+		rec := serve(NewHandler(new(fakeStore)), http.MethodGet, "/", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		c.Assert(rec.Body.String(), qt.Contains, "No websites checked yet.")
+	})
+
+	c.Run("Not found", func(c *qt.C) {
+		rec := serve(h, http.MethodGet, "/foo", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusNotFound)
+	})
+}
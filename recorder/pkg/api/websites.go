@@ -0,0 +1,252 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+const (
+	// DefaultRange is the queried period when from is not provided.
+	DefaultRange = 24 * time.Hour
+	// DefaultLimit is the page size of the results when limit is not provided.
+	DefaultLimit = 100
+	// MaxLimit is the maximum page size of the results.
+	MaxLimit = 1000
+)
+
+// resultsPage is the response of the website results endpoint.
+type resultsPage struct {
+	Results []domain.WebsiteResult `json:"results"`
+	Limit   int                    `json:"limit"`
+	Offset  int                    `json:"offset"`
+}
+
+// uptimeReport is the response of the website uptime endpoint.
+type uptimeReport struct {
+	WebsiteID string               `json:"website_id"`
+	From      time.Time            `json:"from"`
+	To        time.Time            `json:"to"`
+	Bucket    string               `json:"bucket,omitempty"`
+	Stats     []domain.UptimeStats `json:"stats"`
+}
+
+// handleWebsites lists the websites.
+func (h *Handler) handleWebsites(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	websites, err := h.Store.Websites(req.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if websites == nil {
+		websites = []domain.WebsiteParams{}
+	}
+	writeJSON(w, http.StatusOK, websites)
+}
+
+// handleWebsite routes /websites/{id}/results, /websites/{id}/uptime and /websites/{id}/rollups.
+func (h *Handler) handleWebsite(w http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/websites/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	var handle func(w http.ResponseWriter, req *http.Request, websiteID string)
+	switch parts[1] {
+	case "results":
+		handle = h.handleResults
+	case "uptime":
+		handle = h.handleUptime
+	case "rollups":
+		handle = h.handleRollups
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	if req.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+	handle(w, req, parts[0])
+}
+
+// handleResults returns a page of the website results from the latest to the oldest.
+func (h *Handler) handleResults(w http.ResponseWriter, req *http.Request, websiteID string) {
+	params := req.URL.Query()
+	from, to, err := parseTimeRange(params, time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit, err := parseInt(params, "limit", DefaultLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if limit <= 0 || limit > MaxLimit {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %d", MaxLimit))
+		return
+	}
+	offset, err := parseInt(params, "offset", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if offset < 0 {
+		writeError(w, http.StatusBadRequest, errors.New("offset must not be negative"))
+		return
+	}
+
+	results, err := h.Store.QueryWebsiteResults(req.Context(), domain.ResultsQuery{
+		WebsiteID: websiteID,
+		From:      from,
+		To:        to,
+		Location:  params.Get("location"),
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if results == nil {
+		results = []domain.WebsiteResult{}
+	}
+	writeJSON(w, http.StatusOK, resultsPage{Results: results, Limit: limit, Offset: offset})
+}
+
+// handleUptime returns the uptime and latency percentiles of the website, optionally
+// grouped in time buckets.
+func (h *Handler) handleUptime(w http.ResponseWriter, req *http.Request, websiteID string) {
+	params := req.URL.Query()
+	from, to, err := parseTimeRange(params, time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var bucket time.Duration
+	if raw := params.Get("bucket"); raw != "" {
+		bucket, err = time.ParseDuration(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("can't parse bucket: %w", err))
+			return
+		}
+		if bucket < time.Second {
+			writeError(w, http.StatusBadRequest, errors.New("bucket must be at least 1s"))
+			return
+		}
+	}
+
+	stats, err := h.Store.Uptime(req.Context(), websiteID, from, to, bucket)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if stats == nil {
+		stats = []domain.UptimeStats{}
+	}
+
+	report := uptimeReport{WebsiteID: websiteID, From: from, To: to, Stats: stats}
+	if bucket > 0 {
+		report.Bucket = bucket.String()
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleRollups returns the hourly or daily rollups of the website.
+func (h *Handler) handleRollups(w http.ResponseWriter, req *http.Request, websiteID string) {
+	params := req.URL.Query()
+	from, to, err := parseTimeRange(params, time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	period := domain.RollupPeriod(params.Get("period"))
+	switch period {
+	case "":
+		period = domain.RollupHourly
+	case domain.RollupHourly, domain.RollupDaily:
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("period must be %s or %s", domain.RollupHourly, domain.RollupDaily))
+		return
+	}
+
+	rollups, err := h.Store.Rollups(req.Context(), websiteID, period, from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if rollups == nil {
+		rollups = []domain.Rollup{}
+	}
+	writeJSON(w, http.StatusOK, rollups)
+}
+
+// handleStatus returns the latest result and verdict of every website.
+func (h *Handler) handleStatus(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeMethodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	statuses, err := h.Store.LatestStatuses(req.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if statuses == nil {
+		statuses = []domain.WebsiteLatestStatus{}
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// parseTimeRange parses from and to RFC3339 parameters and converts them to UTC.
+// to defaults to now and from to DefaultRange before to.
+func parseTimeRange(params url.Values, now time.Time) (from, to time.Time, err error) {
+	to = now.UTC()
+	if raw := params.Get("to"); raw != "" {
+		to, err = time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return from, to, fmt.Errorf("can't parse to: %w", err)
+		}
+		// The results are stored in UTC without time zone
+		to = to.UTC()
+	}
+	from = to.Add(-DefaultRange)
+	if raw := params.Get("from"); raw != "" {
+		from, err = time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return from, to, fmt.Errorf("can't parse from: %w", err)
+		}
+		from = from.UTC()
+	}
+	if !to.After(from) {
+		return from, to, errors.New("to must be after from")
+	}
+	return from, to, nil
+}
+
+func parseInt(params url.Values, name string, def int) (int, error) {
+	raw := params.Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("can't parse %s: %w", name, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+func TestWebsites(t *testing.T) {
+	c := qt.New(t)
+
+	ok := http.StatusOK
+	at := time.Date(2021, 5, 24, 10, 0, 0, 0, time.UTC)
+	verdict := domain.VerdictUp
+	store := &fakeStore{
+		websites: []domain.WebsiteParams{
+			{ID: "id1", URL: "http://example.com", Method: http.MethodGet, Tags: []string{"api"}},
+		},
+		results: map[string][]domain.WebsiteResult{
+			"id1": {
+				{Elapsed: 100 * time.Millisecond, Status: &ok, At: at.Add(2 * time.Minute), Location: "eu-west"},
+				{Elapsed: 200 * time.Millisecond, Status: &ok, At: at.Add(time.Minute), Location: "us-east"},
+				{Unreachable: true, At: at, Location: "eu-west"},
+			},
+		},
+	}
+	store.statuses = []domain.WebsiteLatestStatus{
+		{Website: store.websites[0], Result: &store.results["id1"][0], Verdict: &verdict},
+	}
+	h := NewHandler(store)
+
+	c.Run("List", func(c *qt.C) {
+		rec := serve(h, http.MethodGet, "/websites", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		c.Assert(rec.Body.String(), qt.JSONEquals, []interface{}{
+			map[string]interface{}{
+				"id":           "id1",
+				"url":          "http://example.com",
+				"method":       "GET",
+				"match_regexp": nil,
+				"tags":         []interface{}{"api"},
+			},
+		})
+	})
+
+	c.Run("Results", func(c *qt.C) {
+		rec := serve(h, http.MethodGet, "/websites/id1/results?from=2021-05-24T09:00:00Z&to=2021-05-24T11:00:00Z&limit=2", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		c.Assert(rec.Body.String(), qt.JSONEquals, map[string]interface{}{
+			"results": []interface{}{
+				map[string]interface{}{
+					"elapsed": 100 * time.Millisecond, "status": 200, "matched": nil, "unreachable": false,
+					"at": "2021-05-24T10:02:00Z", "location": "eu-west", "in_maintenance": false,
+				},
+				map[string]interface{}{
+					"elapsed": 200 * time.Millisecond, "status": 200, "matched": nil, "unreachable": false,
+					"at": "2021-05-24T10:01:00Z", "location": "us-east", "in_maintenance": false,
+				},
+			},
+			"limit":  2,
+			"offset": 0,
+		})
+
+		rec = serve(h, http.MethodGet, "/websites/id1/results?from=2021-05-24T09:00:00Z&to=2021-05-24T11:00:00Z&location=eu-west&offset=1", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		c.Assert(store.resultsQuery, qt.DeepEquals, domain.ResultsQuery{
+			WebsiteID: "id1",
+			From:      at.Add(-time.Hour),
+			To:        at.Add(time.Hour),
+			Location:  "eu-west",
+			Limit:     DefaultLimit,
+			Offset:    1,
+		})
+		c.Assert(rec.Body.String(), qt.JSONEquals, map[string]interface{}{
+			"results": []interface{}{
+				map[string]interface{}{
+					"elapsed": 0, "status": nil, "matched": nil, "unreachable": true,
+					"at": "2021-05-24T10:00:00Z", "location": "eu-west", "in_maintenance": false,
+				},
+			},
+			"limit":  DefaultLimit,
+			"offset": 1,
+		})
+	})
+
+	c.Run("Results time zone", func(c *qt.C) {
+		rec := serve(h, http.MethodGet, "/websites/id1/results?from=2021-05-24T11:00:00%2B02:00&to=2021-05-24T13:00:00%2B02:00", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		c.Assert(store.resultsQuery.From, qt.DeepEquals, at.Add(-time.Hour))
+		c.Assert(store.resultsQuery.To, qt.DeepEquals, at.Add(time.Hour))
+	})
+
+	c.Run("Results default range", func(c *qt.C) {
+		rec := serve(h, http.MethodGet, "/websites/unknown/results", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		c.Assert(rec.Body.String(), qt.JSONEquals, map[string]interface{}{
+			"results": []interface{}{},
+			"limit":   DefaultLimit,
+			"offset":  0,
+		})
+		c.Assert(store.resultsQuery.To.Sub(store.resultsQuery.From), qt.Equals, DefaultRange)
+	})
+
+	c.Run("Results bad request", func(c *qt.C) {
+		for _, target := range []string{
+			"/websites/id1/results?from=yesterday",
+			"/websites/id1/results?from=2021-05-24T11:00:00Z&to=2021-05-24T10:00:00Z",
+			"/websites/id1/results?limit=0",
+			"/websites/id1/results?limit=1001",
+			"/websites/id1/results?offset=-1",
+			"/websites/id1/results?offset=foo",
+		} {
+			rec := serve(h, http.MethodGet, target, "")
+			c.Check(rec.Code, qt.Equals, http.StatusBadRequest, qt.Commentf(target))
+		}
+	})
+
+	c.Run("Uptime", func(c *qt.C) {
+		rec := serve(h, http.MethodGet, "/websites/id1/uptime?from=2021-05-24T00:00:00Z&to=2021-05-25T00:00:00Z&bucket=1h", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		from := time.Date(2021, 5, 24, 0, 0, 0, 0, time.UTC)
+		c.Assert(store.uptimeQuery, qt.DeepEquals, []interface{}{"id1", from, from.Add(24 * time.Hour), time.Hour})
+		c.Assert(rec.Body.String(), qt.JSONEquals, map[string]interface{}{
+			"website_id": "id1",
+			"from":       "2021-05-24T00:00:00Z",
+			"to":         "2021-05-25T00:00:00Z",
+			"bucket":     "1h0m0s",
+			"stats": []interface{}{
+				map[string]interface{}{
+					"from": "2021-05-24T00:00:00Z", "to": "2021-05-25T00:00:00Z",
+					"checks": 4, "failures": 1, "uptime": 75, "p50": 0.1, "p95": 0.3, "p99": 0.5,
+				},
+			},
+		})
+
+		rec = serve(h, http.MethodGet, "/websites/id1/uptime?bucket=1ms", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusBadRequest)
+	})
+
+	c.Run("Rollups", func(c *qt.C) {
+		store.rollupsByPeriod = map[domain.RollupPeriod][]domain.Rollup{
+			domain.RollupDaily: {
+				{WebsiteID: "id1", From: time.Date(2021, 5, 23, 0, 0, 0, 0, time.UTC), Checks: 2, Failures: 1, StatusCodes: map[int]int{200: 1}},
+				{WebsiteID: "id1", From: time.Date(2021, 5, 24, 0, 0, 0, 0, time.UTC), Checks: 1, StatusCodes: map[int]int{200: 1}},
+			},
+		}
+
+		rec := serve(h, http.MethodGet, "/websites/id1/rollups?period=day&from=2021-05-24T00:00:00Z&to=2021-05-25T00:00:00Z", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		c.Assert(rec.Body.String(), qt.JSONEquals, []interface{}{
+			map[string]interface{}{
+				"website_id": "id1", "from": "2021-05-24T00:00:00Z", "checks": 1, "failures": 0,
+				"min_elapsed": 0, "avg_elapsed": 0, "max_elapsed": 0, "p50": 0, "p95": 0, "p99": 0,
+				"status_codes": map[string]interface{}{"200": 1},
+			},
+		})
+
+		rec = serve(h, http.MethodGet, "/websites/id1/rollups", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		c.Assert(rec.Body.String(), qt.JSONEquals, []interface{}{})
+
+		rec = serve(h, http.MethodGet, "/websites/id1/rollups?period=week", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusBadRequest)
+	})
+
+	c.Run("Status", func(c *qt.C) {
+		rec := serve(h, http.MethodGet, "/status", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusOK)
+		c.Assert(rec.Body.String(), qt.JSONEquals, []interface{}{
+			map[string]interface{}{
+				"website": map[string]interface{}{
+					"id": "id1", "url": "http://example.com", "method": "GET", "match_regexp": nil, "tags": []interface{}{"api"},
+				},
+				"result": map[string]interface{}{
+					"elapsed": 100 * time.Millisecond, "status": 200, "matched": nil, "unreachable": false,
+					"at": "2021-05-24T10:02:00Z", "location": "eu-west", "in_maintenance": false,
+				},
+				"verdict": "up",
+			},
+		})
+	})
+
+	c.Run("Not found", func(c *qt.C) {
+		for _, target := range []string{"/websites/id1", "/websites/id1/foo", "/websites/id1/results/foo"} {
+			rec := serve(h, http.MethodGet, target, "")
+			c.Check(rec.Code, qt.Equals, http.StatusNotFound, qt.Commentf(target))
+		}
+	})
+
+	c.Run("Method not allowed", func(c *qt.C) {
+		rec := serve(h, http.MethodPost, "/websites/id1/results", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusMethodNotAllowed)
+		rec = serve(h, http.MethodDelete, "/status", "")
+		c.Assert(rec.Code, qt.Equals, http.StatusMethodNotAllowed)
+	})
+}
@@ -0,0 +1,72 @@
+package domain
+
+import "time"
+
+// FlappingState defines whether a website keeps changing between failed and successful checks.
+type FlappingState struct {
+	WebsiteID string `json:"website_id" db:"website_id"`
+	Flapping  bool   `json:"flapping" db:"flapping"`
+	// Score is the ratio of state changes over the window when the state changed.
+	Score float64 `json:"score" db:"score"`
+	// Since is when the website started or stopped flapping.
+	Since time.Time `json:"since" db:"since"`
+}
+
+// FlapScore returns the ratio of state changes between consecutive results.
+func FlapScore(history []bool) float64 {
+	if len(history) < 2 {
+		return 0
+	}
+	changes := 0
+	for i := 1; i < len(history); i++ {
+		if history[i] != history[i-1] {
+			changes++
+		}
+	}
+	return float64(changes) / float64(len(history)-1)
+}
+
+// trackFlapping updates the results history of the source and sets the flapping state of the website
+// in the changes if it started or stopped flapping. It returns the event to notify in that case.
+func (e *IncidentEngine) trackFlapping(st *incidentState, src *sourceState, wp WebsiteParams, wr WebsiteResult, ch *IncidentChanges) *IncidentEvent {
+	if e.FlapWindow <= 1 {
+		return nil
+	}
+
+	src.history = append(src.history, wr.Failed())
+	if len(src.history) > e.FlapWindow {
+		src.history = src.history[len(src.history)-e.FlapWindow:]
+	}
+
+	// The score of the website is the highest one of the sources with a full window.
+	score, full := 0.0, false
+	for _, src := range st.sources {
+		if len(src.history) < e.FlapWindow {
+			continue
+		}
+		full = true
+		if s := FlapScore(src.history); s > score {
+			score = s
+		}
+	}
+	if !full {
+		return nil
+	}
+
+	kind := IncidentFlapping
+	switch {
+	case !st.flapping && score >= e.FlapStartThreshold:
+	case st.flapping && score < e.FlapStopThreshold:
+		kind = IncidentFlappingStopped
+	default:
+		return nil
+	}
+
+	ch.Flapping = &FlappingState{
+		WebsiteID: wp.ID,
+		Flapping:  kind == IncidentFlapping,
+		Score:     score,
+		Since:     wr.At,
+	}
+	return &IncidentEvent{Kind: kind, Website: wp, Flapping: ch.Flapping}
+}
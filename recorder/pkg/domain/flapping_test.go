@@ -0,0 +1,180 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestFlapScore(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(FlapScore(nil), qt.Equals, 0.0)
+	c.Assert(FlapScore([]bool{true}), qt.Equals, 0.0)
+	c.Assert(FlapScore([]bool{false, false, false}), qt.Equals, 0.0)
+	c.Assert(FlapScore([]bool{true, false, true}), qt.Equals, 1.0)
+	c.Assert(FlapScore([]bool{true, true, false, false, true}), qt.Equals, 0.5)
+}
+
+func TestIncidentEngineFlapping(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+
+	ok := http.StatusOK
+	badGateway := http.StatusBadGateway
+	start := time.Date(2021, 5, 19, 16, 0, 0, 0, time.UTC)
+
+	store := newFakeIncidentStore()
+	e := &IncidentEngine{
+		Threshold:          1,
+		FlapWindow:         4,
+		FlapStartThreshold: 0.6,
+		FlapStopThreshold:  0.3,
+		SaveChanges:        store.SaveChanges,
+	}
+
+	// Bouncing between 502 and 200 and then stable
+	checks := "s.s.s.s....."
+	for i, ch := range checks {
+		wr := WebsiteResult{At: start.Add(time.Duration(i) * time.Second), Status: &ok}
+		if ch == 's' {
+			wr.Status = &badGateway
+		}
+		err := e.HandleResult(ctx, WebsiteParams{ID: "id1"}, wr)
+		c.Assert(err, qt.IsNil)
+	}
+
+	events, states := store.events, store.states
+	var kinds []IncidentEventKind
+	for _, ev := range events {
+		kinds = append(kinds, ev.Kind)
+	}
+	c.Assert(kinds, qt.DeepEquals, []IncidentEventKind{
+		IncidentOpened, IncidentResolved, IncidentOpened, IncidentResolved, IncidentFlapping, IncidentFlappingStopped,
+	}, qt.Commentf("the resolution along with the flapping start is notified"))
+	c.Assert(store.incidents, qt.HasLen, 4, qt.Commentf("incidents are tracked while flapping"))
+
+	c.Assert(states, qt.DeepEquals, []FlappingState{
+		{WebsiteID: "id1", Flapping: true, Score: 1.0, Since: start.Add(3 * time.Second)},
+		{WebsiteID: "id1", Flapping: false, Score: 0.0, Since: start.Add(10 * time.Second)},
+	})
+	c.Assert(events[4].Flapping, qt.DeepEquals, &states[0])
+	c.Assert(events[4].Incident, qt.IsNil)
+
+	c.Run("Pending", func(c *qt.C) {
+		store := newFakeIncidentStore()
+		e := &IncidentEngine{
+			Threshold:          1,
+			FlapWindow:         4,
+			FlapStartThreshold: 0.6,
+			FlapStopThreshold:  0.3,
+			SaveChanges:        store.SaveChanges,
+		}
+
+		// The incident opened along with the flapping start is resolved while flapping
+		// and another one is open when it stops.
+		for i, ch := range ".s.s.ssss" {
+			wr := WebsiteResult{At: start.Add(time.Duration(i) * time.Second), Status: &ok}
+			if ch == 's' {
+				wr.Status = &badGateway
+			}
+			err := e.HandleResult(ctx, WebsiteParams{ID: "id1"}, wr)
+			c.Assert(err, qt.IsNil)
+		}
+
+		var kinds []IncidentEventKind
+		var ids []int64
+		for _, ev := range store.events {
+			kinds = append(kinds, ev.Kind)
+			if ev.Incident != nil {
+				ids = append(ids, ev.Incident.ID)
+			}
+		}
+		c.Assert(kinds, qt.DeepEquals, []IncidentEventKind{
+			IncidentOpened, IncidentResolved, IncidentOpened, IncidentFlapping,
+			IncidentFlappingStopped, IncidentResolved, IncidentOpened,
+		})
+		c.Assert(ids, qt.DeepEquals, []int64{1, 1, 2, 2, 3, 2, 3})
+		c.Assert(store.events[5].Incident.Open(), qt.IsFalse)
+	})
+
+	c.Run("Sources", func(c *qt.C) {
+		store := newFakeIncidentStore()
+		e := &IncidentEngine{
+			Threshold:          1,
+			FlapWindow:         4,
+			FlapStartThreshold: 0.6,
+			FlapStopThreshold:  0.3,
+			SaveChanges:        store.SaveChanges,
+		}
+
+		// A failing IP and a healthy one alternate without flapping
+		for i := 0; i < 10; i++ {
+			wr := WebsiteResult{At: start.Add(time.Duration(i) * time.Second), Status: &ok, IP: "10.0.0.1"}
+			if i%2 == 0 {
+				wr.Status, wr.IP = &badGateway, "10.0.0.2"
+			}
+			err := e.HandleResult(ctx, WebsiteParams{ID: "id1"}, wr)
+			c.Assert(err, qt.IsNil)
+		}
+		c.Assert(store.events, qt.HasLen, 1)
+		c.Assert(store.events[0].Kind, qt.Equals, IncidentOpened)
+	})
+
+	c.Run("SaveError", func(c *qt.C) {
+		store := newFakeIncidentStore()
+		fail := false
+		e := &IncidentEngine{
+			Threshold:          1,
+			FlapWindow:         4,
+			FlapStartThreshold: 0.6,
+			FlapStopThreshold:  0.3,
+			SaveChanges: func(ctx context.Context, ch IncidentChanges, notifications NotificationsFunc) error {
+				if fail {
+					return errors.New("database is down")
+				}
+				return store.SaveChanges(ctx, ch, notifications)
+			},
+		}
+
+		// The flapping start can't be saved, the result is not in the window of the next one
+		for i, ch := range "s.s.s" {
+			wr := WebsiteResult{At: start.Add(time.Duration(i) * time.Second), Status: &ok}
+			if ch == 's' {
+				wr.Status = &badGateway
+			}
+			fail = i == 3
+			err := e.HandleResult(ctx, WebsiteParams{ID: "id1"}, wr)
+			if fail {
+				c.Assert(err, qt.ErrorMatches, "can't save incident changes: database is down")
+				continue
+			}
+			c.Assert(err, qt.IsNil)
+		}
+
+		c.Assert(store.states, qt.DeepEquals, []FlappingState{
+			{WebsiteID: "id1", Flapping: true, Score: 2.0 / 3, Since: start.Add(4 * time.Second)},
+		})
+	})
+
+	c.Run("Restore", func(c *qt.C) {
+		store := newFakeIncidentStore()
+		e := &IncidentEngine{
+			Threshold:          1,
+			FlapWindow:         4,
+			FlapStartThreshold: 0.6,
+			FlapStopThreshold:  0.3,
+			SaveChanges:        store.SaveChanges,
+		}
+		e.RestoreFlapping([]FlappingState{{WebsiteID: "id1", Flapping: true}})
+
+		err := e.HandleResult(ctx, WebsiteParams{ID: "id1"}, WebsiteResult{At: start, Status: &badGateway})
+		c.Assert(err, qt.IsNil)
+		c.Assert(store.incidents, qt.HasLen, 1)
+		c.Assert(store.events, qt.HasLen, 0, qt.Commentf("suppressed while flapping"))
+	})
+}
@@ -0,0 +1,382 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Cause defines why a website check failed.
+type Cause string
+
+const (
+	CauseStatus         Cause = "status"
+	CauseTimeout        Cause = "timeout"
+	CauseRegexpMismatch Cause = "regexp_mismatch"
+)
+
+// Cause returns why the check failed. It returns an empty Cause if the check did not fail.
+func (wr WebsiteResult) Cause() Cause {
+	switch {
+	case !wr.Failed():
+		return ""
+	case wr.Unreachable:
+		return CauseTimeout
+	case wr.Status == nil || *wr.Status >= 400:
+		return CauseStatus
+	default:
+		return CauseRegexpMismatch
+	}
+}
+
+// Incident defines a period of time where a website kept failing.
+type Incident struct {
+	ID        int64      `json:"id" db:"id"`
+	WebsiteID string     `json:"website_id" db:"website_id"`
+	StartedAt time.Time  `json:"started_at" db:"started_at"`
+	EndedAt   *time.Time `json:"ended_at" db:"ended_at"`
+	Cause     Cause      `json:"cause" db:"cause"`
+	// FailedChecks is the number of failed checks affected by the incident.
+	FailedChecks int `json:"failed_checks" db:"failed_checks"`
+}
+
+// Open returns true if the incident is not resolved yet.
+func (i Incident) Open() bool {
+	return i.EndedAt == nil
+}
+
+// IncidentEventKind defines the incident transitions to notify about.
+type IncidentEventKind string
+
+const (
+	IncidentOpened          IncidentEventKind = "opened"
+	IncidentResolved        IncidentEventKind = "resolved"
+	IncidentFlapping        IncidentEventKind = "flapping"
+	IncidentFlappingStopped IncidentEventKind = "flapping_stopped"
+)
+
+// IncidentEvent defines a transition of a website incident.
+type IncidentEvent struct {
+	Kind    IncidentEventKind `json:"kind"`
+	Website WebsiteParams     `json:"website"`
+	// Incident is the opened or resolved incident. It is the open incident, if any, on flapping events.
+	Incident *Incident `json:"incident,omitempty"`
+	// Flapping is only set on flapping events.
+	Flapping *FlappingState `json:"flapping,omitempty"`
+}
+
+// IncidentChanges defines the changes caused by a result. They are stored in a single
+// transaction along with the notifications of their events.
+type IncidentChanges struct {
+	// Opened is the incident to insert. Its ID is set once stored.
+	Opened *Incident
+	// Updated is the incident to update.
+	Updated *Incident
+	// Flapping is the new flapping state of the website.
+	Flapping *FlappingState
+	// Events are the events to notify. The incident of the opened event is Opened.
+	Events []IncidentEvent
+}
+
+// empty returns true if there is nothing to store.
+func (ch IncidentChanges) empty() bool {
+	return ch.Opened == nil && ch.Updated == nil && ch.Flapping == nil && len(ch.Events) == 0
+}
+
+// openIncident returns the open incident of the website once the changes are stored given the current one.
+func (ch IncidentChanges) openIncident(current *Incident) *Incident {
+	switch {
+	case ch.Opened != nil:
+		return ch.Opened
+	case ch.Updated != nil && ch.Updated.Open():
+		return ch.Updated
+	case ch.Updated != nil:
+		return nil
+	}
+	return current
+}
+
+// DefaultSourceTimeout is the time after which the sources of results which stopped reporting are forgotten.
+const DefaultSourceTimeout = 10 * time.Minute
+
+// IncidentEngine tracks the transitions of every website to open an incident
+// after Threshold consecutive failures and resolve it after Threshold consecutive successes.
+//
+// The consecutive checks are counted per source of results: the location and the ID of the
+// checker and the checked IP. An incident is opened as soon as a source fails and resolved
+// once none fails anymore, so a failing region or backend is not hidden by the healthy ones.
+//
+// Optionally, it detects flapping websites computing the ratio of state changes over the
+// last FlapWindow results of every source. A website starts flapping when the ratio of a
+// source reaches FlapStartThreshold and stops when the ratios of all of them go below
+// FlapStopThreshold. While flapping, incidents are still tracked but not notified: once it
+// stops, the resolution of the last notified incident and the open incident are notified.
+type IncidentEngine struct {
+	Threshold int
+	// SourceTimeout is the time after which the state of a source without results is forgotten,
+	// e.g. a stopped checker or an IP removed from DNS. Zero means DefaultSourceTimeout.
+	SourceTimeout time.Duration
+
+	FlapWindow         int
+	FlapStartThreshold float64
+	FlapStopThreshold  float64
+
+	// SaveChanges stores the changes caused by a result, setting the ID of the opened incident,
+	// and enqueues the notifications of their events in the same transaction.
+	SaveChanges func(ctx context.Context, ch IncidentChanges, notifications NotificationsFunc) error
+	// Notifications optionally returns the notifications of an event: when an incident is opened
+	// or resolved or the website starts or stops flapping.
+	Notifications NotificationsFunc
+
+	mu     sync.Mutex
+	states map[string]*incidentState
+}
+
+// incidentState holds the incident and the sources of results of a website.
+type incidentState struct {
+	sources  map[resultSource]*sourceState
+	incident *Incident
+	flapping bool
+	// notified is the last incident notified as opened. It is the resolved incident when
+	// its resolution was not notified because the website is flapping.
+	notified *Incident
+}
+
+// resultSource identifies where the results of a website come from.
+type resultSource struct {
+	location  string
+	checkerID string
+	ip        string
+}
+
+// sourceState holds the consecutive checks of a website from a source.
+type sourceState struct {
+	failures     int
+	firstFailure WebsiteResult
+	successes    int
+	firstSuccess time.Time
+	// failing means the source reached Threshold consecutive failures and did not
+	// recover with Threshold consecutive successes yet.
+	failing bool
+	lastAt  time.Time
+
+	// history holds whether the last FlapWindow results failed.
+	history []bool
+}
+
+// source returns the state of the source of the result creating it if required.
+// A new source is considered failing while an incident is open, so an incident restored
+// on startup, or opened before the source reported, is only resolved once it is healthy.
+func (st *incidentState) source(wr WebsiteResult) *sourceState {
+	if st.sources == nil {
+		st.sources = make(map[resultSource]*sourceState)
+	}
+	key := resultSource{location: wr.Location, checkerID: wr.CheckerID, ip: wr.IP}
+	src, ok := st.sources[key]
+	if !ok {
+		src = &sourceState{failing: st.incident != nil}
+		st.sources[key] = src
+	}
+	return src
+}
+
+// copySources returns a copy of the state of the sources.
+func (st *incidentState) copySources() map[resultSource]*sourceState {
+	sources := make(map[resultSource]*sourceState, len(st.sources))
+	for key, src := range st.sources {
+		cp := *src
+		cp.history = append([]bool(nil), src.history...)
+		sources[key] = &cp
+	}
+	return sources
+}
+
+// expireSources forgets the sources without results since timeout before at.
+func (st *incidentState) expireSources(at time.Time, timeout time.Duration) {
+	for key, src := range st.sources {
+		if src.lastAt.Before(at.Add(-timeout)) {
+			delete(st.sources, key)
+		}
+	}
+}
+
+// failing returns true if a source of the website is failing.
+func (st *incidentState) failing() bool {
+	for _, src := range st.sources {
+		if src.failing {
+			return true
+		}
+	}
+	return false
+}
+
+// Restore sets the open incidents to keep track of, usually on startup.
+func (e *IncidentEngine) Restore(incidents []Incident) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i := range incidents {
+		if incidents[i].Open() {
+			in := incidents[i]
+			st := e.state(in.WebsiteID)
+			st.incident = &in
+			st.notified = &in
+		}
+	}
+}
+
+// RestoreFlapping sets the flapping websites, usually on startup.
+func (e *IncidentEngine) RestoreFlapping(states []FlappingState) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, fs := range states {
+		e.state(fs.WebsiteID).flapping = fs.Flapping
+	}
+}
+
+// HandleResult processes a website result opening, updating or resolving incidents.
+// Results in maintenance are ignored.
+func (e *IncidentEngine) HandleResult(ctx context.Context, wp WebsiteParams, wr WebsiteResult) error {
+	if wr.InMaintenance {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	st := e.state(wp.ID)
+	// The sources are rolled back if the changes can't be saved, so the next result retries them
+	sources := st.copySources()
+	src := st.source(wr)
+	src.lastAt = wr.At
+	timeout := e.SourceTimeout
+	if timeout <= 0 {
+		timeout = DefaultSourceTimeout
+	}
+	st.expireSources(wr.At, timeout)
+
+	var ch IncidentChanges
+	flapEv := e.trackFlapping(st, src, wp, wr, &ch)
+	ev := e.trackIncident(st, src, wp, wr, &ch)
+
+	flapping := st.flapping
+	if ch.Flapping != nil {
+		flapping = ch.Flapping.Flapping
+	}
+	current := ch.openIncident(st.incident)
+	notified := st.notified
+
+	switch {
+	case ev == nil:
+	case !st.flapping:
+		// Notified even if the website starts flapping with this result
+		ch.Events = append(ch.Events, *ev)
+		notified = ev.Incident
+		if ev.Kind == IncidentResolved {
+			notified = nil
+		}
+	case ev.Kind == IncidentResolved && notified != nil && notified.ID == ev.Incident.ID:
+		// Notified once the website stops flapping
+		notified = ev.Incident
+	}
+	if flapEv != nil {
+		flapEv.Incident = current
+		ch.Events = append(ch.Events, *flapEv)
+	}
+	if st.flapping && !flapping {
+		if notified != nil && !notified.Open() {
+			ch.Events = append(ch.Events, IncidentEvent{Kind: IncidentResolved, Website: wp, Incident: notified})
+			notified = nil
+		}
+		if current != nil && notified == nil {
+			ch.Events = append(ch.Events, IncidentEvent{Kind: IncidentOpened, Website: wp, Incident: current})
+			notified = current
+		}
+	}
+	if ch.empty() {
+		return nil
+	}
+
+	if err := e.SaveChanges(ctx, ch, e.Notifications); err != nil {
+		st.sources = sources
+		return fmt.Errorf("can't save incident changes: %w", err)
+	}
+	st.incident = current
+	st.flapping = flapping
+	st.notified = notified
+
+	return nil
+}
+
+// trackIncident counts the consecutive checks of the source of the result and sets the incident
+// of the website to open, update or resolve in the changes. It returns the event to notify if any.
+func (e *IncidentEngine) trackIncident(st *incidentState, src *sourceState, wp WebsiteParams, wr WebsiteResult, ch *IncidentChanges) *IncidentEvent {
+	if !wr.Failed() {
+		src.failures = 0
+		// The incident ends at the first success of the source which recovered last,
+		// or now if the failing sources expired.
+		endedAt := wr.At
+		if src.failing {
+			if src.successes == 0 {
+				src.firstSuccess = wr.At
+			}
+			src.successes++
+			if src.successes >= e.Threshold {
+				src.failing = false
+				src.successes = 0
+				endedAt = src.firstSuccess
+			}
+		}
+		if st.incident == nil || st.failing() {
+			return nil
+		}
+
+		resolved := *st.incident
+		resolved.EndedAt = &endedAt
+		ch.Updated = &resolved
+		return &IncidentEvent{Kind: IncidentResolved, Website: wp, Incident: &resolved}
+	}
+
+	src.successes = 0
+	if src.failures == 0 {
+		src.firstFailure = wr
+	}
+	src.failures++
+	if src.failures >= e.Threshold {
+		src.failing = true
+	}
+
+	if st.incident != nil {
+		updated := *st.incident
+		updated.FailedChecks++
+		ch.Updated = &updated
+		return nil
+	}
+
+	if !src.failing {
+		return nil
+	}
+
+	ch.Opened = &Incident{
+		WebsiteID:    wp.ID,
+		StartedAt:    src.firstFailure.At,
+		Cause:        src.firstFailure.Cause(),
+		FailedChecks: src.failures,
+	}
+	return &IncidentEvent{Kind: IncidentOpened, Website: wp, Incident: ch.Opened}
+}
+
+// state returns the state of a website creating it if required. It must be called with the lock held.
+func (e *IncidentEngine) state(websiteID string) *incidentState {
+	if e.states == nil {
+		e.states = make(map[string]*incidentState)
+	}
+	st, ok := e.states[websiteID]
+	if !ok {
+		st = new(incidentState)
+		e.states[websiteID] = st
+	}
+	return st
+}
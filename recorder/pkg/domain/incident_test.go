@@ -0,0 +1,273 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestIncidentEngine(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+
+	ok := http.StatusOK
+	internalError := http.StatusInternalServerError
+	no := false
+	start := time.Date(2021, 5, 14, 8, 0, 0, 0, time.UTC)
+
+	// sequence builds one result per second from a string where
+	// '.' is a success, 's' a failed status, 't' a timeout, 'r' a regexp mismatch
+	// and 'm' a failed status in maintenance.
+	sequence := func(checks string) []WebsiteResult {
+		results := make([]WebsiteResult, len(checks))
+		for i, ch := range checks {
+			wr := WebsiteResult{At: start.Add(time.Duration(i) * time.Second), Status: &ok}
+			switch ch {
+			case 's':
+				wr.Status = &internalError
+			case 't':
+				wr.Status = nil
+				wr.Unreachable = true
+			case 'r':
+				wr.Matched = &no
+			case 'm':
+				wr.Status = &internalError
+				wr.InMaintenance = true
+			}
+			results[i] = wr
+		}
+		return results
+	}
+	at := func(sec int) *time.Time {
+		t := start.Add(time.Duration(sec) * time.Second)
+		return &t
+	}
+
+	tests := []struct {
+		Name     string
+		Checks   string
+		Expected []Incident
+	}{
+		{
+			Name:   "no failures",
+			Checks: ".....",
+		},
+		{
+			Name:   "failures below threshold",
+			Checks: "..ss.s.ss.",
+		},
+		{
+			Name:   "open incident",
+			Checks: ".sss",
+			Expected: []Incident{
+				{ID: 1, WebsiteID: "id1", StartedAt: *at(1), Cause: CauseStatus, FailedChecks: 3},
+			},
+		},
+		{
+			Name:   "track failed checks",
+			Checks: "tsrss..s",
+			Expected: []Incident{
+				{ID: 1, WebsiteID: "id1", StartedAt: *at(0), Cause: CauseTimeout, FailedChecks: 6},
+			},
+		},
+		{
+			Name:   "resolve incident",
+			Checks: "rrr..s...",
+			Expected: []Incident{
+				{ID: 1, WebsiteID: "id1", StartedAt: *at(0), EndedAt: at(6), Cause: CauseRegexpMismatch, FailedChecks: 4},
+			},
+		},
+		{
+			Name:   "ignore maintenance",
+			Checks: "ssmmm.mmm.",
+		},
+		{
+			Name:   "several incidents",
+			Checks: "sss...ttt.",
+			Expected: []Incident{
+				{ID: 1, WebsiteID: "id1", StartedAt: *at(0), EndedAt: at(3), Cause: CauseStatus, FailedChecks: 3},
+				{ID: 2, WebsiteID: "id1", StartedAt: *at(6), Cause: CauseTimeout, FailedChecks: 3},
+			},
+		},
+	}
+	for _, st := range tests {
+		c.Run(st.Name, func(c *qt.C) {
+			store := newFakeIncidentStore()
+			e := &IncidentEngine{
+				Threshold:   3,
+				SaveChanges: store.SaveChanges,
+			}
+
+			for _, wr := range sequence(st.Checks) {
+				err := e.HandleResult(ctx, WebsiteParams{ID: "id1"}, wr)
+				c.Assert(err, qt.IsNil)
+			}
+
+			c.Assert(store.incidents, qt.DeepEquals, st.Expected)
+		})
+	}
+
+	c.Run("Notify", func(c *qt.C) {
+		store := newFakeIncidentStore()
+		var kinds []IncidentEventKind
+		e := &IncidentEngine{
+			Threshold:   2,
+			SaveChanges: store.SaveChanges,
+			Notifications: func(ev IncidentEvent) ([]Notification, error) {
+				c.Assert(ev.Website.ID, qt.Equals, "id1")
+				c.Assert(ev.Incident.ID, qt.Equals, int64(len(store.incidents)), qt.Commentf("the opened incident is stored first"))
+				kinds = append(kinds, ev.Kind)
+				return []Notification{{Receiver: "ops"}}, nil
+			},
+		}
+
+		for _, wr := range sequence("ss.s..tt..") {
+			err := e.HandleResult(ctx, WebsiteParams{ID: "id1"}, wr)
+			c.Assert(err, qt.IsNil)
+		}
+
+		c.Assert(kinds, qt.DeepEquals, []IncidentEventKind{IncidentOpened, IncidentResolved, IncidentOpened, IncidentResolved})
+		c.Assert(store.notifications, qt.HasLen, 4)
+	})
+
+	c.Run("SaveError", func(c *qt.C) {
+		store := newFakeIncidentStore()
+		fail := true
+		e := &IncidentEngine{
+			Threshold: 2,
+			SaveChanges: func(ctx context.Context, ch IncidentChanges, notifications NotificationsFunc) error {
+				if fail {
+					return errors.New("database is down")
+				}
+				return store.SaveChanges(ctx, ch, notifications)
+			},
+		}
+
+		results := sequence("sss")
+		c.Assert(e.HandleResult(ctx, WebsiteParams{ID: "id1"}, results[0]), qt.IsNil, qt.Commentf("nothing to store"))
+		err := e.HandleResult(ctx, WebsiteParams{ID: "id1"}, results[1])
+		c.Assert(err, qt.ErrorMatches, "can't save incident changes: database is down")
+
+		// The result whose changes were not saved is not counted, the incident
+		// is opened again once the store is back
+		fail = false
+		err = e.HandleResult(ctx, WebsiteParams{ID: "id1"}, results[2])
+		c.Assert(err, qt.IsNil)
+		c.Assert(store.incidents, qt.DeepEquals, []Incident{
+			{ID: 1, WebsiteID: "id1", StartedAt: start, Cause: CauseStatus, FailedChecks: 2},
+		})
+
+		// Same for its resolution
+		fail = true
+		results = sequence("....")
+		c.Assert(e.HandleResult(ctx, WebsiteParams{ID: "id1"}, results[0]), qt.IsNil, qt.Commentf("nothing to store"))
+		err = e.HandleResult(ctx, WebsiteParams{ID: "id1"}, results[1])
+		c.Assert(err, qt.ErrorMatches, "can't save incident changes: database is down")
+		c.Assert(store.incidents[0].Open(), qt.IsTrue)
+
+		fail = false
+		err = e.HandleResult(ctx, WebsiteParams{ID: "id1"}, results[2])
+		c.Assert(err, qt.IsNil)
+		c.Assert(store.incidents[0].EndedAt, qt.DeepEquals, at(0))
+	})
+
+	c.Run("Sources", func(c *qt.C) {
+		store := newFakeIncidentStore()
+		e := &IncidentEngine{
+			Threshold:     3,
+			SourceTimeout: time.Minute,
+			SaveChanges:   store.SaveChanges,
+		}
+
+		// Results of both locations alternate: eu-west fails 4 times and then recovers
+		// while us-east stays healthy.
+		sec := 0
+		handle := func(location, ip string, checks string) {
+			for _, wr := range sequence(checks) {
+				wr.At = start.Add(time.Duration(sec) * time.Second)
+				wr.Location, wr.IP = location, ip
+				err := e.HandleResult(ctx, WebsiteParams{ID: "id1"}, wr)
+				c.Assert(err, qt.IsNil)
+				sec++
+			}
+		}
+		for _, ch := range "ssss..." {
+			handle("eu-west", "", string(ch))
+			handle("us-east", "", ".")
+		}
+		c.Assert(store.incidents, qt.DeepEquals, []Incident{
+			{ID: 1, WebsiteID: "id1", StartedAt: *at(0), EndedAt: at(8), Cause: CauseStatus, FailedChecks: 4},
+		}, qt.Commentf("a failing location is not hidden by a healthy one"))
+
+		// An IP which keeps failing and then disappears from DNS stops blocking the resolution
+		handle("eu-west", "10.0.0.2", "sss")
+		c.Assert(store.incidents, qt.HasLen, 2)
+		c.Assert(store.incidents[1].Open(), qt.IsTrue)
+		handle("eu-west", "10.0.0.1", "...")
+		c.Assert(store.incidents[1].Open(), qt.IsTrue)
+		sec += 60
+		handle("eu-west", "10.0.0.1", ".")
+		c.Assert(store.incidents[1].Open(), qt.IsFalse)
+		c.Assert(*store.incidents[1].EndedAt, qt.Equals, *at(sec - 1))
+	})
+
+	c.Run("Restore", func(c *qt.C) {
+		store := newFakeIncidentStore()
+		store.incidents = []Incident{{ID: 1, WebsiteID: "id1", StartedAt: start, Cause: CauseStatus, FailedChecks: 5}}
+		e := &IncidentEngine{
+			Threshold:   2,
+			SaveChanges: store.SaveChanges,
+		}
+		e.Restore(store.incidents)
+
+		for _, wr := range sequence("s..") {
+			err := e.HandleResult(ctx, WebsiteParams{ID: "id1"}, wr)
+			c.Assert(err, qt.IsNil)
+		}
+
+		c.Assert(store.incidents, qt.DeepEquals, []Incident{
+			{ID: 1, WebsiteID: "id1", StartedAt: start, EndedAt: at(1), Cause: CauseStatus, FailedChecks: 6},
+		})
+	})
+}
+
+// fakeIncidentStore stores incidents, flapping states and notifications in memory.
+type fakeIncidentStore struct {
+	incidents     []Incident
+	states        []FlappingState
+	events        []IncidentEvent
+	notifications []Notification
+}
+
+func newFakeIncidentStore() *fakeIncidentStore {
+	return new(fakeIncidentStore)
+}
+
+func (s *fakeIncidentStore) SaveChanges(ctx context.Context, ch IncidentChanges, notifications NotificationsFunc) error {
+	if ch.Opened != nil {
+		ch.Opened.ID = int64(len(s.incidents) + 1)
+		s.incidents = append(s.incidents, *ch.Opened)
+	}
+	if ch.Updated != nil {
+		s.incidents[ch.Updated.ID-1] = *ch.Updated
+	}
+	if ch.Flapping != nil {
+		s.states = append(s.states, *ch.Flapping)
+	}
+	for _, ev := range ch.Events {
+		s.events = append(s.events, ev)
+		if notifications == nil {
+			continue
+		}
+		ns, err := notifications(ev)
+		if err != nil {
+			return err
+		}
+		s.notifications = append(s.notifications, ns...)
+	}
+	return nil
+}
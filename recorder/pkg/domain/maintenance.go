@@ -0,0 +1,131 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// MaintenanceWindow defines a period of time where a website, or the websites with a tag,
+// are under maintenance. It is either one-off, from StartsAt to EndsAt, or recurring,
+// lasting Duration every time Cron is activated.
+type MaintenanceWindow struct {
+	ID        int64      `json:"id" db:"id"`
+	WebsiteID *string    `json:"website_id,omitempty" db:"website_id"`
+	Tag       *string    `json:"tag,omitempty" db:"tag"`
+	StartsAt  *time.Time `json:"starts_at,omitempty" db:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at,omitempty" db:"ends_at"`
+	Cron      *string    `json:"cron,omitempty" db:"cron"`
+	// Duration is formatted as Go duration.
+	Duration *string `json:"duration,omitempty" db:"duration"`
+	Reason   string  `json:"reason" db:"reason"`
+}
+
+// Validate checks the maintenance window is well defined.
+func (mw MaintenanceWindow) Validate() error {
+	if (mw.WebsiteID == nil) == (mw.Tag == nil) {
+		return fmt.Errorf("maintenance window must have either website_id or tag")
+	}
+
+	switch {
+	case mw.Cron != nil && (mw.StartsAt != nil || mw.EndsAt != nil):
+		return fmt.Errorf("maintenance window must be either one-off or recurring")
+	case mw.Cron != nil:
+		if _, err := cron.ParseStandard(*mw.Cron); err != nil {
+			return fmt.Errorf("can't parse cron: %w", err)
+		}
+		if mw.Duration == nil {
+			return fmt.Errorf("missing duration")
+		}
+		d, err := time.ParseDuration(*mw.Duration)
+		if err != nil {
+			return fmt.Errorf("can't parse duration: %w", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("duration must be positive: %s provided", d)
+		}
+	case mw.StartsAt == nil || mw.EndsAt == nil:
+		return fmt.Errorf("missing starts_at or ends_at")
+	case !mw.EndsAt.After(*mw.StartsAt):
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+
+	return nil
+}
+
+// Applies returns true if the maintenance window scope includes the website.
+func (mw MaintenanceWindow) Applies(wp WebsiteParams) bool {
+	if mw.WebsiteID != nil {
+		return *mw.WebsiteID == wp.ID
+	}
+	return mw.Tag != nil && wp.HasAnyTag([]string{*mw.Tag})
+}
+
+// Active returns true if the maintenance window is active at the given time.
+// An invalid window is never active.
+func (mw MaintenanceWindow) Active(at time.Time) bool {
+	if mw.Cron != nil {
+		schedule, err := cron.ParseStandard(*mw.Cron)
+		if err != nil || mw.Duration == nil {
+			return false
+		}
+		d, err := time.ParseDuration(*mw.Duration)
+		if err != nil {
+			return false
+		}
+		// The last activation before at within the duration
+		return !schedule.Next(at.Add(-d)).After(at)
+	}
+	if mw.StartsAt == nil || mw.EndsAt == nil {
+		return false
+	}
+	return !at.Before(*mw.StartsAt) && at.Before(*mw.EndsAt)
+}
+
+// MaintenanceFilter marks the website results recorded during a maintenance window.
+type MaintenanceFilter struct {
+	MaintenanceWindows func(ctx context.Context) ([]MaintenanceWindow, error)
+
+	mu      sync.RWMutex
+	windows []MaintenanceWindow
+}
+
+// Reload loads the maintenance windows again. It must be called when they change.
+func (f *MaintenanceFilter) Reload(ctx context.Context) error {
+	windows, err := f.MaintenanceWindows(ctx)
+	if err != nil {
+		return fmt.Errorf("can't get maintenance windows: %w", err)
+	}
+
+	f.mu.Lock()
+	f.windows = windows
+	f.mu.Unlock()
+
+	return nil
+}
+
+// InMaintenance returns true if the website is under maintenance at the given time.
+func (f *MaintenanceFilter) InMaintenance(wp WebsiteParams, at time.Time) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, mw := range f.windows {
+		if mw.Applies(wp) && mw.Active(at) {
+			return true
+		}
+	}
+	return false
+}
+
+// Wrap returns a handler which marks the result as in maintenance before calling next.
+func (f *MaintenanceFilter) Wrap(next func(ctx context.Context, wp WebsiteParams, wr WebsiteResult) error) func(ctx context.Context, wp WebsiteParams, wr WebsiteResult) error {
+	return func(ctx context.Context, wp WebsiteParams, wr WebsiteResult) error {
+		if !wr.InMaintenance && f.InMaintenance(wp, wr.At) {
+			wr.InMaintenance = true
+		}
+		return next(ctx, wp, wr)
+	}
+}
@@ -0,0 +1,128 @@
+package domain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestMaintenanceWindow(t *testing.T) {
+	c := qt.New(t)
+
+	str := func(s string) *string { return &s }
+	tm := func(s string) *time.Time {
+		t, err := time.Parse(time.RFC3339, s)
+		c.Assert(err, qt.IsNil)
+		return &t
+	}
+
+	c.Run("Validate", func(c *qt.C) {
+		tests := []struct {
+			Name   string
+			Window MaintenanceWindow
+			Error  string
+		}{
+			{
+				Name:   "one-off",
+				Window: MaintenanceWindow{WebsiteID: str("id1"), StartsAt: tm("2021-05-21T22:00:00Z"), EndsAt: tm("2021-05-22T02:00:00Z")},
+			},
+			{
+				Name:   "recurring",
+				Window: MaintenanceWindow{Tag: str("api"), Cron: str("0 3 * * SUN"), Duration: str("2h")},
+			},
+			{
+				Name:   "no scope",
+				Window: MaintenanceWindow{Cron: str("0 3 * * SUN"), Duration: str("2h")},
+				Error:  "maintenance window must have either website_id or tag",
+			},
+			{
+				Name:   "both scopes",
+				Window: MaintenanceWindow{WebsiteID: str("id1"), Tag: str("api"), Cron: str("0 3 * * SUN"), Duration: str("2h")},
+				Error:  "maintenance window must have either website_id or tag",
+			},
+			{
+				Name:   "one-off and recurring",
+				Window: MaintenanceWindow{Tag: str("api"), StartsAt: tm("2021-05-21T22:00:00Z"), Cron: str("0 3 * * SUN"), Duration: str("2h")},
+				Error:  "maintenance window must be either one-off or recurring",
+			},
+			{
+				Name:   "wrong cron",
+				Window: MaintenanceWindow{Tag: str("api"), Cron: str("at 3"), Duration: str("2h")},
+				Error:  "can't parse cron: .*",
+			},
+			{
+				Name:   "missing duration",
+				Window: MaintenanceWindow{Tag: str("api"), Cron: str("0 3 * * SUN")},
+				Error:  "missing duration",
+			},
+			{
+				Name:   "missing ends_at",
+				Window: MaintenanceWindow{Tag: str("api"), StartsAt: tm("2021-05-21T22:00:00Z")},
+				Error:  "missing starts_at or ends_at",
+			},
+			{
+				Name:   "ends_at before starts_at",
+				Window: MaintenanceWindow{Tag: str("api"), StartsAt: tm("2021-05-21T22:00:00Z"), EndsAt: tm("2021-05-21T20:00:00Z")},
+				Error:  "ends_at must be after starts_at",
+			},
+		}
+		for _, st := range tests {
+			c.Run(st.Name, func(c *qt.C) {
+				err := st.Window.Validate()
+				if st.Error == "" {
+					c.Assert(err, qt.IsNil)
+				} else {
+					c.Assert(err, qt.ErrorMatches, st.Error)
+				}
+			})
+		}
+	})
+
+	c.Run("Active", func(c *qt.C) {
+		oneOff := MaintenanceWindow{StartsAt: tm("2021-05-21T22:00:00Z"), EndsAt: tm("2021-05-22T02:00:00Z")}
+		c.Assert(oneOff.Active(*tm("2021-05-21T21:00:00Z")), qt.IsFalse)
+		c.Assert(oneOff.Active(*tm("2021-05-21T22:00:00Z")), qt.IsTrue)
+		c.Assert(oneOff.Active(*tm("2021-05-22T02:00:00Z")), qt.IsFalse)
+
+		recurring := MaintenanceWindow{Cron: str("CRON_TZ=UTC 0 3 * * SUN"), Duration: str("2h")}
+		c.Assert(recurring.Active(*tm("2021-05-23T02:59:59Z")), qt.IsFalse)
+		c.Assert(recurring.Active(*tm("2021-05-23T04:00:00Z")), qt.IsTrue)
+		c.Assert(recurring.Active(*tm("2021-05-23T05:00:00Z")), qt.IsFalse)
+	})
+
+	c.Run("Filter", func(c *qt.C) {
+		f := &MaintenanceFilter{
+			MaintenanceWindows: func(ctx context.Context) ([]MaintenanceWindow, error) {
+				return []MaintenanceWindow{
+					{WebsiteID: str("id1"), StartsAt: tm("2021-05-21T22:00:00Z"), EndsAt: tm("2021-05-22T02:00:00Z")},
+					{Tag: str("api"), Cron: str("CRON_TZ=UTC 0 3 * * SUN"), Duration: str("2h")},
+				}, nil
+			},
+		}
+		err := f.Reload(context.Background())
+		c.Assert(err, qt.IsNil)
+
+		var got []bool
+		handle := f.Wrap(func(ctx context.Context, wp WebsiteParams, wr WebsiteResult) error {
+			got = append(got, wr.InMaintenance)
+			return nil
+		})
+
+		for _, in := range []struct {
+			wp WebsiteParams
+			wr WebsiteResult
+		}{
+			{WebsiteParams{ID: "id1"}, WebsiteResult{At: *tm("2021-05-21T23:00:00Z")}},
+			{WebsiteParams{ID: "id1"}, WebsiteResult{At: *tm("2021-05-23T03:00:00Z")}},
+			{WebsiteParams{ID: "id2", Tags: []string{"api"}}, WebsiteResult{At: *tm("2021-05-23T03:00:00Z")}},
+			{WebsiteParams{ID: "id2", Tags: []string{"api"}}, WebsiteResult{At: *tm("2021-05-21T23:00:00Z")}},
+			{WebsiteParams{ID: "id3"}, WebsiteResult{At: *tm("2021-05-21T23:00:00Z"), InMaintenance: true}},
+		} {
+			err := handle(context.Background(), in.wp, in.wr)
+			c.Assert(err, qt.IsNil)
+		}
+		c.Assert(got, qt.DeepEquals, []bool{true, false, true, false, true})
+	})
+}
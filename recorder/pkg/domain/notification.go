@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// Notification defines a message waiting in the outbox to be delivered to a receiver.
+type Notification struct {
+	ID       int64  `db:"id"`
+	Receiver string `db:"receiver"`
+	// Payload is the JSON encoded IncidentEvent.
+	Payload  []byte `db:"payload"`
+	Attempts int    `db:"attempts"`
+	// NextAttemptAt is nil once the notification is not retried anymore.
+	NextAttemptAt *time.Time `db:"next_attempt_at"`
+	LastError     *string    `db:"last_error"`
+}
+
+// NotificationsFunc returns the notifications of an incident event to enqueue in the outbox.
+type NotificationsFunc func(ev IncidentEvent) ([]Notification, error)
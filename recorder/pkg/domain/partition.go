@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Partitioner creates the monthly partitions of the results ahead of time.
+type Partitioner struct {
+	Interval time.Duration
+	// Ahead is the number of months after the current one to create partitions for.
+	Ahead int
+
+	CreatePartitions func(ctx context.Context, from, to time.Time) error
+}
+
+// Run creates the partitions every Interval until the context is done.
+func (p *Partitioner) Run(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(p.Interval):
+			if err := p.Partition(ctx, time.Now().UTC()); err != nil {
+				log.Error().Err(err).Msg("can't create partitions")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Partition creates the partitions of the month of now and the Ahead following ones.
+func (p *Partitioner) Partition(ctx context.Context, now time.Time) error {
+	now = now.UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if err := p.CreatePartitions(ctx, from, from.AddDate(0, p.Ahead+1, 0)); err != nil {
+		return fmt.Errorf("can't create partitions: %w", err)
+	}
+	return nil
+}
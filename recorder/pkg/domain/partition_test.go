@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestPartitioner(t *testing.T) {
+	c := qt.New(t)
+
+	var from, to time.Time
+	p := &Partitioner{
+		Ahead: 1,
+		CreatePartitions: func(ctx context.Context, f, t time.Time) error {
+			from, to = f, t
+			return nil
+		},
+	}
+
+	tests := []struct {
+		Name     string
+		Now      time.Time
+		From, To time.Time
+	}{
+		{
+			Name: "end of month",
+			Now:  time.Date(2021, 1, 31, 23, 59, 59, 0, time.UTC),
+			From: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Name: "start of month",
+			Now:  time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC),
+			From: time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Name: "end of year",
+			Now:  time.Date(2021, 12, 15, 0, 0, 0, 0, time.UTC),
+			From: time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Name: "other time zone",
+			Now:  time.Date(2021, 6, 1, 1, 0, 0, 0, time.FixedZone("CEST", 2*3600)),
+			From: time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, st := range tests {
+		c.Run(st.Name, func(c *qt.C) {
+			err := p.Partition(context.Background(), st.Now)
+			c.Assert(err, qt.IsNil)
+			c.Assert(from, qt.Equals, st.From)
+			c.Assert(to, qt.Equals, st.To)
+		})
+	}
+}
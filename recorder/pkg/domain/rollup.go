@@ -0,0 +1,212 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RollupPeriod defines the granularity of the results rollups.
+type RollupPeriod string
+
+const (
+	RollupHourly RollupPeriod = "hour"
+	RollupDaily  RollupPeriod = "day"
+)
+
+// Duration returns the size of the rollup buckets.
+func (p RollupPeriod) Duration() time.Duration {
+	if p == RollupDaily {
+		return 24 * time.Hour
+	}
+	return time.Hour
+}
+
+// RollupThreshold is the period from which the stores aggregate the rollups instead of the raw results.
+var RollupThreshold = 48 * time.Hour
+
+// RollupPeriodFor returns the rollups to read to aggregate a period in buckets.
+// Rollups are only read for periods longer than RollupThreshold and buckets multiple
+// of the rollup period.
+func RollupPeriodFor(period, bucket time.Duration) (RollupPeriod, bool) {
+	if period <= RollupThreshold {
+		return "", false
+	}
+	for _, p := range []RollupPeriod{RollupDaily, RollupHourly} {
+		if bucket%p.Duration() == 0 {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// Rollup defines the aggregated results of a website within an hour or a day.
+// Results in maintenance are excluded.
+type Rollup struct {
+	WebsiteID string    `json:"website_id" db:"website_id"`
+	From      time.Time `json:"from" db:"bucket_start"`
+	Checks    int       `json:"checks" db:"checks"`
+	Failures  int       `json:"failures" db:"failures"`
+	// Latencies in seconds
+	MinElapsed float64 `json:"min_elapsed" db:"min_elapsed"`
+	AvgElapsed float64 `json:"avg_elapsed" db:"avg_elapsed"`
+	MaxElapsed float64 `json:"max_elapsed" db:"max_elapsed"`
+	P50        float64 `json:"p50" db:"p50_elapsed"`
+	P95        float64 `json:"p95" db:"p95_elapsed"`
+	P99        float64 `json:"p99" db:"p99_elapsed"`
+	// StatusCodes is the number of checks per HTTP status code.
+	StatusCodes map[int]int `json:"status_codes" db:"-"`
+}
+
+// Downsampler keeps the hourly and daily rollups up to date and deletes the
+// raw results older than Retention once they are rolled up.
+//
+// The hours which received results since the last call are rolled up again, so
+// the rollups include late results, e.g. from a checker catching up.
+type Downsampler struct {
+	Interval time.Duration
+	// Retention is how long raw results are kept. Zero keeps them forever.
+	Retention time.Duration
+
+	// RollupWatermark returns the time to start rolling up from when starting,
+	// nil if there are no results at all.
+	RollupWatermark     func(ctx context.Context) (*time.Time, error)
+	Rollup              func(ctx context.Context, period RollupPeriod, from, to time.Time) error
+	DeleteResultsBefore func(ctx context.Context, before time.Time) (int64, error)
+
+	rolledUpTo time.Time
+
+	mu sync.Mutex
+	// dirty are the hours which received results since the last call.
+	dirty map[time.Time]struct{}
+}
+
+// HandleResult marks the hour of the result to be rolled up again.
+func (d *Downsampler) HandleResult(ctx context.Context, wp WebsiteParams, wr WebsiteResult) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.dirty == nil {
+		d.dirty = make(map[time.Time]struct{})
+	}
+	d.dirty[wr.At.UTC().Truncate(time.Hour)] = struct{}{}
+	return nil
+}
+
+// Run downsamples every Interval until the context is done.
+func (d *Downsampler) Run(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(d.Interval):
+			if err := d.Downsample(ctx, time.Now().UTC()); err != nil {
+				log.Error().Err(err).Msg("can't downsample results")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Downsample rolls up the results recorded since the last call until now and deletes
+// the expired raw results. The previous hour and the hours which received results since
+// the last call are rolled up again to include late results.
+func (d *Downsampler) Downsample(ctx context.Context, now time.Time) error {
+	d.mu.Lock()
+	dirty := d.dirty
+	d.dirty = nil
+	d.mu.Unlock()
+
+	from := d.rolledUpTo
+	if from.IsZero() {
+		watermark, err := d.RollupWatermark(ctx)
+		if err != nil {
+			return fmt.Errorf("can't get rollup watermark: %w", err)
+		}
+		if watermark == nil {
+			return nil
+		}
+		from = *watermark
+	}
+
+	for _, period := range []RollupPeriod{RollupHourly, RollupDaily} {
+		if err := d.Rollup(ctx, period, from.Truncate(period.Duration()), now); err != nil {
+			d.markDirty(dirty)
+			return fmt.Errorf("can't roll up results per %s: %w", period, err)
+		}
+	}
+	if err := d.rollupDirty(ctx, dirty, from, now); err != nil {
+		d.markDirty(dirty)
+		return err
+	}
+	d.rolledUpTo = now.Truncate(time.Hour).Add(-time.Hour)
+
+	if d.Retention <= 0 {
+		return nil
+	}
+	// The current day is rolled up again on next calls so its raw results are kept
+	before := now.Add(-d.Retention)
+	if today := now.Truncate(24 * time.Hour); before.After(today) {
+		before = today
+	}
+	n, err := d.DeleteResultsBefore(ctx, before)
+	if err != nil {
+		return fmt.Errorf("can't delete expired results: %w", err)
+	}
+	if n > 0 {
+		log.Info().Msgf("Deleted %d results before %s", n, before.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// rollupDirty rolls up again the dirty hours, and their days, before the rolled up period
+// starting at from. The hours whose day is older than Retention are skipped as their
+// raw results may be deleted already, so rolling them up again would lose results.
+func (d *Downsampler) rollupDirty(ctx context.Context, dirty map[time.Time]struct{}, from, now time.Time) error {
+	hours := make([]time.Time, 0, len(dirty))
+	for hour := range dirty {
+		if !hour.Before(from.Truncate(time.Hour)) {
+			continue
+		}
+		if d.Retention > 0 && hour.Truncate(24*time.Hour).Before(now.Add(-d.Retention)) {
+			log.Warn().Msgf("Results of %s older than retention are not rolled up", hour.Format(time.RFC3339))
+			continue
+		}
+		hours = append(hours, hour)
+	}
+	sort.Slice(hours, func(i, j int) bool { return hours[i].Before(hours[j]) })
+
+	days := make(map[time.Time]bool)
+	for _, hour := range hours {
+		if err := d.Rollup(ctx, RollupHourly, hour, hour.Add(time.Hour)); err != nil {
+			return fmt.Errorf("can't roll up late results per %s: %w", RollupHourly, err)
+		}
+		day := hour.Truncate(24 * time.Hour)
+		if days[day] {
+			continue
+		}
+		days[day] = true
+		if err := d.Rollup(ctx, RollupDaily, day, day.Add(24*time.Hour)); err != nil {
+			return fmt.Errorf("can't roll up late results per %s: %w", RollupDaily, err)
+		}
+	}
+
+	return nil
+}
+
+// markDirty marks the hours to be rolled up on the next call, usually after a failure.
+func (d *Downsampler) markDirty(hours map[time.Time]struct{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.dirty == nil {
+		d.dirty = make(map[time.Time]struct{})
+	}
+	for hour := range hours {
+		d.dirty[hour] = struct{}{}
+	}
+}
@@ -0,0 +1,125 @@
+package domain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestDownsampler(t *testing.T) {
+	c := qt.New(t)
+
+	type rollup struct {
+		Period   RollupPeriod
+		From, To time.Time
+	}
+	var (
+		rollups   []rollup
+		deleted   []time.Time
+		watermark *time.Time
+	)
+	newDownsampler := func(retention time.Duration) *Downsampler {
+		return &Downsampler{
+			Retention: retention,
+			RollupWatermark: func(ctx context.Context) (*time.Time, error) {
+				return watermark, nil
+			},
+			Rollup: func(ctx context.Context, period RollupPeriod, from, to time.Time) error {
+				rollups = append(rollups, rollup{period, from, to})
+				return nil
+			},
+			DeleteResultsBefore: func(ctx context.Context, before time.Time) (int64, error) {
+				deleted = append(deleted, before)
+				return 1, nil
+			},
+		}
+	}
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2021, 5, day, hour, min, 0, 0, time.UTC)
+	}
+
+	c.Run("No results", func(c *qt.C) {
+		rollups, deleted, watermark = nil, nil, nil
+		d := newDownsampler(72 * time.Hour)
+		err := d.Downsample(context.Background(), at(26, 10, 30))
+		c.Assert(err, qt.IsNil)
+		c.Assert(rollups, qt.HasLen, 0)
+		c.Assert(deleted, qt.HasLen, 0)
+	})
+
+	c.Run("Catch up and follow", func(c *qt.C) {
+		rollups, deleted = nil, nil
+		first := at(20, 13, 45)
+		watermark = &first
+		d := newDownsampler(72 * time.Hour)
+
+		err := d.Downsample(context.Background(), at(26, 10, 30))
+		c.Assert(err, qt.IsNil)
+		c.Assert(rollups, qt.DeepEquals, []rollup{
+			{RollupHourly, at(20, 13, 0), at(26, 10, 30)},
+			{RollupDaily, at(20, 0, 0), at(26, 10, 30)},
+		})
+		c.Assert(deleted, qt.DeepEquals, []time.Time{at(23, 10, 30)})
+
+		// The previous hour and the current day are rolled up again
+		rollups, deleted = nil, nil
+		err = d.Downsample(context.Background(), at(26, 10, 35))
+		c.Assert(err, qt.IsNil)
+		c.Assert(rollups, qt.DeepEquals, []rollup{
+			{RollupHourly, at(26, 9, 0), at(26, 10, 35)},
+			{RollupDaily, at(26, 0, 0), at(26, 10, 35)},
+		})
+		c.Assert(deleted, qt.DeepEquals, []time.Time{at(23, 10, 35)})
+	})
+
+	c.Run("Late results", func(c *qt.C) {
+		rollups, deleted = nil, nil
+		first := at(26, 8, 0)
+		watermark = &first
+		d := newDownsampler(72 * time.Hour)
+		err := d.Downsample(context.Background(), at(26, 10, 30))
+		c.Assert(err, qt.IsNil)
+
+		// Results of a checker catching up, of the current hour and too old to be rolled up
+		for _, t := range []time.Time{at(25, 22, 10), at(25, 22, 40), at(26, 3, 5), at(26, 10, 32), at(20, 1, 0)} {
+			err := d.HandleResult(context.Background(), WebsiteParams{}, WebsiteResult{At: t})
+			c.Assert(err, qt.IsNil)
+		}
+		rollups = nil
+		err = d.Downsample(context.Background(), at(26, 10, 35))
+		c.Assert(err, qt.IsNil)
+		c.Assert(rollups, qt.DeepEquals, []rollup{
+			{RollupHourly, at(26, 9, 0), at(26, 10, 35)},
+			{RollupDaily, at(26, 0, 0), at(26, 10, 35)},
+			{RollupHourly, at(25, 22, 0), at(25, 23, 0)},
+			{RollupDaily, at(25, 0, 0), at(26, 0, 0)},
+			{RollupHourly, at(26, 3, 0), at(26, 4, 0)},
+			{RollupDaily, at(26, 0, 0), at(27, 0, 0)},
+		})
+
+		// Dirty hours are only rolled up once
+		rollups = nil
+		err = d.Downsample(context.Background(), at(26, 10, 40))
+		c.Assert(err, qt.IsNil)
+		c.Assert(rollups, qt.HasLen, 2)
+	})
+
+	c.Run("Retention shorter than a day", func(c *qt.C) {
+		rollups, deleted = nil, nil
+		d := newDownsampler(time.Hour)
+		err := d.Downsample(context.Background(), at(26, 10, 30))
+		c.Assert(err, qt.IsNil)
+		c.Assert(deleted, qt.DeepEquals, []time.Time{at(26, 0, 0)}, qt.Commentf("current day results are kept"))
+	})
+
+	c.Run("Keep forever", func(c *qt.C) {
+		rollups, deleted = nil, nil
+		d := newDownsampler(0)
+		err := d.Downsample(context.Background(), at(26, 10, 30))
+		c.Assert(err, qt.IsNil)
+		c.Assert(rollups, qt.HasLen, 2)
+		c.Assert(deleted, qt.HasLen, 0)
+	})
+}
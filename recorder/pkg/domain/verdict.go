@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Verdict defines the availability of a website within a time window.
+type Verdict string
+
+const (
+	VerdictUp       Verdict = "up"
+	VerdictDegraded Verdict = "degraded"
+	VerdictDown     Verdict = "down"
+)
+
+// NewVerdict returns the verdict given the number of failed locations over the total
+// of locations which checked the website. The website is down if at least quorum locations
+// failed or all of them if there are less locations than quorum.
+func NewVerdict(failed, total, quorum int) Verdict {
+	if quorum > total {
+		quorum = total
+	}
+	switch {
+	case failed == 0:
+		return VerdictUp
+	case failed >= quorum:
+		return VerdictDown
+	default:
+		return VerdictDegraded
+	}
+}
+
+// WebsiteStatus defines the verdict of a website within a time window.
+type WebsiteStatus struct {
+	WebsiteID       string    `json:"website_id" db:"website_id"`
+	WindowStart     time.Time `json:"window_start" db:"window_start"`
+	WindowEnd       time.Time `json:"window_end" db:"window_end"`
+	Verdict         Verdict   `json:"verdict" db:"verdict"`
+	Locations       int       `json:"locations" db:"locations"`
+	FailedLocations int       `json:"failed_locations" db:"failed_locations"`
+}
+
+// StatusEvaluator computes the website status of the time window a result belongs to.
+type StatusEvaluator struct {
+	// Window is the time window size to group results.
+	Window time.Duration
+	// Quorum is the number of locations which must fail to consider the website down.
+	Quorum int
+
+	FetchWebsiteResults func(ctx context.Context, websiteID string, from, to time.Time) ([]WebsiteResult, error)
+	SaveWebsiteStatus   func(ctx context.Context, ws WebsiteStatus) error
+}
+
+// HandleResult evaluates the status of the window where wr was recorded.
+// A location counts as failed if any of its checks failed within the window.
+// Results in maintenance are excluded.
+func (e *StatusEvaluator) HandleResult(ctx context.Context, wp WebsiteParams, wr WebsiteResult) error {
+	if wr.InMaintenance {
+		return nil
+	}
+
+	from := wr.At.Truncate(e.Window)
+	to := from.Add(e.Window)
+
+	results, err := e.FetchWebsiteResults(ctx, wp.ID, from, to)
+	if err != nil {
+		return fmt.Errorf("can't fetch website results: %w", err)
+	}
+
+	failedByLocation := make(map[string]bool)
+	for _, r := range results {
+		if r.InMaintenance {
+			continue
+		}
+		failedByLocation[r.Location] = failedByLocation[r.Location] || r.Failed()
+	}
+	failed := 0
+	for _, f := range failedByLocation {
+		if f {
+			failed++
+		}
+	}
+
+	ws := WebsiteStatus{
+		WebsiteID:       wp.ID,
+		WindowStart:     from,
+		WindowEnd:       to,
+		Verdict:         NewVerdict(failed, len(failedByLocation), e.Quorum),
+		Locations:       len(failedByLocation),
+		FailedLocations: failed,
+	}
+	if err := e.SaveWebsiteStatus(ctx, ws); err != nil {
+		return fmt.Errorf("can't save website status: %w", err)
+	}
+
+	return nil
+}
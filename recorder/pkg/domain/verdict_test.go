@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestNewVerdict(t *testing.T) {
+	c := qt.New(t)
+
+	tests := []struct {
+		Name     string
+		Failed   int
+		Total    int
+		Quorum   int
+		Expected Verdict
+	}{
+		{Name: "no failures", Failed: 0, Total: 3, Quorum: 2, Expected: VerdictUp},
+		{Name: "below quorum", Failed: 1, Total: 3, Quorum: 2, Expected: VerdictDegraded},
+		{Name: "quorum", Failed: 2, Total: 3, Quorum: 2, Expected: VerdictDown},
+		{Name: "all failed", Failed: 3, Total: 3, Quorum: 2, Expected: VerdictDown},
+		{Name: "less locations than quorum", Failed: 1, Total: 1, Quorum: 2, Expected: VerdictDown},
+		{Name: "no results", Failed: 0, Total: 0, Quorum: 2, Expected: VerdictUp},
+	}
+	for _, st := range tests {
+		c.Run(st.Name, func(c *qt.C) {
+			c.Assert(NewVerdict(st.Failed, st.Total, st.Quorum), qt.Equals, st.Expected)
+		})
+	}
+}
+
+func TestStatusEvaluator(t *testing.T) {
+	c := qt.New(t)
+
+	ok := http.StatusOK
+	badGateway := http.StatusBadGateway
+	at := time.Date(2021, 5, 12, 10, 30, 20, 0, time.UTC)
+	results := []WebsiteResult{
+		{Status: &ok, At: at, Location: "eu-west"},
+		{Status: &badGateway, At: at, Location: "us-east"},
+		{Status: &ok, At: at.Add(time.Second), Location: "us-east"},
+		{Unreachable: true, At: at, Location: "ap-south"},
+		{Unreachable: true, At: at, Location: "sa-east", InMaintenance: true},
+	}
+
+	var saved []WebsiteStatus
+	e := &StatusEvaluator{
+		Window: time.Minute,
+		Quorum: 3,
+		FetchWebsiteResults: func(ctx context.Context, websiteID string, from, to time.Time) ([]WebsiteResult, error) {
+			c.Assert(websiteID, qt.Equals, "id1")
+			c.Assert(from, qt.Equals, time.Date(2021, 5, 12, 10, 30, 0, 0, time.UTC))
+			c.Assert(to, qt.Equals, time.Date(2021, 5, 12, 10, 31, 0, 0, time.UTC))
+			return results, nil
+		},
+		SaveWebsiteStatus: func(ctx context.Context, ws WebsiteStatus) error {
+			saved = append(saved, ws)
+			return nil
+		},
+	}
+
+	err := e.HandleResult(context.Background(), WebsiteParams{ID: "id1"}, results[0])
+	c.Assert(err, qt.IsNil)
+	c.Assert(saved, qt.DeepEquals, []WebsiteStatus{{
+		WebsiteID:       "id1",
+		WindowStart:     time.Date(2021, 5, 12, 10, 30, 0, 0, time.UTC),
+		WindowEnd:       time.Date(2021, 5, 12, 10, 31, 0, 0, time.UTC),
+		Verdict:         VerdictDegraded,
+		Locations:       3,
+		FailedLocations: 2,
+	}})
+
+	saved = nil
+	err = e.HandleResult(context.Background(), WebsiteParams{ID: "id1"}, results[4])
+	c.Assert(err, qt.IsNil)
+	c.Assert(saved, qt.HasLen, 0, qt.Commentf("results in maintenance are excluded"))
+}
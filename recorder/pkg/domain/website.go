@@ -4,20 +4,243 @@ import "time"
 
 // WebsiteParams defines the website parameters to check against
 type WebsiteParams struct {
-	ID          string  `json:"id" db:"id"`
-	URL         string  `json:"url" db:"url"`
-	Method      string  `json:"method" db:"method"`
-	MatchRegexp *string `json:"match_regexp" db:"match_regexp"`
+	ID          string   `json:"id" db:"id"`
+	URL         string   `json:"url" db:"url"`
+	Method      string   `json:"method" db:"method"`
+	MatchRegexp *string  `json:"match_regexp" db:"match_regexp"`
+	Tags        []string `json:"tags" db:"-"`
+}
+
+// HasAnyTag returns true if the website has any of the given tags.
+func (wp WebsiteParams) HasAnyTag(tags []string) bool {
+	for _, t := range tags {
+		for _, wt := range wp.Tags {
+			if t == wt {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // WebsiteResult defines the result of a website check
 type WebsiteResult struct {
 	Elapsed time.Duration `json:"elapsed"`
 	Status  *int          `json:"status"`
-	// Matched optionally says if the body response matched the regular expression if provided.
+	// Matched optionally says if the body response matched the regular expression if provided,
+	// or if the expected answers were resolved by DNS probes.
 	Matched *bool `json:"matched" `
 	// Unreachable means the website check timed out.
 	Unreachable bool `json:"unreachable"`
 	// At determines when the result was recorded
 	At time.Time `json:"at"`
+	// Location identifies where the check was performed from.
+	Location string `json:"location"`
+	// CheckerID identifies the checker among the ones of the same location.
+	CheckerID string `json:"checker_id,omitempty"`
+	// IP is the address checked in per IP mode.
+	IP string `json:"ip,omitempty"`
+	// InMaintenance means the check was performed during a maintenance window.
+	InMaintenance bool `json:"in_maintenance"`
+	// Probe is the type of check: http, tcp, tls, dns, grpc or websocket. Empty means http.
+	Probe string `json:"probe,omitempty"`
+	// Error describes why a check of a non HTTP probe, or a step of a multi-step check, failed.
+	Error *string `json:"error,omitempty"`
+	// TLS is set by TLS probes once the handshake is done.
+	TLS *TLSResult `json:"tls,omitempty"`
+	// DNS is set by DNS probes.
+	DNS *DNSResult `json:"dns,omitempty"`
+	// GRPC is set by gRPC probes once the health service is called.
+	GRPC *GRPCResult `json:"grpc,omitempty"`
+	// WebSocket is set by WebSocket probes once the handshake is done.
+	WebSocket *WebSocketResult `json:"websocket,omitempty"`
+	// Steps holds the results of the steps of a multi-step check, up to the failed one.
+	Steps []StepResult `json:"steps,omitempty"`
+	// FailedStep is the index in Steps of the step the multi-step check failed at.
+	FailedStep *int `json:"failed_step,omitempty"`
+	// BodySize is the number of bytes of the response body read by HTTP probes,
+	// up to the maximum body size.
+	BodySize int64 `json:"body_size,omitempty"`
+	// BodyTruncated means the response body is larger than the maximum body size.
+	BodyTruncated bool `json:"body_truncated,omitempty"`
+	// ContentType is the Content-Type header of the response of HTTP probes.
+	ContentType string `json:"content_type,omitempty"`
+	// Redirects is the chain of redirects followed by HTTP probes.
+	Redirects []Redirect `json:"redirects,omitempty"`
+	// FinalURL is the URL of the last response of HTTP probes which were redirected
+	// or expect a final URL.
+	FinalURL string `json:"final_url,omitempty"`
+}
+
+// ProbeHTTP is the probe type of HTTP checks.
+const ProbeHTTP = "http"
+
+// TLSResult defines the handshake details of a TLS probe.
+type TLSResult struct {
+	Version     string    `json:"version"`
+	CipherSuite string    `json:"cipher_suite"`
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	NotAfter    time.Time `json:"not_after"`
+	// Verified says if the certificate chain is trusted and valid for the host.
+	Verified bool `json:"verified"`
+}
+
+// DNSResult defines the answers of a DNS probe.
+type DNSResult struct {
+	Answers []string `json:"answers"`
+}
+
+// GRPCResult defines the answer of a gRPC probe.
+type GRPCResult struct {
+	// Code is the gRPC status code of the health check call.
+	Code int `json:"code"`
+	// ServingStatus is the health of the service, e.g. SERVING or NOT_SERVING, if the call succeeded.
+	ServingStatus string `json:"serving_status,omitempty"`
+}
+
+// WebSocketResult defines the timings of a WebSocket probe.
+type WebSocketResult struct {
+	// Handshake is the time to open the WebSocket.
+	Handshake time.Duration `json:"handshake"`
+	// ReplyLatency is the time to receive the reply, if one was awaited and received.
+	ReplyLatency *time.Duration `json:"reply_latency,omitempty"`
+	// CloseCode is the status code of the close frame sent by the server, if any.
+	CloseCode *int `json:"close_code,omitempty"`
+}
+
+// Redirect defines a hop of the redirect chain of an HTTP probe.
+type Redirect struct {
+	URL     string        `json:"url"`
+	Status  int           `json:"status"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// StepResult defines the result of a step of a multi-step check.
+type StepResult struct {
+	Name    string        `json:"name,omitempty"`
+	Elapsed time.Duration `json:"elapsed"`
+	Status  *int          `json:"status"`
+	Matched *bool         `json:"matched"`
+	Error   *string       `json:"error,omitempty"`
+
+	BodySize      int64  `json:"body_size,omitempty"`
+	BodyTruncated bool   `json:"body_truncated,omitempty"`
+	ContentType   string `json:"content_type,omitempty"`
+}
+
+// SetFailedStep sets FailedStep from the steps of a multi-step check. As they
+// stop at the first failure, the failed step is the last one of a failed check.
+func (wr *WebsiteResult) SetFailedStep() {
+	wr.FailedStep = nil
+	if len(wr.Steps) > 0 && wr.Failed() {
+		i := len(wr.Steps) - 1
+		wr.FailedStep = &i
+	}
+}
+
+// ResultDetails holds the probe specific fields of a result. They are stored as JSON.
+type ResultDetails struct {
+	TLS       *TLSResult       `json:"tls,omitempty"`
+	DNS       *DNSResult       `json:"dns,omitempty"`
+	GRPC      *GRPCResult      `json:"grpc,omitempty"`
+	WebSocket *WebSocketResult `json:"websocket,omitempty"`
+	Redirects []Redirect       `json:"redirects,omitempty"`
+	FinalURL  string           `json:"final_url,omitempty"`
+
+	BodySize      int64  `json:"body_size,omitempty"`
+	BodyTruncated bool   `json:"body_truncated,omitempty"`
+	ContentType   string `json:"content_type,omitempty"`
+}
+
+// Details returns the probe specific fields of the result or nil if there are none.
+func (wr WebsiteResult) Details() *ResultDetails {
+	if wr.TLS == nil && wr.DNS == nil && wr.GRPC == nil && wr.WebSocket == nil &&
+		len(wr.Redirects) == 0 && wr.FinalURL == "" &&
+		wr.BodySize == 0 && !wr.BodyTruncated && wr.ContentType == "" {
+		return nil
+	}
+	return &ResultDetails{
+		TLS:       wr.TLS,
+		DNS:       wr.DNS,
+		GRPC:      wr.GRPC,
+		WebSocket: wr.WebSocket,
+		Redirects: wr.Redirects,
+		FinalURL:  wr.FinalURL,
+
+		BodySize:      wr.BodySize,
+		BodyTruncated: wr.BodyTruncated,
+		ContentType:   wr.ContentType,
+	}
+}
+
+// SetDetails sets the probe specific fields of the result.
+func (wr *WebsiteResult) SetDetails(details ResultDetails) {
+	wr.TLS, wr.DNS, wr.GRPC, wr.WebSocket = details.TLS, details.DNS, details.GRPC, details.WebSocket
+	wr.Redirects, wr.FinalURL = details.Redirects, details.FinalURL
+	wr.BodySize, wr.BodyTruncated, wr.ContentType = details.BodySize, details.BodyTruncated, details.ContentType
+}
+
+// Failed returns true if the check did not succeed: the website was
+// unreachable, answered with an error status, did not match the
+// regular expression or the probe reported an error.
+func (wr WebsiteResult) Failed() bool {
+	if wr.Unreachable || wr.Error != nil || (wr.Matched != nil && !*wr.Matched) {
+		return true
+	}
+	// Only HTTP probes have a status
+	if wr.Probe != "" && wr.Probe != ProbeHTTP {
+		return false
+	}
+	return wr.Status == nil || *wr.Status >= 400
+}
+
+// LocationStats defines the aggregated results of a website checked from a location.
+type LocationStats struct {
+	Location string `json:"location" db:"location"`
+	Checks   int    `json:"checks" db:"checks"`
+	Failures int    `json:"failures" db:"failures"`
+	// AvgElapsed is the average elapsed time of the checks in seconds.
+	AvgElapsed float64 `json:"avg_elapsed" db:"avg_elapsed"`
+}
+
+// ResultsQuery defines the filters to query the results of a website.
+type ResultsQuery struct {
+	WebsiteID string
+	// From is inclusive and To is exclusive.
+	From, To time.Time
+	// Location optionally filters by location.
+	Location string
+	Limit    int
+	Offset   int
+}
+
+// UptimeStats defines the aggregated results of a website within a period.
+// Results in maintenance are excluded.
+type UptimeStats struct {
+	From     time.Time `json:"from" db:"bucket_start"`
+	To       time.Time `json:"to" db:"-"`
+	Checks   int       `json:"checks" db:"checks"`
+	Failures int       `json:"failures" db:"failures"`
+	// Uptime is the percentage of successful checks.
+	Uptime float64 `json:"uptime" db:"-"`
+	// Latency percentiles in seconds
+	P50 float64 `json:"p50" db:"p50"`
+	P95 float64 `json:"p95" db:"p95"`
+	P99 float64 `json:"p99" db:"p99"`
+}
+
+// WebsiteLatestStatus defines the latest known state of a website.
+type WebsiteLatestStatus struct {
+	Website WebsiteParams  `json:"website"`
+	Result  *WebsiteResult `json:"result"`
+	Verdict *Verdict       `json:"verdict"`
+}
+
+// Uptime returns the percentage of successful checks.
+func Uptime(checks, failures int) float64 {
+	if checks == 0 {
+		return 0
+	}
+	return 100 * float64(checks-failures) / float64(checks)
 }
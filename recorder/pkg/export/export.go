@@ -0,0 +1,103 @@
+// Package export forwards the website results as time series to external systems.
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+const (
+	// measurement is the prefix of the exported series
+	measurement = "gpagdispo_check"
+	// maxErrorBodySize is the maximum size of the response body read to report an error
+	maxErrorBodySize = 512
+)
+
+// label defines a label, or tag, of the exported series.
+type label struct {
+	name, value string
+}
+
+// sample defines a value of a result exported as a series.
+type sample struct {
+	field string
+	value float64
+}
+
+// resultLabels returns the labels of the series of a website result sorted by name.
+// Labels with empty values are omitted.
+func resultLabels(wp domain.WebsiteParams, wr domain.WebsiteResult) []label {
+	labels := make([]label, 0, 6)
+	for _, l := range []label{
+		{name: "checker_id", value: wr.CheckerID},
+		{name: "ip", value: wr.IP},
+		{name: "location", value: wr.Location},
+		{name: "method", value: wp.Method},
+		{name: "url", value: wp.URL},
+		{name: "website_id", value: wp.ID},
+	} {
+		if l.value != "" {
+			labels = append(labels, l)
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+	return labels
+}
+
+// resultSamples returns the values of a website result to export.
+func resultSamples(wr domain.WebsiteResult) []sample {
+	samples := []sample{
+		{field: "duration_seconds", value: wr.Elapsed.Seconds()},
+		{field: "success", value: boolValue(!wr.Failed())},
+		{field: "in_maintenance", value: boolValue(wr.InMaintenance)},
+	}
+	if wr.Status != nil {
+		samples = append(samples, sample{field: "status_code", value: float64(*wr.Status)})
+	}
+	if wr.Matched != nil {
+		samples = append(samples, sample{field: "matched", value: boolValue(*wr.Matched)})
+	}
+	return samples
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// post sends the body to the URL and returns an error if the response is not successful.
+func post(ctx context.Context, client *http.Client, url string, header http.Header, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("can't create request: %w", err)
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("can't send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		blob, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(blob))
+	}
+	// Drain the body to reuse the connection
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
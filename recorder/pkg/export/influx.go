@@ -0,0 +1,75 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+// InfluxWriter forwards the website results to an InfluxDB write endpoint using
+// the line protocol. Every result is a gpagdispo_check point tagged with the
+// website ID, URL, method and location.
+type InfluxWriter struct {
+	// URL is the write endpoint including the database or bucket, e.g.
+	// http://localhost:8086/write?db=gpagdispo or http://localhost:8086/api/v2/write?org=o&bucket=b
+	URL string
+	// Token is optionally sent as authorization token (InfluxDB 2.x)
+	Token  string
+	Client *http.Client
+}
+
+// Export sends the results to the InfluxDB endpoint in a single request. It has the signature of ExportFn.
+func (iw *InfluxWriter) Export(ctx context.Context, results []Result) error {
+	header := http.Header{}
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	if iw.Token != "" {
+		header.Set("Authorization", "Token "+iw.Token)
+	}
+
+	var body strings.Builder
+	for _, r := range results {
+		body.WriteString(encodeLine(r.Website, r.Result))
+	}
+
+	if err := post(ctx, iw.Client, iw.URL, header, []byte(body.String())); err != nil {
+		return fmt.Errorf("can't write results to InfluxDB: %w", err)
+	}
+
+	return nil
+}
+
+// tagEscaper escapes the special characters of tag keys and values.
+var tagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// encodeLine encodes the result as a line of the InfluxDB line protocol with nanosecond precision.
+func encodeLine(wp domain.WebsiteParams, wr domain.WebsiteResult) string {
+	var sb strings.Builder
+	sb.WriteString(measurement)
+	for _, l := range resultLabels(wp, wr) {
+		sb.WriteByte(',')
+		sb.WriteString(l.name)
+		sb.WriteByte('=')
+		sb.WriteString(tagEscaper.Replace(l.value))
+	}
+
+	for i, s := range resultSamples(wr) {
+		if i == 0 {
+			sb.WriteByte(' ')
+		} else {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(s.field)
+		sb.WriteByte('=')
+		sb.WriteString(strconv.FormatFloat(s.value, 'f', -1, 64))
+	}
+
+	sb.WriteByte(' ')
+	sb.WriteString(strconv.FormatInt(wr.At.UnixNano(), 10))
+	sb.WriteByte('\n')
+
+	return sb.String()
+}
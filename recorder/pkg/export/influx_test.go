@@ -0,0 +1,85 @@
+package export
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+func TestInfluxWriter(t *testing.T) {
+	c := qt.New(t)
+
+	var received string
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query().Get("db"), qt.Equals, "gpagdispo")
+		c.Check(r.Header.Get("Authorization"), qt.Equals, "Token s3cr3t")
+
+		blob, err := io.ReadAll(r.Body)
+		c.Assert(err, qt.IsNil)
+		received = string(blob)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer svr.Close()
+
+	iw := &InfluxWriter{URL: svr.URL + "/write?db=gpagdispo", Token: "s3cr3t"}
+
+	status := http.StatusServiceUnavailable
+	err := iw.Export(context.Background(), []Result{{Website: testWebsite, Result: domain.WebsiteResult{
+		Elapsed:       1500 * time.Millisecond,
+		Status:        &status,
+		At:            testAt,
+		Location:      "us-east,1",
+		InMaintenance: true,
+	}}})
+	c.Assert(err, qt.IsNil)
+	c.Assert(received, qt.Equals,
+		`gpagdispo_check,location=us-east\,1,method=GET,url=http://foo.org/a\ b,website_id=id1 `+
+			`duration_seconds=1.5,success=0,in_maintenance=1,status_code=503 1621850400000000000`+"\n")
+
+	c.Run("Without location", func(c *qt.C) {
+		err := iw.Export(context.Background(), []Result{{Website: testWebsite, Result: domain.WebsiteResult{Unreachable: true, At: testAt}}})
+		c.Assert(err, qt.IsNil)
+		c.Assert(received, qt.Equals,
+			`gpagdispo_check,method=GET,url=http://foo.org/a\ b,website_id=id1 `+
+				`duration_seconds=0,success=0,in_maintenance=0 1621850400000000000`+"\n")
+	})
+
+	c.Run("Per IP", func(c *qt.C) {
+		err := iw.Export(context.Background(), []Result{{Website: testWebsite, Result: domain.WebsiteResult{Unreachable: true, At: testAt, IP: "10.0.0.1"}}})
+		c.Assert(err, qt.IsNil)
+		c.Assert(received, qt.Equals,
+			`gpagdispo_check,ip=10.0.0.1,method=GET,url=http://foo.org/a\ b,website_id=id1 `+
+				`duration_seconds=0,success=0,in_maintenance=0 1621850400000000000`+"\n")
+	})
+
+	c.Run("Batch", func(c *qt.C) {
+		err := iw.Export(context.Background(), []Result{
+			{Website: testWebsite, Result: domain.WebsiteResult{Unreachable: true, At: testAt}},
+			{Website: testWebsite, Result: domain.WebsiteResult{Unreachable: true, At: testAt.Add(time.Second)}},
+		})
+		c.Assert(err, qt.IsNil)
+		c.Assert(received, qt.Equals,
+			`gpagdispo_check,method=GET,url=http://foo.org/a\ b,website_id=id1 `+
+				`duration_seconds=0,success=0,in_maintenance=0 1621850400000000000`+"\n"+
+				`gpagdispo_check,method=GET,url=http://foo.org/a\ b,website_id=id1 `+
+				`duration_seconds=0,success=0,in_maintenance=0 1621850401000000000`+"\n")
+	})
+
+	c.Run("Error", func(c *qt.C) {
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"error":"database not found"}`, http.StatusNotFound)
+		}))
+		defer svr.Close()
+
+		iw := &InfluxWriter{URL: svr.URL + "/write?db=unknown"}
+		err := iw.Export(context.Background(), []Result{{Website: testWebsite, Result: domain.WebsiteResult{At: testAt}}})
+		c.Assert(err, qt.ErrorMatches, `can't write results to InfluxDB: unexpected status 404: {"error":"database not found"}`)
+	})
+}
@@ -0,0 +1,107 @@
+package export
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+// Result defines a website result to export.
+type Result struct {
+	Website domain.WebsiteParams
+	Result  domain.WebsiteResult
+}
+
+// ExportFn sends a batch of website results to an external system.
+type ExportFn func(ctx context.Context, results []Result) error
+
+// Queue buffers the website results in memory and exports them in batches in the
+// background, so a slow or unavailable endpoint doesn't delay the recording of the
+// results. Results are dropped when the queue is full.
+type Queue struct {
+	// Name identifies the exporter in the logs.
+	Name string
+	// BatchSize is the maximum number of results exported at once.
+	BatchSize int
+	// FlushInterval is the maximum time a result waits for its batch to be full.
+	FlushInterval time.Duration
+	Export        ExportFn
+
+	results chan Result
+}
+
+// NewQueue creates a queue holding up to size results waiting to be exported.
+func NewQueue(name string, size int, export ExportFn) *Queue {
+	return &Queue{
+		Name:    name,
+		Export:  export,
+		results: make(chan Result, size),
+	}
+}
+
+// HandleResult queues the result without waiting for its export. It has the signature of kafka.HandleFn.
+func (q *Queue) HandleResult(ctx context.Context, wp domain.WebsiteParams, wr domain.WebsiteResult) error {
+	select {
+	case q.results <- Result{Website: wp, Result: wr}:
+	default:
+		log.Warn().Str("exporter", q.Name).Str("website_id", wp.ID).Msg("export queue is full, dropping result")
+	}
+	return nil
+}
+
+// Run exports the queued results until the context is done. The results queued
+// by then are exported before returning.
+func (q *Queue) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Result, 0, q.BatchSize)
+	for {
+		select {
+		case r := <-q.results:
+			batch = append(batch, r)
+			if len(batch) < q.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+		case <-ctx.Done():
+			q.drain(batch)
+			return
+		}
+		q.flush(ctx, batch)
+		batch = batch[:0]
+	}
+}
+
+// drain exports the pending batch and the results still queued.
+func (q *Queue) drain(batch []Result) {
+	// The exporters clients have a timeout
+	ctx := context.Background()
+	for {
+		select {
+		case r := <-q.results:
+			batch = append(batch, r)
+			if len(batch) < q.BatchSize {
+				continue
+			}
+		default:
+			q.flush(ctx, batch)
+			return
+		}
+		q.flush(ctx, batch)
+		batch = batch[:0]
+	}
+}
+
+// flush exports the batch, errors are logged and the results dropped.
+func (q *Queue) flush(ctx context.Context, batch []Result) {
+	if len(batch) == 0 {
+		return
+	}
+	if err := q.Export(ctx, batch); err != nil {
+		log.Error().Err(err).Str("exporter", q.Name).Int("results", len(batch)).Msg("can't export results")
+	}
+}
@@ -0,0 +1,96 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+func TestQueue(t *testing.T) {
+	c := qt.New(t)
+
+	var mu sync.Mutex
+	var batches [][]Result
+	exported := make(chan struct{}, 10)
+	q := NewQueue("test", 4, func(ctx context.Context, results []Result) error {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, append([]Result(nil), results...))
+		exported <- struct{}{}
+		return errors.New("endpoint is down")
+	})
+	q.BatchSize = 2
+	q.FlushInterval = time.Hour
+
+	result := func(sec int) domain.WebsiteResult {
+		return domain.WebsiteResult{At: testAt.Add(time.Duration(sec) * time.Second)}
+	}
+
+	// The results are queued without exporter running, the last one is dropped
+	for i := 0; i < 5; i++ {
+		err := q.HandleResult(context.Background(), testWebsite, result(i))
+		c.Assert(err, qt.IsNil)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+
+	<-exported
+	<-exported
+	mu.Lock()
+	c.Assert(batches, qt.DeepEquals, [][]Result{
+		{{Website: testWebsite, Result: result(0)}, {Website: testWebsite, Result: result(1)}},
+		{{Website: testWebsite, Result: result(2)}, {Website: testWebsite, Result: result(3)}},
+	}, qt.Commentf("an export error doesn't stop the queue"))
+	mu.Unlock()
+
+	c.Run("Flush interval", func(c *qt.C) {
+		q.FlushInterval = 10 * time.Millisecond
+		cancel()
+		<-done
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go q.Run(ctx)
+
+		err := q.HandleResult(context.Background(), testWebsite, result(5))
+		c.Assert(err, qt.IsNil)
+		<-exported
+		mu.Lock()
+		defer mu.Unlock()
+		c.Assert(batches[2], qt.DeepEquals, []Result{{Website: testWebsite, Result: result(5)}})
+	})
+
+	c.Run("Shutdown", func(c *qt.C) {
+		q.FlushInterval = time.Hour
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			q.Run(ctx)
+			close(done)
+		}()
+
+		for i := 6; i < 9; i++ {
+			err := q.HandleResult(context.Background(), testWebsite, result(i))
+			c.Assert(err, qt.IsNil)
+		}
+		<-exported
+		cancel()
+		<-done
+
+		mu.Lock()
+		defer mu.Unlock()
+		c.Assert(batches[4], qt.DeepEquals, []Result{{Website: testWebsite, Result: result(8)}}, qt.Commentf("the queued results are exported"))
+	})
+}
@@ -0,0 +1,95 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+// RemoteWriter forwards the website results to a Prometheus remote-write endpoint.
+// Every result is sent as a set of gpagdispo_check_* series labeled with the
+// website ID, URL, method and location.
+type RemoteWriter struct {
+	URL    string
+	Client *http.Client
+}
+
+// Export sends the results to the remote-write endpoint in a single request. It has the signature of ExportFn.
+func (rw *RemoteWriter) Export(ctx context.Context, results []Result) error {
+	header := http.Header{}
+	header.Set("Content-Encoding", "snappy")
+	header.Set("Content-Type", "application/x-protobuf")
+	header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	var req []byte
+	for _, r := range results {
+		req = appendWriteRequest(req, r.Website, r.Result)
+	}
+
+	body := snappy.Encode(nil, req)
+	if err := post(ctx, rw.Client, rw.URL, header, body); err != nil {
+		return fmt.Errorf("can't remote write results: %w", err)
+	}
+
+	return nil
+}
+
+// Field numbers of the remote-write protobuf messages defined in
+// https://github.com/prometheus/prometheus/blob/main/prompb/remote.proto and types.proto
+const (
+	writeRequestTimeseries = 1
+	timeSeriesLabels       = 1
+	timeSeriesSamples      = 2
+	labelName              = 1
+	labelValue             = 2
+	sampleValue            = 1
+	sampleTimestamp        = 2
+)
+
+// appendWriteRequest appends the time series of the result to a prometheus.WriteRequest
+// protobuf message. The time series being a repeated field, the messages of several
+// results concatenated are a valid message.
+func appendWriteRequest(req []byte, wp domain.WebsiteParams, wr domain.WebsiteResult) []byte {
+	labels := resultLabels(wp, wr)
+	timestamp := wr.At.UnixNano() / 1e6
+
+	for _, s := range resultSamples(wr) {
+		var ts []byte
+		// __name__ sorts before any other label
+		ts = appendMessage(ts, timeSeriesLabels, encodeLabel(label{name: "__name__", value: measurement + "_" + s.field}))
+		for _, l := range labels {
+			ts = appendMessage(ts, timeSeriesLabels, encodeLabel(l))
+		}
+
+		var smp []byte
+		smp = protowire.AppendTag(smp, sampleValue, protowire.Fixed64Type)
+		smp = protowire.AppendFixed64(smp, math.Float64bits(s.value))
+		smp = protowire.AppendTag(smp, sampleTimestamp, protowire.VarintType)
+		smp = protowire.AppendVarint(smp, uint64(timestamp))
+		ts = appendMessage(ts, timeSeriesSamples, smp)
+
+		req = appendMessage(req, writeRequestTimeseries, ts)
+	}
+
+	return req
+}
+
+func encodeLabel(l label) []byte {
+	var b []byte
+	b = protowire.AppendTag(b, labelName, protowire.BytesType)
+	b = protowire.AppendString(b, l.name)
+	b = protowire.AppendTag(b, labelValue, protowire.BytesType)
+	b = protowire.AppendString(b, l.value)
+	return b
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
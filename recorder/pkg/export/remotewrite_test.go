@@ -0,0 +1,176 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+var (
+	testWebsite = domain.WebsiteParams{ID: "id1", URL: "http://foo.org/a b", Method: "GET"}
+	testAt      = time.Date(2021, 5, 24, 10, 0, 0, 0, time.UTC)
+)
+
+// series is a decoded remote-write time series with a single sample.
+type series struct {
+	Labels    map[string]string
+	Value     float64
+	Timestamp int64
+}
+
+func TestRemoteWriter(t *testing.T) {
+	c := qt.New(t)
+
+	var received []series
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Content-Encoding"), qt.Equals, "snappy")
+		c.Check(r.Header.Get("Content-Type"), qt.Equals, "application/x-protobuf")
+		c.Check(r.Header.Get("X-Prometheus-Remote-Write-Version"), qt.Equals, "0.1.0")
+
+		compressed, err := io.ReadAll(r.Body)
+		c.Assert(err, qt.IsNil)
+		blob, err := snappy.Decode(nil, compressed)
+		c.Assert(err, qt.IsNil)
+		received, err = decodeWriteRequest(blob)
+		c.Assert(err, qt.IsNil)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer svr.Close()
+
+	rw := &RemoteWriter{URL: svr.URL}
+	status := http.StatusOK
+	matched := false
+	err := rw.Export(context.Background(), []Result{{Website: testWebsite, Result: domain.WebsiteResult{
+		Elapsed:  250 * time.Millisecond,
+		Status:   &status,
+		Matched:  &matched,
+		At:       testAt,
+		Location: "eu-west",
+	}}})
+	c.Assert(err, qt.IsNil)
+
+	labels := func(name string) map[string]string {
+		return map[string]string{
+			"__name__":   name,
+			"location":   "eu-west",
+			"method":     "GET",
+			"url":        "http://foo.org/a b",
+			"website_id": "id1",
+		}
+	}
+	ts := testAt.UnixNano() / 1e6
+	c.Assert(received, qt.DeepEquals, []series{
+		{Labels: labels("gpagdispo_check_duration_seconds"), Value: 0.25, Timestamp: ts},
+		{Labels: labels("gpagdispo_check_success"), Value: 0, Timestamp: ts},
+		{Labels: labels("gpagdispo_check_in_maintenance"), Value: 0, Timestamp: ts},
+		{Labels: labels("gpagdispo_check_status_code"), Value: 200, Timestamp: ts},
+		{Labels: labels("gpagdispo_check_matched"), Value: 0, Timestamp: ts},
+	})
+
+	c.Run("Batch", func(c *qt.C) {
+		err := rw.Export(context.Background(), []Result{
+			{Website: testWebsite, Result: domain.WebsiteResult{At: testAt, Unreachable: true}},
+			{Website: domain.WebsiteParams{ID: "id2", URL: "http://bar.org", Method: "HEAD"}, Result: domain.WebsiteResult{At: testAt, Unreachable: true}},
+		})
+		c.Assert(err, qt.IsNil)
+		c.Assert(received, qt.HasLen, 6, qt.Commentf("duration, success and in_maintenance of both results"))
+		c.Assert(received[0].Labels["website_id"], qt.Equals, "id1")
+		c.Assert(received[3].Labels["website_id"], qt.Equals, "id2")
+	})
+
+	c.Run("Error", func(c *qt.C) {
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "out of order sample", http.StatusBadRequest)
+		}))
+		defer svr.Close()
+
+		rw := &RemoteWriter{URL: svr.URL}
+		err := rw.Export(context.Background(), []Result{{Website: testWebsite, Result: domain.WebsiteResult{At: testAt, Unreachable: true}}})
+		c.Assert(err, qt.ErrorMatches, "can't remote write results: unexpected status 400: out of order sample")
+	})
+}
+
+// decodeWriteRequest decodes a prometheus.WriteRequest protobuf message.
+func decodeWriteRequest(b []byte) ([]series, error) {
+	var res []series
+	err := decodeMessage(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if num != writeRequestTimeseries {
+			return fmt.Errorf("unexpected field %d", num)
+		}
+		s := series{Labels: make(map[string]string)}
+		err := decodeMessage(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+			switch num {
+			case timeSeriesLabels:
+				var l label
+				err := decodeMessage(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+					if num == labelName {
+						l.name = string(v)
+					} else {
+						l.value = string(v)
+					}
+					return nil
+				})
+				s.Labels[l.name] = l.value
+				return err
+			case timeSeriesSamples:
+				return decodeMessage(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+					if num == sampleValue {
+						bits, _ := protowire.ConsumeFixed64(v)
+						s.Value = math.Float64frombits(bits)
+					} else {
+						ts, _ := protowire.ConsumeVarint(v)
+						s.Timestamp = int64(ts)
+					}
+					return nil
+				})
+			}
+			return fmt.Errorf("unexpected field %d", num)
+		})
+		res = append(res, s)
+		return err
+	})
+	return res, err
+}
+
+// decodeMessage calls fn for every field of the message. For fixed and varint
+// fields v holds the raw encoded value.
+func decodeMessage(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var v []byte
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n >= 0 {
+				v = b[:n]
+			}
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := fn(num, typ, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
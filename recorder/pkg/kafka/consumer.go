@@ -9,22 +9,57 @@ import (
 
 	"github.com/Shopify/sarama"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
 )
 
-const topic = "website.monitor"
+const (
+	topic = "website.monitor"
+	// maxBatchSize is the maximum number of already fetched messages handled at once
+	maxBatchSize = 256
+)
 
+// HandleFn handles a consumed website check
 type HandleFn func(ctx context.Context, wp domain.WebsiteParams, wr domain.WebsiteResult) error
 
+// Chain returns a HandleFn calling every handler in order. It stops at the first error.
+func Chain(handlers ...HandleFn) HandleFn {
+	return func(ctx context.Context, wp domain.WebsiteParams, wr domain.WebsiteResult) error {
+		for _, h := range handlers {
+			if err := h(ctx, wp, wr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Observer is notified about the consumption of messages.
+type Observer interface {
+	// BatchConsumed is called with the number of messages fetched at once.
+	BatchConsumed(size int)
+	// MessageConsumed is called for every message with the number of messages
+	// remaining in its partition.
+	MessageConsumed(partition int32, lag int64)
+	// DecodeFailed is called for every message that can't be decoded.
+	DecodeFailed()
+}
+
 // Consumer consumes website checks from a Kafka topic
 type Consumer struct {
+	client           sarama.Client
 	kfkConsumerGroup sarama.ConsumerGroup
 	handler          sarama.ConsumerGroupHandler
 	wg               sync.WaitGroup
 
 	// HandleMessage will be called upon every consumption
 	HandleMessage HandleFn
+	// Observer is optionally notified about every consumption.
+	Observer Observer
 }
 
 // NewConsumer creates the consumer group from the given addresses
@@ -36,12 +71,19 @@ func NewConsumer(addrs []string, cfg Config, handleFn HandleFn) (*Consumer, erro
 	saramaCfg.Consumer.Return.Errors = true
 	saramaCfg.Consumer.Offsets.Initial = sarama.OffsetOldest
 
-	consumer, err := sarama.NewConsumerGroup(addrs, "website-monitor-1", saramaCfg)
+	client, err := sarama.NewClient(addrs, saramaCfg)
 	if err != nil {
+		return nil, fmt.Errorf("can't create client: %w", err)
+	}
+
+	consumer, err := sarama.NewConsumerGroupFromClient("website-monitor-1", client)
+	if err != nil {
+		_ = client.Close()
 		return nil, fmt.Errorf("can't create consumer: %w", err)
 	}
 
 	c := &Consumer{
+		client:           client,
 		kfkConsumerGroup: consumer,
 		HandleMessage:    handleFn,
 	}
@@ -50,6 +92,7 @@ func NewConsumer(addrs []string, cfg Config, handleFn HandleFn) (*Consumer, erro
 	// Track errors
 	c.wg.Add(1)
 	go func() {
+		defer c.wg.Done()
 		for err := range c.kfkConsumerGroup.Errors() {
 			if errors.Is(err, context.DeadlineExceeded) {
 				// Ignore the error
@@ -79,10 +122,21 @@ func (c *Consumer) Consume(ctx context.Context) error {
 	}
 }
 
+// Ready returns an error if the brokers holding the topic can't be reached.
+func (c *Consumer) Ready() error {
+	if err := c.client.RefreshMetadata(topic); err != nil {
+		return fmt.Errorf("can't reach Kafka brokers: %w", err)
+	}
+	return nil
+}
+
 // Close closes the resources of the consumer
 func (c *Consumer) Close() error {
 	err := c.kfkConsumerGroup.Close()
 	c.wg.Wait()
+	if cerr := c.client.Close(); err == nil {
+		err = cerr
+	}
 	return err
 }
 
@@ -110,41 +164,92 @@ func (h *handler) Cleanup(sess sarama.ConsumerGroupSession) error {
 // loop and exit.
 func (h *handler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
 	ctx := sess.Context()
-loop:
 	for {
 		select {
-		case msg := <-claim.Messages():
-			log.Log().Int32("partition", msg.Partition).Int64("offset", msg.Offset).
-				Str("key", string(msg.Key)).Str("body", string(msg.Value)).Msg("consumed message")
-
-			if h.consumer.HandleMessage != nil {
-
-				var check payload
-				err := json.Unmarshal(msg.Value, &check)
-				if err != nil {
-					// Log and continue (don't retry)
-					log.Error().Err(err).Msg("unable to unmarshal message")
-				}
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
 
-				err = h.consumer.HandleMessage(ctx, check.WebsiteParams, check.WebsiteResult)
-				if err != nil {
-					// Log and continue (don't retry)
-					log.Error().Err(err).Msg("error handling message")
+			// Handle together the messages already fetched
+			batch := []*sarama.ConsumerMessage{msg}
+		drain:
+			for len(batch) < maxBatchSize {
+				select {
+				case msg, ok := <-claim.Messages():
+					if !ok {
+						break drain
+					}
+					batch = append(batch, msg)
+				default:
+					break drain
 				}
 			}
+			if h.consumer.Observer != nil {
+				h.consumer.Observer.BatchConsumed(len(batch))
+			}
 
-			sess.MarkMessage(msg, "")
+			for _, msg := range batch {
+				h.handleMessage(ctx, claim, msg)
+				sess.MarkMessage(msg, "")
+			}
 
 		case <-ctx.Done():
 			err := ctx.Err()
 			if err != nil {
 				log.Info().Err(err).Msg("work done")
 			}
-			break loop
+			return nil
 		}
 	}
+}
 
-	return nil
+func (h *handler) handleMessage(ctx context.Context, claim sarama.ConsumerGroupClaim, msg *sarama.ConsumerMessage) {
+	// Continue the trace of the check
+	ctx = otel.GetTextMapPropagator().Extract(ctx, headerCarrier{msg: msg})
+	ctx, span := otel.Tracer(tracerName).Start(ctx, topic+" receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination", topic),
+			attribute.String("messaging.kafka.message_key", string(msg.Key)),
+			attribute.Int64("messaging.kafka.partition", int64(msg.Partition)),
+			attribute.Int64("messaging.kafka.offset", msg.Offset),
+		))
+	defer span.End()
+
+	log.Log().Int32("partition", msg.Partition).Int64("offset", msg.Offset).
+		Str("key", string(msg.Key)).Str("body", string(msg.Value)).Msg("consumed message")
+
+	observer := h.consumer.Observer
+	if observer != nil {
+		observer.MessageConsumed(msg.Partition, claim.HighWaterMarkOffset()-msg.Offset-1)
+	}
+
+	if h.consumer.HandleMessage == nil {
+		return
+	}
+
+	var check payload
+	err := json.Unmarshal(msg.Value, &check)
+	if err != nil {
+		// Log and skip it (don't retry)
+		log.Error().Err(err).Msg("unable to unmarshal message")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "unable to unmarshal message")
+		if observer != nil {
+			observer.DecodeFailed()
+		}
+		return
+	}
+
+	err = h.consumer.HandleMessage(ctx, check.WebsiteParams, check.WebsiteResult)
+	if err != nil {
+		// Log and continue (don't retry)
+		log.Error().Err(err).Msg("error handling message")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "error handling message")
+	}
 }
 
 type payload struct {
@@ -0,0 +1,135 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	qt "github.com/frankban/quicktest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	marked []int64
+}
+
+func (s *fakeSession) Context() context.Context { return s.ctx }
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return 10 }
+
+type fakeObserver struct {
+	mu      sync.Mutex
+	batches []int
+	lags    []int64
+	decode  int
+}
+
+func (o *fakeObserver) BatchConsumed(size int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.batches = append(o.batches, size)
+}
+
+func (o *fakeObserver) MessageConsumed(partition int32, lag int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.lags = append(o.lags, lag)
+}
+
+func (o *fakeObserver) DecodeFailed() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.decode++
+}
+
+func TestConsumeClaim(t *testing.T) {
+	c := qt.New(t)
+
+	var handled []string
+	observer := new(fakeObserver)
+	consumer := &Consumer{
+		HandleMessage: func(ctx context.Context, wp domain.WebsiteParams, wr domain.WebsiteResult) error {
+			handled = append(handled, wp.ID)
+			return nil
+		},
+		Observer: observer,
+	}
+	h := &handler{consumer: consumer}
+
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 3)}
+	claim.messages <- &sarama.ConsumerMessage{Offset: 7, Value: []byte(`{"website": {"id": "id1"}, "result": {}}`)}
+	claim.messages <- &sarama.ConsumerMessage{Offset: 8, Value: []byte(`not json`)}
+	claim.messages <- &sarama.ConsumerMessage{Offset: 9, Value: []byte(`{"website": {"id": "id2"}, "result": {}}`)}
+	close(claim.messages)
+
+	sess := &fakeSession{ctx: context.Background()}
+	err := h.ConsumeClaim(sess, claim)
+	c.Assert(err, qt.IsNil, qt.Commentf("it stops once the messages are closed"))
+
+	c.Assert(handled, qt.DeepEquals, []string{"id1", "id2"}, qt.Commentf("undecodable messages are not handled"))
+	c.Assert(sess.marked, qt.DeepEquals, []int64{7, 8, 9})
+	c.Assert(observer.batches, qt.DeepEquals, []int{3})
+	c.Assert(observer.lags, qt.DeepEquals, []int64{2, 1, 0})
+	c.Assert(observer.decode, qt.Equals, 1)
+}
+
+func TestConsumeClaimTracing(t *testing.T) {
+	c := qt.New(t)
+
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	c.Cleanup(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+
+	var handled trace.SpanContext
+	h := &handler{consumer: &Consumer{
+		HandleMessage: func(ctx context.Context, wp domain.WebsiteParams, wr domain.WebsiteResult) error {
+			handled = trace.SpanContextFromContext(ctx)
+			return nil
+		},
+	}}
+
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 1)}
+	claim.messages <- &sarama.ConsumerMessage{
+		Offset: 9,
+		Headers: []*sarama.RecordHeader{{
+			Key:   []byte("traceparent"),
+			Value: []byte("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"),
+		}},
+		Value: []byte(`{"website": {"id": "id1"}, "result": {}}`),
+	}
+	close(claim.messages)
+
+	err := h.ConsumeClaim(&fakeSession{ctx: context.Background()}, claim)
+	c.Assert(err, qt.IsNil)
+
+	spans := exporter.GetSpans()
+	c.Assert(spans, qt.HasLen, 1)
+	c.Assert(spans[0].Name, qt.Equals, "website.monitor receive")
+	c.Assert(spans[0].SpanKind, qt.Equals, trace.SpanKindConsumer)
+	c.Assert(spans[0].SpanContext.TraceID().String(), qt.Equals, "0af7651916cd43dd8448eb211c80319c")
+	c.Assert(spans[0].Parent.SpanID().String(), qt.Equals, "b7ad6b7169203331")
+	c.Assert(handled.SpanID(), qt.Equals, spans[0].SpanContext.SpanID(), qt.Commentf("the message is handled within the span"))
+}
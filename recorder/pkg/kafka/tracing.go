@@ -0,0 +1,48 @@
+package kafka
+
+import (
+	"github.com/Shopify/sarama"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const tracerName = "github.com/sixstone-qq/gpagdispo/recorder/pkg/kafka"
+
+// Compile-time check around interface implementation
+var _ propagation.TextMapCarrier = (*headerCarrier)(nil)
+
+// headerCarrier carries the trace context in the headers of a Kafka message.
+type headerCarrier struct {
+	msg *sarama.ConsumerMessage
+}
+
+// Get returns the value of the header with the given key.
+func (c headerCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// Set sets a header replacing any header with the same key.
+func (c headerCarrier) Set(key, value string) {
+	for _, h := range c.msg.Headers {
+		if h != nil && string(h.Key) == key {
+			h.Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, &sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+}
+
+// Keys returns the keys of the headers.
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c.msg.Headers))
+	for _, h := range c.msg.Headers {
+		if h != nil {
+			keys = append(keys, string(h.Key))
+		}
+	}
+	return keys
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+// ReadinessTimeout is the maximum time given to every readiness check.
+var ReadinessTimeout = 5 * time.Second
+
+// ReadinessCheck returns an error if a dependency is not ready.
+type ReadinessCheck func(ctx context.Context) error
+
+// NewHandler returns the handler serving the metrics gathered by g in /metrics,
+// liveness in /healthz and readiness in /readyz. The service is ready when all
+// the checks succeed.
+func NewHandler(g prometheus.Gatherer, checks map[string]ReadinessCheck) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(g, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		handleReadyz(w, r, checks)
+	})
+	return mux
+}
+
+func handleReadyz(w http.ResponseWriter, r *http.Request, checks map[string]ReadinessCheck) {
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	code := http.StatusOK
+	statuses := make(map[string]string, len(checks))
+	for _, name := range names {
+		ctx, cancel := context.WithTimeout(r.Context(), ReadinessTimeout)
+		err := checks[name](ctx)
+		cancel()
+		if err != nil {
+			code = http.StatusServiceUnavailable
+			statuses[name] = err.Error()
+			continue
+		}
+		statuses[name] = "ok"
+	}
+
+	writeJSON(w, code, statuses)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error().Err(err).Msg("can't write response")
+	}
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/kafka"
+)
+
+const namespace = "gpagdispo_recorder"
+
+// Compile-time check around interface implementation
+var _ kafka.Observer = (*Recorder)(nil)
+
+// Recorder holds the Prometheus metrics of the consumption of results. It implements kafka.Observer.
+type Recorder struct {
+	messagesConsumed prometheus.Counter
+	decodeFailures   prometheus.Counter
+	batchSize        prometheus.Histogram
+	consumerLag      *prometheus.GaugeVec
+	insertDuration   prometheus.Histogram
+}
+
+// NewRecorder creates and registers the recorder metrics.
+func NewRecorder(reg prometheus.Registerer) *Recorder {
+	m := &Recorder{
+		messagesConsumed: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_consumed_total",
+			Help:      "Number of messages consumed from Kafka.",
+		}),
+		decodeFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "decode_failures_total",
+			Help:      "Number of consumed messages that couldn't be decoded.",
+		}),
+		batchSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "batch_size",
+			Help:      "Number of messages fetched at once from Kafka.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 9),
+		}),
+		consumerLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "consumer_lag",
+			Help:      "Number of messages remaining to consume per partition.",
+		}, []string{"partition"}),
+		insertDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "insert_duration_seconds",
+			Help:      "Time spent inserting a result into the database.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+	}
+	reg.MustRegister(m.messagesConsumed, m.decodeFailures, m.batchSize, m.consumerLag, m.insertDuration)
+
+	return m
+}
+
+// BatchConsumed implements kafka.Observer.
+func (m *Recorder) BatchConsumed(size int) {
+	m.batchSize.Observe(float64(size))
+}
+
+// MessageConsumed implements kafka.Observer.
+func (m *Recorder) MessageConsumed(partition int32, lag int64) {
+	m.messagesConsumed.Inc()
+	m.consumerLag.WithLabelValues(strconv.Itoa(int(partition))).Set(float64(lag))
+}
+
+// DecodeFailed implements kafka.Observer.
+func (m *Recorder) DecodeFailed() {
+	m.decodeFailures.Inc()
+}
+
+// InstrumentInsert returns a HandleFn observing the latency of the given insert.
+func (m *Recorder) InstrumentInsert(insert kafka.HandleFn) kafka.HandleFn {
+	return func(ctx context.Context, wp domain.WebsiteParams, wr domain.WebsiteResult) error {
+		start := time.Now()
+		err := insert(ctx, wp, wr)
+		m.insertDuration.Observe(time.Since(start).Seconds())
+		return err
+	}
+}
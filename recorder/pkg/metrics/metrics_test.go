@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+func TestRecorder(t *testing.T) {
+	c := qt.New(t)
+
+	reg := prometheus.NewPedanticRegistry()
+	m := NewRecorder(reg)
+
+	m.BatchConsumed(2)
+	m.MessageConsumed(0, 5)
+	m.MessageConsumed(0, 4)
+	m.MessageConsumed(1, 0)
+	m.DecodeFailed()
+
+	insertErr := errors.New("boom")
+	insert := m.InstrumentInsert(func(ctx context.Context, wp domain.WebsiteParams, wr domain.WebsiteResult) error {
+		return insertErr
+	})
+	err := insert(context.Background(), domain.WebsiteParams{}, domain.WebsiteResult{})
+	c.Assert(err, qt.Equals, insertErr)
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP gpagdispo_recorder_consumer_lag Number of messages remaining to consume per partition.
+# TYPE gpagdispo_recorder_consumer_lag gauge
+gpagdispo_recorder_consumer_lag{partition="0"} 4
+gpagdispo_recorder_consumer_lag{partition="1"} 0
+# HELP gpagdispo_recorder_decode_failures_total Number of consumed messages that couldn't be decoded.
+# TYPE gpagdispo_recorder_decode_failures_total counter
+gpagdispo_recorder_decode_failures_total 1
+# HELP gpagdispo_recorder_messages_consumed_total Number of messages consumed from Kafka.
+# TYPE gpagdispo_recorder_messages_consumed_total counter
+gpagdispo_recorder_messages_consumed_total 3
+`),
+		"gpagdispo_recorder_consumer_lag",
+		"gpagdispo_recorder_decode_failures_total",
+		"gpagdispo_recorder_messages_consumed_total")
+	c.Assert(err, qt.IsNil)
+	c.Assert(testutil.CollectAndCount(m.batchSize), qt.Equals, 1)
+	c.Assert(testutil.CollectAndCount(m.insertDuration), qt.Equals, 1)
+}
+
+func TestHandler(t *testing.T) {
+	c := qt.New(t)
+
+	reg := prometheus.NewRegistry()
+	NewRecorder(reg).MessageConsumed(0, 0)
+
+	dbErr := errors.New("connection refused")
+	h := NewHandler(reg, map[string]ReadinessCheck{
+		"db":    func(ctx context.Context) error { return dbErr },
+		"kafka": func(ctx context.Context) error { return nil },
+	})
+
+	get := func(path string) (int, string) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		body, err := io.ReadAll(rec.Body)
+		c.Assert(err, qt.IsNil)
+		return rec.Code, string(body)
+	}
+
+	code, body := get("/metrics")
+	c.Assert(code, qt.Equals, http.StatusOK)
+	c.Assert(body, qt.Contains, "gpagdispo_recorder_messages_consumed_total 1")
+
+	code, _ = get("/healthz")
+	c.Assert(code, qt.Equals, http.StatusOK)
+
+	code, body = get("/readyz")
+	c.Assert(code, qt.Equals, http.StatusServiceUnavailable)
+	var statuses map[string]string
+	c.Assert(json.Unmarshal([]byte(body), &statuses), qt.IsNil)
+	c.Assert(statuses, qt.DeepEquals, map[string]string{"db": "connection refused", "kafka": "ok"})
+
+	dbErr = nil
+	code, _ = get("/readyz")
+	c.Assert(code, qt.Equals, http.StatusOK)
+}
@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+const (
+	// maxBackoff limits the time between delivery attempts.
+	maxBackoff = time.Hour
+	// deliveryBatch is the maximum number of notifications delivered per poll.
+	deliveryBatch = 100
+)
+
+// Outbox routes incident events to the receivers storing them in an outbox to be delivered with retries.
+type Outbox struct {
+	Receivers []Receiver
+	// PollInterval is the time between outbox checks of pending notifications.
+	PollInterval time.Duration
+	// Backoff is the time to wait after the first failed attempt. It doubles on every attempt.
+	Backoff time.Duration
+	// MaxAttempts is the number of attempts before giving up.
+	MaxAttempts int
+
+	PendingNotifications      func(ctx context.Context, now time.Time, limit int) ([]domain.Notification, error)
+	MarkNotificationDelivered func(ctx context.Context, id int64, at time.Time) error
+	MarkNotificationFailed    func(ctx context.Context, n domain.Notification) error
+	Send                      func(ctx context.Context, r Receiver, ev domain.IncidentEvent) error
+}
+
+// Notifications returns the notifications of the incident event to enqueue for every receiver routing
+// the website. They are stored along with the incident changes so none is lost.
+func (o *Outbox) Notifications(ev domain.IncidentEvent) ([]domain.Notification, error) {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return nil, fmt.Errorf("can't encode incident event: %w", err)
+	}
+
+	var ns []domain.Notification
+	for _, r := range o.Receivers {
+		if !r.Routes(ev.Website) {
+			continue
+		}
+		ns = append(ns, domain.Notification{
+			Receiver: r.Name,
+			Payload:  payload,
+		})
+	}
+
+	return ns, nil
+}
+
+// Run delivers the pending notifications periodically until ctx is done.
+func (o *Outbox) Run(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(o.PollInterval):
+			if err := o.Deliver(ctx); err != nil {
+				log.Error().Err(err).Msg("can't deliver notifications")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Deliver tries to deliver the pending notifications once.
+func (o *Outbox) Deliver(ctx context.Context) error {
+	now := time.Now().UTC()
+	pending, err := o.PendingNotifications(ctx, now, deliveryBatch)
+	if err != nil {
+		return fmt.Errorf("can't get pending notifications: %w", err)
+	}
+
+	receivers := make(map[string]Receiver, len(o.Receivers))
+	for _, r := range o.Receivers {
+		receivers[r.Name] = r
+	}
+
+	for _, n := range pending {
+		err := o.deliver(ctx, receivers, n)
+		if err == nil {
+			if err := o.MarkNotificationDelivered(ctx, n.ID, time.Now().UTC()); err != nil {
+				return fmt.Errorf("can't mark notification as delivered: %w", err)
+			}
+			continue
+		}
+
+		n.Attempts++
+		errMsg := err.Error()
+		n.LastError = &errMsg
+		n.NextAttemptAt = nil
+		if n.Attempts < o.MaxAttempts {
+			next := now.Add(Backoff(o.Backoff, n.Attempts))
+			n.NextAttemptAt = &next
+		} else {
+			log.Error().Err(err).Str("receiver", n.Receiver).Int64("id", n.ID).Msg("giving up delivering notification")
+		}
+		if err := o.MarkNotificationFailed(ctx, n); err != nil {
+			return fmt.Errorf("can't mark notification as failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (o *Outbox) deliver(ctx context.Context, receivers map[string]Receiver, n domain.Notification) error {
+	r, ok := receivers[n.Receiver]
+	if !ok {
+		return fmt.Errorf("unknown receiver %q", n.Receiver)
+	}
+
+	var ev domain.IncidentEvent
+	if err := json.Unmarshal(n.Payload, &ev); err != nil {
+		return fmt.Errorf("can't decode incident event: %w", err)
+	}
+
+	return o.Send(ctx, r, ev)
+}
+
+// Backoff returns the time to wait before the next attempt after the given failed attempts.
+func Backoff(base time.Duration, attempts int) time.Duration {
+	d := base
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
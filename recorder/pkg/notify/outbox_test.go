@@ -0,0 +1,166 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+func TestOutbox(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+
+	receivers := []Receiver{
+		{Name: "all", Type: ReceiverWebhook, URL: "http://all"},
+		{Name: "api", Type: ReceiverSlack, URL: "http://api", Tags: []string{"api"}},
+		{Name: "prod", Type: ReceiverWebhook, URL: "http://prod", Tags: []string{"prod"}},
+	}
+	ev := domain.IncidentEvent{
+		Kind:     domain.IncidentResolved,
+		Website:  domain.WebsiteParams{ID: "id1", URL: "http://foo.org", Tags: []string{"api", "internal"}},
+		Incident: &domain.Incident{ID: 1, WebsiteID: "id1", Cause: domain.CauseStatus},
+	}
+
+	c.Run("Route", func(c *qt.C) {
+		store := new(fakeOutboxStore)
+		o := newTestOutbox(store, receivers, nil)
+
+		err := store.enqueue(o, ev)
+		c.Assert(err, qt.IsNil)
+		c.Assert(store.receivers(), qt.DeepEquals, []string{"all", "api"})
+	})
+
+	c.Run("Deliver", func(c *qt.C) {
+		store := new(fakeOutboxStore)
+		var sent []string
+		o := newTestOutbox(store, receivers, func(ctx context.Context, r Receiver, got domain.IncidentEvent) error {
+			c.Assert(got, qt.DeepEquals, ev)
+			sent = append(sent, r.Name)
+			return nil
+		})
+
+		err := store.enqueue(o, ev)
+		c.Assert(err, qt.IsNil)
+		err = o.Deliver(ctx)
+		c.Assert(err, qt.IsNil)
+		c.Assert(sent, qt.DeepEquals, []string{"all", "api"})
+		c.Assert(store.delivered, qt.DeepEquals, []int64{1, 2})
+
+		// Nothing else to deliver
+		err = o.Deliver(ctx)
+		c.Assert(err, qt.IsNil)
+		c.Assert(sent, qt.HasLen, 2)
+	})
+
+	c.Run("Retry", func(c *qt.C) {
+		store := new(fakeOutboxStore)
+		attempts := 0
+		o := newTestOutbox(store, receivers[:1], func(ctx context.Context, r Receiver, got domain.IncidentEvent) error {
+			attempts++
+			return errors.New("connection refused")
+		})
+
+		err := store.enqueue(o, ev)
+		c.Assert(err, qt.IsNil)
+
+		for i := 1; i <= 3; i++ {
+			before := time.Now().UTC()
+			err = o.Deliver(ctx)
+			c.Assert(err, qt.IsNil)
+			c.Assert(attempts, qt.Equals, i)
+
+			n := store.notifications[0]
+			c.Assert(n.Attempts, qt.Equals, i)
+			c.Assert(*n.LastError, qt.Equals, "connection refused")
+			if i < o.MaxAttempts {
+				c.Assert(n.NextAttemptAt, qt.Not(qt.IsNil))
+				c.Assert(n.NextAttemptAt.Sub(before) >= Backoff(o.Backoff, i), qt.IsTrue)
+				// Make it pending again
+				store.notifications[0].NextAttemptAt = &before
+			} else {
+				c.Assert(n.NextAttemptAt, qt.IsNil, qt.Commentf("gave up"))
+			}
+		}
+
+		err = o.Deliver(ctx)
+		c.Assert(err, qt.IsNil)
+		c.Assert(attempts, qt.Equals, 3)
+		c.Assert(store.delivered, qt.HasLen, 0)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(Backoff(time.Second, 1), qt.Equals, time.Second)
+	c.Assert(Backoff(time.Second, 2), qt.Equals, 2*time.Second)
+	c.Assert(Backoff(time.Second, 5), qt.Equals, 16*time.Second)
+	c.Assert(Backoff(time.Second, 100), qt.Equals, time.Hour)
+}
+
+func newTestOutbox(store *fakeOutboxStore, receivers []Receiver, send func(context.Context, Receiver, domain.IncidentEvent) error) *Outbox {
+	return &Outbox{
+		Receivers:                 receivers,
+		Backoff:                   time.Minute,
+		MaxAttempts:               3,
+		PendingNotifications:      store.PendingNotifications,
+		MarkNotificationDelivered: store.MarkNotificationDelivered,
+		MarkNotificationFailed:    store.MarkNotificationFailed,
+		Send:                      send,
+	}
+}
+
+// fakeOutboxStore stores notifications in memory.
+type fakeOutboxStore struct {
+	notifications []domain.Notification
+	delivered     []int64
+}
+
+func (s *fakeOutboxStore) receivers() []string {
+	var names []string
+	for _, n := range s.notifications {
+		names = append(names, n.Receiver)
+	}
+	return names
+}
+
+// enqueue stores the notifications of the event as the store does along with the incident changes.
+func (s *fakeOutboxStore) enqueue(o *Outbox, ev domain.IncidentEvent) error {
+	ns, err := o.Notifications(ev)
+	if err != nil {
+		return err
+	}
+	for _, n := range ns {
+		n.ID = int64(len(s.notifications) + 1)
+		now := time.Now().UTC()
+		n.NextAttemptAt = &now
+		s.notifications = append(s.notifications, n)
+	}
+	return nil
+}
+
+func (s *fakeOutboxStore) PendingNotifications(ctx context.Context, now time.Time, limit int) ([]domain.Notification, error) {
+	var pending []domain.Notification
+	for _, n := range s.notifications {
+		if n.NextAttemptAt != nil && !n.NextAttemptAt.After(now) && len(pending) < limit {
+			pending = append(pending, n)
+		}
+	}
+	return pending, nil
+}
+
+func (s *fakeOutboxStore) MarkNotificationDelivered(ctx context.Context, id int64, at time.Time) error {
+	s.delivered = append(s.delivered, id)
+	s.notifications[id-1].NextAttemptAt = nil
+	return nil
+}
+
+func (s *fakeOutboxStore) MarkNotificationFailed(ctx context.Context, n domain.Notification) error {
+	s.notifications[n.ID-1] = n
+	return nil
+}
@@ -0,0 +1,98 @@
+// Package notify delivers incident events to the configured receivers
+// through an outbox.
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+// ReceiverType defines how a receiver is notified.
+type ReceiverType string
+
+const (
+	ReceiverWebhook ReceiverType = "webhook"
+	ReceiverSlack   ReceiverType = "slack"
+	ReceiverEmail   ReceiverType = "email"
+)
+
+// Receiver defines where to deliver the notifications.
+type Receiver struct {
+	Name string       `json:"name"`
+	Type ReceiverType `json:"type"`
+	// Tags routes the websites with any of these tags to the receiver. Empty means every website.
+	Tags []string `json:"tags"`
+
+	// URL is the endpoint of webhook and slack receivers.
+	URL string `json:"url"`
+	// Secret signs the webhook bodies with HMAC-SHA256 if set.
+	Secret string `json:"secret"`
+
+	SMTP struct {
+		Addr     string   `json:"addr"`
+		Username string   `json:"username"`
+		Password string   `json:"password"`
+		From     string   `json:"from"`
+		To       []string `json:"to"`
+	} `json:"smtp"`
+}
+
+// Routes returns true if the receiver must be notified about the website.
+func (r Receiver) Routes(wp domain.WebsiteParams) bool {
+	return len(r.Tags) == 0 || wp.HasAnyTag(r.Tags)
+}
+
+// validate checks the receiver has the required fields set by its type.
+func (r Receiver) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("missing receiver name")
+	}
+
+	switch r.Type {
+	case ReceiverWebhook, ReceiverSlack:
+		if r.URL == "" {
+			return fmt.Errorf("missing URL in receiver %q", r.Name)
+		}
+	case ReceiverEmail:
+		if r.SMTP.Addr == "" || r.SMTP.From == "" || len(r.SMTP.To) == 0 {
+			return fmt.Errorf("missing SMTP addr, from or to in receiver %q", r.Name)
+		}
+	default:
+		return fmt.Errorf(`unknown receiver type "%s" in receiver %q. Valid ones: %s`,
+			r.Type, r.Name, []ReceiverType{ReceiverWebhook, ReceiverSlack, ReceiverEmail})
+	}
+
+	return nil
+}
+
+// LoadReceivers loads the receivers from a JSON configuration file.
+func LoadReceivers(confPath string) ([]Receiver, error) {
+	f, err := os.Open(confPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	var cfg struct {
+		Receivers []Receiver `json:"receivers"`
+	}
+	if err := json.NewDecoder(f).Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("unable to decode configuration file: %w", err)
+	}
+
+	names := make(map[string]bool, len(cfg.Receivers))
+	for _, r := range cfg.Receivers {
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("duplicated receiver %q", r.Name)
+		}
+		names[r.Name] = true
+	}
+
+	return cfg.Receivers, nil
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"os"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+func TestLoadReceivers(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("OK", func(c *qt.C) {
+		receivers, err := LoadReceivers("testdata/receivers.json")
+		c.Assert(err, qt.IsNil)
+		c.Assert(receivers, qt.HasLen, 3)
+		c.Assert(receivers[0].Secret, qt.Equals, "s3cr3t")
+		c.Assert(receivers[1].Tags, qt.DeepEquals, []string{"api"})
+		c.Assert(receivers[2].SMTP.To, qt.DeepEquals, []string{"oncall@example.org"})
+
+		api := domain.WebsiteParams{Tags: []string{"api", "public"}}
+		c.Assert(receivers[0].Routes(api), qt.IsTrue)
+		c.Assert(receivers[1].Routes(api), qt.IsTrue)
+		c.Assert(receivers[2].Routes(api), qt.IsFalse)
+	})
+
+	c.Run("NOK", func(c *qt.C) {
+		tests := []struct {
+			Name      string
+			InContent string
+			Error     string
+		}{
+			{
+				Name:      "missing name",
+				InContent: `{"receivers": [{"type": "webhook"}]}`,
+				Error:     `missing receiver name`,
+			},
+			{
+				Name:      "unknown type",
+				InContent: `{"receivers": [{"name": "foo", "type": "pager"}]}`,
+				Error:     `unknown receiver type "pager" in receiver "foo". Valid ones: \[webhook slack email\]`,
+			},
+			{
+				Name:      "missing URL",
+				InContent: `{"receivers": [{"name": "foo", "type": "slack"}]}`,
+				Error:     `missing URL in receiver "foo"`,
+			},
+			{
+				Name:      "missing SMTP",
+				InContent: `{"receivers": [{"name": "foo", "type": "email", "smtp": {"addr": "localhost:25"}}]}`,
+				Error:     `missing SMTP addr, from or to in receiver "foo"`,
+			},
+			{
+				Name:      "duplicated",
+				InContent: `{"receivers": [{"name": "foo", "type": "webhook", "url": "http://foo"}, {"name": "foo", "type": "webhook", "url": "http://bar"}]}`,
+				Error:     `duplicated receiver "foo"`,
+			},
+		}
+		for _, st := range tests {
+			c.Run(st.Name, func(c *qt.C) {
+				f, err := os.CreateTemp("testdata", "*.json")
+				c.Assert(err, qt.IsNil)
+				defer func() {
+					f.Close()
+					err := os.Remove(f.Name())
+					c.Check(err, qt.IsNil)
+				}()
+
+				_, err = f.WriteString(st.InContent)
+				c.Assert(err, qt.IsNil)
+
+				receivers, err := LoadReceivers(f.Name())
+				c.Assert(err, qt.ErrorMatches, st.Error)
+				c.Assert(receivers, qt.IsNil)
+			})
+		}
+	})
+}
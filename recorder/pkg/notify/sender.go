@@ -0,0 +1,206 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+// SignatureHeader is the HTTP header holding the HMAC-SHA256 signature of the body.
+const SignatureHeader = "X-Gpagdispo-Signature"
+
+// Sign returns the signature of the body with the given secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sender delivers incident events to receivers.
+type Sender struct {
+	Client *http.Client
+	// Timeout bounds the SMTP sessions of the email receivers. Zero means the timeout of Client.
+	Timeout time.Duration
+}
+
+// Send delivers the incident event to the receiver.
+func (s *Sender) Send(ctx context.Context, r Receiver, ev domain.IncidentEvent) error {
+	switch r.Type {
+	case ReceiverWebhook:
+		return s.post(ctx, r, ev)
+	case ReceiverSlack:
+		return s.post(ctx, r, slackMessage{Text: summary(ev)})
+	case ReceiverEmail:
+		return s.sendEmail(ctx, r, ev)
+	}
+	return fmt.Errorf("unknown receiver type %q", r.Type)
+}
+
+// post sends the JSON encoded v to the receiver URL signing it if required.
+func (s *Sender) post(ctx context.Context, r Receiver, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(r.Secret, body))
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, r.URL)
+	}
+
+	return nil
+}
+
+// slackMessage defines a Slack-compatible webhook payload.
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// sendEmail sends the incident event by SMTP. Authentication is only performed if username is set.
+func (s *Sender) sendEmail(ctx context.Context, r Receiver, ev domain.IncidentEvent) error {
+	var auth smtp.Auth
+	if r.SMTP.Username != "" {
+		host := r.SMTP.Addr
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			host = host[:i]
+		}
+		auth = smtp.PlainAuth("", r.SMTP.Username, r.SMTP.Password, host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", r.SMTP.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(r.SMTP.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", summary(ev))
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "Website: %s %s\r\n", ev.Website.Method, ev.Website.URL)
+	if fs := ev.Flapping; fs != nil {
+		fmt.Fprintf(&msg, "Flapping: %t\r\n", fs.Flapping)
+		fmt.Fprintf(&msg, "Flap score: %.2f\r\n", fs.Score)
+		fmt.Fprintf(&msg, "Since: %s\r\n", fs.Since.Format(time.RFC3339))
+	}
+	if in := ev.Incident; in != nil {
+		fmt.Fprintf(&msg, "Cause: %s\r\n", in.Cause)
+		fmt.Fprintf(&msg, "Started at: %s\r\n", in.StartedAt.Format(time.RFC3339))
+		if in.EndedAt != nil {
+			fmt.Fprintf(&msg, "Ended at: %s\r\n", in.EndedAt.Format(time.RFC3339))
+		}
+		fmt.Fprintf(&msg, "Failed checks: %d\r\n", in.FailedChecks)
+	}
+
+	if err := s.sendMail(ctx, r.SMTP.Addr, auth, r.SMTP.From, r.SMTP.To, msg.Bytes()); err != nil {
+		return fmt.Errorf("can't send email: %w", err)
+	}
+
+	return nil
+}
+
+// sendMail does as smtp.SendMail within the timeout of the sender, so a stalled SMTP
+// server doesn't block the next notifications.
+func (s *Sender) sendMail(ctx context.Context, addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	timeout := s.Timeout
+	if timeout == 0 && s.Client != nil {
+		timeout = s.Client.Timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			_ = conn.Close()
+			return err
+		}
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("SMTP server doesn't support AUTH")
+		}
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// summary returns a one line description of the incident event.
+func summary(ev domain.IncidentEvent) string {
+	kind := strings.ToUpper(string(ev.Kind))
+	switch ev.Kind {
+	case domain.IncidentFlapping:
+		return fmt.Sprintf("[%s] %s is flapping, notifications are suppressed", kind, ev.Website.URL)
+	case domain.IncidentFlappingStopped:
+		return fmt.Sprintf("[%s] %s stopped flapping", kind, ev.Website.URL)
+	}
+	return fmt.Sprintf("[%s] Incident on %s: %s", kind, ev.Website.URL, ev.Incident.Cause)
+}
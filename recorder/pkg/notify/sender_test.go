@@ -0,0 +1,253 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+func TestSend(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+	sender := &Sender{Client: http.DefaultClient}
+
+	ev := domain.IncidentEvent{
+		Kind:    domain.IncidentOpened,
+		Website: domain.WebsiteParams{ID: "id1", URL: "http://foo.org", Method: "GET"},
+		Incident: &domain.Incident{
+			ID:           1,
+			WebsiteID:    "id1",
+			StartedAt:    time.Date(2021, 5, 17, 9, 0, 0, 0, time.UTC),
+			Cause:        domain.CauseTimeout,
+			FailedChecks: 3,
+		},
+	}
+
+	c.Run("Webhook", func(c *qt.C) {
+		svr, requests := newFakeWebhookServer(c, http.StatusNoContent)
+
+		r := Receiver{Name: "ops", Type: ReceiverWebhook, URL: svr.URL, Secret: "s3cr3t"}
+		err := sender.Send(ctx, r, ev)
+		c.Assert(err, qt.IsNil)
+
+		req := <-requests
+		c.Assert(req.Header.Get("Content-Type"), qt.Equals, "application/json")
+		c.Assert(req.Header.Get(SignatureHeader), qt.Equals, Sign("s3cr3t", req.Body))
+		var got domain.IncidentEvent
+		err = json.Unmarshal(req.Body, &got)
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, ev)
+	})
+
+	c.Run("Unsigned webhook", func(c *qt.C) {
+		svr, requests := newFakeWebhookServer(c, http.StatusOK)
+
+		r := Receiver{Name: "ops", Type: ReceiverWebhook, URL: svr.URL}
+		err := sender.Send(ctx, r, ev)
+		c.Assert(err, qt.IsNil)
+
+		req := <-requests
+		c.Assert(req.Header.Get(SignatureHeader), qt.Equals, "")
+	})
+
+	c.Run("Slack", func(c *qt.C) {
+		svr, requests := newFakeWebhookServer(c, http.StatusOK)
+
+		r := Receiver{Name: "team", Type: ReceiverSlack, URL: svr.URL, Secret: "foo"}
+		err := sender.Send(ctx, r, ev)
+		c.Assert(err, qt.IsNil)
+
+		req := <-requests
+		c.Assert(req.Header.Get(SignatureHeader), qt.Equals, Sign("foo", req.Body))
+		c.Assert(string(req.Body), qt.JSONEquals, map[string]string{
+			"text": "[OPENED] Incident on http://foo.org: timeout",
+		})
+	})
+
+	c.Run("Slack flapping", func(c *qt.C) {
+		svr, requests := newFakeWebhookServer(c, http.StatusOK)
+
+		flapping := domain.IncidentEvent{
+			Kind:     domain.IncidentFlapping,
+			Website:  ev.Website,
+			Flapping: &domain.FlappingState{WebsiteID: "id1", Flapping: true, Score: 0.8},
+		}
+		r := Receiver{Name: "team", Type: ReceiverSlack, URL: svr.URL}
+		err := sender.Send(ctx, r, flapping)
+		c.Assert(err, qt.IsNil)
+
+		req := <-requests
+		c.Assert(string(req.Body), qt.JSONEquals, map[string]string{
+			"text": "[FLAPPING] http://foo.org is flapping, notifications are suppressed",
+		})
+	})
+
+	c.Run("Webhook error", func(c *qt.C) {
+		svr, _ := newFakeWebhookServer(c, http.StatusBadGateway)
+
+		r := Receiver{Name: "ops", Type: ReceiverWebhook, URL: svr.URL}
+		err := sender.Send(ctx, r, ev)
+		c.Assert(err, qt.ErrorMatches, `unexpected status code 502 from .*`)
+	})
+
+	c.Run("Email", func(c *qt.C) {
+		addr, mails := newFakeSMTPServer(c)
+
+		r := Receiver{Name: "oncall", Type: ReceiverEmail}
+		r.SMTP.Addr = addr
+		r.SMTP.From = "gpagdispo@example.org"
+		r.SMTP.To = []string{"oncall@example.org", "boss@example.org"}
+		err := sender.Send(ctx, r, ev)
+		c.Assert(err, qt.IsNil)
+
+		m := <-mails
+		c.Assert(m.From, qt.Equals, "gpagdispo@example.org")
+		c.Assert(m.To, qt.DeepEquals, []string{"oncall@example.org", "boss@example.org"})
+		c.Assert(m.Data, qt.Contains, "Subject: [OPENED] Incident on http://foo.org: timeout\r\n")
+		c.Assert(m.Data, qt.Contains, "Started at: 2021-05-17T09:00:00Z\r\n")
+	})
+
+	c.Run("Email stalled", func(c *qt.C) {
+		// The server accepts the connection but never greets
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		c.Assert(err, qt.IsNil)
+		c.Cleanup(func() { _ = l.Close() })
+		conns := make(chan net.Conn, 1)
+		go func() {
+			conn, err := l.Accept()
+			if err == nil {
+				conns <- conn
+			}
+		}()
+
+		r := Receiver{Name: "oncall", Type: ReceiverEmail}
+		r.SMTP.Addr = l.Addr().String()
+		r.SMTP.From = "gpagdispo@example.org"
+		r.SMTP.To = []string{"oncall@example.org"}
+		sender := &Sender{Client: &http.Client{Timeout: 100 * time.Millisecond}}
+		err = sender.Send(ctx, r, ev)
+		c.Assert(err, qt.ErrorMatches, "can't send email: .*i/o timeout")
+		_ = (<-conns).Close()
+	})
+}
+
+func TestSign(t *testing.T) {
+	c := qt.New(t)
+
+	// echo -n '{"foo":"bar"}' | openssl dgst -sha256 -hmac s3cr3t
+	c.Assert(Sign("s3cr3t", []byte(`{"foo":"bar"}`)), qt.Equals,
+		"sha256=d9e5c7743a67dd6109db8a96cc2072249c1ebf997efade09667ed2c58f1deb8f")
+}
+
+// Fake webhook server
+
+type fakeRequest struct {
+	Header http.Header
+	Body   []byte
+}
+
+func newFakeWebhookServer(c *qt.C, status int) (*httptest.Server, <-chan fakeRequest) {
+	requests := make(chan fakeRequest, 1)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		c.Check(err, qt.IsNil)
+		requests <- fakeRequest{Header: req.Header, Body: body}
+		w.WriteHeader(status)
+	}))
+	c.Cleanup(svr.Close)
+
+	return svr, requests
+}
+
+// Fake SMTP server
+
+type fakeMail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// newFakeSMTPServer serves a minimal SMTP server accepting any mail.
+func newFakeSMTPServer(c *qt.C) (string, <-chan fakeMail) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, qt.IsNil)
+
+	mails := make(chan fakeMail, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+		reply := func(line string) {
+			fmt.Fprintf(rw, "%s\r\n", line)
+			rw.Flush()
+		}
+
+		var m fakeMail
+		reply("220 localhost ESMTP fake")
+		for {
+			line, err := rw.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				m.From = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				m.To = append(m.To, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					l, err := rw.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				m.Data = data.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				mails <- m
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	c.Cleanup(func() {
+		l.Close()
+		wg.Wait()
+	})
+
+	return l.Addr().String(), mails
+}
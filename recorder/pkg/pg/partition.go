@@ -0,0 +1,197 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	partitionPrefix = "websites_results_"
+	// defaultPartition holds the results out of the range of the monthly partitions.
+	defaultPartition = "websites_results_default"
+	// partitionSuffixLayout is the time layout of the month of the partition in its name.
+	partitionSuffixLayout = "2006_01"
+	timestampLayout       = "2006-01-02 15:04:05"
+)
+
+// Partition defines a monthly partition of websites_results table.
+type Partition struct {
+	Name string
+	// From is inclusive and To is exclusive.
+	From, To time.Time
+}
+
+// monthStart returns the start of the month of t in UTC.
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// newPartition returns the partition of the month starting at month.
+func newPartition(month time.Time) Partition {
+	return Partition{
+		Name: partitionPrefix + month.Format(partitionSuffixLayout),
+		From: month,
+		To:   month.AddDate(0, 1, 0),
+	}
+}
+
+// CreatePartitions creates the missing monthly partitions of websites_results
+// covering from (inclusive) to (exclusive).
+func (s *Store) CreatePartitions(ctx context.Context, from, to time.Time) error {
+	for month := monthStart(from); month.Before(to); month = month.AddDate(0, 1, 0) {
+		p := newPartition(month)
+		if err := s.createPartition(ctx, p); err != nil {
+			return fmt.Errorf("can't create partition %s: %w", p.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// createPartition creates the partition if it is not attached yet, or re-attaches it if it
+// was detached. A partition can't be added while the default one holds results of its range:
+// only then the default partition is detached in the same transaction, its results of the
+// range moved to the new partition and it is attached back. This locks websites_results, so
+// the inserts wait for it.
+func (s *Store) createPartition(ctx context.Context, p Partition) error {
+	var attached bool
+	err := s.DB.GetContext(ctx, &attached, `
+                   SELECT EXISTS(
+                          SELECT 1
+                          FROM pg_inherits i
+                          JOIN pg_class c ON c.oid = i.inhrelid
+                          JOIN pg_class p ON p.oid = i.inhparent
+                          WHERE p.relname = 'websites_results' AND c.relname = $1)`, p.Name)
+	if err != nil {
+		return err
+	}
+	if attached {
+		return nil
+	}
+
+	// DDL can't have parameters, the values are generated by newPartition
+	from, to := p.From.Format(timestampLayout), p.To.Format(timestampLayout)
+
+	var exists bool
+	if err := s.DB.GetContext(ctx, &exists, `SELECT to_regclass($1) IS NOT NULL`, p.Name); err != nil {
+		return err
+	}
+	add := fmt.Sprintf(`CREATE TABLE %s PARTITION OF websites_results FOR VALUES FROM ('%s') TO ('%s')`, p.Name, from, to)
+	if exists {
+		add = fmt.Sprintf(`ALTER TABLE websites_results ATTACH PARTITION %s FOR VALUES FROM ('%s') TO ('%s')`, p.Name, from, to)
+	}
+
+	var inDefault bool
+	err = s.DB.GetContext(ctx, &inDefault,
+		`SELECT EXISTS(SELECT 1 FROM `+defaultPartition+` WHERE at >= $1 AND at < $2)`, p.From, p.To)
+	if err != nil {
+		return err
+	}
+	if !inDefault {
+		_, err := s.DB.ExecContext(ctx, add)
+		return err
+	}
+
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, query := range []string{
+		`ALTER TABLE websites_results DETACH PARTITION ` + defaultPartition,
+		add,
+		fmt.Sprintf(`INSERT INTO %s SELECT * FROM %s WHERE at >= '%s' AND at < '%s'`, p.Name, defaultPartition, from, to),
+		fmt.Sprintf(`DELETE FROM %s WHERE at >= '%s' AND at < '%s'`, defaultPartition, from, to),
+		`ALTER TABLE websites_results ATTACH PARTITION ` + defaultPartition + ` DEFAULT`,
+	} {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Partitions returns the monthly partitions attached to websites_results sorted by time.
+func (s *Store) Partitions(ctx context.Context) ([]Partition, error) {
+	var names []string
+	err := s.DB.SelectContext(ctx, &names, `
+                   SELECT c.relname
+                   FROM pg_inherits i
+                   JOIN pg_class c ON c.oid = i.inhrelid
+                   JOIN pg_class p ON p.oid = i.inhparent
+                   WHERE p.relname = 'websites_results' AND c.relname <> $1
+                   ORDER BY c.relname`, defaultPartition)
+	if err != nil {
+		return nil, fmt.Errorf("can't get partitions: %w", err)
+	}
+
+	partitions := make([]Partition, 0, len(names))
+	for _, name := range names {
+		month, err := time.Parse(partitionSuffixLayout, strings.TrimPrefix(name, partitionPrefix))
+		if err != nil {
+			log.Warn().Msgf("Unknown partition %s of websites_results", name)
+			continue
+		}
+		partitions = append(partitions, newPartition(month))
+	}
+
+	return partitions, nil
+}
+
+// DeleteResultsBefore deletes the raw results, and their steps, recorded before the given time.
+// The partitions only holding expired results are dropped, or detached if
+// DetachExpiredPartitions is set, and the expired results left in the other partitions are
+// deleted. It returns the number of deleted rows, not counting the ones of the expired partitions.
+func (s *Store) DeleteResultsBefore(ctx context.Context, before time.Time) (int64, error) {
+	partitions, err := s.Partitions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range partitions {
+		if p.To.After(before) {
+			break
+		}
+		if err := s.expirePartition(ctx, p); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := s.DB.ExecContext(ctx, `DELETE FROM websites_step_results WHERE at < $1`, before); err != nil {
+		return 0, fmt.Errorf("can't delete step results: %w", err)
+	}
+
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM websites_results WHERE at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("can't delete results: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("can't get number of affected rows: %w", err)
+	}
+
+	return n, nil
+}
+
+func (s *Store) expirePartition(ctx context.Context, p Partition) error {
+	if _, err := s.DB.ExecContext(ctx, `ALTER TABLE websites_results DETACH PARTITION `+p.Name); err != nil {
+		return fmt.Errorf("can't detach partition %s: %w", p.Name, err)
+	}
+	if s.DetachExpiredPartitions {
+		log.Info().Msgf("Detached expired partition %s", p.Name)
+		return nil
+	}
+
+	if _, err := s.DB.ExecContext(ctx, `DROP TABLE `+p.Name); err != nil {
+		return fmt.Errorf("can't drop partition %s: %w", p.Name, err)
+	}
+	log.Info().Msgf("Dropped expired partition %s", p.Name)
+
+	return nil
+}
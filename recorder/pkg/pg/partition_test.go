@@ -0,0 +1,166 @@
+package pg
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+func TestPartitions(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+
+	s := newTestStore(c)
+	c.Cleanup(func() {
+		_, _ = s.DB.Exec(`DROP TABLE IF EXISTS websites_results_2020_12`)
+		_, _ = s.DB.Exec(`DROP TABLE IF EXISTS websites_results_2021_01`)
+	})
+
+	dec := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+	jan := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	c.Run("Create across month boundary", func(c *qt.C) {
+		err := s.CreatePartitions(ctx, jan.Add(15*24*time.Hour), feb.Add(time.Second))
+		c.Assert(err, qt.IsNil)
+		// Creating them again is a no-op
+		err = s.CreatePartitions(ctx, jan, mar)
+		c.Assert(err, qt.IsNil)
+
+		partitions, err := s.Partitions(ctx)
+		c.Assert(err, qt.IsNil)
+		c.Assert(len(partitions) >= 2, qt.IsTrue)
+		c.Assert(partitions[:2], qt.DeepEquals, []Partition{
+			{Name: "websites_results_2021_01", From: jan, To: feb},
+			{Name: "websites_results_2021_02", From: feb, To: mar},
+		})
+
+		wp := domain.WebsiteParams{ID: "id1", URL: "http://foo.org", Method: "GET"}
+		ok := http.StatusOK
+		for _, at := range []time.Time{feb.Add(-time.Second), feb} {
+			err := s.InsertWebsiteResult(ctx, wp, domain.WebsiteResult{Status: &ok, At: at})
+			c.Assert(err, qt.IsNil)
+		}
+
+		var partitionOf []string
+		err = s.DB.SelectContext(ctx, &partitionOf, `
+                         SELECT tableoid::regclass::TEXT
+                         FROM websites_results
+                         WHERE website_id = $1
+                         ORDER BY at`, wp.ID)
+		c.Assert(err, qt.IsNil)
+		c.Assert(partitionOf, qt.DeepEquals, []string{"websites_results_2021_01", "websites_results_2021_02"})
+
+	})
+
+	c.Run("Default partition", func(c *qt.C) {
+		wp := domain.WebsiteParams{ID: "id2", URL: "http://bar.org", Method: "GET"}
+		ok := http.StatusOK
+		for _, at := range []time.Time{jan.Add(-time.Second), jan.AddDate(-1, 0, 0)} {
+			err := s.InsertWebsiteResult(ctx, wp, domain.WebsiteResult{Status: &ok, At: at})
+			c.Assert(err, qt.IsNil, qt.Commentf("results without partition are kept"))
+		}
+
+		partitionOf := func() []string {
+			var names []string
+			err := s.DB.SelectContext(ctx, &names, `
+                                 SELECT tableoid::regclass::TEXT
+                                 FROM websites_results
+                                 WHERE website_id = $1
+                                 ORDER BY at`, wp.ID)
+			c.Assert(err, qt.IsNil)
+			return names
+		}
+		c.Assert(partitionOf(), qt.DeepEquals, []string{"websites_results_default", "websites_results_default"})
+
+		// The results of the month are moved to its partition once created
+		err := s.CreatePartitions(ctx, dec, jan)
+		c.Assert(err, qt.IsNil)
+		c.Assert(partitionOf(), qt.DeepEquals, []string{"websites_results_default", "websites_results_2020_12"})
+
+		partitions, err := s.Partitions(ctx)
+		c.Assert(err, qt.IsNil)
+		c.Assert(partitions[0], qt.DeepEquals, Partition{Name: "websites_results_2020_12", From: dec, To: jan})
+		for _, p := range partitions {
+			c.Assert(p.Name, qt.Not(qt.Equals), "websites_results_default")
+		}
+	})
+
+	c.Run("Detach expired", func(c *qt.C) {
+		s.DetachExpiredPartitions = true
+		defer func() { s.DetachExpiredPartitions = false }()
+
+		n, err := s.DeleteResultsBefore(ctx, feb)
+		c.Assert(err, qt.IsNil)
+		c.Assert(n, qt.Equals, int64(1), qt.Commentf("expired results are in the detached partitions but the default one"))
+
+		partitions, err := s.Partitions(ctx)
+		c.Assert(err, qt.IsNil)
+		c.Assert(partitions[0].Name, qt.Equals, "websites_results_2021_02")
+
+		var detached int
+		err = s.DB.GetContext(ctx, &detached, `SELECT COUNT(*) FROM websites_results_2021_01`)
+		c.Assert(err, qt.IsNil)
+		c.Assert(detached, qt.Equals, 1)
+	})
+
+	c.Run("Reattach detached", func(c *qt.C) {
+		err := s.CreatePartitions(ctx, jan, feb)
+		c.Assert(err, qt.IsNil)
+
+		partitions, err := s.Partitions(ctx)
+		c.Assert(err, qt.IsNil)
+		c.Assert(partitions[0], qt.DeepEquals, Partition{Name: "websites_results_2021_01", From: jan, To: feb})
+
+		var count int
+		err = s.DB.GetContext(ctx, &count, `SELECT COUNT(*) FROM websites_results WHERE website_id = 'id1'`)
+		c.Assert(err, qt.IsNil)
+		c.Assert(count, qt.Equals, 2)
+	})
+
+	c.Run("Drop expired", func(c *qt.C) {
+		n, err := s.DeleteResultsBefore(ctx, feb.Add(time.Hour))
+		c.Assert(err, qt.IsNil)
+		c.Assert(n, qt.Equals, int64(1), qt.Commentf("partially expired partition is kept"))
+
+		n, err = s.DeleteResultsBefore(ctx, mar)
+		c.Assert(err, qt.IsNil)
+		c.Assert(n, qt.Equals, int64(0))
+
+		partitions, err := s.Partitions(ctx)
+		c.Assert(err, qt.IsNil)
+		for _, p := range partitions {
+			c.Assert(p.To.After(mar), qt.IsTrue, qt.Commentf(p.Name))
+		}
+
+		var exists bool
+		err = s.DB.GetContext(ctx, &exists, `SELECT to_regclass('websites_results_2021_02') IS NOT NULL`)
+		c.Assert(err, qt.IsNil)
+		c.Assert(exists, qt.IsFalse)
+	})
+}
+
+// newTestStore connects to the test DB with a fresh schema.
+func newTestStore(c *qt.C) *Store {
+	uri := os.Getenv("POSTGRESQL_DSN")
+	if uri == "" {
+		uri = "postgres://postgres@localhost/website_test?sslmode=disable"
+	}
+
+	s, err := NewStore(uri)
+	c.Assert(err, qt.IsNil)
+	c.Cleanup(func() { _ = s.Close() })
+
+	err = s.CreateSchema("../../db/migrations")
+	c.Assert(err, qt.IsNil)
+	c.Cleanup(func() { _ = s.DropSchema("../../db/migrations") })
+
+	return s
+}
@@ -0,0 +1,116 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+var rollupTables = map[domain.RollupPeriod]string{
+	domain.RollupHourly: "websites_results_hourly",
+	domain.RollupDaily:  "websites_results_daily",
+}
+
+// RollupWatermark returns the start of the latest hourly rollup or, if there are none,
+// the time of the oldest result. It returns nil if there are no results.
+func (s *Store) RollupWatermark(ctx context.Context) (*time.Time, error) {
+	var watermark sql.NullTime
+	err := s.DB.GetContext(ctx, &watermark, `
+                   SELECT COALESCE((SELECT MAX(bucket_start) FROM websites_results_hourly),
+                                   (SELECT MIN(at) FROM websites_results))`)
+	if err != nil {
+		return nil, fmt.Errorf("can't get rollup watermark: %w", err)
+	}
+	if !watermark.Valid {
+		return nil, nil
+	}
+
+	t := watermark.Time.UTC()
+	return &t, nil
+}
+
+// Rollup aggregates the results between from (inclusive) and to (exclusive) per website and period,
+// replacing the existing rollups. from must be aligned to the period.
+func (s *Store) Rollup(ctx context.Context, period domain.RollupPeriod, from, to time.Time) error {
+	table, ok := rollupTables[period]
+	if !ok {
+		return fmt.Errorf("unknown rollup period %q", period)
+	}
+
+	_, err := s.DB.ExecContext(ctx, `
+                   WITH stats AS (
+                        SELECT website_id, date_trunc($3, at) AS bucket_start,
+                               COUNT(*) AS checks,
+                               COUNT(*) FILTER (WHERE `+failedResultSQL+`) AS failures,
+                               COALESCE(MIN(elapsed_time), 0) AS min_elapsed,
+                               COALESCE(AVG(elapsed_time), 0) AS avg_elapsed,
+                               COALESCE(MAX(elapsed_time), 0) AS max_elapsed,
+                               COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY elapsed_time), 0) AS p50_elapsed,
+                               COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY elapsed_time), 0) AS p95_elapsed,
+                               COALESCE(percentile_cont(0.99) WITHIN GROUP (ORDER BY elapsed_time), 0) AS p99_elapsed
+                        FROM websites_results
+                        WHERE at >= $1 AND at < $2 AND NOT in_maintenance
+                        GROUP BY 1, 2),
+                   codes AS (
+                        SELECT website_id, bucket_start, jsonb_object_agg(status, n) AS status_codes
+                        FROM (SELECT website_id, date_trunc($3, at) AS bucket_start, status, COUNT(*) AS n
+                              FROM websites_results
+                              WHERE at >= $1 AND at < $2 AND NOT in_maintenance AND status IS NOT NULL
+                              GROUP BY 1, 2, 3) c
+                        GROUP BY 1, 2)
+                   INSERT INTO `+table+`(website_id, bucket_start, checks, failures, min_elapsed, avg_elapsed, max_elapsed,
+                                         p50_elapsed, p95_elapsed, p99_elapsed, status_codes)
+                   SELECT s.website_id, s.bucket_start, s.checks, s.failures, s.min_elapsed, s.avg_elapsed, s.max_elapsed,
+                          s.p50_elapsed, s.p95_elapsed, s.p99_elapsed, COALESCE(c.status_codes, '{}')
+                   FROM stats s
+                   LEFT JOIN codes c USING (website_id, bucket_start)
+                   ON CONFLICT (website_id, bucket_start) DO UPDATE
+                   SET checks = EXCLUDED.checks, failures = EXCLUDED.failures,
+                       min_elapsed = EXCLUDED.min_elapsed, avg_elapsed = EXCLUDED.avg_elapsed, max_elapsed = EXCLUDED.max_elapsed,
+                       p50_elapsed = EXCLUDED.p50_elapsed, p95_elapsed = EXCLUDED.p95_elapsed, p99_elapsed = EXCLUDED.p99_elapsed,
+                       status_codes = EXCLUDED.status_codes`,
+		from, to, string(period))
+	if err != nil {
+		return fmt.Errorf("can't roll up results: %w", err)
+	}
+
+	return nil
+}
+
+// Rollups returns the rollups of a website whose bucket starts between from (inclusive) and to (exclusive).
+func (s *Store) Rollups(ctx context.Context, websiteID string, period domain.RollupPeriod, from, to time.Time) ([]domain.Rollup, error) {
+	table, ok := rollupTables[period]
+	if !ok {
+		return nil, fmt.Errorf("unknown rollup period %q", period)
+	}
+
+	var rows []struct {
+		domain.Rollup
+		StatusCodes string `db:"status_codes"`
+	}
+	err := s.DB.SelectContext(ctx, &rows, `
+                   SELECT website_id, bucket_start, checks, failures, min_elapsed, avg_elapsed, max_elapsed,
+                          p50_elapsed, p95_elapsed, p99_elapsed, status_codes::TEXT AS status_codes
+                   FROM `+table+`
+                   WHERE website_id = $1 AND bucket_start >= $2 AND bucket_start < $3
+                   ORDER BY bucket_start`,
+		websiteID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("can't get rollups: %w", err)
+	}
+
+	rollups := make([]domain.Rollup, len(rows))
+	for i, r := range rows {
+		rollups[i] = r.Rollup
+		rollups[i].From = r.From.UTC()
+		if err := json.Unmarshal([]byte(r.StatusCodes), &rollups[i].StatusCodes); err != nil {
+			return nil, fmt.Errorf("can't decode status codes: %w", err)
+		}
+	}
+
+	return rollups, nil
+}
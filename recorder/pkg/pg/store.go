@@ -2,26 +2,36 @@ package pg
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	migrate "github.com/golang-migrate/migrate/v4"
 	migratepg "github.com/golang-migrate/migrate/v4/database/postgres"
 	"github.com/jmoiron/sqlx"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	// Required to read migration files from OS
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 
-	// Required to load PG driver
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
 )
 
+const tracerName = "github.com/sixstone-qq/gpagdispo/recorder/pkg/pg"
+
 // Store holds the DB connection.
 type Store struct {
 	DB *sqlx.DB
+	// DetachExpiredPartitions detaches the expired partitions of results instead of dropping
+	// them, so they can be archived.
+	DetachExpiredPartitions bool
 }
 
 // NewStore connects to DB for to use store
@@ -82,17 +92,53 @@ func (s *Store) migrate(sourcePath string) (*migrate.Migrate, error) {
 
 // InsertWebsiteResult inserts the website and website_results in the respective tables.
 func (s *Store) InsertWebsiteResult(ctx context.Context, wp domain.WebsiteParams, wr domain.WebsiteResult) error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "insert website result",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.sql.table", "websites_results"),
+			attribute.String("website.id", wp.ID),
+		))
+	defer span.End()
+
+	err := s.insertWebsiteResult(ctx, wp, wr)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "can't insert website result")
+	}
+	return err
+}
+
+func (s *Store) insertWebsiteResult(ctx context.Context, wp domain.WebsiteParams, wr domain.WebsiteResult) error {
+	details, err := encodeDetails(wr)
+	if err != nil {
+		return err
+	}
+
 	tx, err := s.DB.Beginx()
 	if err != nil {
 		return err
 	}
 	defer func() { _ = tx.Rollback() }()
 
+	// A nil array is encoded as NULL, the tags column requires an empty one.
+	tags := pq.StringArray(wp.Tags)
+	if tags == nil {
+		tags = pq.StringArray{}
+	}
 	res, err := tx.NamedExecContext(ctx, `
-                    INSERT INTO websites(id, url, method, match_regexp) VALUES
-                    (:id, :url, :method, :match_regexp)
-                    ON CONFLICT DO NOTHING;
-                    `, wp)
+                    INSERT INTO websites(id, url, method, match_regexp, tags) VALUES
+                    (:id, :url, :method, :match_regexp, :tags)
+                    ON CONFLICT (id) DO UPDATE SET tags = EXCLUDED.tags
+                    WHERE websites.tags IS DISTINCT FROM EXCLUDED.tags;
+                    `,
+		map[string]interface{}{
+			"id":           wp.ID,
+			"url":          wp.URL,
+			"method":       wp.Method,
+			"match_regexp": wp.MatchRegexp,
+			"tags":         tags,
+		})
 	if err != nil {
 		return fmt.Errorf("can't insert website: %w", err)
 	}
@@ -101,20 +147,27 @@ func (s *Store) InsertWebsiteResult(ctx context.Context, wp domain.WebsiteParams
 		return fmt.Errorf("can't get number of affected rows: %w", err)
 	}
 	if n == 1 {
-		log.Info().Msgf("Added website %s", wp.URL)
+		log.Info().Msgf("Added or updated website %s", wp.URL)
 	}
 
 	res, err = tx.NamedExecContext(ctx, `
-                   INSERT INTO websites_results(website_id, elapsed_time, status, matched, unreachable, at) VALUES
-                   (:id, :elapsed_time, :status, :matched, :unreachable, :at)
+                   INSERT INTO websites_results(website_id, elapsed_time, status, matched, unreachable, at, location, checker_id, ip, in_maintenance, probe, error, details) VALUES
+                   (:id, :elapsed_time, :status, :matched, :unreachable, :at, :location, :checker_id, :ip, :in_maintenance, :probe, :error, :details)
                    ON CONFLICT DO NOTHING`,
 		map[string]interface{}{
-			"id":           wp.ID,
-			"elapsed_time": wr.Elapsed.Seconds(),
-			"status":       wr.Status,
-			"matched":      wr.Matched,
-			"unreachable":  wr.Unreachable,
-			"at":           wr.At,
+			"id":             wp.ID,
+			"elapsed_time":   wr.Elapsed.Seconds(),
+			"status":         wr.Status,
+			"matched":        wr.Matched,
+			"unreachable":    wr.Unreachable,
+			"at":             wr.At,
+			"location":       wr.Location,
+			"checker_id":     wr.CheckerID,
+			"ip":             wr.IP,
+			"in_maintenance": wr.InMaintenance,
+			"probe":          probeOrDefault(wr),
+			"error":          wr.Error,
+			"details":        details,
 		})
 	if err != nil {
 		return fmt.Errorf("can't insert website result: %w", err)
@@ -127,6 +180,32 @@ func (s *Store) InsertWebsiteResult(ctx context.Context, wp domain.WebsiteParams
 		log.Info().Msgf("Added website result from %s", wp.URL)
 	}
 
+	for i, sr := range wr.Steps {
+		_, err = tx.NamedExecContext(ctx, `
+                   INSERT INTO websites_step_results(website_id, location, checker_id, ip, at, position, name, elapsed_time, status, matched, error, body_size, body_truncated, content_type) VALUES
+                   (:id, :location, :checker_id, :ip, :at, :position, :name, :elapsed_time, :status, :matched, :error, :body_size, :body_truncated, :content_type)
+                   ON CONFLICT DO NOTHING`,
+			map[string]interface{}{
+				"id":             wp.ID,
+				"location":       wr.Location,
+				"checker_id":     wr.CheckerID,
+				"ip":             wr.IP,
+				"at":             wr.At,
+				"position":       i,
+				"name":           sr.Name,
+				"elapsed_time":   sr.Elapsed.Seconds(),
+				"status":         sr.Status,
+				"matched":        sr.Matched,
+				"error":          sr.Error,
+				"body_size":      sr.BodySize,
+				"body_truncated": sr.BodyTruncated,
+				"content_type":   sr.ContentType,
+			})
+		if err != nil {
+			return fmt.Errorf("can't insert step result: %w", err)
+		}
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return fmt.Errorf("can't commit tx: %w", err)
@@ -135,6 +214,676 @@ func (s *Store) InsertWebsiteResult(ctx context.Context, wp domain.WebsiteParams
 	return nil
 }
 
+// failedResultSQL is the SQL condition equivalent to domain.WebsiteResult.Failed.
+const failedResultSQL = `(unreachable OR error IS NOT NULL OR matched IS FALSE OR (probe = 'http' AND (status IS NULL OR status >= 400)))`
+
+// LocationStats aggregates the results of a website per location between from (inclusive) and to (exclusive).
+// An empty location returns the stats of every location. Results in maintenance are excluded.
+func (s *Store) LocationStats(ctx context.Context, websiteID, location string, from, to time.Time) ([]domain.LocationStats, error) {
+	var stats []domain.LocationStats
+	err := s.DB.SelectContext(ctx, &stats, `
+                   SELECT location,
+                          COUNT(*) AS checks,
+                          COUNT(*) FILTER (WHERE `+failedResultSQL+`) AS failures,
+                          COALESCE(AVG(elapsed_time), 0) AS avg_elapsed
+                   FROM websites_results
+                   WHERE website_id = $1 AND ($2 = '' OR location = $2) AND at >= $3 AND at < $4
+                         AND NOT in_maintenance
+                   GROUP BY location
+                   ORDER BY location`,
+		websiteID, location, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("can't get location stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// WebsiteResults returns the results of a website between from (inclusive) and to (exclusive) sorted by time.
+func (s *Store) WebsiteResults(ctx context.Context, websiteID string, from, to time.Time) ([]domain.WebsiteResult, error) {
+	var rows []websiteResultRow
+	err := s.DB.SelectContext(ctx, &rows, `
+                   SELECT elapsed_time, status, matched, unreachable, at, location, checker_id, ip, in_maintenance, probe, error, details
+                   FROM websites_results
+                   WHERE website_id = $1 AND at >= $2 AND at < $3
+                   ORDER BY at, location, checker_id, ip`,
+		websiteID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("can't get website results: %w", err)
+	}
+
+	results := make([]domain.WebsiteResult, len(rows))
+	for i, r := range rows {
+		if results[i], err = r.toDomain(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.attachSteps(ctx, websiteID, results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// SaveWebsiteStatus inserts or updates the website status of a time window.
+func (s *Store) SaveWebsiteStatus(ctx context.Context, ws domain.WebsiteStatus) error {
+	_, err := s.DB.NamedExecContext(ctx, `
+                   INSERT INTO website_status(website_id, window_start, window_end, verdict, locations, failed_locations) VALUES
+                   (:website_id, :window_start, :window_end, :verdict, :locations, :failed_locations)
+                   ON CONFLICT (website_id, window_start) DO UPDATE SET
+                      window_end = EXCLUDED.window_end,
+                      verdict = EXCLUDED.verdict,
+                      locations = EXCLUDED.locations,
+                      failed_locations = EXCLUDED.failed_locations`, ws)
+	if err != nil {
+		return fmt.Errorf("can't save website status: %w", err)
+	}
+
+	return nil
+}
+
+// SaveIncidentChanges stores the incident changes, setting the ID of the opened incident,
+// and enqueues the notifications of their events in a single transaction.
+func (s *Store) SaveIncidentChanges(ctx context.Context, ch domain.IncidentChanges, notifications domain.NotificationsFunc) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if ch.Opened != nil {
+		if err := openIncident(ctx, tx, ch.Opened); err != nil {
+			return err
+		}
+	}
+	if ch.Updated != nil {
+		_, err := tx.NamedExecContext(ctx, `
+                   UPDATE incidents
+                   SET ended_at = :ended_at, failed_checks = :failed_checks
+                   WHERE id = :id`, ch.Updated)
+		if err != nil {
+			return fmt.Errorf("can't update incident: %w", err)
+		}
+	}
+	if ch.Flapping != nil {
+		_, err := tx.NamedExecContext(ctx, `
+                   INSERT INTO websites_flapping(website_id, flapping, score, since) VALUES
+                   (:website_id, :flapping, :score, :since)
+                   ON CONFLICT (website_id) DO UPDATE SET
+                      flapping = EXCLUDED.flapping,
+                      score = EXCLUDED.score,
+                      since = EXCLUDED.since`, ch.Flapping)
+		if err != nil {
+			return fmt.Errorf("can't save flapping state: %w", err)
+		}
+	}
+
+	for _, ev := range ch.Events {
+		if notifications == nil {
+			break
+		}
+		ns, err := notifications(ev)
+		if err != nil {
+			return fmt.Errorf("can't get notifications of incident %s: %w", ev.Kind, err)
+		}
+		for _, n := range ns {
+			_, err := tx.ExecContext(ctx, `
+                   INSERT INTO notifications(receiver, payload, next_attempt_at) VALUES
+                   ($1, $2, NOW() AT TIME ZONE 'utc')`,
+				n.Receiver, string(n.Payload))
+			if err != nil {
+				return fmt.Errorf("can't enqueue notification: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("can't commit tx: %w", err)
+	}
+
+	return nil
+}
+
+// openIncident inserts a new incident setting its ID.
+func openIncident(ctx context.Context, tx *sqlx.Tx, in *domain.Incident) error {
+	rows, err := sqlx.NamedQueryContext(ctx, tx, `
+                   INSERT INTO incidents(website_id, started_at, ended_at, cause, failed_checks) VALUES
+                   (:website_id, :started_at, :ended_at, :cause, :failed_checks)
+                   RETURNING id`, in)
+	if err != nil {
+		return fmt.Errorf("can't insert incident: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return fmt.Errorf("can't get incident ID: %w", rows.Err())
+	}
+	if err := rows.Scan(&in.ID); err != nil {
+		return fmt.Errorf("can't scan incident ID: %w", err)
+	}
+
+	return nil
+}
+
+// OpenIncidents returns the incidents which are not resolved yet.
+func (s *Store) OpenIncidents(ctx context.Context) ([]domain.Incident, error) {
+	var incidents []domain.Incident
+	err := s.DB.SelectContext(ctx, &incidents, `
+                   SELECT id, website_id, started_at, ended_at, cause, failed_checks
+                   FROM incidents
+                   WHERE ended_at IS NULL
+                   ORDER BY started_at`)
+	if err != nil {
+		return nil, fmt.Errorf("can't get open incidents: %w", err)
+	}
+
+	return incidents, nil
+}
+
+// Incidents returns the open incidents and the ones resolved since the given time
+// from the latest to the oldest.
+func (s *Store) Incidents(ctx context.Context, since time.Time) ([]domain.Incident, error) {
+	var incidents []domain.Incident
+	err := s.DB.SelectContext(ctx, &incidents, `
+                   SELECT id, website_id, started_at, ended_at, cause, failed_checks
+                   FROM incidents
+                   WHERE ended_at IS NULL OR ended_at >= $1
+                   ORDER BY started_at DESC`, since)
+	if err != nil {
+		return nil, fmt.Errorf("can't get incidents: %w", err)
+	}
+
+	return incidents, nil
+}
+
+// FlappingWebsites returns the flapping state of the websites which are currently flapping.
+func (s *Store) FlappingWebsites(ctx context.Context) ([]domain.FlappingState, error) {
+	var states []domain.FlappingState
+	err := s.DB.SelectContext(ctx, &states, `
+                   SELECT website_id, flapping, score, since
+                   FROM websites_flapping
+                   WHERE flapping
+                   ORDER BY since`)
+	if err != nil {
+		return nil, fmt.Errorf("can't get flapping websites: %w", err)
+	}
+
+	return states, nil
+}
+
+// PendingNotifications returns up to limit notifications to deliver at now.
+func (s *Store) PendingNotifications(ctx context.Context, now time.Time, limit int) ([]domain.Notification, error) {
+	var notifications []domain.Notification
+	err := s.DB.SelectContext(ctx, &notifications, `
+                   SELECT id, receiver, payload::TEXT AS payload, attempts, next_attempt_at, last_error
+                   FROM notifications
+                   WHERE delivered_at IS NULL AND next_attempt_at <= $1
+                   ORDER BY next_attempt_at
+                   LIMIT $2`,
+		now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("can't get pending notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// MarkNotificationDelivered sets the notification as delivered.
+func (s *Store) MarkNotificationDelivered(ctx context.Context, id int64, at time.Time) error {
+	_, err := s.DB.ExecContext(ctx, `
+                   UPDATE notifications
+                   SET delivered_at = $2, next_attempt_at = NULL
+                   WHERE id = $1`,
+		id, at)
+	if err != nil {
+		return fmt.Errorf("can't mark notification as delivered: %w", err)
+	}
+
+	return nil
+}
+
+// MarkNotificationFailed updates the attempts of a failed notification.
+func (s *Store) MarkNotificationFailed(ctx context.Context, n domain.Notification) error {
+	_, err := s.DB.NamedExecContext(ctx, `
+                   UPDATE notifications
+                   SET attempts = :attempts, next_attempt_at = :next_attempt_at, last_error = :last_error
+                   WHERE id = :id`, n)
+	if err != nil {
+		return fmt.Errorf("can't mark notification as failed: %w", err)
+	}
+
+	return nil
+}
+
+// CreateMaintenanceWindow inserts a maintenance window setting its ID.
+func (s *Store) CreateMaintenanceWindow(ctx context.Context, mw *domain.MaintenanceWindow) error {
+	rows, err := s.DB.NamedQueryContext(ctx, `
+                   INSERT INTO maintenance_windows(website_id, tag, starts_at, ends_at, cron, duration, reason) VALUES
+                   (:website_id, :tag, :starts_at, :ends_at, :cron, :duration, :reason)
+                   RETURNING id`, mw)
+	if err != nil {
+		return fmt.Errorf("can't insert maintenance window: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return fmt.Errorf("can't get maintenance window ID: %w", rows.Err())
+	}
+	if err := rows.Scan(&mw.ID); err != nil {
+		return fmt.Errorf("can't scan maintenance window ID: %w", err)
+	}
+
+	return nil
+}
+
+// MaintenanceWindows returns every maintenance window.
+func (s *Store) MaintenanceWindows(ctx context.Context) ([]domain.MaintenanceWindow, error) {
+	var windows []domain.MaintenanceWindow
+	err := s.DB.SelectContext(ctx, &windows, `
+                   SELECT id, website_id, tag, starts_at, ends_at, cron, duration, reason
+                   FROM maintenance_windows
+                   ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("can't get maintenance windows: %w", err)
+	}
+
+	return windows, nil
+}
+
+// DeleteMaintenanceWindow deletes a maintenance window. It returns false if it does not exist.
+func (s *Store) DeleteMaintenanceWindow(ctx context.Context, id int64) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM maintenance_windows WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("can't delete maintenance window: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("can't get number of affected rows: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+// Websites returns every website sorted by URL.
+func (s *Store) Websites(ctx context.Context) ([]domain.WebsiteParams, error) {
+	var rows []websiteRow
+	err := s.DB.SelectContext(ctx, &rows, `
+                   SELECT id, url, method, match_regexp, tags
+                   FROM websites
+                   ORDER BY url, id`)
+	if err != nil {
+		return nil, fmt.Errorf("can't get websites: %w", err)
+	}
+
+	websites := make([]domain.WebsiteParams, len(rows))
+	for i, r := range rows {
+		websites[i] = r.toDomain()
+	}
+
+	return websites, nil
+}
+
+// QueryWebsiteResults returns a page of the results of a website from the latest to the oldest.
+func (s *Store) QueryWebsiteResults(ctx context.Context, q domain.ResultsQuery) ([]domain.WebsiteResult, error) {
+	var rows []websiteResultRow
+	err := s.DB.SelectContext(ctx, &rows, `
+                   SELECT elapsed_time, status, matched, unreachable, at, location, checker_id, ip, in_maintenance, probe, error, details
+                   FROM websites_results
+                   WHERE website_id = $1 AND at >= $2 AND at < $3 AND ($4 = '' OR location = $4)
+                   ORDER BY at DESC, location, checker_id, ip
+                   LIMIT $5 OFFSET $6`,
+		q.WebsiteID, q.From, q.To, q.Location, q.Limit, q.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("can't query website results: %w", err)
+	}
+
+	results := make([]domain.WebsiteResult, len(rows))
+	for i, r := range rows {
+		if results[i], err = r.toDomain(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.attachSteps(ctx, q.WebsiteID, results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// attachSteps sets the steps of the multi-step check results of a website.
+func (s *Store) attachSteps(ctx context.Context, websiteID string, results []domain.WebsiteResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	from, to := results[0].At, results[0].At
+	for _, r := range results {
+		if r.At.Before(from) {
+			from = r.At
+		}
+		if r.At.After(to) {
+			to = r.At
+		}
+	}
+
+	var rows []stepResultRow
+	err := s.DB.SelectContext(ctx, &rows, `
+                   SELECT location, checker_id, ip, at, name, elapsed_time, status, matched, error, body_size, body_truncated, content_type
+                   FROM websites_step_results
+                   WHERE website_id = $1 AND at >= $2 AND at <= $3
+                   ORDER BY at, location, checker_id, ip, position`,
+		websiteID, from, to)
+	if err != nil {
+		return fmt.Errorf("can't get step results: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	type resultKey struct {
+		location  string
+		checkerID string
+		ip        string
+		at        int64
+	}
+	steps := make(map[resultKey][]domain.StepResult)
+	for _, r := range rows {
+		k := resultKey{r.Location, r.CheckerID, r.IP, r.At.UnixNano()}
+		steps[k] = append(steps[k], r.toDomain())
+	}
+	for i := range results {
+		results[i].Steps = steps[resultKey{results[i].Location, results[i].CheckerID, results[i].IP, results[i].At.UnixNano()}]
+		results[i].SetFailedStep()
+	}
+
+	return nil
+}
+
+// Uptime aggregates the results of a website between from (inclusive) and to (exclusive) in
+// buckets of the given size aligned to Unix epoch. A zero bucket aggregates the whole period.
+// Results in maintenance are excluded and empty buckets are omitted.
+//
+// Periods longer than domain.RollupThreshold are read from the hourly or daily rollups if the bucket
+// is a multiple of them. In that case, from is truncated to the rollup period and the latency
+// percentiles of several rollups are averaged weighted by their number of checks.
+func (s *Store) Uptime(ctx context.Context, websiteID string, from, to time.Time, bucket time.Duration) ([]domain.UptimeStats, error) {
+	stats, err := s.uptime(ctx, websiteID, from, to, bucket)
+	if err != nil {
+		return nil, err
+	}
+	return stats[websiteID], nil
+}
+
+// UptimeByWebsite aggregates the results of every website as Uptime does, in a single query.
+func (s *Store) UptimeByWebsite(ctx context.Context, from, to time.Time, bucket time.Duration) (map[string][]domain.UptimeStats, error) {
+	return s.uptime(ctx, "", from, to, bucket)
+}
+
+// uptime aggregates the results per website. An empty websiteID aggregates every website.
+func (s *Store) uptime(ctx context.Context, websiteID string, from, to time.Time, bucket time.Duration) (map[string][]domain.UptimeStats, error) {
+	period, useRollups := domain.RollupPeriodFor(to.Sub(from), bucket)
+	if useRollups {
+		from = from.Truncate(period.Duration())
+	}
+
+	var origin int64
+	if bucket <= 0 {
+		bucket = to.Sub(from)
+		origin = from.Unix()
+	}
+	bucketSecs := int64(bucket / time.Second)
+	if bucketSecs <= 0 {
+		return nil, fmt.Errorf("bucket must be at least one second: %s provided", bucket)
+	}
+
+	query := `
+                   SELECT website_id,
+                          ` + bucketSQL("at") + ` AS bucket_start,
+                          COUNT(*) AS checks,
+                          COUNT(*) FILTER (WHERE ` + failedResultSQL + `) AS failures,
+                          percentile_cont(0.5) WITHIN GROUP (ORDER BY elapsed_time) AS p50,
+                          percentile_cont(0.95) WITHIN GROUP (ORDER BY elapsed_time) AS p95,
+                          percentile_cont(0.99) WITHIN GROUP (ORDER BY elapsed_time) AS p99
+                   FROM websites_results
+                   WHERE ($1 = '' OR website_id = $1) AND at >= $2 AND at < $3 AND NOT in_maintenance
+                   GROUP BY 1, 2
+                   ORDER BY 1, 2`
+	if useRollups {
+		query = `
+                   SELECT website_id,
+                          ` + bucketSQL("bucket_start") + ` AS bucket_start,
+                          SUM(checks) AS checks,
+                          SUM(failures) AS failures,
+                          SUM(p50_elapsed * checks) / SUM(checks) AS p50,
+                          SUM(p95_elapsed * checks) / SUM(checks) AS p95,
+                          SUM(p99_elapsed * checks) / SUM(checks) AS p99
+                   FROM ` + rollupTables[period] + `
+                   WHERE ($1 = '' OR website_id = $1) AND bucket_start >= $2 AND bucket_start < $3
+                   GROUP BY 1, 2
+                   ORDER BY 1, 2`
+	}
+
+	var rows []struct {
+		WebsiteID string `db:"website_id"`
+		domain.UptimeStats
+	}
+	err := s.DB.SelectContext(ctx, &rows, query, websiteID, from, to, bucketSecs, origin)
+	if err != nil {
+		return nil, fmt.Errorf("can't get uptime: %w", err)
+	}
+
+	stats := make(map[string][]domain.UptimeStats)
+	for _, r := range rows {
+		r.From = r.From.UTC()
+		r.To = r.From.Add(bucket)
+		r.Uptime = domain.Uptime(r.Checks, r.Failures)
+		stats[r.WebsiteID] = append(stats[r.WebsiteID], r.UptimeStats)
+	}
+
+	return stats, nil
+}
+
+// bucketSQL returns the SQL expression of the start of the bucket the column belongs to,
+// given the bucket size in seconds as $4 and the origin as Unix time in $5.
+func bucketSQL(column string) string {
+	return `to_timestamp($5::FLOAT8 + floor((extract(epoch FROM ` + column + `)::FLOAT8 - $5::FLOAT8) / $4::FLOAT8) * $4::FLOAT8) AT TIME ZONE 'UTC'`
+}
+
+// LatestStatuses returns the latest result and verdict of every website sorted by URL.
+func (s *Store) LatestStatuses(ctx context.Context) ([]domain.WebsiteLatestStatus, error) {
+	var rows []struct {
+		websiteRow
+		Elapsed       *float64   `db:"elapsed_time"`
+		Status        *int       `db:"status"`
+		Matched       *bool      `db:"matched"`
+		Unreachable   *bool      `db:"unreachable"`
+		At            *time.Time `db:"at"`
+		Location      *string    `db:"location"`
+		CheckerID     *string    `db:"checker_id"`
+		IP            *string    `db:"ip"`
+		InMaintenance *bool      `db:"in_maintenance"`
+		Probe         *string    `db:"probe"`
+		Error         *string    `db:"error"`
+		Details       *string    `db:"details"`
+		Verdict       *string    `db:"verdict"`
+	}
+	err := s.DB.SelectContext(ctx, &rows, `
+                   SELECT w.id, w.url, w.method, w.match_regexp, w.tags,
+                          r.elapsed_time, r.status, r.matched, r.unreachable, r.at, r.location, r.checker_id, r.ip, r.in_maintenance,
+                          r.probe, r.error, r.details,
+                          ws.verdict
+                   FROM websites w
+                   LEFT JOIN LATERAL (
+                        SELECT elapsed_time, status, matched, unreachable, at, location, checker_id, ip, in_maintenance, probe, error, details
+                        FROM websites_results
+                        WHERE website_id = w.id
+                        ORDER BY at DESC
+                        LIMIT 1) r ON TRUE
+                   LEFT JOIN LATERAL (
+                        SELECT verdict
+                        FROM website_status
+                        WHERE website_id = w.id
+                        ORDER BY window_start DESC
+                        LIMIT 1) ws ON TRUE
+                   ORDER BY w.url, w.id`)
+	if err != nil {
+		return nil, fmt.Errorf("can't get latest statuses: %w", err)
+	}
+
+	statuses := make([]domain.WebsiteLatestStatus, len(rows))
+	for i, r := range rows {
+		statuses[i].Website = r.websiteRow.toDomain()
+		if r.At != nil {
+			wr, err := websiteResultRow{
+				Elapsed:       *r.Elapsed,
+				Status:        r.Status,
+				Matched:       r.Matched,
+				Unreachable:   *r.Unreachable,
+				At:            *r.At,
+				Location:      *r.Location,
+				CheckerID:     *r.CheckerID,
+				IP:            *r.IP,
+				InMaintenance: *r.InMaintenance,
+				Probe:         *r.Probe,
+				Error:         r.Error,
+				Details:       r.Details,
+			}.toDomain()
+			if err != nil {
+				return nil, err
+			}
+			statuses[i].Result = &wr
+		}
+		if r.Verdict != nil {
+			v := domain.Verdict(*r.Verdict)
+			statuses[i].Verdict = &v
+		}
+	}
+
+	return statuses, nil
+}
+
+// websiteRow maps a row from websites table.
+type websiteRow struct {
+	ID          string         `db:"id"`
+	URL         string         `db:"url"`
+	Method      string         `db:"method"`
+	MatchRegexp *string        `db:"match_regexp"`
+	Tags        pq.StringArray `db:"tags"`
+}
+
+func (r websiteRow) toDomain() domain.WebsiteParams {
+	return domain.WebsiteParams{
+		ID:          r.ID,
+		URL:         r.URL,
+		Method:      r.Method,
+		MatchRegexp: r.MatchRegexp,
+		Tags:        []string(r.Tags),
+	}
+}
+
+// websiteResultRow maps a row from websites_results table.
+type websiteResultRow struct {
+	Elapsed       float64   `db:"elapsed_time"`
+	Status        *int      `db:"status"`
+	Matched       *bool     `db:"matched"`
+	Unreachable   bool      `db:"unreachable"`
+	At            time.Time `db:"at"`
+	Location      string    `db:"location"`
+	CheckerID     string    `db:"checker_id"`
+	IP            string    `db:"ip"`
+	InMaintenance bool      `db:"in_maintenance"`
+	Probe         string    `db:"probe"`
+	Error         *string   `db:"error"`
+	// Details is a JSON object
+	Details *string `db:"details"`
+}
+
+func (r websiteResultRow) toDomain() (domain.WebsiteResult, error) {
+	wr := domain.WebsiteResult{
+		Elapsed:       time.Duration(r.Elapsed * float64(time.Second)),
+		Status:        r.Status,
+		Matched:       r.Matched,
+		Unreachable:   r.Unreachable,
+		At:            r.At,
+		Location:      r.Location,
+		CheckerID:     r.CheckerID,
+		IP:            r.IP,
+		InMaintenance: r.InMaintenance,
+		Probe:         r.Probe,
+		Error:         r.Error,
+	}
+	if r.Details != nil {
+		var details domain.ResultDetails
+		if err := json.Unmarshal([]byte(*r.Details), &details); err != nil {
+			return wr, fmt.Errorf("can't decode result details: %w", err)
+		}
+		wr.SetDetails(details)
+	}
+	return wr, nil
+}
+
+// stepResultRow maps a row from websites_step_results table.
+type stepResultRow struct {
+	Location  string    `db:"location"`
+	CheckerID string    `db:"checker_id"`
+	IP        string    `db:"ip"`
+	At        time.Time `db:"at"`
+	Name      string    `db:"name"`
+	Elapsed   float64   `db:"elapsed_time"`
+	Status    *int      `db:"status"`
+	Matched   *bool     `db:"matched"`
+	Error     *string   `db:"error"`
+
+	BodySize      int64  `db:"body_size"`
+	BodyTruncated bool   `db:"body_truncated"`
+	ContentType   string `db:"content_type"`
+}
+
+func (r stepResultRow) toDomain() domain.StepResult {
+	return domain.StepResult{
+		Name:    r.Name,
+		Elapsed: time.Duration(r.Elapsed * float64(time.Second)),
+		Status:  r.Status,
+		Matched: r.Matched,
+		Error:   r.Error,
+
+		BodySize:      r.BodySize,
+		BodyTruncated: r.BodyTruncated,
+		ContentType:   r.ContentType,
+	}
+}
+
+// encodeDetails returns the probe specific fields of the result as a JSON object or nil if there are none.
+func encodeDetails(wr domain.WebsiteResult) (*string, error) {
+	details := wr.Details()
+	if details == nil {
+		return nil, nil
+	}
+	blob, err := json.Marshal(details)
+	if err != nil {
+		return nil, fmt.Errorf("can't encode result details: %w", err)
+	}
+	st := string(blob)
+	return &st, nil
+}
+
+// probeOrDefault returns the probe type of the result, http if not set.
+func probeOrDefault(wr domain.WebsiteResult) string {
+	if wr.Probe == "" {
+		return domain.ProbeHTTP
+	}
+	return wr.Probe
+}
+
+// Ping returns an error if the DB can't be reached.
+func (s *Store) Ping(ctx context.Context) error {
+	if err := s.DB.PingContext(ctx); err != nil {
+		return fmt.Errorf("can't reach DB: %w", err)
+	}
+	return nil
+}
+
 // Close closes the connection
 func (s *Store) Close() error {
 	return s.DB.Close()
@@ -0,0 +1,214 @@
+// Package recorder assembles the components handling the website results, so the
+// recorder and the all-in-one binaries provide the same features.
+package recorder
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/api"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/export"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/kafka"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/metrics"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/notify"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/pg"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/store"
+)
+
+// Config defines the settings of the recorder.
+type Config struct {
+	// StatusQuorum has no default as it depends on the number of checkers.
+	StatusQuorum       int           `env:"STATUS_QUORUM"`
+	StatusWindow       time.Duration `env:"STATUS_WINDOW" envDefault:"1m"`
+	IncidentThreshold  int           `env:"INCIDENT_THRESHOLD" envDefault:"3"`
+	FlapWindow         int           `env:"FLAP_WINDOW" envDefault:"20"`
+	FlapStartThreshold float64       `env:"FLAP_START_THRESHOLD" envDefault:"0.5"`
+	FlapStopThreshold  float64       `env:"FLAP_STOP_THRESHOLD" envDefault:"0.25"`
+	NotifyConfigPath   string        `env:"NOTIFY_CONFIG_PATH"`
+	NotifyPollInterval time.Duration `env:"NOTIFY_POLL_INTERVAL" envDefault:"5s"`
+	NotifyBackoff      time.Duration `env:"NOTIFY_BACKOFF" envDefault:"10s"`
+	NotifyMaxAttempts  int           `env:"NOTIFY_MAX_ATTEMPTS" envDefault:"10"`
+	AdminToken         string        `env:"API_ADMIN_TOKEN"`
+	StatusPageGroups   []string      `env:"STATUS_PAGE_GROUPS"`
+	RollupInterval     time.Duration `env:"ROLLUP_INTERVAL" envDefault:"5m"`
+	ResultsRetention   time.Duration `env:"RESULTS_RETENTION"`
+	PartitionsAhead    int           `env:"PARTITIONS_AHEAD" envDefault:"2"`
+	DetachPartitions   bool          `env:"DETACH_EXPIRED_PARTITIONS"`
+	RemoteWriteURL     string        `env:"REMOTE_WRITE_URL"`
+	InfluxWriteURL     string        `env:"INFLUX_WRITE_URL"`
+	InfluxToken        string        `env:"INFLUX_TOKEN"`
+	ExportTimeout      time.Duration `env:"EXPORT_TIMEOUT" envDefault:"5s"`
+	ExportQueueSize    int           `env:"EXPORT_QUEUE_SIZE" envDefault:"10000"`
+	ExportBatchSize    int           `env:"EXPORT_BATCH_SIZE" envDefault:"500"`
+	ExportInterval     time.Duration `env:"EXPORT_INTERVAL" envDefault:"5s"`
+}
+
+// Validate returns an error if a setting can't be used by the recorder.
+func (cfg Config) Validate() error {
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"STATUS_WINDOW", cfg.StatusWindow},
+		{"ROLLUP_INTERVAL", cfg.RollupInterval},
+		{"NOTIFY_POLL_INTERVAL", cfg.NotifyPollInterval},
+		{"EXPORT_INTERVAL", cfg.ExportInterval},
+	} {
+		if d.value <= 0 {
+			return fmt.Errorf("%s must be positive: %s provided", d.name, d.value)
+		}
+	}
+	return nil
+}
+
+// Recorder records the website results and keeps the state derived from them:
+// statuses, incidents, rollups and partitions. It notifies the incidents and
+// exports the results if configured.
+type Recorder struct {
+	// HandleResult handles a website result consumed from the checkers.
+	HandleResult kafka.HandleFn
+	// API serves the recorder HTTP API.
+	API *api.Handler
+
+	partitioner *domain.Partitioner
+	downsampler *domain.Downsampler
+	outbox      *notify.Outbox
+	exporters   []*export.Queue
+}
+
+// New creates a recorder on s and restores the incidents and flapping state.
+// The inserts are instrumented if m is not nil.
+func New(ctx context.Context, cfg Config, s store.Store, m *metrics.Recorder) (*Recorder, error) {
+	if ps, ok := s.(*pg.Store); ok {
+		ps.DetachExpiredPartitions = cfg.DetachPartitions
+	}
+
+	r := &Recorder{}
+
+	r.partitioner = &domain.Partitioner{
+		Interval:         time.Hour,
+		Ahead:            cfg.PartitionsAhead,
+		CreatePartitions: s.CreatePartitions,
+	}
+	if err := r.partitioner.Partition(ctx, time.Now()); err != nil {
+		return nil, fmt.Errorf("can't create partitions: %w", err)
+	}
+
+	evaluator := &domain.StatusEvaluator{
+		Window:              cfg.StatusWindow,
+		Quorum:              cfg.StatusQuorum,
+		FetchWebsiteResults: s.WebsiteResults,
+		SaveWebsiteStatus:   s.SaveWebsiteStatus,
+	}
+
+	incidents := &domain.IncidentEngine{
+		Threshold:          cfg.IncidentThreshold,
+		FlapWindow:         cfg.FlapWindow,
+		FlapStartThreshold: cfg.FlapStartThreshold,
+		FlapStopThreshold:  cfg.FlapStopThreshold,
+		SaveChanges:        s.SaveIncidentChanges,
+	}
+	openIncidents, err := s.OpenIncidents(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't get open incidents: %w", err)
+	}
+	incidents.Restore(openIncidents)
+	flapping, err := s.FlappingWebsites(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't get flapping websites: %w", err)
+	}
+	incidents.RestoreFlapping(flapping)
+
+	if cfg.NotifyConfigPath != "" {
+		receivers, err := notify.LoadReceivers(cfg.NotifyConfigPath)
+		if err != nil {
+			return nil, fmt.Errorf("can't load receivers: %w", err)
+		}
+
+		sender := &notify.Sender{Client: &http.Client{Timeout: 10 * time.Second}}
+		r.outbox = &notify.Outbox{
+			Receivers:                 receivers,
+			PollInterval:              cfg.NotifyPollInterval,
+			Backoff:                   cfg.NotifyBackoff,
+			MaxAttempts:               cfg.NotifyMaxAttempts,
+			PendingNotifications:      s.PendingNotifications,
+			MarkNotificationDelivered: s.MarkNotificationDelivered,
+			MarkNotificationFailed:    s.MarkNotificationFailed,
+			Send:                      sender.Send,
+		}
+		incidents.Notifications = r.outbox.Notifications
+	}
+
+	r.downsampler = &domain.Downsampler{
+		Interval:            cfg.RollupInterval,
+		Retention:           cfg.ResultsRetention,
+		RollupWatermark:     s.RollupWatermark,
+		Rollup:              s.Rollup,
+		DeleteResultsBefore: s.DeleteResultsBefore,
+	}
+
+	maintenance := &domain.MaintenanceFilter{MaintenanceWindows: s.MaintenanceWindows}
+	if err := maintenance.Reload(ctx); err != nil {
+		return nil, fmt.Errorf("can't load maintenance windows: %w", err)
+	}
+
+	r.API = api.NewHandler(s)
+	r.API.AdminToken = cfg.AdminToken
+	r.API.OnMaintenanceChange = maintenance.Reload
+	r.API.StatusGroups = cfg.StatusPageGroups
+
+	insert := kafka.HandleFn(s.InsertWebsiteResult)
+	if m != nil {
+		insert = m.InstrumentInsert(insert)
+	}
+
+	// Results are exported in the background, exporters failures don't prevent recording them
+	exportClient := &http.Client{Timeout: cfg.ExportTimeout}
+	if cfg.RemoteWriteURL != "" {
+		rw := &export.RemoteWriter{URL: cfg.RemoteWriteURL, Client: exportClient}
+		r.exporters = append(r.exporters, export.NewQueue("remote-write", cfg.ExportQueueSize, rw.Export))
+	}
+	if cfg.InfluxWriteURL != "" {
+		iw := &export.InfluxWriter{URL: cfg.InfluxWriteURL, Token: cfg.InfluxToken, Client: exportClient}
+		r.exporters = append(r.exporters, export.NewQueue("influx", cfg.ExportQueueSize, iw.Export))
+	}
+
+	handlers := []kafka.HandleFn{insert}
+	for _, q := range r.exporters {
+		q.BatchSize = cfg.ExportBatchSize
+		q.FlushInterval = cfg.ExportInterval
+		handlers = append(handlers, q.HandleResult)
+	}
+	handlers = append(handlers, r.downsampler.HandleResult, evaluator.HandleResult, incidents.HandleResult)
+	r.HandleResult = maintenance.Wrap(kafka.Chain(handlers...))
+
+	return r, nil
+}
+
+// Run runs the background tasks of the recorder until the context is done. It
+// returns once the queued results are exported.
+func (r *Recorder) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	run := func(fn func(ctx context.Context)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(ctx)
+		}()
+	}
+
+	run(r.downsampler.Run)
+	run(r.partitioner.Run)
+	if r.outbox != nil {
+		run(r.outbox.Run)
+	}
+	for _, q := range r.exporters {
+		run(q.Run)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,96 @@
+package recorder
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/store"
+)
+
+func TestRecorder(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+
+	s, err := store.Open("sqlite::memory:")
+	c.Assert(err, qt.IsNil)
+	defer s.Close()
+	c.Assert(s.CreateSchema(store.MigrationsPath("../../db", "sqlite::memory:")), qt.IsNil)
+
+	exported := make(chan string, 1)
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		blob, err := io.ReadAll(r.Body)
+		c.Check(err, qt.IsNil)
+		exported <- string(blob)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer svr.Close()
+
+	rec, err := New(ctx, Config{
+		StatusQuorum:      1,
+		StatusWindow:      time.Minute,
+		IncidentThreshold: 2,
+		FlapWindow:        20,
+		RollupInterval:    time.Hour,
+		InfluxWriteURL:    svr.URL + "/write?db=gpagdispo",
+		ExportTimeout:     time.Second,
+		ExportQueueSize:   10,
+		ExportBatchSize:   10,
+		ExportInterval:    time.Hour,
+	}, s, nil)
+	c.Assert(err, qt.IsNil)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		rec.Run(runCtx)
+		close(done)
+	}()
+
+	wp := domain.WebsiteParams{ID: "id1", URL: "http://foo.org", Method: "GET"}
+	now := time.Now().UTC().Truncate(time.Second)
+	for i := 0; i < 2; i++ {
+		err := rec.HandleResult(ctx, wp, domain.WebsiteResult{At: now.Add(time.Duration(i) * time.Second), Unreachable: true})
+		c.Assert(err, qt.IsNil)
+	}
+
+	results, err := s.WebsiteResults(ctx, "id1", now, now.Add(time.Minute))
+	c.Assert(err, qt.IsNil)
+	c.Assert(results, qt.HasLen, 2)
+
+	incidents, err := s.OpenIncidents(ctx)
+	c.Assert(err, qt.IsNil)
+	c.Assert(incidents, qt.HasLen, 1)
+	c.Assert(incidents[0].WebsiteID, qt.Equals, "id1")
+
+	cancel()
+	<-done
+	lines := <-exported
+	c.Assert(strings.Count(lines, "\n"), qt.Equals, 2, qt.Commentf("the queued results are exported on shutdown"))
+}
+
+func TestConfigValidate(t *testing.T) {
+	c := qt.New(t)
+
+	cfg := Config{
+		StatusQuorum:       1,
+		StatusWindow:       time.Minute,
+		RollupInterval:     5 * time.Minute,
+		NotifyPollInterval: 5 * time.Second,
+		ExportInterval:     5 * time.Second,
+	}
+	c.Assert(cfg.Validate(), qt.IsNil)
+
+	for _, window := range []time.Duration{0, -time.Minute} {
+		cfg := cfg
+		cfg.StatusWindow = window
+		c.Assert(cfg.Validate(), qt.ErrorMatches, "STATUS_WINDOW must be positive: .* provided")
+	}
+}
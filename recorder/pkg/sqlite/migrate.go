@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migration is a version of the schema read from <version>_<name>.up.sql
+// and <version>_<name>.down.sql files, as golang-migrate does.
+type migration struct {
+	Version  int64
+	Up, Down string
+}
+
+// readMigrations returns the migrations in sourcePath sorted by version.
+func readMigrations(sourcePath string) ([]migration, error) {
+	files, err := ioutil.ReadDir(sourcePath)
+	if err != nil {
+		return nil, fmt.Errorf("can't read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*migration)
+	for _, f := range files {
+		name := f.Name()
+		var up bool
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			up = true
+		case strings.HasSuffix(name, ".down.sql"):
+		default:
+			continue
+		}
+
+		version, err := strconv.ParseInt(strings.SplitN(name, "_", 2)[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse version of migration %s: %w", name, err)
+		}
+		blob, err := ioutil.ReadFile(filepath.Join(sourcePath, name))
+		if err != nil {
+			return nil, fmt.Errorf("can't read migration %s: %w", name, err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{Version: version}
+			byVersion[version] = m
+		}
+		if up {
+			m.Up = string(blob)
+		} else {
+			m.Down = string(blob)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// appliedVersions returns the versions of the applied migrations.
+func (s *Store) appliedVersions() (map[int64]bool, error) {
+	_, err := s.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return nil, fmt.Errorf("can't create migrations table: %w", err)
+	}
+
+	var versions []int64
+	if err := s.DB.Select(&versions, `SELECT version FROM schema_migrations`); err != nil {
+		return nil, fmt.Errorf("can't get applied migrations: %w", err)
+	}
+
+	applied := make(map[int64]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+
+	return applied, nil
+}
+
+// applyMigration runs the migration script and records the version in a transaction.
+func (s *Store) applyMigration(version int64, script string, up bool) error {
+	tx, err := s.DB.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(script); err != nil {
+		return fmt.Errorf("can't run migration %d: %w", version, err)
+	}
+
+	record := `INSERT INTO schema_migrations(version) VALUES (?)`
+	if !up {
+		record = `DELETE FROM schema_migrations WHERE version = ?`
+	}
+	if _, err := tx.Exec(record, version); err != nil {
+		return fmt.Errorf("can't record migration %d: %w", version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("can't commit tx: %w", err)
+	}
+
+	return nil
+}
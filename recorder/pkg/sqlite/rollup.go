@@ -0,0 +1,321 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+var rollupTables = map[domain.RollupPeriod]string{
+	domain.RollupHourly: "websites_results_hourly",
+	domain.RollupDaily:  "websites_results_daily",
+}
+
+// RollupWatermark returns the start of the latest hourly rollup or, if there are none,
+// the time of the oldest result. It returns nil if there are no results.
+func (s *Store) RollupWatermark(ctx context.Context) (*time.Time, error) {
+	for _, query := range []string{
+		`SELECT bucket_start FROM websites_results_hourly ORDER BY bucket_start DESC LIMIT 1`,
+		`SELECT at FROM websites_results ORDER BY at LIMIT 1`,
+	} {
+		var watermark time.Time
+		err := s.DB.GetContext(ctx, &watermark, query)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("can't get rollup watermark: %w", err)
+		}
+
+		watermark = watermark.UTC()
+		return &watermark, nil
+	}
+
+	return nil, nil
+}
+
+// resultSample is the part of a result aggregated by the rollups and uptime stats.
+type resultSample struct {
+	WebsiteID string    `db:"website_id"`
+	At        time.Time `db:"at"`
+	Elapsed   float64   `db:"elapsed_time"`
+	Status    *int      `db:"status"`
+	Failed    bool      `db:"failed"`
+}
+
+// samples returns the results not in maintenance between from (inclusive) and to (exclusive)
+// sorted by website and time. An empty websiteID returns the results of every website.
+func (s *Store) samples(ctx context.Context, websiteID string, from, to time.Time) ([]resultSample, error) {
+	var samples []resultSample
+	err := s.DB.SelectContext(ctx, &samples, `
+                   SELECT website_id, at, COALESCE(elapsed_time, 0) AS elapsed_time, status, `+failedResultSQL+` AS failed
+                   FROM websites_results
+                   WHERE (? = '' OR website_id = ?) AND at >= ? AND at < ? AND NOT in_maintenance
+                   ORDER BY website_id, at`,
+		websiteID, websiteID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
+
+// Rollup aggregates the results between from (inclusive) and to (exclusive) per website and period,
+// replacing the existing rollups. from must be aligned to the period.
+//
+// SQLite has no percentile aggregate, so the results are aggregated here.
+func (s *Store) Rollup(ctx context.Context, period domain.RollupPeriod, from, to time.Time) error {
+	table, ok := rollupTables[period]
+	if !ok {
+		return fmt.Errorf("unknown rollup period %q", period)
+	}
+
+	samples, err := s.samples(ctx, "", from, to)
+	if err != nil {
+		return fmt.Errorf("can't roll up results: %w", err)
+	}
+
+	var rollups []domain.Rollup
+	for len(samples) > 0 {
+		websiteID, start := samples[0].WebsiteID, samples[0].At.Truncate(period.Duration())
+		n := 1
+		for n < len(samples) && samples[n].WebsiteID == websiteID && samples[n].At.Truncate(period.Duration()).Equal(start) {
+			n++
+		}
+		rollups = append(rollups, newRollup(websiteID, start, samples[:n]))
+		samples = samples[n:]
+	}
+
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, r := range rollups {
+		codes, err := json.Marshal(r.StatusCodes)
+		if err != nil {
+			return fmt.Errorf("can't encode status codes: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+                   INSERT INTO `+table+`(website_id, bucket_start, checks, failures, min_elapsed, avg_elapsed, max_elapsed,
+                                         p50_elapsed, p95_elapsed, p99_elapsed, status_codes) VALUES
+                   (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+                   ON CONFLICT (website_id, bucket_start) DO UPDATE
+                   SET checks = excluded.checks, failures = excluded.failures,
+                       min_elapsed = excluded.min_elapsed, avg_elapsed = excluded.avg_elapsed, max_elapsed = excluded.max_elapsed,
+                       p50_elapsed = excluded.p50_elapsed, p95_elapsed = excluded.p95_elapsed, p99_elapsed = excluded.p99_elapsed,
+                       status_codes = excluded.status_codes`,
+			r.WebsiteID, r.From, r.Checks, r.Failures, r.MinElapsed, r.AvgElapsed, r.MaxElapsed,
+			r.P50, r.P95, r.P99, string(codes))
+		if err != nil {
+			return fmt.Errorf("can't roll up results: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("can't commit tx: %w", err)
+	}
+
+	return nil
+}
+
+// newRollup aggregates the samples of a website starting at from.
+func newRollup(websiteID string, from time.Time, samples []resultSample) domain.Rollup {
+	r := domain.Rollup{
+		WebsiteID:   websiteID,
+		From:        from,
+		Checks:      len(samples),
+		MinElapsed:  math.Inf(1),
+		MaxElapsed:  math.Inf(-1),
+		StatusCodes: make(map[int]int),
+	}
+
+	elapsed := make([]float64, len(samples))
+	var sum float64
+	for i, sp := range samples {
+		if sp.Failed {
+			r.Failures++
+		}
+		if sp.Status != nil {
+			r.StatusCodes[*sp.Status]++
+		}
+		elapsed[i] = sp.Elapsed
+		sum += sp.Elapsed
+		r.MinElapsed = math.Min(r.MinElapsed, sp.Elapsed)
+		r.MaxElapsed = math.Max(r.MaxElapsed, sp.Elapsed)
+	}
+	r.AvgElapsed = sum / float64(len(samples))
+
+	sort.Float64s(elapsed)
+	r.P50 = percentile(elapsed, 0.5)
+	r.P95 = percentile(elapsed, 0.95)
+	r.P99 = percentile(elapsed, 0.99)
+
+	return r
+}
+
+// percentile interpolates the percentile p of the sorted values as PostgreSQL percentile_cont does.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	pos := p * float64(len(sorted)-1)
+	lower := math.Floor(pos)
+	i := int(lower)
+	if i+1 >= len(sorted) {
+		return sorted[i]
+	}
+	return sorted[i] + (pos-lower)*(sorted[i+1]-sorted[i])
+}
+
+// Rollups returns the rollups of a website whose bucket starts between from (inclusive) and to (exclusive).
+func (s *Store) Rollups(ctx context.Context, websiteID string, period domain.RollupPeriod, from, to time.Time) ([]domain.Rollup, error) {
+	return s.rollups(ctx, websiteID, period, from, to)
+}
+
+// rollups returns the rollups sorted by website and bucket. An empty websiteID returns the rollups of every website.
+func (s *Store) rollups(ctx context.Context, websiteID string, period domain.RollupPeriod, from, to time.Time) ([]domain.Rollup, error) {
+	table, ok := rollupTables[period]
+	if !ok {
+		return nil, fmt.Errorf("unknown rollup period %q", period)
+	}
+
+	var rows []struct {
+		domain.Rollup
+		StatusCodes string `db:"status_codes"`
+	}
+	err := s.DB.SelectContext(ctx, &rows, `
+                   SELECT website_id, bucket_start, checks, failures, min_elapsed, avg_elapsed, max_elapsed,
+                          p50_elapsed, p95_elapsed, p99_elapsed, status_codes
+                   FROM `+table+`
+                   WHERE (? = '' OR website_id = ?) AND bucket_start >= ? AND bucket_start < ?
+                   ORDER BY website_id, bucket_start`,
+		websiteID, websiteID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("can't get rollups: %w", err)
+	}
+
+	rollups := make([]domain.Rollup, len(rows))
+	for i, r := range rows {
+		rollups[i] = r.Rollup
+		rollups[i].From = r.From.UTC()
+		if err := json.Unmarshal([]byte(r.StatusCodes), &rollups[i].StatusCodes); err != nil {
+			return nil, fmt.Errorf("can't decode status codes: %w", err)
+		}
+	}
+
+	return rollups, nil
+}
+
+// Uptime aggregates the results of a website between from (inclusive) and to (exclusive) in
+// buckets of the given size aligned to Unix epoch. A zero bucket aggregates the whole period.
+// Results in maintenance are excluded and empty buckets are omitted.
+//
+// Periods longer than domain.RollupThreshold are read from the hourly or daily rollups if the bucket
+// is a multiple of them. In that case, from is truncated to the rollup period and the latency
+// percentiles of several rollups are averaged weighted by their number of checks.
+func (s *Store) Uptime(ctx context.Context, websiteID string, from, to time.Time, bucket time.Duration) ([]domain.UptimeStats, error) {
+	stats, err := s.uptime(ctx, websiteID, from, to, bucket)
+	if err != nil {
+		return nil, err
+	}
+	return stats[websiteID], nil
+}
+
+// UptimeByWebsite aggregates the results of every website as Uptime does, in a single query.
+func (s *Store) UptimeByWebsite(ctx context.Context, from, to time.Time, bucket time.Duration) (map[string][]domain.UptimeStats, error) {
+	return s.uptime(ctx, "", from, to, bucket)
+}
+
+// uptime aggregates the results per website. An empty websiteID aggregates every website.
+func (s *Store) uptime(ctx context.Context, websiteID string, from, to time.Time, bucket time.Duration) (map[string][]domain.UptimeStats, error) {
+	period, useRollups := domain.RollupPeriodFor(to.Sub(from), bucket)
+	if useRollups {
+		from = from.Truncate(period.Duration())
+	}
+
+	origin := time.Unix(0, 0).UTC()
+	if bucket <= 0 {
+		bucket = to.Sub(from)
+		origin = time.Unix(from.Unix(), 0).UTC()
+	}
+	bucketSecs := bucket.Truncate(time.Second)
+	if bucketSecs <= 0 {
+		return nil, fmt.Errorf("bucket must be at least one second: %s provided", bucket)
+	}
+	bucketStart := func(t time.Time) time.Time {
+		n := math.Floor(float64(t.Sub(origin)) / float64(bucketSecs))
+		return origin.Add(time.Duration(n) * bucketSecs)
+	}
+
+	stats := make(map[string][]domain.UptimeStats)
+	add := func(websiteID string, st domain.UptimeStats) {
+		st.To = st.From.Add(bucket)
+		st.Uptime = domain.Uptime(st.Checks, st.Failures)
+		stats[websiteID] = append(stats[websiteID], st)
+	}
+	if useRollups {
+		rollups, err := s.rollups(ctx, websiteID, period, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("can't get uptime: %w", err)
+		}
+		for len(rollups) > 0 {
+			id, start := rollups[0].WebsiteID, bucketStart(rollups[0].From)
+			n := 1
+			for n < len(rollups) && rollups[n].WebsiteID == id && bucketStart(rollups[n].From).Equal(start) {
+				n++
+			}
+			add(id, mergeRollups(start, rollups[:n]))
+			rollups = rollups[n:]
+		}
+	} else {
+		samples, err := s.samples(ctx, websiteID, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("can't get uptime: %w", err)
+		}
+		for len(samples) > 0 {
+			id, start := samples[0].WebsiteID, bucketStart(samples[0].At)
+			n := 1
+			for n < len(samples) && samples[n].WebsiteID == id && bucketStart(samples[n].At).Equal(start) {
+				n++
+			}
+			r := newRollup(id, start, samples[:n])
+			add(id, domain.UptimeStats{
+				From: start, Checks: r.Checks, Failures: r.Failures, P50: r.P50, P95: r.P95, P99: r.P99,
+			})
+			samples = samples[n:]
+		}
+	}
+
+	return stats, nil
+}
+
+// mergeRollups aggregates the rollups of a bucket averaging their percentiles weighted by
+// their number of checks.
+func mergeRollups(from time.Time, rollups []domain.Rollup) domain.UptimeStats {
+	stats := domain.UptimeStats{From: from}
+	for _, r := range rollups {
+		stats.Checks += r.Checks
+		stats.Failures += r.Failures
+		stats.P50 += r.P50 * float64(r.Checks)
+		stats.P95 += r.P95 * float64(r.Checks)
+		stats.P99 += r.P99 * float64(r.Checks)
+	}
+	if stats.Checks > 0 {
+		stats.P50 /= float64(stats.Checks)
+		stats.P95 /= float64(stats.Checks)
+		stats.P99 /= float64(stats.Checks)
+	}
+
+	return stats
+}
@@ -0,0 +1,815 @@
+// Package sqlite stores the website results in a SQLite database with a pure Go driver,
+// so the recorder can run without a database server.
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	// Register the pure Go SQLite driver as "sqlite"
+	_ "modernc.org/sqlite"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+)
+
+const tracerName = "github.com/sixstone-qq/gpagdispo/recorder/pkg/sqlite"
+
+// Store holds the DB connection.
+//
+// Timestamps are stored as text, so every time is converted to UTC to be compared
+// in the right order.
+type Store struct {
+	DB *sqlx.DB
+}
+
+// NewStore opens the SQLite database at path, creating it if it does not exist.
+// ":memory:" opens an in-memory database.
+func NewStore(path string) (*Store, error) {
+	db, err := sqlx.Connect("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to DB: %w", err)
+	}
+
+	// A single connection serializes the writes, which SQLite does anyway, and
+	// keeps the in-memory databases and the pragmas of the connection alive.
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`PRAGMA foreign_keys = ON; PRAGMA busy_timeout = 5000; PRAGMA journal_mode = WAL`)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("can't set up DB: %w", err)
+	}
+
+	return &Store{DB: db}, nil
+}
+
+// CreateSchema applies the migrations from sourcePath which are not applied yet.
+func (s *Store) CreateSchema(sourcePath string) error {
+	migrations, err := readMigrations(sourcePath)
+	if err != nil {
+		return err
+	}
+	applied, err := s.appliedVersions()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		if err := s.applyMigration(m.Version, m.Up, true); err != nil {
+			return fmt.Errorf("can't perform up migrations: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// DropSchema reverts the applied migrations from sourcePath.
+func (s *Store) DropSchema(sourcePath string) error {
+	migrations, err := readMigrations(sourcePath)
+	if err != nil {
+		return err
+	}
+	applied, err := s.appliedVersions()
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if !applied[m.Version] {
+			continue
+		}
+		if err := s.applyMigration(m.Version, m.Down, false); err != nil {
+			return fmt.Errorf("can't perform down migrations: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// InsertWebsiteResult inserts the website and website_results in the respective tables.
+func (s *Store) InsertWebsiteResult(ctx context.Context, wp domain.WebsiteParams, wr domain.WebsiteResult) error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "insert website result",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "sqlite"),
+			attribute.String("db.sql.table", "websites_results"),
+			attribute.String("website.id", wp.ID),
+		))
+	defer span.End()
+
+	err := s.insertWebsiteResult(ctx, wp, wr)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "can't insert website result")
+	}
+	return err
+}
+
+func (s *Store) insertWebsiteResult(ctx context.Context, wp domain.WebsiteParams, wr domain.WebsiteResult) error {
+	tags, err := encodeTags(wp.Tags)
+	if err != nil {
+		return err
+	}
+	details, err := encodeDetails(wr)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `
+                    INSERT INTO websites(id, url, method, match_regexp, tags) VALUES
+                    (?, ?, ?, ?, ?)
+                    ON CONFLICT (id) DO UPDATE SET tags = excluded.tags
+                    WHERE websites.tags IS NOT excluded.tags`,
+		wp.ID, wp.URL, wp.Method, wp.MatchRegexp, tags)
+	if err != nil {
+		return fmt.Errorf("can't insert website: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("can't get number of affected rows: %w", err)
+	}
+	if n == 1 {
+		log.Info().Msgf("Added or updated website %s", wp.URL)
+	}
+
+	res, err = tx.ExecContext(ctx, `
+                   INSERT INTO websites_results(website_id, elapsed_time, status, matched, unreachable, at, location, checker_id, ip, in_maintenance, probe, error, details) VALUES
+                   (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+                   ON CONFLICT DO NOTHING`,
+		wp.ID, wr.Elapsed.Seconds(), wr.Status, wr.Matched, wr.Unreachable, wr.At.UTC(), wr.Location, wr.CheckerID, wr.IP, wr.InMaintenance,
+		probeOrDefault(wr), wr.Error, details)
+	if err != nil {
+		return fmt.Errorf("can't insert website result: %w", err)
+	}
+	n, err = res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("can't get number of affected rows from website results: %w", err)
+	}
+	if n == 1 {
+		log.Info().Msgf("Added website result from %s", wp.URL)
+	}
+
+	for i, sr := range wr.Steps {
+		_, err = tx.ExecContext(ctx, `
+                   INSERT INTO websites_step_results(website_id, location, checker_id, ip, at, position, name, elapsed_time, status, matched, error, body_size, body_truncated, content_type) VALUES
+                   (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+                   ON CONFLICT DO NOTHING`,
+			wp.ID, wr.Location, wr.CheckerID, wr.IP, wr.At.UTC(), i, sr.Name, sr.Elapsed.Seconds(), sr.Status, sr.Matched, sr.Error,
+			sr.BodySize, sr.BodyTruncated, sr.ContentType)
+		if err != nil {
+			return fmt.Errorf("can't insert step result: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("can't commit tx: %w", err)
+	}
+
+	return nil
+}
+
+// failedResultSQL is the SQL condition equivalent to domain.WebsiteResult.Failed.
+const failedResultSQL = `(unreachable OR error IS NOT NULL OR matched IS FALSE OR (probe = 'http' AND (status IS NULL OR status >= 400)))`
+
+// LocationStats aggregates the results of a website per location between from (inclusive) and to (exclusive).
+// An empty location returns the stats of every location. Results in maintenance are excluded.
+func (s *Store) LocationStats(ctx context.Context, websiteID, location string, from, to time.Time) ([]domain.LocationStats, error) {
+	var stats []domain.LocationStats
+	err := s.DB.SelectContext(ctx, &stats, `
+                   SELECT location,
+                          COUNT(*) AS checks,
+                          SUM(`+failedResultSQL+`) AS failures,
+                          COALESCE(AVG(elapsed_time), 0) AS avg_elapsed
+                   FROM websites_results
+                   WHERE website_id = ? AND (? = '' OR location = ?) AND at >= ? AND at < ?
+                         AND NOT in_maintenance
+                   GROUP BY location
+                   ORDER BY location`,
+		websiteID, location, location, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("can't get location stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// WebsiteResults returns the results of a website between from (inclusive) and to (exclusive) sorted by time.
+func (s *Store) WebsiteResults(ctx context.Context, websiteID string, from, to time.Time) ([]domain.WebsiteResult, error) {
+	var rows []websiteResultRow
+	err := s.DB.SelectContext(ctx, &rows, `
+                   SELECT elapsed_time, status, matched, unreachable, at, location, checker_id, ip, in_maintenance, probe, error, details
+                   FROM websites_results
+                   WHERE website_id = ? AND at >= ? AND at < ?
+                   ORDER BY at, location, checker_id, ip`,
+		websiteID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("can't get website results: %w", err)
+	}
+
+	results := make([]domain.WebsiteResult, len(rows))
+	for i, r := range rows {
+		if results[i], err = r.toDomain(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.attachSteps(ctx, websiteID, results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// SaveWebsiteStatus inserts or updates the website status of a time window.
+func (s *Store) SaveWebsiteStatus(ctx context.Context, ws domain.WebsiteStatus) error {
+	_, err := s.DB.ExecContext(ctx, `
+                   INSERT INTO website_status(website_id, window_start, window_end, verdict, locations, failed_locations) VALUES
+                   (?, ?, ?, ?, ?, ?)
+                   ON CONFLICT (website_id, window_start) DO UPDATE SET
+                      window_end = excluded.window_end,
+                      verdict = excluded.verdict,
+                      locations = excluded.locations,
+                      failed_locations = excluded.failed_locations`,
+		ws.WebsiteID, ws.WindowStart.UTC(), ws.WindowEnd.UTC(), ws.Verdict, ws.Locations, ws.FailedLocations)
+	if err != nil {
+		return fmt.Errorf("can't save website status: %w", err)
+	}
+
+	return nil
+}
+
+// SaveIncidentChanges stores the incident changes, setting the ID of the opened incident,
+// and enqueues the notifications of their events in a single transaction.
+func (s *Store) SaveIncidentChanges(ctx context.Context, ch domain.IncidentChanges, notifications domain.NotificationsFunc) error {
+	tx, err := s.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if in := ch.Opened; in != nil {
+		res, err := tx.ExecContext(ctx, `
+                   INSERT INTO incidents(website_id, started_at, ended_at, cause, failed_checks) VALUES
+                   (?, ?, ?, ?, ?)`,
+			in.WebsiteID, in.StartedAt.UTC(), utcPtr(in.EndedAt), in.Cause, in.FailedChecks)
+		if err != nil {
+			return fmt.Errorf("can't insert incident: %w", err)
+		}
+		in.ID, err = res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("can't get incident ID: %w", err)
+		}
+	}
+	if in := ch.Updated; in != nil {
+		_, err := tx.ExecContext(ctx, `
+                   UPDATE incidents
+                   SET ended_at = ?, failed_checks = ?
+                   WHERE id = ?`,
+			utcPtr(in.EndedAt), in.FailedChecks, in.ID)
+		if err != nil {
+			return fmt.Errorf("can't update incident: %w", err)
+		}
+	}
+	if fs := ch.Flapping; fs != nil {
+		_, err := tx.ExecContext(ctx, `
+                   INSERT INTO websites_flapping(website_id, flapping, score, since) VALUES
+                   (?, ?, ?, ?)
+                   ON CONFLICT (website_id) DO UPDATE SET
+                      flapping = excluded.flapping,
+                      score = excluded.score,
+                      since = excluded.since`,
+			fs.WebsiteID, fs.Flapping, fs.Score, fs.Since.UTC())
+		if err != nil {
+			return fmt.Errorf("can't save flapping state: %w", err)
+		}
+	}
+
+	now := time.Now().UTC()
+	for _, ev := range ch.Events {
+		if notifications == nil {
+			break
+		}
+		ns, err := notifications(ev)
+		if err != nil {
+			return fmt.Errorf("can't get notifications of incident %s: %w", ev.Kind, err)
+		}
+		for _, n := range ns {
+			_, err := tx.ExecContext(ctx, `
+                   INSERT INTO notifications(receiver, payload, next_attempt_at, created_at) VALUES
+                   (?, ?, ?, ?)`,
+				n.Receiver, string(n.Payload), now, now)
+			if err != nil {
+				return fmt.Errorf("can't enqueue notification: %w", err)
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("can't commit tx: %w", err)
+	}
+
+	return nil
+}
+
+// OpenIncidents returns the incidents which are not resolved yet.
+func (s *Store) OpenIncidents(ctx context.Context) ([]domain.Incident, error) {
+	var incidents []domain.Incident
+	err := s.DB.SelectContext(ctx, &incidents, `
+                   SELECT id, website_id, started_at, ended_at, cause, failed_checks
+                   FROM incidents
+                   WHERE ended_at IS NULL
+                   ORDER BY started_at`)
+	if err != nil {
+		return nil, fmt.Errorf("can't get open incidents: %w", err)
+	}
+
+	return incidents, nil
+}
+
+// Incidents returns the open incidents and the ones resolved since the given time
+// from the latest to the oldest.
+func (s *Store) Incidents(ctx context.Context, since time.Time) ([]domain.Incident, error) {
+	var incidents []domain.Incident
+	err := s.DB.SelectContext(ctx, &incidents, `
+                   SELECT id, website_id, started_at, ended_at, cause, failed_checks
+                   FROM incidents
+                   WHERE ended_at IS NULL OR ended_at >= ?
+                   ORDER BY started_at DESC`, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("can't get incidents: %w", err)
+	}
+
+	return incidents, nil
+}
+
+// FlappingWebsites returns the flapping state of the websites which are currently flapping.
+func (s *Store) FlappingWebsites(ctx context.Context) ([]domain.FlappingState, error) {
+	var states []domain.FlappingState
+	err := s.DB.SelectContext(ctx, &states, `
+                   SELECT website_id, flapping, score, since
+                   FROM websites_flapping
+                   WHERE flapping
+                   ORDER BY since`)
+	if err != nil {
+		return nil, fmt.Errorf("can't get flapping websites: %w", err)
+	}
+
+	return states, nil
+}
+
+// PendingNotifications returns up to limit notifications to deliver at now.
+func (s *Store) PendingNotifications(ctx context.Context, now time.Time, limit int) ([]domain.Notification, error) {
+	var notifications []domain.Notification
+	err := s.DB.SelectContext(ctx, &notifications, `
+                   SELECT id, receiver, payload, attempts, next_attempt_at, last_error
+                   FROM notifications
+                   WHERE delivered_at IS NULL AND next_attempt_at <= ?
+                   ORDER BY next_attempt_at
+                   LIMIT ?`,
+		now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("can't get pending notifications: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// MarkNotificationDelivered sets the notification as delivered.
+func (s *Store) MarkNotificationDelivered(ctx context.Context, id int64, at time.Time) error {
+	_, err := s.DB.ExecContext(ctx, `
+                   UPDATE notifications
+                   SET delivered_at = ?, next_attempt_at = NULL
+                   WHERE id = ?`,
+		at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("can't mark notification as delivered: %w", err)
+	}
+
+	return nil
+}
+
+// MarkNotificationFailed updates the attempts of a failed notification.
+func (s *Store) MarkNotificationFailed(ctx context.Context, n domain.Notification) error {
+	_, err := s.DB.ExecContext(ctx, `
+                   UPDATE notifications
+                   SET attempts = ?, next_attempt_at = ?, last_error = ?
+                   WHERE id = ?`,
+		n.Attempts, utcPtr(n.NextAttemptAt), n.LastError, n.ID)
+	if err != nil {
+		return fmt.Errorf("can't mark notification as failed: %w", err)
+	}
+
+	return nil
+}
+
+// CreateMaintenanceWindow inserts a maintenance window setting its ID.
+func (s *Store) CreateMaintenanceWindow(ctx context.Context, mw *domain.MaintenanceWindow) error {
+	res, err := s.DB.ExecContext(ctx, `
+                   INSERT INTO maintenance_windows(website_id, tag, starts_at, ends_at, cron, duration, reason) VALUES
+                   (?, ?, ?, ?, ?, ?, ?)`,
+		mw.WebsiteID, mw.Tag, utcPtr(mw.StartsAt), utcPtr(mw.EndsAt), mw.Cron, mw.Duration, mw.Reason)
+	if err != nil {
+		return fmt.Errorf("can't insert maintenance window: %w", err)
+	}
+
+	mw.ID, err = res.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("can't get maintenance window ID: %w", err)
+	}
+
+	return nil
+}
+
+// MaintenanceWindows returns every maintenance window.
+func (s *Store) MaintenanceWindows(ctx context.Context) ([]domain.MaintenanceWindow, error) {
+	var windows []domain.MaintenanceWindow
+	err := s.DB.SelectContext(ctx, &windows, `
+                   SELECT id, website_id, tag, starts_at, ends_at, cron, duration, reason
+                   FROM maintenance_windows
+                   ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("can't get maintenance windows: %w", err)
+	}
+
+	return windows, nil
+}
+
+// DeleteMaintenanceWindow deletes a maintenance window. It returns false if it does not exist.
+func (s *Store) DeleteMaintenanceWindow(ctx context.Context, id int64) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM maintenance_windows WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("can't delete maintenance window: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("can't get number of affected rows: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+// Websites returns every website sorted by URL.
+func (s *Store) Websites(ctx context.Context) ([]domain.WebsiteParams, error) {
+	var rows []websiteRow
+	err := s.DB.SelectContext(ctx, &rows, `
+                   SELECT id, url, method, match_regexp, tags
+                   FROM websites
+                   ORDER BY url, id`)
+	if err != nil {
+		return nil, fmt.Errorf("can't get websites: %w", err)
+	}
+
+	websites := make([]domain.WebsiteParams, len(rows))
+	for i, r := range rows {
+		if websites[i], err = r.toDomain(); err != nil {
+			return nil, err
+		}
+	}
+
+	return websites, nil
+}
+
+// QueryWebsiteResults returns a page of the results of a website from the latest to the oldest.
+func (s *Store) QueryWebsiteResults(ctx context.Context, q domain.ResultsQuery) ([]domain.WebsiteResult, error) {
+	var rows []websiteResultRow
+	err := s.DB.SelectContext(ctx, &rows, `
+                   SELECT elapsed_time, status, matched, unreachable, at, location, checker_id, ip, in_maintenance, probe, error, details
+                   FROM websites_results
+                   WHERE website_id = ? AND at >= ? AND at < ? AND (? = '' OR location = ?)
+                   ORDER BY at DESC, location, checker_id, ip
+                   LIMIT ? OFFSET ?`,
+		q.WebsiteID, q.From.UTC(), q.To.UTC(), q.Location, q.Location, q.Limit, q.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("can't query website results: %w", err)
+	}
+
+	results := make([]domain.WebsiteResult, len(rows))
+	for i, r := range rows {
+		if results[i], err = r.toDomain(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.attachSteps(ctx, q.WebsiteID, results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// attachSteps sets the steps of the multi-step check results of a website.
+func (s *Store) attachSteps(ctx context.Context, websiteID string, results []domain.WebsiteResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	from, to := results[0].At, results[0].At
+	for _, r := range results {
+		if r.At.Before(from) {
+			from = r.At
+		}
+		if r.At.After(to) {
+			to = r.At
+		}
+	}
+
+	var rows []stepResultRow
+	err := s.DB.SelectContext(ctx, &rows, `
+                   SELECT location, checker_id, ip, at, name, elapsed_time, status, matched, error, body_size, body_truncated, content_type
+                   FROM websites_step_results
+                   WHERE website_id = ? AND at >= ? AND at <= ?
+                   ORDER BY at, location, checker_id, ip, position`,
+		websiteID, from.UTC(), to.UTC())
+	if err != nil {
+		return fmt.Errorf("can't get step results: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	type resultKey struct {
+		location  string
+		checkerID string
+		ip        string
+		at        int64
+	}
+	steps := make(map[resultKey][]domain.StepResult)
+	for _, r := range rows {
+		k := resultKey{r.Location, r.CheckerID, r.IP, r.At.UnixNano()}
+		steps[k] = append(steps[k], r.toDomain())
+	}
+	for i := range results {
+		results[i].Steps = steps[resultKey{results[i].Location, results[i].CheckerID, results[i].IP, results[i].At.UnixNano()}]
+		results[i].SetFailedStep()
+	}
+
+	return nil
+}
+
+// LatestStatuses returns the latest result and verdict of every website sorted by URL.
+func (s *Store) LatestStatuses(ctx context.Context) ([]domain.WebsiteLatestStatus, error) {
+	var rows []struct {
+		websiteRow
+		Elapsed       *float64   `db:"elapsed_time"`
+		Status        *int       `db:"status"`
+		Matched       *bool      `db:"matched"`
+		Unreachable   *bool      `db:"unreachable"`
+		At            *time.Time `db:"at"`
+		Location      *string    `db:"location"`
+		CheckerID     *string    `db:"checker_id"`
+		IP            *string    `db:"ip"`
+		InMaintenance *bool      `db:"in_maintenance"`
+		Probe         *string    `db:"probe"`
+		Error         *string    `db:"error"`
+		Details       *string    `db:"details"`
+		Verdict       *string    `db:"verdict"`
+	}
+	err := s.DB.SelectContext(ctx, &rows, `
+                   SELECT w.id, w.url, w.method, w.match_regexp, w.tags,
+                          r.elapsed_time, r.status, r.matched, r.unreachable, r.at, r.location, r.checker_id, r.ip, r.in_maintenance,
+                          r.probe, r.error, r.details,
+                          (SELECT verdict
+                           FROM website_status
+                           WHERE website_id = w.id
+                           ORDER BY window_start DESC
+                           LIMIT 1) AS verdict
+                   FROM websites w
+                   LEFT JOIN websites_results r ON r.rowid = (
+                        SELECT rowid
+                        FROM websites_results
+                        WHERE website_id = w.id
+                        ORDER BY at DESC
+                        LIMIT 1)
+                   ORDER BY w.url, w.id`)
+	if err != nil {
+		return nil, fmt.Errorf("can't get latest statuses: %w", err)
+	}
+
+	statuses := make([]domain.WebsiteLatestStatus, len(rows))
+	for i, r := range rows {
+		if statuses[i].Website, err = r.websiteRow.toDomain(); err != nil {
+			return nil, err
+		}
+		if r.At != nil {
+			wr, err := websiteResultRow{
+				Elapsed:       *r.Elapsed,
+				Status:        r.Status,
+				Matched:       r.Matched,
+				Unreachable:   *r.Unreachable,
+				At:            *r.At,
+				Location:      *r.Location,
+				CheckerID:     *r.CheckerID,
+				IP:            *r.IP,
+				InMaintenance: *r.InMaintenance,
+				Probe:         *r.Probe,
+				Error:         r.Error,
+				Details:       r.Details,
+			}.toDomain()
+			if err != nil {
+				return nil, err
+			}
+			statuses[i].Result = &wr
+		}
+		if r.Verdict != nil {
+			v := domain.Verdict(*r.Verdict)
+			statuses[i].Verdict = &v
+		}
+	}
+
+	return statuses, nil
+}
+
+// CreatePartitions is a no-op as SQLite tables are not partitioned. It allows to use
+// the store with domain.Partitioner.
+func (s *Store) CreatePartitions(ctx context.Context, from, to time.Time) error {
+	return nil
+}
+
+// DeleteResultsBefore deletes the raw results, and their steps, recorded before the given time.
+// It returns the number of deleted rows.
+func (s *Store) DeleteResultsBefore(ctx context.Context, before time.Time) (int64, error) {
+	if _, err := s.DB.ExecContext(ctx, `DELETE FROM websites_step_results WHERE at < ?`, before.UTC()); err != nil {
+		return 0, fmt.Errorf("can't delete step results: %w", err)
+	}
+
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM websites_results WHERE at < ?`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("can't delete results: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("can't get number of affected rows: %w", err)
+	}
+
+	return n, nil
+}
+
+// websiteRow maps a row from websites table.
+type websiteRow struct {
+	ID          string  `db:"id"`
+	URL         string  `db:"url"`
+	Method      string  `db:"method"`
+	MatchRegexp *string `db:"match_regexp"`
+	// Tags is a JSON array
+	Tags string `db:"tags"`
+}
+
+func (r websiteRow) toDomain() (domain.WebsiteParams, error) {
+	wp := domain.WebsiteParams{
+		ID:          r.ID,
+		URL:         r.URL,
+		Method:      r.Method,
+		MatchRegexp: r.MatchRegexp,
+	}
+	if err := json.Unmarshal([]byte(r.Tags), &wp.Tags); err != nil {
+		return wp, fmt.Errorf("can't decode tags: %w", err)
+	}
+	return wp, nil
+}
+
+// encodeTags returns the tags as a JSON array.
+func encodeTags(tags []string) (string, error) {
+	if tags == nil {
+		tags = []string{}
+	}
+	blob, err := json.Marshal(tags)
+	if err != nil {
+		return "", fmt.Errorf("can't encode tags: %w", err)
+	}
+	return string(blob), nil
+}
+
+// websiteResultRow maps a row from websites_results table.
+type websiteResultRow struct {
+	Elapsed       float64   `db:"elapsed_time"`
+	Status        *int      `db:"status"`
+	Matched       *bool     `db:"matched"`
+	Unreachable   bool      `db:"unreachable"`
+	At            time.Time `db:"at"`
+	Location      string    `db:"location"`
+	CheckerID     string    `db:"checker_id"`
+	IP            string    `db:"ip"`
+	InMaintenance bool      `db:"in_maintenance"`
+	Probe         string    `db:"probe"`
+	Error         *string   `db:"error"`
+	// Details is a JSON object
+	Details *string `db:"details"`
+}
+
+func (r websiteResultRow) toDomain() (domain.WebsiteResult, error) {
+	wr := domain.WebsiteResult{
+		Elapsed:       time.Duration(r.Elapsed * float64(time.Second)),
+		Status:        r.Status,
+		Matched:       r.Matched,
+		Unreachable:   r.Unreachable,
+		At:            r.At,
+		Location:      r.Location,
+		CheckerID:     r.CheckerID,
+		IP:            r.IP,
+		InMaintenance: r.InMaintenance,
+		Probe:         r.Probe,
+		Error:         r.Error,
+	}
+	if r.Details != nil {
+		var details domain.ResultDetails
+		if err := json.Unmarshal([]byte(*r.Details), &details); err != nil {
+			return wr, fmt.Errorf("can't decode result details: %w", err)
+		}
+		wr.SetDetails(details)
+	}
+	return wr, nil
+}
+
+// stepResultRow maps a row from websites_step_results table.
+type stepResultRow struct {
+	Location  string    `db:"location"`
+	CheckerID string    `db:"checker_id"`
+	IP        string    `db:"ip"`
+	At        time.Time `db:"at"`
+	Name      string    `db:"name"`
+	Elapsed   float64   `db:"elapsed_time"`
+	Status    *int      `db:"status"`
+	Matched   *bool     `db:"matched"`
+	Error     *string   `db:"error"`
+
+	BodySize      int64  `db:"body_size"`
+	BodyTruncated bool   `db:"body_truncated"`
+	ContentType   string `db:"content_type"`
+}
+
+func (r stepResultRow) toDomain() domain.StepResult {
+	return domain.StepResult{
+		Name:    r.Name,
+		Elapsed: time.Duration(r.Elapsed * float64(time.Second)),
+		Status:  r.Status,
+		Matched: r.Matched,
+		Error:   r.Error,
+
+		BodySize:      r.BodySize,
+		BodyTruncated: r.BodyTruncated,
+		ContentType:   r.ContentType,
+	}
+}
+
+// encodeDetails returns the probe specific fields of the result as a JSON object or nil if there are none.
+func encodeDetails(wr domain.WebsiteResult) (*string, error) {
+	details := wr.Details()
+	if details == nil {
+		return nil, nil
+	}
+	blob, err := json.Marshal(details)
+	if err != nil {
+		return nil, fmt.Errorf("can't encode result details: %w", err)
+	}
+	st := string(blob)
+	return &st, nil
+}
+
+// probeOrDefault returns the probe type of the result, http if not set.
+func probeOrDefault(wr domain.WebsiteResult) string {
+	if wr.Probe == "" {
+		return domain.ProbeHTTP
+	}
+	return wr.Probe
+}
+
+// utcPtr returns the optional time in UTC.
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// Ping returns an error if the DB can't be reached.
+func (s *Store) Ping(ctx context.Context) error {
+	if err := s.DB.PingContext(ctx); err != nil {
+		return fmt.Errorf("can't reach DB: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection
+func (s *Store) Close() error {
+	return s.DB.Close()
+}
@@ -0,0 +1,66 @@
+package sqlite
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+const migrationsPath = "../../db/sqlite/migrations"
+
+func TestSchema(t *testing.T) {
+	c := qt.New(t)
+
+	s, err := NewStore(":memory:")
+	c.Assert(err, qt.IsNil)
+	defer s.Close()
+
+	tables := func() []string {
+		var names []string
+		err := s.DB.Select(&names, `SELECT name FROM sqlite_master WHERE type = 'table' AND name LIKE 'website%' ORDER BY name`)
+		c.Assert(err, qt.IsNil)
+		return names
+	}
+
+	c.Assert(s.CreateSchema(migrationsPath), qt.IsNil)
+	// Applied migrations are skipped
+	c.Assert(s.CreateSchema(migrationsPath), qt.IsNil)
+	c.Assert(tables(), qt.DeepEquals, []string{
+		"website_status", "websites", "websites_flapping", "websites_results",
+		"websites_results_daily", "websites_results_hourly", "websites_step_results",
+	})
+
+	c.Assert(s.DropSchema(migrationsPath), qt.IsNil)
+	c.Assert(tables(), qt.HasLen, 0)
+
+	var applied int
+	c.Assert(s.DB.Get(&applied, `SELECT COUNT(*) FROM schema_migrations`), qt.IsNil)
+	c.Assert(applied, qt.Equals, 0)
+
+	c.Assert(s.CreateSchema(migrationsPath), qt.IsNil)
+	c.Assert(tables(), qt.HasLen, 7)
+
+	err = s.CreateSchema("unknown")
+	c.Assert(err, qt.ErrorMatches, "can't read migrations: .*")
+}
+
+func TestPercentile(t *testing.T) {
+	c := qt.New(t)
+
+	for _, test := range []struct {
+		values []float64
+		p      float64
+		want   float64
+	}{
+		{nil, 0.5, 0},
+		{[]float64{0.3}, 0.99, 0.3},
+		{[]float64{0.1, 0.2, 0.3}, 0.5, 0.2},
+		{[]float64{0.1, 0.2, 0.3, 0.4}, 0.5, 0.25},
+		{[]float64{1, 2, 3, 4, 5}, 0.95, 4.8},
+		{[]float64{1, 2}, 1, 2},
+	} {
+		c.Check(percentile(test.values, test.p), qt.CmpEquals(cmpopts.EquateApprox(0, 1e-9)), test.want,
+			qt.Commentf("%v p%v", test.values, test.p))
+	}
+}
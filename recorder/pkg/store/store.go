@@ -0,0 +1,94 @@
+// Package store defines the storage of the recorder and opens the PostgreSQL or
+// SQLite implementation given a DSN.
+package store
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/pg"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/sqlite"
+)
+
+// sqlitePrefix is the scheme of the DSN of the SQLite databases.
+const sqlitePrefix = "sqlite:"
+
+// Store stores the website results and the state derived from them.
+type Store interface {
+	// CreateSchema applies the migrations from sourcePath and DropSchema reverts them.
+	CreateSchema(sourcePath string) error
+	DropSchema(sourcePath string) error
+
+	InsertWebsiteResult(ctx context.Context, wp domain.WebsiteParams, wr domain.WebsiteResult) error
+	DeleteResultsBefore(ctx context.Context, before time.Time) (int64, error)
+	CreatePartitions(ctx context.Context, from, to time.Time) error
+
+	Websites(ctx context.Context) ([]domain.WebsiteParams, error)
+	WebsiteResults(ctx context.Context, websiteID string, from, to time.Time) ([]domain.WebsiteResult, error)
+	QueryWebsiteResults(ctx context.Context, q domain.ResultsQuery) ([]domain.WebsiteResult, error)
+	LocationStats(ctx context.Context, websiteID, location string, from, to time.Time) ([]domain.LocationStats, error)
+	Uptime(ctx context.Context, websiteID string, from, to time.Time, bucket time.Duration) ([]domain.UptimeStats, error)
+	UptimeByWebsite(ctx context.Context, from, to time.Time, bucket time.Duration) (map[string][]domain.UptimeStats, error)
+	LatestStatuses(ctx context.Context) ([]domain.WebsiteLatestStatus, error)
+
+	RollupWatermark(ctx context.Context) (*time.Time, error)
+	Rollup(ctx context.Context, period domain.RollupPeriod, from, to time.Time) error
+	Rollups(ctx context.Context, websiteID string, period domain.RollupPeriod, from, to time.Time) ([]domain.Rollup, error)
+
+	SaveWebsiteStatus(ctx context.Context, ws domain.WebsiteStatus) error
+
+	// SaveIncidentChanges stores the changes and enqueues their notifications in a single transaction.
+	SaveIncidentChanges(ctx context.Context, ch domain.IncidentChanges, notifications domain.NotificationsFunc) error
+	OpenIncidents(ctx context.Context) ([]domain.Incident, error)
+	Incidents(ctx context.Context, since time.Time) ([]domain.Incident, error)
+
+	FlappingWebsites(ctx context.Context) ([]domain.FlappingState, error)
+
+	PendingNotifications(ctx context.Context, now time.Time, limit int) ([]domain.Notification, error)
+	MarkNotificationDelivered(ctx context.Context, id int64, at time.Time) error
+	MarkNotificationFailed(ctx context.Context, n domain.Notification) error
+
+	CreateMaintenanceWindow(ctx context.Context, mw *domain.MaintenanceWindow) error
+	MaintenanceWindows(ctx context.Context) ([]domain.MaintenanceWindow, error)
+	DeleteMaintenanceWindow(ctx context.Context, id int64) (bool, error)
+
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Compile-time check around interface implementation
+var (
+	_ Store = (*pg.Store)(nil)
+	_ Store = (*sqlite.Store)(nil)
+)
+
+// IsSQLite returns true if the DSN is a SQLite one: sqlite:<path> or sqlite::memory:.
+func IsSQLite(dsn string) bool {
+	return strings.HasPrefix(dsn, sqlitePrefix)
+}
+
+// Open connects to the store of the DSN: SQLite for sqlite:<path> and PostgreSQL otherwise.
+func Open(dsn string) (Store, error) {
+	if IsSQLite(dsn) {
+		path := strings.TrimPrefix(strings.TrimPrefix(dsn, sqlitePrefix), "//")
+		if path == "" {
+			return nil, fmt.Errorf("missing SQLite database path in %q", dsn)
+		}
+		return sqlite.NewStore(path)
+	}
+
+	return pg.NewStore(dsn)
+}
+
+// MigrationsPath returns the directory of the migrations of the store of the DSN,
+// given the db directory of the recorder.
+func MigrationsPath(dbPath, dsn string) string {
+	if IsSQLite(dsn) {
+		return filepath.Join(dbPath, "sqlite", "migrations")
+	}
+	return filepath.Join(dbPath, "migrations")
+}
@@ -0,0 +1,769 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/domain"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/pg"
+	"github.com/sixstone-qq/gpagdispo/recorder/pkg/sqlite"
+)
+
+// backends open every store implementation with a fresh schema. The raw DB
+// is returned to check what is stored.
+var backends = []struct {
+	name string
+	open func(c *qt.C) (Store, *sqlx.DB)
+}{
+	{"postgres", newPostgresStore},
+	{"sqlite", newSQLiteStore},
+}
+
+func TestStore(t *testing.T) {
+	c := qt.New(t)
+
+	for _, b := range backends {
+		c.Run(b.name, func(c *qt.C) {
+			s, db := b.open(c)
+			testInsertWebsiteResult(c, s, db)
+		})
+	}
+}
+
+func testInsertWebsiteResult(c *qt.C, s Store, db *sqlx.DB) {
+	ctx := context.Background()
+
+	// Partitions for the results recorded in the past
+	err := s.CreatePartitions(ctx, time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC), time.Now())
+	c.Assert(err, qt.IsNil)
+
+	// Test data
+	wp := domain.WebsiteParams{
+		ID:     "id1",
+		URL:    "http://foo.org",
+		Method: "GET",
+	}
+	ok := http.StatusOK
+	wr := domain.WebsiteResult{
+		Elapsed: time.Second,
+		Status:  &ok,
+		At:      time.Now().UTC(),
+	}
+
+	c.Run("OK", func(c *qt.C) {
+		err := s.InsertWebsiteResult(ctx, wp, wr)
+		c.Assert(err, qt.IsNil)
+
+		r := db.QueryRowxContext(ctx, db.Rebind(
+			`SELECT website_id, elapsed_time, status, matched, unreachable, at
+                         FROM websites_results
+                         WHERE website_id = ?`), wp.ID)
+		c.Assert(r.Err(), qt.IsNil)
+		var rec websiteResultRecord
+		err = r.StructScan(&rec)
+		c.Assert(err, qt.IsNil)
+		c.Assert(rec, qt.CmpEquals(cmpopts.EquateApproxTime(time.Second)), websiteResultRecord{
+			ID:          "id1",
+			Elapsed:     1.0,
+			Status:      sql.NullInt32{Valid: true, Int32: http.StatusOK},
+			Matched:     sql.NullBool{},
+			Unreachable: false,
+			At:          time.Now().UTC()})
+	})
+
+	c.Run("Check no duplicates", func(c *qt.C) {
+		wp.ID = "id2"
+		wp.URL = "https://bar.org"
+		wp.Method = "HEAD"
+
+		err := s.InsertWebsiteResult(ctx, wp, wr)
+		c.Assert(err, qt.IsNil)
+		err = s.InsertWebsiteResult(ctx, wp, wr)
+		c.Assert(err, qt.IsNil)
+
+		var n int
+		err = db.GetContext(ctx, &n, db.Rebind(
+			`SELECT COUNT(*)
+                         FROM websites
+                         WHERE id = ?`), wp.ID)
+		c.Assert(err, qt.IsNil)
+		c.Assert(n, qt.Equals, 1, qt.Commentf("expected inserted websites"))
+
+		err = db.GetContext(ctx, &n, db.Rebind(
+			`SELECT COUNT(*)
+                         FROM websites_results
+                         WHERE website_id = ?`), wp.ID)
+		c.Assert(err, qt.IsNil)
+		c.Assert(n, qt.Equals, 1, qt.Commentf("expected inserted websites results"))
+	})
+
+	c.Run("Locations", func(c *qt.C) {
+		wp.ID = "id3"
+		wp.URL = "https://quux.org"
+		wp.Method = "GET"
+
+		notFound := http.StatusNotFound
+		for _, r := range []domain.WebsiteResult{
+			{Elapsed: time.Second, Status: &ok, At: wr.At, Location: "eu-west"},
+			{Elapsed: 3 * time.Second, Status: &notFound, At: wr.At, Location: "us-east"},
+			{Elapsed: time.Second, Unreachable: true, At: wr.At.Add(time.Second), Location: "us-east"},
+		} {
+			err := s.InsertWebsiteResult(ctx, wp, r)
+			c.Assert(err, qt.IsNil)
+		}
+
+		from, to := wr.At.Add(-time.Minute), wr.At.Add(time.Minute)
+		stats, err := s.LocationStats(ctx, wp.ID, "", from, to)
+		c.Assert(err, qt.IsNil)
+		c.Assert(stats, qt.DeepEquals, []domain.LocationStats{
+			{Location: "eu-west", Checks: 1, Failures: 0, AvgElapsed: 1.0},
+			{Location: "us-east", Checks: 2, Failures: 2, AvgElapsed: 2.0},
+		})
+
+		stats, err = s.LocationStats(ctx, wp.ID, "eu-west", from, to)
+		c.Assert(err, qt.IsNil)
+		c.Assert(stats, qt.DeepEquals, []domain.LocationStats{
+			{Location: "eu-west", Checks: 1, Failures: 0, AvgElapsed: 1.0},
+		})
+
+		results, err := s.WebsiteResults(ctx, wp.ID, from, to)
+		c.Assert(err, qt.IsNil)
+		c.Assert(results, qt.HasLen, 3)
+		c.Assert(results[0].Location, qt.Equals, "eu-west")
+		c.Assert(results[1].Status, qt.DeepEquals, &notFound)
+		c.Assert(results[1].Elapsed, qt.Equals, 3*time.Second)
+		c.Assert(results[2].Unreachable, qt.IsTrue)
+	})
+
+	c.Run("Website status", func(c *qt.C) {
+		start := wr.At.Truncate(time.Minute)
+		ws := domain.WebsiteStatus{
+			WebsiteID:       "id3",
+			WindowStart:     start,
+			WindowEnd:       start.Add(time.Minute),
+			Verdict:         domain.VerdictDegraded,
+			Locations:       2,
+			FailedLocations: 1,
+		}
+		err := s.SaveWebsiteStatus(ctx, ws)
+		c.Assert(err, qt.IsNil)
+
+		ws.Verdict = domain.VerdictDown
+		ws.FailedLocations = 2
+		err = s.SaveWebsiteStatus(ctx, ws)
+		c.Assert(err, qt.IsNil)
+
+		var got []domain.WebsiteStatus
+		err = db.SelectContext(ctx, &got, db.Rebind(
+			`SELECT website_id, window_start, window_end, verdict, locations, failed_locations
+                         FROM website_status
+                         WHERE website_id = ?`), ws.WebsiteID)
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.CmpEquals(cmpopts.EquateApproxTime(time.Second)), []domain.WebsiteStatus{ws})
+	})
+
+	c.Run("Incidents", func(c *qt.C) {
+		in := &domain.Incident{
+			WebsiteID:    "id3",
+			StartedAt:    wr.At,
+			Cause:        domain.CauseTimeout,
+			FailedChecks: 3,
+		}
+		err := s.SaveIncidentChanges(ctx, domain.IncidentChanges{Opened: in}, nil)
+		c.Assert(err, qt.IsNil)
+		c.Assert(in.ID, qt.Not(qt.Equals), int64(0))
+
+		incidents, err := s.OpenIncidents(ctx)
+		c.Assert(err, qt.IsNil)
+		c.Assert(incidents, qt.CmpEquals(cmpopts.EquateApproxTime(time.Second)), []domain.Incident{*in})
+
+		endedAt := wr.At.Add(time.Minute)
+		in.EndedAt = &endedAt
+		in.FailedChecks = 5
+		err = s.SaveIncidentChanges(ctx, domain.IncidentChanges{Updated: in}, nil)
+		c.Assert(err, qt.IsNil)
+
+		incidents, err = s.OpenIncidents(ctx)
+		c.Assert(err, qt.IsNil)
+		c.Assert(incidents, qt.HasLen, 0)
+
+		incidents, err = s.Incidents(ctx, wr.At)
+		c.Assert(err, qt.IsNil)
+		c.Assert(incidents, qt.HasLen, 1)
+		c.Assert(incidents[0].ID, qt.Equals, in.ID)
+
+		incidents, err = s.Incidents(ctx, endedAt.Add(time.Second))
+		c.Assert(err, qt.IsNil)
+		c.Assert(incidents, qt.HasLen, 0)
+
+		c.Run("Rollback", func(c *qt.C) {
+			opened := &domain.Incident{WebsiteID: "id3", StartedAt: wr.At, Cause: domain.CauseStatus, FailedChecks: 3}
+			err := s.SaveIncidentChanges(ctx, domain.IncidentChanges{
+				Opened: opened,
+				Events: []domain.IncidentEvent{{Kind: domain.IncidentOpened, Incident: opened}},
+			}, func(ev domain.IncidentEvent) ([]domain.Notification, error) {
+				return nil, errors.New("can't encode")
+			})
+			c.Assert(err, qt.ErrorMatches, "can't get notifications of incident opened: can't encode")
+
+			incidents, err := s.OpenIncidents(ctx)
+			c.Assert(err, qt.IsNil)
+			c.Assert(incidents, qt.HasLen, 0, qt.Commentf("the incident is not stored without its notifications"))
+		})
+	})
+
+	c.Run("Flapping", func(c *qt.C) {
+		fs := domain.FlappingState{WebsiteID: "id3", Flapping: true, Score: 0.75, Since: wr.At}
+		err := s.SaveIncidentChanges(ctx, domain.IncidentChanges{Flapping: &fs}, nil)
+		c.Assert(err, qt.IsNil)
+
+		states, err := s.FlappingWebsites(ctx)
+		c.Assert(err, qt.IsNil)
+		c.Assert(states, qt.CmpEquals(cmpopts.EquateApproxTime(time.Second)), []domain.FlappingState{fs})
+
+		fs.Flapping = false
+		fs.Score = 0.1
+		err = s.SaveIncidentChanges(ctx, domain.IncidentChanges{Flapping: &fs}, nil)
+		c.Assert(err, qt.IsNil)
+
+		states, err = s.FlappingWebsites(ctx)
+		c.Assert(err, qt.IsNil)
+		c.Assert(states, qt.HasLen, 0)
+	})
+
+	c.Run("Maintenance", func(c *qt.C) {
+		wp.ID = "id5"
+		wr.Location = "eu-west"
+		err := s.InsertWebsiteResult(ctx, wp, wr)
+		c.Assert(err, qt.IsNil)
+		inMaintenance := wr
+		inMaintenance.At = wr.At.Add(time.Second)
+		inMaintenance.InMaintenance = true
+		err = s.InsertWebsiteResult(ctx, wp, inMaintenance)
+		c.Assert(err, qt.IsNil)
+
+		from, to := wr.At.Add(-time.Minute), wr.At.Add(time.Minute)
+		results, err := s.WebsiteResults(ctx, wp.ID, from, to)
+		c.Assert(err, qt.IsNil)
+		c.Assert(results, qt.HasLen, 2)
+		c.Assert(results[1].InMaintenance, qt.IsTrue)
+
+		stats, err := s.LocationStats(ctx, wp.ID, "", from, to)
+		c.Assert(err, qt.IsNil)
+		c.Assert(stats, qt.HasLen, 1)
+		c.Assert(stats[0].Checks, qt.Equals, 1, qt.Commentf("results in maintenance are excluded"))
+
+		tag, cron, duration := "api", "0 3 * * SUN", "2h"
+		mw := &domain.MaintenanceWindow{Tag: &tag, Cron: &cron, Duration: &duration, Reason: "weekly deploy"}
+		err = s.CreateMaintenanceWindow(ctx, mw)
+		c.Assert(err, qt.IsNil)
+		c.Assert(mw.ID, qt.Not(qt.Equals), int64(0))
+
+		windows, err := s.MaintenanceWindows(ctx)
+		c.Assert(err, qt.IsNil)
+		c.Assert(windows, qt.DeepEquals, []domain.MaintenanceWindow{*mw})
+
+		found, err := s.DeleteMaintenanceWindow(ctx, mw.ID)
+		c.Assert(err, qt.IsNil)
+		c.Assert(found, qt.IsTrue)
+		found, err = s.DeleteMaintenanceWindow(ctx, mw.ID)
+		c.Assert(err, qt.IsNil)
+		c.Assert(found, qt.IsFalse)
+	})
+
+	c.Run("Tags", func(c *qt.C) {
+		wp.ID = "id4"
+		wp.Tags = []string{"api"}
+		err := s.InsertWebsiteResult(ctx, wp, wr)
+		c.Assert(err, qt.IsNil)
+
+		wp.Tags = []string{"api", "prod"}
+		wr.At = wr.At.Add(time.Second)
+		err = s.InsertWebsiteResult(ctx, wp, wr)
+		c.Assert(err, qt.IsNil)
+
+		websites, err := s.Websites(ctx)
+		c.Assert(err, qt.IsNil)
+		var tags []string
+		for _, w := range websites {
+			if w.ID == wp.ID {
+				tags = w.Tags
+			}
+		}
+		c.Assert(tags, qt.DeepEquals, []string{"api", "prod"})
+	})
+
+	c.Run("Query", func(c *qt.C) {
+		wp := domain.WebsiteParams{ID: "id6", URL: "http://query.org", Method: "GET", Tags: []string{"api"}}
+		at := time.Date(2021, 5, 24, 10, 0, 0, 0, time.UTC)
+		badGateway := http.StatusBadGateway
+		for i, r := range []domain.WebsiteResult{
+			{Elapsed: 100 * time.Millisecond, Status: &ok, Location: "eu-west"},
+			{Elapsed: 200 * time.Millisecond, Status: &ok, Location: "us-east"},
+			{Elapsed: 300 * time.Millisecond, Status: &badGateway, Location: "eu-west"},
+			{Elapsed: 400 * time.Millisecond, Status: &ok, Location: "eu-west"},
+			{Elapsed: 500 * time.Millisecond, Unreachable: true, Location: "eu-west", InMaintenance: true},
+		} {
+			r.At = at.Add(time.Duration(i) * 30 * time.Minute)
+			err := s.InsertWebsiteResult(ctx, wp, r)
+			c.Assert(err, qt.IsNil)
+		}
+
+		websites, err := s.Websites(ctx)
+		c.Assert(err, qt.IsNil)
+		c.Assert(websites, qt.Any(qt.DeepEquals), wp)
+
+		results, err := s.QueryWebsiteResults(ctx, domain.ResultsQuery{
+			WebsiteID: wp.ID, From: at, To: at.Add(3 * time.Hour), Location: "eu-west", Limit: 2, Offset: 1,
+		})
+		c.Assert(err, qt.IsNil)
+		c.Assert(results, qt.HasLen, 2)
+		c.Assert(results[0].At.Equal(at.Add(90*time.Minute)), qt.IsTrue)
+		c.Assert(results[1].At.Equal(at.Add(60*time.Minute)), qt.IsTrue)
+
+		stats, err := s.Uptime(ctx, wp.ID, at, at.Add(3*time.Hour), time.Hour)
+		c.Assert(err, qt.IsNil)
+		c.Assert(stats, qt.HasLen, 2, qt.Commentf("results in maintenance are excluded"))
+		c.Assert(stats[0].From, qt.Equals, at)
+		c.Assert(stats[0].To, qt.Equals, at.Add(time.Hour))
+		c.Assert(stats[0].Checks, qt.Equals, 2)
+		c.Assert(stats[0].Uptime, qt.Equals, 100.0)
+		c.Assert(stats[1].Failures, qt.Equals, 1)
+		c.Assert(stats[1].Uptime, qt.Equals, 50.0)
+
+		stats, err = s.Uptime(ctx, wp.ID, at, at.Add(3*time.Hour), 0)
+		c.Assert(err, qt.IsNil)
+		c.Assert(stats, qt.HasLen, 1)
+		c.Assert(stats[0].Checks, qt.Equals, 4)
+		c.Assert(stats[0].Uptime, qt.Equals, 75.0)
+		c.Assert(stats[0].P50, qt.CmpEquals(cmpopts.EquateApprox(0, 1e-9)), 0.25)
+
+		byWebsite, err := s.UptimeByWebsite(ctx, at, at.Add(3*time.Hour), time.Hour)
+		c.Assert(err, qt.IsNil)
+		stats, err = s.Uptime(ctx, wp.ID, at, at.Add(3*time.Hour), time.Hour)
+		c.Assert(err, qt.IsNil)
+		c.Assert(byWebsite[wp.ID], qt.DeepEquals, stats)
+
+		statuses, err := s.LatestStatuses(ctx)
+		c.Assert(err, qt.IsNil)
+		var latest *domain.WebsiteLatestStatus
+		for i := range statuses {
+			if statuses[i].Website.ID == wp.ID {
+				latest = &statuses[i]
+			}
+		}
+		c.Assert(latest, qt.Not(qt.IsNil))
+		c.Assert(latest.Result.At.Equal(at.Add(2*time.Hour)), qt.IsTrue)
+		c.Assert(latest.Verdict, qt.IsNil)
+	})
+
+	c.Run("Probes", func(c *qt.C) {
+		at := time.Date(2021, 5, 25, 10, 0, 0, 0, time.UTC)
+		tcp := domain.WebsiteParams{ID: "id8", URL: "tcp://db.internal:5432"}
+		refused := "connection refused"
+		for i, r := range []domain.WebsiteResult{
+			{Elapsed: time.Millisecond, Probe: "tcp"},
+			{Elapsed: time.Millisecond, Probe: "tcp", Error: &refused},
+		} {
+			r.At = at.Add(time.Duration(i) * time.Minute)
+			err := s.InsertWebsiteResult(ctx, tcp, r)
+			c.Assert(err, qt.IsNil)
+		}
+
+		stats, err := s.Uptime(ctx, tcp.ID, at, at.Add(time.Hour), 0)
+		c.Assert(err, qt.IsNil)
+		c.Assert(stats, qt.HasLen, 1)
+		c.Assert(stats[0].Checks, qt.Equals, 2)
+		c.Assert(stats[0].Failures, qt.Equals, 1, qt.Commentf("TCP results have no status"))
+
+		tls := domain.WebsiteParams{ID: "id9", URL: "tls://foo.org"}
+		wr := domain.WebsiteResult{
+			Elapsed: 10 * time.Millisecond,
+			At:      at,
+			Probe:   "tls",
+			TLS: &domain.TLSResult{
+				Version:     "TLS 1.3",
+				CipherSuite: "TLS_AES_128_GCM_SHA256",
+				Subject:     "CN=foo.org",
+				Issuer:      "CN=R3,O=Let's Encrypt,C=US",
+				NotAfter:    time.Date(2021, 8, 1, 0, 0, 0, 0, time.UTC),
+				Verified:    true,
+			},
+		}
+		err = s.InsertWebsiteResult(ctx, tls, wr)
+		c.Assert(err, qt.IsNil)
+
+		results, err := s.WebsiteResults(ctx, tls.ID, at, at.Add(time.Minute))
+		c.Assert(err, qt.IsNil)
+		c.Assert(results, qt.HasLen, 1)
+		c.Assert(results[0].TLS, qt.DeepEquals, wr.TLS)
+		c.Assert(results[0].Failed(), qt.IsFalse)
+
+		grpc := domain.WebsiteParams{ID: "id10", URL: "grpc://api.internal:8443/foo.v1.Users"}
+		notServing := "service is NOT_SERVING"
+		wr = domain.WebsiteResult{
+			Elapsed: 5 * time.Millisecond,
+			At:      at,
+			Probe:   "grpc",
+			Error:   &notServing,
+			GRPC:    &domain.GRPCResult{Code: 0, ServingStatus: "NOT_SERVING"},
+		}
+		err = s.InsertWebsiteResult(ctx, grpc, wr)
+		c.Assert(err, qt.IsNil)
+
+		results, err = s.WebsiteResults(ctx, grpc.ID, at, at.Add(time.Minute))
+		c.Assert(err, qt.IsNil)
+		c.Assert(results, qt.HasLen, 1)
+		c.Assert(results[0].GRPC, qt.DeepEquals, wr.GRPC)
+		c.Assert(results[0].Failed(), qt.IsTrue)
+
+		ws := domain.WebsiteParams{ID: "id11", URL: "wss://rt.foo.org/socket"}
+		latency, normal, no := 3*time.Millisecond, 1000, false
+		wr = domain.WebsiteResult{
+			Elapsed:   8 * time.Millisecond,
+			At:        at,
+			Probe:     "websocket",
+			Matched:   &no,
+			WebSocket: &domain.WebSocketResult{Handshake: 5 * time.Millisecond, ReplyLatency: &latency, CloseCode: &normal},
+		}
+		err = s.InsertWebsiteResult(ctx, ws, wr)
+		c.Assert(err, qt.IsNil)
+
+		results, err = s.WebsiteResults(ctx, ws.ID, at, at.Add(time.Minute))
+		c.Assert(err, qt.IsNil)
+		c.Assert(results, qt.HasLen, 1)
+		c.Assert(results[0].WebSocket, qt.DeepEquals, wr.WebSocket)
+		c.Assert(results[0].Failed(), qt.IsTrue)
+
+		statuses, err := s.LatestStatuses(ctx)
+		c.Assert(err, qt.IsNil)
+		for _, st := range statuses {
+			if st.Website.ID == tcp.ID {
+				c.Assert(st.Result.Probe, qt.Equals, "tcp")
+				c.Assert(st.Result.Error, qt.DeepEquals, &refused)
+			}
+		}
+	})
+
+	c.Run("Steps", func(c *qt.C) {
+		wp := domain.WebsiteParams{ID: "id12", URL: "https://shop.foo.org", Method: "GET"}
+		at := time.Date(2021, 5, 26, 10, 0, 0, 0, time.UTC)
+		forbidden, yes := http.StatusForbidden, true
+		failed := "step 2 (cart) failed"
+		passed := domain.WebsiteResult{
+			Elapsed: 30 * time.Millisecond,
+			Status:  &ok,
+			Matched: &yes,
+			At:      at,
+			Steps: []domain.StepResult{
+				{Name: "login", Elapsed: 20 * time.Millisecond, Status: &ok, BodySize: 42, ContentType: "application/json"},
+				{Name: "cart", Elapsed: 10 * time.Millisecond, Status: &ok, Matched: &yes, BodySize: 1 << 20, BodyTruncated: true},
+			},
+		}
+		ko := domain.WebsiteResult{
+			Elapsed:  30 * time.Millisecond,
+			Status:   &forbidden,
+			At:       at,
+			Location: "eu",
+			Error:    &failed,
+			Steps: []domain.StepResult{
+				{Name: "login", Elapsed: 20 * time.Millisecond, Status: &ok},
+				{Name: "cart", Elapsed: 10 * time.Millisecond, Status: &forbidden},
+			},
+		}
+		for _, r := range []domain.WebsiteResult{passed, ko} {
+			err := s.InsertWebsiteResult(ctx, wp, r)
+			c.Assert(err, qt.IsNil)
+		}
+
+		results, err := s.WebsiteResults(ctx, wp.ID, at, at.Add(time.Minute))
+		c.Assert(err, qt.IsNil)
+		c.Assert(results, qt.HasLen, 2)
+		c.Assert(results[0].Steps, qt.DeepEquals, passed.Steps)
+		c.Assert(results[0].FailedStep, qt.IsNil)
+		c.Assert(results[1].Steps, qt.DeepEquals, ko.Steps)
+		c.Assert(*results[1].FailedStep, qt.Equals, 1)
+
+		results, err = s.QueryWebsiteResults(ctx, domain.ResultsQuery{
+			WebsiteID: wp.ID, From: at, To: at.Add(time.Minute), Location: "eu", Limit: 10,
+		})
+		c.Assert(err, qt.IsNil)
+		c.Assert(results, qt.HasLen, 1)
+		c.Assert(results[0].Steps, qt.DeepEquals, ko.Steps)
+	})
+
+	c.Run("Redirects", func(c *qt.C) {
+		wp := domain.WebsiteParams{ID: "id13", URL: "http://foo.org/account", Method: "GET"}
+		at := time.Date(2021, 5, 27, 10, 0, 0, 0, time.UTC)
+		notExpected := "final URL https://foo.org/login is not https://foo.org/account/home"
+		wr := domain.WebsiteResult{
+			Elapsed: 30 * time.Millisecond,
+			Status:  &ok,
+			At:      at,
+			Error:   &notExpected,
+			Redirects: []domain.Redirect{
+				{URL: "http://foo.org/account", Status: http.StatusMovedPermanently, Elapsed: 10 * time.Millisecond},
+				{URL: "https://foo.org/account", Status: http.StatusFound, Elapsed: 10 * time.Millisecond},
+			},
+			FinalURL: "https://foo.org/login",
+		}
+		err := s.InsertWebsiteResult(ctx, wp, wr)
+		c.Assert(err, qt.IsNil)
+
+		results, err := s.WebsiteResults(ctx, wp.ID, at, at.Add(time.Minute))
+		c.Assert(err, qt.IsNil)
+		c.Assert(results, qt.HasLen, 1)
+		c.Assert(results[0].Redirects, qt.DeepEquals, wr.Redirects)
+		c.Assert(results[0].FinalURL, qt.Equals, wr.FinalURL)
+		c.Assert(results[0].Failed(), qt.IsTrue)
+	})
+
+	c.Run("Checkers", func(c *qt.C) {
+		wp := domain.WebsiteParams{ID: "id16", URL: "https://foo.org/checkers", Method: "GET"}
+		at := time.Date(2021, 5, 27, 13, 0, 0, 0, time.UTC)
+		for _, id := range []string{"checker-2", "checker-1"} {
+			err := s.InsertWebsiteResult(ctx, wp, domain.WebsiteResult{
+				Elapsed: 10 * time.Millisecond, Status: &ok, At: at, Location: "eu-west", CheckerID: id,
+			})
+			c.Assert(err, qt.IsNil)
+		}
+
+		results, err := s.WebsiteResults(ctx, wp.ID, at, at.Add(time.Minute))
+		c.Assert(err, qt.IsNil)
+		c.Assert(results, qt.HasLen, 2, qt.Commentf("checkers of the same location don't collide"))
+		c.Assert(results[0].CheckerID, qt.Equals, "checker-1")
+		c.Assert(results[1].CheckerID, qt.Equals, "checker-2")
+	})
+
+	c.Run("Body", func(c *qt.C) {
+		wp := domain.WebsiteParams{ID: "id15", URL: "http://foo.org/large", Method: "GET"}
+		at := time.Date(2021, 5, 27, 12, 0, 0, 0, time.UTC)
+		notMatched := false
+		wr := domain.WebsiteResult{
+			Elapsed:       30 * time.Millisecond,
+			Status:        &ok,
+			Matched:       &notMatched,
+			At:            at,
+			BodySize:      1 << 20,
+			BodyTruncated: true,
+			ContentType:   "text/html; charset=utf-8",
+		}
+		err := s.InsertWebsiteResult(ctx, wp, wr)
+		c.Assert(err, qt.IsNil)
+
+		results, err := s.WebsiteResults(ctx, wp.ID, at, at.Add(time.Minute))
+		c.Assert(err, qt.IsNil)
+		c.Assert(results, qt.HasLen, 1)
+		c.Assert(results[0].BodySize, qt.Equals, wr.BodySize)
+		c.Assert(results[0].BodyTruncated, qt.IsTrue)
+		c.Assert(results[0].ContentType, qt.Equals, wr.ContentType)
+	})
+
+	c.Run("PerIP", func(c *qt.C) {
+		wp := domain.WebsiteParams{ID: "id14", URL: "https://foo.org", Method: "GET"}
+		at := time.Date(2021, 5, 27, 11, 0, 0, 0, time.UTC)
+		refused := "connection refused"
+		for _, wr := range []domain.WebsiteResult{
+			{Elapsed: 20 * time.Millisecond, Status: &ok, At: at, IP: "10.0.0.1",
+				Steps: []domain.StepResult{{Name: "home", Elapsed: 20 * time.Millisecond, Status: &ok}}},
+			{Elapsed: 10 * time.Millisecond, At: at, IP: "10.0.0.2", Error: &refused,
+				Steps: []domain.StepResult{{Name: "home", Elapsed: 10 * time.Millisecond, Error: &refused}}},
+		} {
+			err := s.InsertWebsiteResult(ctx, wp, wr)
+			c.Assert(err, qt.IsNil)
+		}
+
+		results, err := s.WebsiteResults(ctx, wp.ID, at, at.Add(time.Minute))
+		c.Assert(err, qt.IsNil)
+		c.Assert(results, qt.HasLen, 2)
+		c.Assert(results[0].IP, qt.Equals, "10.0.0.1")
+		c.Assert(results[0].Failed(), qt.IsFalse)
+		c.Assert(results[0].Steps, qt.HasLen, 1)
+		c.Assert(results[0].Steps[0].Error, qt.IsNil)
+		c.Assert(results[1].IP, qt.Equals, "10.0.0.2")
+		c.Assert(results[1].Failed(), qt.IsTrue)
+		c.Assert(results[1].Steps, qt.HasLen, 1)
+		c.Assert(*results[1].Steps[0].Error, qt.Equals, refused)
+	})
+
+	c.Run("Rollups", func(c *qt.C) {
+		wp := domain.WebsiteParams{ID: "id7", URL: "http://rollups.org", Method: "GET"}
+		day := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+		badGateway := http.StatusBadGateway
+		for _, r := range []domain.WebsiteResult{
+			{Elapsed: 100 * time.Millisecond, Status: &ok, At: day.Add(10 * time.Minute)},
+			{Elapsed: 300 * time.Millisecond, Status: &badGateway, At: day.Add(20 * time.Minute)},
+			{Elapsed: 200 * time.Millisecond, Status: &ok, At: day.Add(70 * time.Minute)},
+			{Elapsed: time.Second, Unreachable: true, At: day.Add(80 * time.Minute), InMaintenance: true},
+			{Elapsed: 400 * time.Millisecond, Unreachable: true, At: day.Add(25 * time.Hour)},
+		} {
+			err := s.InsertWebsiteResult(ctx, wp, r)
+			c.Assert(err, qt.IsNil)
+		}
+
+		watermark, err := s.RollupWatermark(ctx)
+		c.Assert(err, qt.IsNil)
+		c.Assert(watermark, qt.Not(qt.IsNil))
+		c.Assert(watermark.After(day.Add(time.Hour)), qt.IsFalse)
+
+		for _, period := range []domain.RollupPeriod{domain.RollupHourly, domain.RollupDaily} {
+			err = s.Rollup(ctx, period, day, day.Add(48*time.Hour))
+			c.Assert(err, qt.IsNil)
+			// Rolling up again replaces the rollups
+			err = s.Rollup(ctx, period, day, day.Add(48*time.Hour))
+			c.Assert(err, qt.IsNil)
+		}
+
+		hourly, err := s.Rollups(ctx, wp.ID, domain.RollupHourly, day, day.Add(48*time.Hour))
+		c.Assert(err, qt.IsNil)
+		c.Assert(hourly, qt.HasLen, 3)
+		c.Assert(hourly[0].From, qt.Equals, day)
+		c.Assert(hourly[0].Checks, qt.Equals, 2)
+		c.Assert(hourly[0].Failures, qt.Equals, 1)
+		c.Assert(hourly[0].MinElapsed, qt.Equals, 0.1)
+		c.Assert(hourly[0].MaxElapsed, qt.Equals, 0.3)
+		c.Assert(hourly[0].StatusCodes, qt.DeepEquals, map[int]int{200: 1, 502: 1})
+		c.Assert(hourly[1].Checks, qt.Equals, 1, qt.Commentf("results in maintenance are excluded"))
+		c.Assert(hourly[2].StatusCodes, qt.DeepEquals, map[int]int{})
+
+		daily, err := s.Rollups(ctx, wp.ID, domain.RollupDaily, day, day.Add(48*time.Hour))
+		c.Assert(err, qt.IsNil)
+		c.Assert(daily, qt.HasLen, 2)
+		c.Assert(daily[0].Checks, qt.Equals, 3)
+		c.Assert(daily[0].StatusCodes, qt.DeepEquals, map[int]int{200: 2, 502: 1})
+		c.Assert(daily[0].P50, qt.CmpEquals(cmpopts.EquateApprox(0, 1e-9)), 0.2)
+
+		n, err := s.DeleteResultsBefore(ctx, day.Add(24*time.Hour))
+		c.Assert(err, qt.IsNil)
+		c.Assert(n, qt.Equals, int64(4))
+
+		// Long periods are read from the rollups once raw results are deleted
+		stats, err := s.Uptime(ctx, wp.ID, day.Add(time.Hour), day.Add(72*time.Hour), 24*time.Hour)
+		c.Assert(err, qt.IsNil)
+		c.Assert(stats, qt.HasLen, 2)
+		c.Assert(stats[0].From, qt.Equals, day)
+		c.Assert(stats[0].Checks, qt.Equals, 3)
+		c.Assert(stats[1].Uptime, qt.Equals, 0.0)
+
+		byWebsite, err := s.UptimeByWebsite(ctx, day.Add(time.Hour), day.Add(72*time.Hour), 24*time.Hour)
+		c.Assert(err, qt.IsNil)
+		c.Assert(byWebsite[wp.ID], qt.DeepEquals, stats)
+
+		stats, err = s.Uptime(ctx, wp.ID, day, day.Add(72*time.Hour), 0)
+		c.Assert(err, qt.IsNil)
+		c.Assert(stats, qt.HasLen, 1)
+		c.Assert(stats[0].Checks, qt.Equals, 4)
+		c.Assert(stats[0].Failures, qt.Equals, 2)
+	})
+
+	c.Run("Notifications", func(c *qt.C) {
+		opened := &domain.Incident{WebsiteID: "id3", StartedAt: wr.At, Cause: domain.CauseStatus, FailedChecks: 3}
+		err := s.SaveIncidentChanges(ctx, domain.IncidentChanges{
+			Opened: opened,
+			Events: []domain.IncidentEvent{{Kind: domain.IncidentOpened, Incident: opened}},
+		}, func(ev domain.IncidentEvent) ([]domain.Notification, error) {
+			c.Assert(ev.Incident.ID, qt.Equals, opened.ID, qt.Commentf("the incident is inserted first"))
+			return []domain.Notification{{Receiver: "ops", Payload: []byte(`{"kind": "opened"}`)}}, nil
+		})
+		c.Assert(err, qt.IsNil)
+		c.Assert(opened.ID, qt.Not(qt.Equals), int64(0))
+
+		pending, err := s.PendingNotifications(ctx, time.Now().UTC().Add(time.Second), 10)
+		c.Assert(err, qt.IsNil)
+		c.Assert(pending, qt.HasLen, 1)
+		n := pending[0]
+		c.Assert(n.Receiver, qt.Equals, "ops")
+		c.Assert(string(n.Payload), qt.JSONEquals, map[string]string{"kind": "opened"})
+		c.Assert(n.Attempts, qt.Equals, 0)
+
+		next := time.Now().UTC().Add(time.Hour)
+		errMsg := "connection refused"
+		n.Attempts = 1
+		n.NextAttemptAt = &next
+		n.LastError = &errMsg
+		err = s.MarkNotificationFailed(ctx, n)
+		c.Assert(err, qt.IsNil)
+
+		pending, err = s.PendingNotifications(ctx, time.Now().UTC().Add(time.Second), 10)
+		c.Assert(err, qt.IsNil)
+		c.Assert(pending, qt.HasLen, 0)
+
+		pending, err = s.PendingNotifications(ctx, next, 10)
+		c.Assert(err, qt.IsNil)
+		c.Assert(pending, qt.HasLen, 1)
+		c.Assert(pending[0].Attempts, qt.Equals, 1)
+		c.Assert(*pending[0].LastError, qt.Equals, errMsg)
+
+		err = s.MarkNotificationDelivered(ctx, n.ID, time.Now().UTC())
+		c.Assert(err, qt.IsNil)
+
+		pending, err = s.PendingNotifications(ctx, next, 10)
+		c.Assert(err, qt.IsNil)
+		c.Assert(pending, qt.HasLen, 0)
+	})
+}
+
+// newPostgresStore connects to the test DB with a fresh schema.
+func newPostgresStore(c *qt.C) (Store, *sqlx.DB) {
+	uri := os.Getenv("POSTGRESQL_DSN")
+	if uri == "" {
+		uri = "postgres://postgres@localhost/website_test?sslmode=disable"
+	}
+
+	s, err := pg.NewStore(uri)
+	c.Assert(err, qt.IsNil)
+	c.Cleanup(func() { _ = s.Close() })
+
+	err = s.CreateSchema("../../db/migrations")
+	c.Assert(err, qt.IsNil)
+	c.Cleanup(func() { _ = s.DropSchema("../../db/migrations") })
+
+	return s, s.DB
+}
+
+// newSQLiteStore creates a test DB in a temporary directory.
+func newSQLiteStore(c *qt.C) (Store, *sqlx.DB) {
+	s, err := sqlite.NewStore(filepath.Join(c.TempDir(), "gpagdispo.db"))
+	c.Assert(err, qt.IsNil)
+	c.Cleanup(func() { _ = s.Close() })
+
+	err = s.CreateSchema("../../db/sqlite/migrations")
+	c.Assert(err, qt.IsNil)
+
+	return s, s.DB
+}
+
+type websiteResultRecord struct {
+	ID          string        `db:"website_id"`
+	Elapsed     float64       `db:"elapsed_time"`
+	Status      sql.NullInt32 `db:"status"`
+	Matched     sql.NullBool  `db:"matched"`
+	Unreachable bool          `db:"unreachable"`
+	At          time.Time     `db:"at"`
+}
+
+func TestOpen(t *testing.T) {
+	c := qt.New(t)
+
+	s, err := Open("sqlite::memory:")
+	c.Assert(err, qt.IsNil)
+	defer s.Close()
+	c.Assert(s, qt.Satisfies, func(s Store) bool { _, ok := s.(*sqlite.Store); return ok })
+	c.Assert(s.CreateSchema(MigrationsPath("../../db", "sqlite::memory:")), qt.IsNil)
+
+	_, err = Open("sqlite://")
+	c.Assert(err, qt.ErrorMatches, `missing SQLite database path in "sqlite://"`)
+
+	c.Assert(MigrationsPath("db", "sqlite:///var/lib/gpagdispo.db"), qt.Equals, "db/sqlite/migrations")
+	c.Assert(MigrationsPath("db", "postgres://localhost/website_monitor"), qt.Equals, "db/migrations")
+}